@@ -1,14 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/dinis/musync/internal/config"
 	"github.com/dinis/musync/internal/database"
 	"github.com/dinis/musync/internal/logging"
 	"github.com/dinis/musync/internal/middleware"
 	"github.com/dinis/musync/internal/routes"
+	"github.com/dinis/musync/internal/services"
+	"github.com/dinis/musync/internal/storage"
 	"github.com/gin-gonic/gin"
 )
 
+// shutdownTimeout bounds how long in-flight requests are given to complete on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	// Initialize logging
 	logging.Init(logging.InfoLevel, nil)
@@ -28,6 +41,51 @@ func main() {
 	// Initialize database
 	database.InitDB(cfg.Database)
 
+	// Cancelled on SIGINT or SIGTERM, which stops the server and the background workers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Set up the storage backends track files are read from
+	backends, err := storage.NewRegistryFromConfig(cfg.Storage)
+	if err != nil {
+		logger.Fatal("Failed to set up storage backends: %v", err)
+	}
+	fileStorageService := services.NewFileStorageService(database.GlobalDB, backends)
+
+	// Start the background import workers
+	importJobService := services.NewImportJobService(database.GlobalDB, cfg.Import, fileStorageService)
+	if err := importJobService.Start(ctx); err != nil {
+		logger.Fatal("Failed to start import workers: %v", err)
+	}
+
+	// Start the background waveform workers
+	waveformService := services.NewWaveformService(database.GlobalDB, cfg.Waveform, fileStorageService)
+	if err := waveformService.Start(ctx); err != nil {
+		logger.Fatal("Failed to start waveform workers: %v", err)
+	}
+
+	// Start the background analysis workers
+	analysisService := services.NewAnalysisService(database.GlobalDB, cfg.Analysis, fileStorageService)
+	if err := analysisService.Start(ctx); err != nil {
+		logger.Fatal("Failed to start analysis workers: %v", err)
+	}
+
+	// Start the background library health check workers
+	healthCheckService := services.NewHealthCheckService(database.GlobalDB, cfg.HealthCheck, fileStorageService)
+	if err := healthCheckService.Start(ctx); err != nil {
+		logger.Fatal("Failed to start health check workers: %v", err)
+	}
+
+	// Start the background relocate workers
+	relocateJobService := services.NewRelocateJobService(database.GlobalDB, cfg.Relocate, fileStorageService)
+	if err := relocateJobService.Start(ctx); err != nil {
+		logger.Fatal("Failed to start relocate workers: %v", err)
+	}
+
+	// Start expiring unfinished track file uploads
+	trackFileService := services.NewTrackFileService(database.GlobalDB, cfg.Storage, fileStorageService)
+	trackFileService.Start(ctx)
+
 	// Set up Gin router
 	r := gin.New() // Use gin.New() instead of gin.Default() to avoid using the default logger and recovery middleware
 
@@ -45,11 +103,41 @@ func main() {
 	r.Use(corsMiddleware.Cors())
 
 	// Setup routes
-	routes.SetupRoutes(r, cfg)
+	routes.SetupRoutes(r, cfg, &routes.Services{
+		FileStorage:  fileStorageService,
+		TrackFiles:   trackFileService,
+		ImportJobs:   importJobService,
+		Waveforms:    waveformService,
+		Analysis:     analysisService,
+		HealthChecks: healthCheckService,
+		Relocations:  relocateJobService,
+	})
 
 	// Start the server
-	logger.Info("Starting server on port %s", cfg.Server.Port)
-	if err := r.Run(":" + cfg.Server.Port); err != nil {
-		logger.Fatal("Failed to start server: %v", err)
+	server := &http.Server{Addr: ":" + cfg.Server.Port, Handler: r}
+	go func() {
+		logger.Info("Starting server on port %s", cfg.Server.Port)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("Failed to start server: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	logger.Info("Shutting down")
+
+	// Let in-flight requests complete, then wait for the workers to stop. Jobs they were running are
+	// requeued at the next start.
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		logger.Error("Failed to shut down server: %v", err)
 	}
+	importJobService.Wait()
+	waveformService.Wait()
+	analysisService.Wait()
+	healthCheckService.Wait()
+	relocateJobService.Wait()
+	trackFileService.Wait()
+	logger.Info("Server stopped")
 }
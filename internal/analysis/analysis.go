@@ -0,0 +1,213 @@
+package analysis
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/dinis/musync/internal/audio"
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// Rate the audio is resampled to before analysis. Decimation is by an integer factor, so the actual
+// analysis rate is between targetRate and twice that.
+const targetRate = 11025
+
+// Frame lengths and hops in samples at the analysis rate. Onsets are detected on short frames for time
+// resolution, chroma is computed on long frames for frequency resolution.
+const (
+	onsetFrame  = 512
+	onsetHop    = 128
+	chromaFrame = 4096
+	chromaHop   = 2048
+)
+
+// Pitch range the chroma is computed over: C2 to C7
+const (
+	chromaMinFrequency = 65.4
+	chromaMaxFrequency = 2093
+)
+
+// Result is the outcome of analysing a track
+type Result struct {
+	Duration  float64 // In seconds
+	Bpm       float64 // 0 if no tempo was found
+	FirstBeat float64 // Position of the first beat in seconds
+	Key       musickey.Key
+	HasKey    bool // Whether a key was found
+}
+
+// Analyze decodes a WAV, AIFF, FLAC or MP3 file and estimates its tempo, beat phase and key
+func Analyze(r io.ReadSeeker) (*Result, error) {
+	decoder, err := audio.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	info := decoder.Info()
+	factor := max(1, info.SampleRate/targetRate)
+	a := newAnalyzer(float64(info.SampleRate) / float64(factor))
+	resample := newResampler(factor)
+
+	samples := make([]float32, 4096*info.Channels)
+	mono := make([]float64, 0, 4096)
+	resampled := make([]float64, 0, 4096)
+	var frames int64
+	for {
+		n, err := decoder.Read(samples)
+		if n > 0 {
+			// Downmix to mono
+			mono = mono[:0]
+			for i := 0; i+info.Channels <= n; i += info.Channels {
+				var sum float64
+				for _, sample := range samples[i : i+info.Channels] {
+					sum += float64(sample)
+				}
+				mono = append(mono, sum/float64(info.Channels))
+			}
+			frames += int64(len(mono))
+			resampled = resample.push(mono, resampled[:0])
+			a.push(resampled)
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &Result{Duration: float64(frames) / float64(info.SampleRate)}
+	// Onset frames are timed at their centre
+	frameRate := a.rate / onsetHop
+	result.Bpm, result.FirstBeat = estimateTempo(a.onsets, frameRate, onsetFrame/2/a.rate)
+	result.Key, result.HasKey = estimateKey(a.chroma)
+	return result, nil
+}
+
+// analyzer computes an onset detection function and a chroma profile from a stream of mono samples
+type analyzer struct {
+	rate       float64
+	buf        []float64 // Samples from index base on, that are still needed by a frame
+	base       int
+	nextOnset  int // Index of the first sample of the next onset frame
+	nextChroma int // Index of the first sample of the next chroma frame
+
+	onsets        []float64 // Spectral flux of each onset frame
+	previousFlux  []float64 // Log magnitudes of the previous onset frame
+	onsetWindow   []float64
+	onsetFFT      []complex128
+	onsetSpectrum []float64
+
+	chroma         [12]float64 // Magnitude summed per pitch class, 0 = C
+	chromaWindow   []float64
+	chromaFFT      []complex128
+	chromaSpectrum []float64
+	pitchClasses   []int // Pitch class of each chroma bin, -1 outside the chroma range
+}
+
+// newAnalyzer creates an analyzer for samples at the given rate
+func newAnalyzer(rate float64) *analyzer {
+	a := &analyzer{
+		rate:           rate,
+		previousFlux:   make([]float64, onsetFrame/2),
+		onsetWindow:    hannWindow(onsetFrame),
+		onsetFFT:       make([]complex128, onsetFrame),
+		onsetSpectrum:  make([]float64, onsetFrame/2),
+		chromaWindow:   hannWindow(chromaFrame),
+		chromaFFT:      make([]complex128, chromaFrame),
+		chromaSpectrum: make([]float64, chromaFrame/2),
+		pitchClasses:   make([]int, chromaFrame/2),
+	}
+	for bin := range a.pitchClasses {
+		a.pitchClasses[bin] = -1
+		frequency := float64(bin) * rate / chromaFrame
+		if frequency >= chromaMinFrequency && frequency <= chromaMaxFrequency {
+			// MIDI note 69 is A4 at 440 Hz, and MIDI note 0 is a C
+			note := int(math.Round(69 + 12*math.Log2(frequency/440)))
+			a.pitchClasses[bin] = note % 12
+		}
+	}
+	return a
+}
+
+// push analyses the frames completed by the given samples
+func (a *analyzer) push(samples []float64) {
+	a.buf = append(a.buf, samples...)
+	end := a.base + len(a.buf)
+
+	for a.nextOnset+onsetFrame <= end {
+		frame := a.buf[a.nextOnset-a.base : a.nextOnset-a.base+onsetFrame]
+		spectrum(frame, a.onsetWindow, a.onsetFFT, a.onsetSpectrum)
+
+		// Spectral flux: the increase of log compressed magnitudes over the previous frame
+		var flux float64
+		for bin, magnitude := range a.onsetSpectrum {
+			compressed := math.Log1p(100 * magnitude)
+			flux += math.Max(0, compressed-a.previousFlux[bin])
+			a.previousFlux[bin] = compressed
+		}
+		a.onsets = append(a.onsets, flux)
+		a.nextOnset += onsetHop
+	}
+
+	for a.nextChroma+chromaFrame <= end {
+		frame := a.buf[a.nextChroma-a.base : a.nextChroma-a.base+chromaFrame]
+		spectrum(frame, a.chromaWindow, a.chromaFFT, a.chromaSpectrum)
+		for bin, magnitude := range a.chromaSpectrum {
+			if pitchClass := a.pitchClasses[bin]; pitchClass >= 0 {
+				a.chroma[pitchClass] += magnitude
+			}
+		}
+		a.nextChroma += chromaHop
+	}
+
+	// Drop the samples no frame needs anymore, once they make up most of the buffer
+	if drop := min(a.nextOnset, a.nextChroma) - a.base; drop > len(a.buf)/2 {
+		a.buf = append(a.buf[:0], a.buf[drop:]...)
+		a.base += drop
+	}
+}
+
+// resampler low-pass filters a signal and decimates it by an integer factor
+type resampler struct {
+	factor int
+	taps   []float64
+	input  []float64 // Input not consumed yet, including the history the next output needs
+}
+
+// newResampler creates a resampler with a windowed sinc low-pass filter below the decimated Nyquist frequency
+func newResampler(factor int) *resampler {
+	if factor == 1 {
+		return &resampler{factor: 1, taps: []float64{1}}
+	}
+
+	n := 16*factor + 1
+	cutoff := 0.45 / float64(factor) // In cycles per input sample
+	taps := make([]float64, n)
+	for i := range taps {
+		x := float64(i - (n-1)/2)
+		sinc := 2 * cutoff
+		if x != 0 {
+			sinc = math.Sin(2*math.Pi*cutoff*x) / (math.Pi * x)
+		}
+		hamming := 0.54 - 0.46*math.Cos(2*math.Pi*float64(i)/float64(n-1))
+		taps[i] = sinc * hamming
+	}
+	return &resampler{factor: factor, taps: taps, input: make([]float64, n-1)}
+}
+
+// push filters samples and appends the decimated output to out
+func (r *resampler) push(samples []float64, out []float64) []float64 {
+	r.input = append(r.input, samples...)
+	position := 0
+	for position+len(r.taps) <= len(r.input) {
+		var sum float64
+		for i, tap := range r.taps {
+			sum += tap * r.input[position+i]
+		}
+		out = append(out, sum)
+		position += r.factor
+	}
+	r.input = append(r.input[:0], r.input[position:]...)
+	return out
+}
@@ -0,0 +1,133 @@
+package analysis
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// wavFile encodes mono samples from -1 to 1 as a 16-bit PCM WAV file
+func wavFile(rate int, samples []float64) []byte {
+	var buf bytes.Buffer
+	dataSize := 2 * len(samples)
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+dataSize))
+	buf.WriteString("WAVEfmt ")
+	for _, value := range []interface{}{uint32(16), uint16(1), uint16(1), uint32(rate), uint32(2 * rate), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, value)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(dataSize))
+	for _, sample := range samples {
+		binary.Write(&buf, binary.LittleEndian, int16(math.Round(sample*math.MaxInt16)))
+	}
+	return buf.Bytes()
+}
+
+// clickTrack returns seconds of clicks at a tempo, the first one at start seconds. Each click is a short
+// decaying 3 kHz tone.
+func clickTrack(rate int, seconds, bpm, start float64) []float64 {
+	samples := make([]float64, int(seconds*float64(rate)))
+	clickLength := rate / 100
+	for beat := start; beat < seconds; beat += 60 / bpm {
+		first := int(math.Round(beat * float64(rate)))
+		for i := 0; i < clickLength && first+i < len(samples); i++ {
+			t := float64(i) / float64(rate)
+			samples[first+i] = 0.8 * math.Exp(-t*400) * math.Sin(2*math.Pi*3000*t)
+		}
+	}
+	return samples
+}
+
+// chord returns seconds of sine tones at the given frequencies
+func chord(rate int, seconds float64, frequencies ...float64) []float64 {
+	samples := make([]float64, int(seconds*float64(rate)))
+	for i := range samples {
+		t := float64(i) / float64(rate)
+		for _, frequency := range frequencies {
+			samples[i] += 0.25 * math.Sin(2*math.Pi*frequency*t)
+		}
+	}
+	return samples
+}
+
+func TestAnalyzeTempo(t *testing.T) {
+	tests := []struct {
+		name  string
+		rate  int
+		bpm   float64
+		start float64
+	}{
+		{"house", 22050, 124, 0.1},
+		{"techno", 44100, 132, 0.25},
+		{"hip hop", 11025, 90, 0.3},
+		{"drum and bass", 22050, 174, 0.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Analyze(bytes.NewReader(wavFile(tt.rate, clickTrack(tt.rate, 30, tt.bpm, tt.start))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if math.Abs(result.Duration-30) > 0.01 {
+				t.Errorf("Duration = %v, want 30", result.Duration)
+			}
+			if math.Abs(result.Bpm-tt.bpm) > 0.5 {
+				t.Errorf("Bpm = %v, want %v", result.Bpm, tt.bpm)
+			}
+			if math.Abs(result.FirstBeat-tt.start) > 0.03 {
+				t.Errorf("FirstBeat = %v, want %v", result.FirstBeat, tt.start)
+			}
+		})
+	}
+}
+
+func TestAnalyzeKey(t *testing.T) {
+	tests := []struct {
+		name        string
+		frequencies []float64
+		want        musickey.Key
+	}{
+		{"C major", []float64{261.63, 329.63, 392.00}, musickey.Key{Root: 0}},
+		{"A minor", []float64{220.00, 261.63, 329.63}, musickey.Key{Root: 9, Minor: true}},
+		{"G major", []float64{196.00, 246.94, 293.66}, musickey.Key{Root: 7}},
+		{"F sharp minor", []float64{185.00, 220.00, 277.18}, musickey.Key{Root: 6, Minor: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Analyze(bytes.NewReader(wavFile(22050, chord(22050, 10, tt.frequencies...))))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !result.HasKey || result.Key != tt.want {
+				t.Errorf("Key = %v (found %v), want %v", result.Key, result.HasKey, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnalyzeSilence(t *testing.T) {
+	result, err := Analyze(bytes.NewReader(wavFile(22050, make([]float64, 10*22050))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bpm != 0 {
+		t.Errorf("Bpm = %v, want 0", result.Bpm)
+	}
+	if result.HasKey {
+		t.Errorf("Key = %v, want none", result.Key)
+	}
+}
+
+func TestAnalyzeShortAudio(t *testing.T) {
+	result, err := Analyze(bytes.NewReader(wavFile(22050, clickTrack(22050, 3, 120, 0))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bpm != 0 {
+		t.Errorf("Bpm = %v, want 0 for audio shorter than %v seconds", result.Bpm, minTempoDuration)
+	}
+}
@@ -0,0 +1,54 @@
+package analysis
+
+import (
+	"math"
+	"math/bits"
+	"math/cmplx"
+)
+
+// fft computes the discrete Fourier transform of x in place. The length of x must be a power of two.
+func fft(x []complex128) {
+	n := len(x)
+	shift := 64 - uint(bits.TrailingZeros(uint(n)))
+
+	// Bit reversal permutation
+	for i := 0; i < n; i++ {
+		j := int(bits.Reverse64(uint64(i)) >> shift)
+		if i < j {
+			x[i], x[j] = x[j], x[i]
+		}
+	}
+
+	for size := 2; size <= n; size <<= 1 {
+		step := cmplx.Exp(complex(0, -2*math.Pi/float64(size)))
+		for start := 0; start < n; start += size {
+			w := complex(1, 0)
+			for k := 0; k < size/2; k++ {
+				even, odd := x[start+k], w*x[start+k+size/2]
+				x[start+k] = even + odd
+				x[start+k+size/2] = even - odd
+				w *= step
+			}
+		}
+	}
+}
+
+// hannWindow returns a Hann window of the given length
+func hannWindow(n int) []float64 {
+	window := make([]float64, n)
+	for i := range window {
+		window[i] = 0.5 - 0.5*math.Cos(2*math.Pi*float64(i)/float64(n))
+	}
+	return window
+}
+
+// spectrum computes the magnitude spectrum of a windowed frame into magnitudes, which holds len(frame)/2 bins
+func spectrum(frame, window []float64, buf []complex128, magnitudes []float64) {
+	for i, sample := range frame {
+		buf[i] = complex(sample*window[i], 0)
+	}
+	fft(buf)
+	for i := range magnitudes {
+		magnitudes[i] = cmplx.Abs(buf[i])
+	}
+}
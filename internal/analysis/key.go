@@ -0,0 +1,60 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// Krumhansl-Kessler key profiles: the perceived fit of each pitch class in a major and a minor key, from the
+// tonic up in semitones
+var (
+	majorProfile = [12]float64{6.35, 2.23, 3.48, 2.33, 4.38, 4.09, 2.52, 5.19, 2.39, 3.66, 2.29, 2.88}
+	minorProfile = [12]float64{6.33, 2.68, 3.52, 5.38, 2.60, 3.53, 2.54, 4.75, 3.98, 2.69, 3.34, 3.17}
+)
+
+// minKeyCorrelation is the correlation with the best key profile below which the chroma is considered not to
+// be tonal, as for noise or unpitched percussion
+const minKeyCorrelation = 0.3
+
+// estimateKey finds the key whose profile correlates best with a chroma profile, where index 0 is C.
+// It returns false if the chroma is empty or no profile fits it.
+func estimateKey(chroma [12]float64) (musickey.Key, bool) {
+	var best musickey.Key
+	bestCorrelation := math.Inf(-1)
+	for root := 0; root < 12; root++ {
+		for _, minor := range []bool{false, true} {
+			profile := majorProfile
+			if minor {
+				profile = minorProfile
+			}
+			var rotated [12]float64
+			for pitchClass := range rotated {
+				rotated[pitchClass] = profile[(pitchClass-root+12)%12]
+			}
+			if c := correlation(chroma, rotated); c > bestCorrelation {
+				best, bestCorrelation = musickey.Key{Root: root, Minor: minor}, c
+			}
+		}
+	}
+	return best, bestCorrelation >= minKeyCorrelation
+}
+
+// correlation returns the Pearson correlation coefficient of two profiles, or 0 if either is constant
+func correlation(a, b [12]float64) float64 {
+	var meanA, meanB float64
+	for i := range a {
+		meanA += a[i] / 12
+		meanB += b[i] / 12
+	}
+	var covariance, varianceA, varianceB float64
+	for i := range a {
+		covariance += (a[i] - meanA) * (b[i] - meanB)
+		varianceA += (a[i] - meanA) * (a[i] - meanA)
+		varianceB += (b[i] - meanB) * (b[i] - meanB)
+	}
+	if varianceA == 0 || varianceB == 0 {
+		return 0
+	}
+	return covariance / math.Sqrt(varianceA*varianceB)
+}
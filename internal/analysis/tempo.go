@@ -0,0 +1,138 @@
+package analysis
+
+import "math"
+
+// Tempo range estimates are folded into. Double and half time are hard to tell apart, so a tempo outside the
+// range is taken to be a multiple of the actual one.
+const (
+	MinBpm = 78.0
+	MaxBpm = 180.0
+)
+
+// Tempo range searched by the autocorrelation, before folding
+const (
+	searchMinBpm = 50.0
+	searchMaxBpm = 240.0
+)
+
+// Tempo the autocorrelation is weighted towards, and the width of the weighting in octaves. This resolves
+// the ambiguity between a tempo and its multiples towards the tempos dance music is played at.
+const (
+	preferredBpm   = 120.0
+	preferredWidth = 1.0
+)
+
+// minTempoDuration is the length of audio, in seconds, below which no tempo is estimated
+const minTempoDuration = 5.0
+
+// Refinement of the folded tempo: the search range around it as a fraction, and the step in BPM
+const (
+	refineRange = 0.02
+	refineStep  = 0.05
+)
+
+// estimateTempo estimates the tempo and the position of the first beat from an onset detection function
+// sampled at frameRate, whose first value is at offset seconds. It returns a zero tempo if none was found.
+func estimateTempo(onsets []float64, frameRate, offset float64) (bpm, firstBeat float64) {
+	if float64(len(onsets)) < minTempoDuration*frameRate {
+		return 0, 0
+	}
+	onsets = detrend(onsets, int(frameRate/2))
+
+	// Autocorrelation over the lags of the searched tempos and twice those
+	minLag := int(math.Floor(60 * frameRate / searchMaxBpm))
+	maxLag := int(math.Ceil(60 * frameRate / searchMinBpm))
+	correlation := make([]float64, 2*maxLag+2)
+	for lag := minLag; lag < len(correlation) && lag < len(onsets); lag++ {
+		var sum float64
+		for i := lag; i < len(onsets); i++ {
+			sum += onsets[i] * onsets[i-lag]
+		}
+		correlation[lag] = sum / float64(len(onsets)-lag)
+	}
+
+	// A beat period also correlates with the bar level periodicity at twice the lag
+	score := func(lag int) float64 {
+		lagBpm := 60 * frameRate / float64(lag)
+		weight := math.Exp(-0.5 * math.Pow(math.Log2(lagBpm/preferredBpm)/preferredWidth, 2))
+		return (correlation[lag] + 0.5*correlation[2*lag]) * weight
+	}
+	best := 0
+	var bestScore float64
+	for lag := max(minLag, 1); lag <= maxLag; lag++ {
+		if s := score(lag); s > bestScore {
+			best, bestScore = lag, s
+		}
+	}
+	if best == 0 {
+		return 0, 0
+	}
+
+	// Parabolic interpolation between the neighbouring lags
+	period := float64(best)
+	if best > max(minLag, 1) && best < maxLag {
+		left, right := score(best-1), score(best+1)
+		if denominator := left - 2*bestScore + right; denominator < 0 {
+			period += 0.5 * (left - right) / denominator
+		}
+	}
+	bpm = fold(60 * frameRate / period)
+
+	// Refine the tempo and find its phase with a comb over the onsets
+	var bestComb, bestPhase float64
+	bestBpm := bpm
+	for candidate := bpm * (1 - refineRange); candidate <= bpm*(1+refineRange); candidate += refineStep {
+		if strength, phase := comb(onsets, 60*frameRate/candidate); strength > bestComb {
+			bestComb, bestPhase, bestBpm = strength, phase, candidate
+		}
+	}
+
+	return math.Round(bestBpm*100) / 100, offset + bestPhase/frameRate
+}
+
+// detrend subtracts the moving average over radius values on either side from a signal and clips it at zero,
+// which leaves the onsets that stand out from their surroundings
+func detrend(signal []float64, radius int) []float64 {
+	prefix := make([]float64, len(signal)+1)
+	for i, value := range signal {
+		prefix[i+1] = prefix[i] + value
+	}
+	result := make([]float64, len(signal))
+	for i, value := range signal {
+		start, end := max(0, i-radius), min(len(signal), i+radius+1)
+		mean := (prefix[end] - prefix[start]) / float64(end-start)
+		result[i] = math.Max(0, value-mean)
+	}
+	return result
+}
+
+// comb returns the mean onset strength at the beats of a period, in frames, for the phase where it is highest,
+// and that phase
+func comb(onsets []float64, period float64) (strength, phase float64) {
+	for start := 0; float64(start) < period; start++ {
+		var sum float64
+		beats := 0
+		for position := float64(start); position < float64(len(onsets)-1); position += period {
+			// Linear interpolation between frames
+			i := int(position)
+			fraction := position - float64(i)
+			sum += onsets[i]*(1-fraction) + onsets[i+1]*fraction
+			beats++
+		}
+		if beats > 0 && sum/float64(beats) > strength {
+			strength, phase = sum/float64(beats), float64(start)
+		}
+	}
+	return strength, phase
+}
+
+// fold doubles or halves a tempo until it is between MinBpm and MaxBpm
+func fold(bpm float64) float64 {
+	for bpm < MinBpm {
+		bpm *= 2
+	}
+	for bpm >= MaxBpm {
+		bpm /= 2
+	}
+	return bpm
+}
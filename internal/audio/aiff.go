@@ -0,0 +1,78 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+	"math"
+)
+
+// newAIFFDecoder returns a decoder for the "SSND" chunk of an AIFF or AIFF-C file, described by its "COMM" chunk
+func newAIFFDecoder(r io.ReadSeeker) (Decoder, error) {
+	chunks, err := readChunks(r, 12, binary.BigEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	var common []byte
+	for _, c := range chunks {
+		switch c.id {
+		case "COMM":
+			if common, err = readChunk(r, c, 1<<10); err != nil {
+				return nil, err
+			}
+		case "SSND":
+			if len(common) < 18 || c.size < 8 {
+				return nil, ErrInvalidStream
+			}
+			info := Info{
+				Channels:   int(binary.BigEndian.Uint16(common[0:2])),
+				SampleRate: int(math.Round(extendedFloat(common[8:18]))),
+			}
+			bits := int(binary.BigEndian.Uint16(common[6:8]))
+
+			// AIFF-C adds a compression type, of which only the uncompressed ones are supported
+			order := binary.ByteOrder(binary.BigEndian)
+			float := false
+			if len(common) >= 22 {
+				switch string(common[18:22]) {
+				case "NONE", "twos":
+				case "sowt":
+					order = binary.LittleEndian
+				case "fl32", "FL32":
+					float, bits = true, 32
+				case "fl64", "FL64":
+					float, bits = true, 64
+				default:
+					return nil, ErrUnsupportedFormat
+				}
+			}
+
+			// The sound data chunk starts with an offset to the first sample and a block size
+			header, err := readChunk(r, chunk{offset: c.offset, size: 8}, 8)
+			if err != nil {
+				return nil, err
+			}
+			skip := int64(binary.BigEndian.Uint32(header[0:4])) + 8
+			if skip > c.size {
+				return nil, ErrInvalidStream
+			}
+			return newPCMDecoder(r, c.offset+skip, c.size-skip, info, order, bits, float)
+		}
+	}
+	return nil, ErrInvalidStream
+}
+
+// extendedFloat decodes an 80 bit IEEE 754 extended precision number, which AIFF uses for the sample rate
+func extendedFloat(b []byte) float64 {
+	exponent := int(binary.BigEndian.Uint16(b[0:2]))
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	sign := 1.0
+	if exponent&0x8000 != 0 {
+		sign = -1
+		exponent &= 0x7FFF
+	}
+	if exponent == 0 && mantissa == 0 {
+		return 0
+	}
+	return sign * float64(mantissa) * math.Pow(2, float64(exponent-16383-63))
+}
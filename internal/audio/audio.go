@@ -0,0 +1,83 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// Container formats recognized by Detect
+const (
+	FormatWAV  = "wav"
+	FormatAIFF = "aiff"
+	FormatFLAC = "flac"
+	FormatMP3  = "mp3"
+)
+
+// Audio decoding errors
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrInvalidStream     = errors.New("invalid audio stream")
+)
+
+// Info describes a PCM stream
+type Info struct {
+	SampleRate int
+	Channels   int
+	Frames     int64 // Number of sample frames, 0 if the container does not declare it
+}
+
+// Decoder reads the PCM samples of an audio file
+type Decoder interface {
+	Info() Info
+	// Read decodes interleaved samples scaled to [-1, 1]. The length of samples must be a multiple of the
+	// channel count, and whole frames are returned. Read returns io.EOF at the end of the stream.
+	Read(samples []float32) (int, error)
+}
+
+// Detect returns the container format of an audio file from its first bytes, leaving r at its start
+func Detect(r io.ReadSeeker) (string, error) {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", err
+	}
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return FormatFLAC, nil
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return FormatWAV, nil
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("FORM")) && (bytes.Equal(header[8:12], []byte("AIFF")) || bytes.Equal(header[8:12], []byte("AIFC"))):
+		return FormatAIFF, nil
+	case bytes.HasPrefix(header, []byte("ID3")), len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		return FormatMP3, nil
+	default:
+		return "", ErrUnsupportedFormat
+	}
+}
+
+// NewDecoder returns a PCM decoder for a WAV, AIFF, FLAC or MP3 file
+func NewDecoder(r io.ReadSeeker) (Decoder, error) {
+	format, err := Detect(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case FormatWAV:
+		return newWAVDecoder(r)
+	case FormatAIFF:
+		return newAIFFDecoder(r)
+	case FormatFLAC:
+		return newFLACDecoder(r)
+	case FormatMP3:
+		return newMP3Decoder(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
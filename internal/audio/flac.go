@@ -0,0 +1,525 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// flacStreamInfo is the metadata block type of the stream description
+const flacStreamInfo = 0
+
+// FLAC channel assignments using inter-channel decorrelation, independent channels use 0 to 7
+const (
+	flacLeftSide  = 8
+	flacSideRight = 9
+	flacMidSide   = 10
+)
+
+// flacDecoder decodes the frames of a FLAC stream
+type flacDecoder struct {
+	bits       *bitReader
+	info       Info
+	sampleBits int
+	channels   [][]int64 // Samples of the current frame per channel
+	pending    []float32 // Interleaved samples of the current frame not read yet
+}
+
+// newFLACDecoder reads the stream description from the metadata blocks and positions r at the first frame
+func newFLACDecoder(r io.ReadSeeker) (Decoder, error) {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	d := &flacDecoder{}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if header[0]&0x7F == flacStreamInfo {
+			if size < 34 {
+				return nil, ErrInvalidStream
+			}
+			block := make([]byte, size)
+			if _, err := io.ReadFull(r, block); err != nil {
+				return nil, err
+			}
+			// 20 bits sample rate, 3 bits channels - 1, 5 bits bits per sample - 1, 36 bits total samples
+			packed := binary.BigEndian.Uint64(block[10:18])
+			d.info = Info{
+				SampleRate: int(packed >> 44),
+				Channels:   int(packed>>41&0x7) + 1,
+				Frames:     int64(packed & 0xFFFFFFFFF),
+			}
+			d.sampleBits = int(packed>>36&0x1F) + 1
+		} else if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+
+		if last {
+			break
+		}
+	}
+
+	if d.info.SampleRate == 0 {
+		return nil, ErrInvalidStream
+	}
+	d.bits = &bitReader{r: bufio.NewReaderSize(r, 64<<10)}
+	return d, nil
+}
+
+// Info returns the format of the stream
+func (d *flacDecoder) Info() Info {
+	return d.info
+}
+
+// Read decodes interleaved samples
+func (d *flacDecoder) Read(samples []float32) (int, error) {
+	n := 0
+	want := len(samples) - len(samples)%d.info.Channels
+	for n < want {
+		if len(d.pending) == 0 {
+			if err := d.decodeFrame(); err != nil {
+				if errors.Is(err, io.EOF) && n > 0 {
+					return n, nil
+				}
+				return n, err
+			}
+		}
+		copied := copy(samples[n:want], d.pending)
+		d.pending = d.pending[copied:]
+		n += copied
+	}
+	return n, nil
+}
+
+// decodeFrame decodes the next frame into pending
+func (d *flacDecoder) decodeFrame() error {
+	b := d.bits
+
+	sync, err := b.read(15)
+	if err != nil {
+		return err
+	}
+	if sync != 0x3FFE<<1 {
+		return ErrInvalidStream
+	}
+	if _, err := b.read(1); err != nil { // Blocking strategy
+		return unexpectedEOF(err)
+	}
+	header, err := b.read(16)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	blockSizeCode := header >> 12
+	sampleRateCode := header >> 8 & 0xF
+	assignment := int(header >> 4 & 0xF)
+	sampleSizeCode := header >> 1 & 0x7
+
+	// Frame or sample number, coded like UTF-8
+	first, err := b.read(8)
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	for extra := bits.LeadingZeros8(^uint8(first)) - 1; extra > 0; extra-- {
+		if _, err := b.read(8); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	var blockSize int
+	switch {
+	case blockSizeCode == 1:
+		blockSize = 192
+	case blockSizeCode >= 2 && blockSizeCode <= 5:
+		blockSize = 576 << (blockSizeCode - 2)
+	case blockSizeCode == 6 || blockSizeCode == 7:
+		size, err := b.read(8 * uint(blockSizeCode-5))
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		blockSize = int(size) + 1
+	case blockSizeCode >= 8:
+		blockSize = 256 << (blockSizeCode - 8)
+	default:
+		return ErrInvalidStream
+	}
+
+	switch sampleRateCode {
+	case 12:
+		_, err = b.read(8)
+	case 13, 14:
+		_, err = b.read(16)
+	}
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+
+	sampleBits := d.sampleBits
+	switch sampleSizeCode {
+	case 1:
+		sampleBits = 8
+	case 2:
+		sampleBits = 12
+	case 4:
+		sampleBits = 16
+	case 5:
+		sampleBits = 20
+	case 6:
+		sampleBits = 24
+	case 7:
+		sampleBits = 32
+	}
+
+	if _, err := b.read(8); err != nil { // Header CRC-8
+		return unexpectedEOF(err)
+	}
+
+	channels := assignment + 1
+	if assignment >= flacLeftSide {
+		if assignment > flacMidSide {
+			return ErrInvalidStream
+		}
+		channels = 2
+	}
+	if channels != d.info.Channels {
+		return ErrInvalidStream
+	}
+
+	for len(d.channels) < channels {
+		d.channels = append(d.channels, nil)
+	}
+	for ch := 0; ch < channels; ch++ {
+		if cap(d.channels[ch]) < blockSize {
+			d.channels[ch] = make([]int64, blockSize)
+		}
+		d.channels[ch] = d.channels[ch][:blockSize]
+
+		// The side channel has one more bit
+		channelBits := sampleBits
+		if (assignment == flacLeftSide || assignment == flacMidSide) && ch == 1 || assignment == flacSideRight && ch == 0 {
+			channelBits++
+		}
+		if err := d.decodeSubframe(d.channels[ch], channelBits); err != nil {
+			return unexpectedEOF(err)
+		}
+	}
+
+	left, right := d.channels[0], d.channels[min(1, channels-1)]
+	switch assignment {
+	case flacLeftSide:
+		for i := range right {
+			right[i] = left[i] - right[i]
+		}
+	case flacSideRight:
+		for i := range left {
+			left[i] += right[i]
+		}
+	case flacMidSide:
+		for i := range left {
+			mid, side := left[i]<<1|right[i]&1, right[i]
+			left[i], right[i] = (mid+side)>>1, (mid-side)>>1
+		}
+	}
+
+	// Byte alignment and the frame CRC-16
+	b.align()
+	if _, err := b.read(16); err != nil {
+		return unexpectedEOF(err)
+	}
+
+	if cap(d.pending) < blockSize*channels {
+		d.pending = make([]float32, blockSize*channels)
+	}
+	d.pending = d.pending[:blockSize*channels]
+	scale := 1 / float32(int64(1)<<(sampleBits-1))
+	for ch := 0; ch < channels; ch++ {
+		for i, sample := range d.channels[ch] {
+			d.pending[i*channels+ch] = float32(sample) * scale
+		}
+	}
+	return nil
+}
+
+// decodeSubframe decodes the samples of one channel
+func (d *flacDecoder) decodeSubframe(samples []int64, sampleBits int) error {
+	b := d.bits
+
+	header, err := b.read(8)
+	if err != nil {
+		return err
+	}
+	if header&0x80 != 0 {
+		return ErrInvalidStream
+	}
+	kind := int(header >> 1 & 0x3F)
+
+	// Wasted bits are low bits that are zero in every sample of the subframe
+	wasted := 0
+	if header&1 != 0 {
+		count, err := b.unary()
+		if err != nil {
+			return err
+		}
+		wasted = int(count) + 1
+		sampleBits -= wasted
+	}
+	if sampleBits < 1 {
+		return ErrInvalidStream
+	}
+
+	switch {
+	case kind == 0:
+		value, err := b.readSigned(uint(sampleBits))
+		if err != nil {
+			return err
+		}
+		for i := range samples {
+			samples[i] = value
+		}
+	case kind == 1:
+		for i := range samples {
+			if samples[i], err = b.readSigned(uint(sampleBits)); err != nil {
+				return err
+			}
+		}
+	case kind >= 8 && kind <= 12:
+		if err := d.decodeFixed(samples, sampleBits, kind-8); err != nil {
+			return err
+		}
+	case kind >= 32:
+		if err := d.decodeLPC(samples, sampleBits, kind-31); err != nil {
+			return err
+		}
+	default:
+		return ErrInvalidStream
+	}
+
+	if wasted > 0 {
+		for i := range samples {
+			samples[i] <<= wasted
+		}
+	}
+	return nil
+}
+
+// decodeFixed decodes a subframe using one of the fixed polynomial predictors
+func (d *flacDecoder) decodeFixed(samples []int64, sampleBits, order int) error {
+	if order > len(samples) {
+		return ErrInvalidStream
+	}
+	for i := 0; i < order; i++ {
+		value, err := d.bits.readSigned(uint(sampleBits))
+		if err != nil {
+			return err
+		}
+		samples[i] = value
+	}
+	if err := d.decodeResidual(samples, order); err != nil {
+		return err
+	}
+
+	s := samples
+	for i := order; i < len(s); i++ {
+		switch order {
+		case 1:
+			s[i] += s[i-1]
+		case 2:
+			s[i] += 2*s[i-1] - s[i-2]
+		case 3:
+			s[i] += 3*s[i-1] - 3*s[i-2] + s[i-3]
+		case 4:
+			s[i] += 4*s[i-1] - 6*s[i-2] + 4*s[i-3] - s[i-4]
+		}
+	}
+	return nil
+}
+
+// decodeLPC decodes a subframe using a linear predictor with quantized coefficients
+func (d *flacDecoder) decodeLPC(samples []int64, sampleBits, order int) error {
+	b := d.bits
+	if order > len(samples) {
+		return ErrInvalidStream
+	}
+	for i := 0; i < order; i++ {
+		value, err := b.readSigned(uint(sampleBits))
+		if err != nil {
+			return err
+		}
+		samples[i] = value
+	}
+
+	precision, err := b.read(4)
+	if err != nil {
+		return err
+	}
+	if precision == 0xF {
+		return ErrInvalidStream
+	}
+	shift, err := b.readSigned(5)
+	if err != nil {
+		return err
+	}
+	if shift < 0 {
+		return ErrInvalidStream
+	}
+	coefficients := make([]int64, order)
+	for i := range coefficients {
+		if coefficients[i], err = b.readSigned(uint(precision + 1)); err != nil {
+			return err
+		}
+	}
+
+	if err := d.decodeResidual(samples, order); err != nil {
+		return err
+	}
+
+	for i := order; i < len(samples); i++ {
+		var prediction int64
+		for j, coefficient := range coefficients {
+			prediction += coefficient * samples[i-1-j]
+		}
+		samples[i] += prediction >> shift
+	}
+	return nil
+}
+
+// decodeResidual decodes the Rice coded prediction residual into the samples following the warm-up samples
+func (d *flacDecoder) decodeResidual(samples []int64, order int) error {
+	b := d.bits
+
+	method, err := b.read(2)
+	if err != nil {
+		return err
+	}
+	if method > 1 {
+		return ErrInvalidStream
+	}
+	paramBits, escape := uint(4), uint64(0xF)
+	if method == 1 {
+		paramBits, escape = 5, 0x1F
+	}
+
+	partitionOrder, err := b.read(4)
+	if err != nil {
+		return err
+	}
+	partitionSize := len(samples) >> partitionOrder
+	if partitionSize<<partitionOrder != len(samples) || partitionSize < order {
+		return ErrInvalidStream
+	}
+
+	i := order
+	for partition := 0; partition < 1<<partitionOrder; partition++ {
+		end := (partition + 1) * partitionSize
+		param, err := b.read(paramBits)
+		if err != nil {
+			return err
+		}
+
+		if param == escape {
+			// Unencoded residual of a fixed number of bits
+			size, err := b.read(5)
+			if err != nil {
+				return err
+			}
+			for ; i < end; i++ {
+				if samples[i], err = b.readSigned(uint(size)); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		for ; i < end; i++ {
+			quotient, err := b.unary()
+			if err != nil {
+				return err
+			}
+			remainder, err := b.read(uint(param))
+			if err != nil {
+				return err
+			}
+			value := quotient<<param | remainder
+			samples[i] = int64(value>>1) ^ -int64(value&1)
+		}
+	}
+	return nil
+}
+
+// bitReader reads big endian bit fields
+type bitReader struct {
+	r     *bufio.Reader
+	cache uint64 // Unread bits, aligned to the most significant bit
+	count uint   // Number of unread bits in cache
+}
+
+// read reads an unsigned field of up to 56 bits
+func (b *bitReader) read(n uint) (uint64, error) {
+	for b.count < n {
+		c, err := b.r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		b.cache |= uint64(c) << (56 - b.count)
+		b.count += 8
+	}
+	value := b.cache >> (64 - n)
+	b.cache <<= n
+	b.count -= n
+	return value, nil
+}
+
+// readSigned reads a two's complement field of up to 56 bits
+func (b *bitReader) readSigned(n uint) (int64, error) {
+	value, err := b.read(n)
+	if err != nil || n == 0 {
+		return 0, err
+	}
+	return int64(value<<(64-n)) >> (64 - n), nil
+}
+
+// unary counts the zero bits up to the next one bit, which is consumed
+func (b *bitReader) unary() (uint64, error) {
+	var zeros uint64
+	for {
+		if b.cache == 0 {
+			// The unread bits are all zeros
+			zeros += uint64(b.count)
+			b.count = 0
+			c, err := b.r.ReadByte()
+			if err != nil {
+				return 0, err
+			}
+			b.cache = uint64(c) << 56
+			b.count = 8
+			continue
+		}
+		leading := uint(bits.LeadingZeros64(b.cache))
+		zeros += uint64(leading)
+		b.cache <<= leading + 1
+		b.count -= leading + 1
+		return zeros, nil
+	}
+}
+
+// align skips to the next byte boundary
+func (b *bitReader) align() {
+	skip := b.count % 8
+	b.cache <<= skip
+	b.count -= skip
+}
+
+// unexpectedEOF reports the end of the file within a frame as a truncated stream
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,21 @@
+package audio
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/hajimehoshi/go-mp3"
+)
+
+// newMP3Decoder returns a decoder for an MPEG Layer III stream. Skipping an ID3v2 tag, the stream is decoded
+// to 16 bit stereo, mono streams having both channels equal. The length is counted from the frame headers
+// when the decoder is created.
+func newMP3Decoder(r io.ReadSeeker) (Decoder, error) {
+	stream, err := mp3.NewDecoder(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidStream, err)
+	}
+	info := Info{SampleRate: stream.SampleRate(), Channels: 2}
+	return newPCMDecoder(stream, 0, stream.Length(), info, binary.LittleEndian, 16, false)
+}
@@ -0,0 +1,60 @@
+package audio
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+)
+
+// silentMP3Frame is an MPEG-1 Layer III frame at 128 kbit/s and 44.1 kHz whose side information codes no
+// bits, which decodes to 1152 silent stereo sample frames
+var silentMP3Frame = append([]byte{0xFF, 0xFB, 0x90, 0x64}, make([]byte, 413)...)
+
+func TestMP3Decoder(t *testing.T) {
+	tag := append([]byte("ID3\x03\x00\x00\x00\x00\x00\x0A"), make([]byte, 10)...)
+	file := append(tag, bytes.Repeat(silentMP3Frame, 4)...)
+
+	decoder, err := NewDecoder(bytes.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info := decoder.Info(); info != (Info{SampleRate: 44100, Channels: 2, Frames: 4 * 1152}) {
+		t.Errorf("Info = %+v", info)
+	}
+
+	samples := make([]float32, 1000)
+	var total int64
+	for {
+		n, err := decoder.Read(samples)
+		for _, sample := range samples[:n] {
+			if sample != 0 {
+				t.Fatalf("sample = %v, want silence", sample)
+			}
+		}
+		total += int64(n)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if total != 4*1152*2 {
+		t.Errorf("read %d samples, want %d", total, 4*1152*2)
+	}
+}
+
+func TestMP3DecoderRejectsInvalidStreams(t *testing.T) {
+	tests := map[string][]byte{
+		"tag only":        append([]byte("ID3\x03\x00\x00\x00\x00\x00\x0A"), make([]byte, 10)...),
+		"truncated frame": silentMP3Frame[:100],
+	}
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewDecoder(bytes.NewReader(file)); !errors.Is(err, ErrInvalidStream) {
+				t.Errorf("err = %v, want ErrInvalidStream", err)
+			}
+		})
+	}
+}
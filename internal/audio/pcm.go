@@ -0,0 +1,157 @@
+package audio
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// pcmDecoder reads uncompressed samples, as found in WAV and AIFF files
+type pcmDecoder struct {
+	r              io.Reader
+	info           Info
+	order          binary.ByteOrder
+	bytesPerSample int
+	float          bool
+	unsigned       bool // 8 bit WAV samples are unsigned
+	buf            []byte
+}
+
+// newPCMDecoder returns a decoder for size bytes of samples starting at offset
+func newPCMDecoder(r io.ReadSeeker, offset, size int64, info Info, order binary.ByteOrder, bits int, float bool) (*pcmDecoder, error) {
+	bytesPerSample := (bits + 7) / 8
+	switch {
+	case info.Channels < 1 || info.SampleRate < 1:
+		return nil, ErrInvalidStream
+	case float && bytesPerSample != 4 && bytesPerSample != 8:
+		return nil, ErrUnsupportedFormat
+	case !float && (bytesPerSample < 1 || bytesPerSample > 4):
+		return nil, ErrUnsupportedFormat
+	}
+
+	if _, err := r.Seek(offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	info.Frames = size / int64(bytesPerSample*info.Channels)
+	return &pcmDecoder{
+		r:              bufio.NewReaderSize(io.LimitReader(r, size), 64<<10),
+		info:           info,
+		order:          order,
+		bytesPerSample: bytesPerSample,
+		float:          float,
+	}, nil
+}
+
+// Info returns the format of the stream
+func (d *pcmDecoder) Info() Info {
+	return d.info
+}
+
+// Read decodes interleaved samples
+func (d *pcmDecoder) Read(samples []float32) (int, error) {
+	frameSize := d.bytesPerSample * d.info.Channels
+	frames := len(samples) / d.info.Channels
+	if cap(d.buf) < frames*frameSize {
+		d.buf = make([]byte, frames*frameSize)
+	}
+	buf := d.buf[:frames*frameSize]
+
+	n, err := io.ReadFull(d.r, buf)
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		err = nil
+	}
+	n -= n % frameSize
+	if n == 0 {
+		if err == nil {
+			err = io.EOF
+		}
+		return 0, err
+	}
+
+	count := n / d.bytesPerSample
+	for i := 0; i < count; i++ {
+		samples[i] = d.sample(buf[i*d.bytesPerSample : (i+1)*d.bytesPerSample])
+	}
+	return count, nil
+}
+
+// sample converts one encoded sample
+func (d *pcmDecoder) sample(b []byte) float32 {
+	if d.float {
+		if len(b) == 4 {
+			return math.Float32frombits(d.order.Uint32(b))
+		}
+		return float32(math.Float64frombits(d.order.Uint64(b)))
+	}
+	if len(b) == 1 {
+		if d.unsigned {
+			return float32(int(b[0])-128) / 128
+		}
+		return float32(int8(b[0])) / 128
+	}
+
+	// Assemble the sample in the high bits of an int32 so that the sign is kept
+	var value uint32
+	if d.order == binary.BigEndian {
+		for i, c := range b {
+			value |= uint32(c) << (24 - 8*i)
+		}
+	} else {
+		for i, c := range b {
+			value |= uint32(c) << (32 - 8*len(b) + 8*i)
+		}
+	}
+	return float32(int32(value)) / (1 << 31)
+}
+
+// chunk is the position of a RIFF or IFF chunk's payload
+type chunk struct {
+	id     string
+	offset int64
+	size   int64
+}
+
+// readChunks lists the chunks from the given offset to the end of the file
+func readChunks(r io.ReadSeeker, offset int64, order binary.ByteOrder) ([]chunk, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	var chunks []chunk
+	header := make([]byte, 8)
+	for offset+8 <= end {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		size := int64(order.Uint32(header[4:8]))
+		if offset+8+size > end {
+			// Streamed and truncated files often declare a wrong size for their last chunk
+			size = end - offset - 8
+		}
+		chunks = append(chunks, chunk{id: string(header[:4]), offset: offset + 8, size: size})
+		// Chunks are padded to an even size
+		offset += 8 + size + size%2
+	}
+	return chunks, nil
+}
+
+// readChunk reads the payload of a small chunk
+func readChunk(r io.ReadSeeker, c chunk, maxSize int64) ([]byte, error) {
+	if c.size > maxSize {
+		return nil, ErrInvalidStream
+	}
+	if _, err := r.Seek(c.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	data := make([]byte, c.size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
@@ -0,0 +1,56 @@
+package audio
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// WAV sample format codes
+const (
+	wavFormatPCM        = 1
+	wavFormatFloat      = 3
+	wavFormatExtensible = 0xFFFE
+)
+
+// newWAVDecoder returns a decoder for the "data" chunk of a WAV file, described by its "fmt " chunk
+func newWAVDecoder(r io.ReadSeeker) (Decoder, error) {
+	chunks, err := readChunks(r, 12, binary.LittleEndian)
+	if err != nil {
+		return nil, err
+	}
+
+	var format []byte
+	for _, c := range chunks {
+		switch c.id {
+		case "fmt ":
+			if format, err = readChunk(r, c, 1<<10); err != nil {
+				return nil, err
+			}
+		case "data":
+			if len(format) < 16 {
+				return nil, ErrInvalidStream
+			}
+			code := binary.LittleEndian.Uint16(format[0:2])
+			info := Info{
+				Channels:   int(binary.LittleEndian.Uint16(format[2:4])),
+				SampleRate: int(binary.LittleEndian.Uint32(format[4:8])),
+			}
+			bits := int(binary.LittleEndian.Uint16(format[14:16]))
+			if code == wavFormatExtensible && len(format) >= 26 {
+				// The actual format code starts the sub format GUID
+				code = binary.LittleEndian.Uint16(format[24:26])
+			}
+			if code != wavFormatPCM && code != wavFormatFloat {
+				return nil, ErrUnsupportedFormat
+			}
+
+			decoder, err := newPCMDecoder(r, c.offset, c.size, info, binary.LittleEndian, bits, code == wavFormatFloat)
+			if err != nil {
+				return nil, err
+			}
+			decoder.unsigned = bits <= 8
+			return decoder, nil
+		}
+	}
+	return nil, ErrInvalidStream
+}
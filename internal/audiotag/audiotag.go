@@ -0,0 +1,232 @@
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Container formats the tags were read from
+const (
+	FormatMP3  = "mp3"
+	FormatFLAC = "flac"
+	FormatOgg  = "ogg"
+	FormatMP4  = "mp4"
+	FormatWAV  = "wav"
+	FormatAIFF = "aiff"
+)
+
+// maxTagSize bounds the size of a single tag block read into memory. Tags carrying cover art are
+// usually well below a few megabytes.
+const maxTagSize = 32 << 20
+
+// Tag reader errors
+var (
+	ErrUnsupportedFormat = errors.New("unsupported audio format")
+	ErrInvalidTag        = errors.New("invalid audio tag")
+)
+
+// Tags is the format independent metadata embedded in an audio file. Unset fields are empty or zero.
+type Tags struct {
+	Format      string
+	Title       string
+	Artist      string
+	Album       string
+	Genre       string
+	Composer    string
+	Grouping    string
+	Comment     string
+	Label       string
+	Remixer     string
+	Key         string
+	Bpm         float64
+	Year        int
+	TrackNumber int
+	DiscNumber  int
+}
+
+// Read reads the tags of an audio file, detecting the format from its first bytes
+func Read(r io.ReadSeeker) (*Tags, error) {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, err
+	}
+	header = header[:n]
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		return readMP3(r)
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		return readFLAC(r)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		return readOgg(r)
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WAVE")):
+		return readWAV(r)
+	case len(header) >= 12 && bytes.Equal(header[:4], []byte("FORM")) && (bytes.Equal(header[8:12], []byte("AIFF")) || bytes.Equal(header[8:12], []byte("AIFC"))):
+		return readAIFF(r)
+	case len(header) >= 8 && bytes.Equal(header[4:8], []byte("ftyp")):
+		return readMP4(r)
+	case len(header) >= 2 && header[0] == 0xFF && header[1]&0xE0 == 0xE0:
+		// An MP3 stream without an ID3v2 tag may still carry an ID3v1 tag at the end
+		return readMP3(r)
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+}
+
+// merge fills the fields of t that are unset with the values of other
+func (t *Tags) merge(other *Tags) {
+	if other == nil {
+		return
+	}
+	fill := func(dst *string, src string) {
+		if *dst == "" {
+			*dst = src
+		}
+	}
+	fill(&t.Title, other.Title)
+	fill(&t.Artist, other.Artist)
+	fill(&t.Album, other.Album)
+	fill(&t.Genre, other.Genre)
+	fill(&t.Composer, other.Composer)
+	fill(&t.Grouping, other.Grouping)
+	fill(&t.Comment, other.Comment)
+	fill(&t.Label, other.Label)
+	fill(&t.Remixer, other.Remixer)
+	fill(&t.Key, other.Key)
+	if t.Bpm == 0 {
+		t.Bpm = other.Bpm
+	}
+	if t.Year == 0 {
+		t.Year = other.Year
+	}
+	if t.TrackNumber == 0 {
+		t.TrackNumber = other.TrackNumber
+	}
+	if t.DiscNumber == 0 {
+		t.DiscNumber = other.DiscNumber
+	}
+}
+
+// setField stores a text value under one of the common field names used by Vorbis comments, RIFF INFO chunks
+// and MP4 freeform atoms. Fields that are already set keep their value, and unknown names are ignored.
+func (t *Tags) setField(name, value string) {
+	value = strings.TrimSpace(strings.TrimRight(value, "\x00"))
+	if value == "" {
+		return
+	}
+	set := func(dst *string) {
+		if *dst == "" {
+			*dst = value
+		}
+	}
+
+	switch strings.ToUpper(name) {
+	case "TITLE":
+		set(&t.Title)
+	case "ARTIST":
+		set(&t.Artist)
+	case "ALBUM":
+		set(&t.Album)
+	case "GENRE":
+		set(&t.Genre)
+	case "COMPOSER":
+		set(&t.Composer)
+	case "GROUPING", "CONTENTGROUP":
+		set(&t.Grouping)
+	case "COMMENT", "DESCRIPTION":
+		set(&t.Comment)
+	case "LABEL", "ORGANIZATION", "PUBLISHER":
+		set(&t.Label)
+	case "REMIXER", "MIXARTIST":
+		set(&t.Remixer)
+	case "KEY", "INITIALKEY":
+		set(&t.Key)
+	case "BPM", "TEMPO":
+		if t.Bpm == 0 {
+			t.Bpm = parseBpm(value)
+		}
+	case "DATE", "YEAR":
+		if t.Year == 0 {
+			t.Year = parseYear(value)
+		}
+	case "TRACKNUMBER":
+		if t.TrackNumber == 0 {
+			t.TrackNumber = parseNumber(value)
+		}
+	case "DISCNUMBER":
+		if t.DiscNumber == 0 {
+			t.DiscNumber = parseNumber(value)
+		}
+	}
+}
+
+// parseBpm parses a BPM value, which some taggers write with a comma as decimal separator
+func parseBpm(value string) float64 {
+	bpm, err := strconv.ParseFloat(strings.ReplaceAll(strings.TrimSpace(value), ",", "."), 64)
+	if err != nil || bpm < 0 {
+		return 0
+	}
+	return bpm
+}
+
+// parseYear parses the year at the start of a date such as "2019", "2019-05-01" or "2019-05-01T00:00:00"
+func parseYear(value string) int {
+	value = strings.TrimSpace(value)
+	if len(value) < 4 {
+		return 0
+	}
+	year, err := strconv.Atoi(value[:4])
+	if err != nil {
+		return 0
+	}
+	return year
+}
+
+// parseNumber parses a track or disc number, which may be followed by the total as in "3/12"
+func parseNumber(value string) int {
+	value, _, _ = strings.Cut(strings.TrimSpace(value), "/")
+	number, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || number < 0 {
+		return 0
+	}
+	return number
+}
+
+// readBlock reads size bytes, refusing blocks larger than maxTagSize
+func readBlock(r io.Reader, size int64) ([]byte, error) {
+	if size < 0 || size > maxTagSize {
+		return nil, ErrInvalidTag
+	}
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// genres are the ID3v1 genres, which ID3v2 and MP4 tags may refer to by index
+var genres = []string{
+	"Blues", "Classic Rock", "Country", "Dance", "Disco", "Funk", "Grunge", "Hip-Hop", "Jazz", "Metal",
+	"New Age", "Oldies", "Other", "Pop", "R&B", "Rap", "Reggae", "Rock", "Techno", "Industrial",
+	"Alternative", "Ska", "Death Metal", "Pranks", "Soundtrack", "Euro-Techno", "Ambient", "Trip-Hop", "Vocal", "Jazz+Funk",
+	"Fusion", "Trance", "Classical", "Instrumental", "Acid", "House", "Game", "Sound Clip", "Gospel", "Noise",
+	"AlternRock", "Bass", "Soul", "Punk", "Space", "Meditative", "Instrumental Pop", "Instrumental Rock", "Ethnic", "Gothic",
+	"Darkwave", "Techno-Industrial", "Electronic", "Pop-Folk", "Eurodance", "Dream", "Southern Rock", "Comedy", "Cult", "Gangsta",
+	"Top 40", "Christian Rap", "Pop/Funk", "Jungle", "Native American", "Cabaret", "New Wave", "Psychadelic", "Rave", "Showtunes",
+	"Trailer", "Lo-Fi", "Tribal", "Acid Punk", "Acid Jazz", "Polka", "Retro", "Musical", "Rock & Roll", "Hard Rock",
+}
+
+// genreName returns the ID3v1 genre with the given index, or an empty string if the index is unknown
+func genreName(index int) string {
+	if index < 0 || index >= len(genres) {
+		return ""
+	}
+	return genres[index]
+}
@@ -0,0 +1,105 @@
+package audiotag
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+// readBytes reads the tags of an in-memory file
+func readBytes(t *testing.T, data []byte) *Tags {
+	t.Helper()
+	tags, err := Read(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	return tags
+}
+
+// checkTags compares tags field by field
+func checkTags(t *testing.T, got *Tags, want Tags) {
+	t.Helper()
+	if *got != want {
+		t.Errorf("tags = %+v,\nwant %+v", *got, want)
+	}
+}
+
+func TestReadRejectsUnknownFormats(t *testing.T) {
+	tests := map[string][]byte{
+		"text":          []byte("just some text"),
+		"short":         []byte("ID"),
+		"riff not wav":  append([]byte("RIFF\x04\x00\x00\x00AVI "), make([]byte, 16)...),
+		"form not aiff": append([]byte("FORM\x00\x00\x00\x04"), []byte("8SVX")...),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(data)); !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("err = %v, want ErrUnsupportedFormat", err)
+			}
+		})
+	}
+
+	if _, err := Read(bytes.NewReader(nil)); err == nil {
+		t.Error("Read of an empty file succeeded")
+	}
+}
+
+func TestParseBpm(t *testing.T) {
+	tests := map[string]float64{
+		"124":    124,
+		" 124.5": 124.5,
+		"124,5":  124.5,
+		"-1":     0,
+		"fast":   0,
+		"":       0,
+	}
+	for value, want := range tests {
+		if got := parseBpm(value); got != want {
+			t.Errorf("parseBpm(%q) = %v, want %v", value, got, want)
+		}
+	}
+}
+
+func TestParseYear(t *testing.T) {
+	tests := map[string]int{
+		"2019":                2019,
+		"2019-05-01":          2019,
+		"2019-05-01T00:00:00": 2019,
+		" 1999 ":              1999,
+		"99":                  0,
+		"May 2019":            0,
+	}
+	for value, want := range tests {
+		if got := parseYear(value); got != want {
+			t.Errorf("parseYear(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestParseNumber(t *testing.T) {
+	tests := map[string]int{
+		"3":       3,
+		"3/12":    3,
+		" 3 / 12": 3,
+		"/12":     0,
+		"-2":      0,
+		"A1":      0,
+	}
+	for value, want := range tests {
+		if got := parseNumber(value); got != want {
+			t.Errorf("parseNumber(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
+
+func TestSetFieldKeepsFirstValue(t *testing.T) {
+	tags := &Tags{}
+	tags.setField("title", " First\x00")
+	tags.setField("TITLE", "Second")
+	tags.setField("initialkey", "Am")
+	tags.setField("KEY", "C")
+	tags.setField("publisher", "Label One")
+	tags.setField("unknown", "ignored")
+	tags.setField("ARTIST", "   ")
+	checkTags(t, tags, Tags{Title: "First", Key: "Am", Label: "Label One"})
+}
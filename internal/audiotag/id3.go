@@ -0,0 +1,348 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// ID3v2 header flags
+const (
+	id3Unsynchronisation = 0x80
+	id3ExtendedHeader    = 0x40
+)
+
+// ID3v2.4 frame format flags
+const (
+	id3FrameCompression       = 0x08
+	id3FrameEncryption        = 0x04
+	id3FrameUnsynchronisation = 0x02
+	id3FrameDataLength        = 0x01
+)
+
+// id3v1Size is the size of the ID3v1 tag at the end of an MP3 file
+const id3v1Size = 128
+
+// genreReference matches ID3v2 genres given as an ID3v1 index, e.g. "(17)" or "17", optionally followed by a name
+var genreReference = regexp.MustCompile(`^\((\d+)\)(.*)$|^(\d+)$`)
+
+// readMP3 reads the ID3v2 tag at the start of an MP3 file, falling back on the ID3v1 tag at its end
+func readMP3(r io.ReadSeeker) (*Tags, error) {
+	tags := &Tags{Format: FormatMP3}
+
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+	if bytes.HasPrefix(header, []byte("ID3")) {
+		size := syncsafe(header[6:10])
+		data, err := readBlock(r, int64(size))
+		if err != nil {
+			return nil, err
+		}
+		id3, err := parseID3v2(header, data)
+		if err != nil {
+			return nil, err
+		}
+		tags.merge(id3)
+	}
+
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	if end >= id3v1Size {
+		if _, err := r.Seek(end-id3v1Size, io.SeekStart); err != nil {
+			return nil, err
+		}
+		trailer := make([]byte, id3v1Size)
+		if _, err := io.ReadFull(r, trailer); err != nil {
+			return nil, err
+		}
+		tags.merge(parseID3v1(trailer))
+	}
+
+	return tags, nil
+}
+
+// readID3v2 parses an ID3v2 tag embedded in another container, such as an "ID3 " chunk of a WAV or AIFF file
+func readID3v2(data []byte) (*Tags, error) {
+	if len(data) < 10 || !bytes.HasPrefix(data, []byte("ID3")) {
+		return nil, ErrInvalidTag
+	}
+	size := syncsafe(data[6:10])
+	if size > len(data)-10 {
+		size = len(data) - 10
+	}
+	return parseID3v2(data[:10], data[10:10+size])
+}
+
+// parseID3v2 parses the frames of an ID3v2.2, 2.3 or 2.4 tag given its header and body
+func parseID3v2(header, data []byte) (*Tags, error) {
+	version := header[3]
+	flags := header[5]
+	if version < 2 || version > 4 {
+		return nil, ErrInvalidTag
+	}
+
+	// Before ID3v2.4 unsynchronisation applies to the whole tag
+	if flags&id3Unsynchronisation != 0 && version < 4 {
+		data = removeUnsynchronisation(data)
+	}
+
+	if flags&id3ExtendedHeader != 0 && version > 2 {
+		if len(data) < 4 {
+			return nil, ErrInvalidTag
+		}
+		size := int(binary.BigEndian.Uint32(data[:4])) + 4 // The ID3v2.3 size excludes itself
+		if version == 4 {
+			size = syncsafe(data[:4])
+		}
+		if size > len(data) {
+			return nil, ErrInvalidTag
+		}
+		data = data[size:]
+	}
+
+	idSize, headerSize := 4, 10
+	if version == 2 {
+		idSize, headerSize = 3, 6
+	}
+
+	tags := &Tags{}
+	for len(data) >= headerSize && data[0] != 0 {
+		id := string(data[:idSize])
+		var size int
+		var frameFlags byte
+		switch version {
+		case 2:
+			size = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+			frameFlags = data[9]
+		default:
+			size = syncsafe(data[4:8])
+			frameFlags = data[9]
+		}
+		if size < 0 || size > len(data)-headerSize {
+			break
+		}
+		body := data[headerSize : headerSize+size]
+		data = data[headerSize+size:]
+
+		if version == 3 && frameFlags&0xC0 != 0 {
+			// Compressed or encrypted ID3v2.3 frame
+			continue
+		}
+		if version == 4 {
+			if frameFlags&(id3FrameCompression|id3FrameEncryption) != 0 {
+				continue
+			}
+			if frameFlags&id3FrameDataLength != 0 {
+				if len(body) < 4 {
+					continue
+				}
+				body = body[4:]
+			}
+			if frameFlags&id3FrameUnsynchronisation != 0 {
+				body = removeUnsynchronisation(body)
+			}
+		}
+
+		tags.setID3Frame(id, body)
+	}
+	return tags, nil
+}
+
+// setID3Frame stores the value of a known ID3v2 frame
+func (t *Tags) setID3Frame(id string, body []byte) {
+	switch id {
+	case "TIT2", "TT2":
+		t.setField("TITLE", id3Text(body))
+	case "TPE1", "TP1":
+		t.setField("ARTIST", id3Text(body))
+	case "TALB", "TAL":
+		t.setField("ALBUM", id3Text(body))
+	case "TCON", "TCO":
+		t.setField("GENRE", resolveGenre(id3Text(body)))
+	case "TCOM", "TCM":
+		t.setField("COMPOSER", id3Text(body))
+	case "TIT1", "TT1", "GRP1":
+		t.setField("GROUPING", id3Text(body))
+	case "TPUB", "TPB":
+		t.setField("LABEL", id3Text(body))
+	case "TPE4", "TP4":
+		t.setField("REMIXER", id3Text(body))
+	case "TKEY", "TKE":
+		t.setField("KEY", id3Text(body))
+	case "TBPM", "TBP":
+		t.setField("BPM", id3Text(body))
+	case "TDRC", "TYER", "TYE":
+		t.setField("YEAR", id3Text(body))
+	case "TRCK", "TRK":
+		t.setField("TRACKNUMBER", id3Text(body))
+	case "TPOS", "TPA":
+		t.setField("DISCNUMBER", id3Text(body))
+	case "COMM", "COM":
+		t.setField("COMMENT", id3Comment(body))
+	case "TXXX", "TXX":
+		// User defined text frames, e.g. INITIALKEY or LABEL written by some taggers
+		if name, value, ok := id3UserText(body); ok {
+			t.setField(name, value)
+		}
+	}
+}
+
+// id3Text decodes the value of a text frame. Multiple values, as allowed by ID3v2.4, are joined with ", ".
+func id3Text(body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	values := splitID3Strings(body[0], body[1:])
+	nonEmpty := values[:0]
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			nonEmpty = append(nonEmpty, value)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// id3Comment decodes the text of a comment frame: encoding, language, short description and text
+func id3Comment(body []byte) string {
+	if len(body) < 4 {
+		return ""
+	}
+	values := splitID3Strings(body[0], body[4:])
+	if len(values) < 2 {
+		return ""
+	}
+	return strings.Join(values[1:], "\n")
+}
+
+// id3UserText decodes a user defined text frame: encoding, description and value
+func id3UserText(body []byte) (string, string, bool) {
+	if len(body) < 2 {
+		return "", "", false
+	}
+	values := splitID3Strings(body[0], body[1:])
+	if len(values) < 2 {
+		return "", "", false
+	}
+	return values[0], strings.Join(values[1:], ", "), true
+}
+
+// splitID3Strings decodes null separated strings in one of the ID3v2 text encodings
+func splitID3Strings(encoding byte, data []byte) []string {
+	switch encoding {
+	case 1, 2:
+		// UTF-16 with a byte order mark, or UTF-16BE; strings are separated by two null bytes
+		var values []string
+		for len(data) > 0 {
+			end := 0
+			for end+1 < len(data) && (data[end] != 0 || data[end+1] != 0) {
+				end += 2
+			}
+			if end+1 >= len(data) {
+				end = len(data)
+			}
+			values = append(values, decodeUTF16(data[:end], encoding == 2))
+			if end+2 > len(data) {
+				break
+			}
+			data = data[end+2:]
+		}
+		return values
+	case 3:
+		return strings.Split(strings.TrimRight(string(data), "\x00"), "\x00")
+	default:
+		return strings.Split(strings.TrimRight(decodeLatin1(data), "\x00"), "\x00")
+	}
+}
+
+// decodeUTF16 decodes UTF-16 text, using its byte order mark if present
+func decodeUTF16(data []byte, bigEndian bool) string {
+	if len(data) >= 2 {
+		switch {
+		case data[0] == 0xFF && data[1] == 0xFE:
+			bigEndian, data = false, data[2:]
+		case data[0] == 0xFE && data[1] == 0xFF:
+			bigEndian, data = true, data[2:]
+		}
+	}
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = binary.BigEndian.Uint16(data[2*i:])
+		} else {
+			units[i] = binary.LittleEndian.Uint16(data[2*i:])
+		}
+	}
+	return string(utf16.Decode(units))
+}
+
+// decodeLatin1 decodes ISO-8859-1 text
+func decodeLatin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// resolveGenre replaces ID3v1 genre references such as "(17)" with the genre name
+func resolveGenre(value string) string {
+	match := genreReference.FindStringSubmatch(value)
+	if match == nil {
+		return value
+	}
+	if match[3] != "" {
+		index, _ := strconv.Atoi(match[3])
+		return genreName(index)
+	}
+	if refinement := strings.TrimSpace(match[2]); refinement != "" {
+		return refinement
+	}
+	index, _ := strconv.Atoi(match[1])
+	return genreName(index)
+}
+
+// parseID3v1 parses the fixed size ID3v1 tag found at the end of MP3 files
+func parseID3v1(data []byte) *Tags {
+	if len(data) != id3v1Size || !bytes.HasPrefix(data, []byte("TAG")) {
+		return nil
+	}
+	field := func(b []byte) string {
+		return strings.TrimSpace(strings.TrimRight(decodeLatin1(b), "\x00"))
+	}
+
+	tags := &Tags{
+		Title:  field(data[3:33]),
+		Artist: field(data[33:63]),
+		Album:  field(data[63:93]),
+		Year:   parseYear(field(data[93:97])),
+		Genre:  genreName(int(data[127])),
+	}
+	comment := data[97:127]
+	if comment[28] == 0 && comment[29] != 0 {
+		// ID3v1.1 keeps the track number in the last byte of the comment
+		tags.TrackNumber = int(comment[29])
+		comment = comment[:28]
+	}
+	tags.Comment = field(comment)
+	return tags
+}
+
+// syncsafe decodes a 28 bit syncsafe integer
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7F)<<21 | int(b[1]&0x7F)<<14 | int(b[2]&0x7F)<<7 | int(b[3]&0x7F)
+}
+
+// removeUnsynchronisation reverses ID3v2 unsynchronisation, which inserts a null byte after every 0xFF
+func removeUnsynchronisation(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xFF, 0x00}, []byte{0xFF})
+}
@@ -0,0 +1,254 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+	"unicode/utf16"
+)
+
+// encodeSyncsafe encodes a 28 bit syncsafe integer
+func encodeSyncsafe(size int) []byte {
+	return []byte{byte(size >> 21 & 0x7F), byte(size >> 14 & 0x7F), byte(size >> 7 & 0x7F), byte(size & 0x7F)}
+}
+
+// id3Frame encodes an ID3v2 frame. The size of ID3v2.4 frames is syncsafe, that of ID3v2.2 frames three bytes.
+func id3Frame(version byte, id string, flags byte, body []byte) []byte {
+	frame := []byte(id)
+	switch version {
+	case 2:
+		frame = append(frame, byte(len(body)>>16), byte(len(body)>>8), byte(len(body)))
+		return append(frame, body...)
+	case 3:
+		frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	default:
+		frame = append(frame, encodeSyncsafe(len(body))...)
+	}
+	frame = append(frame, 0, flags)
+	return append(frame, body...)
+}
+
+// id3Tag encodes an ID3v2 tag with the given header flags around an already encoded body
+func id3Tag(version, flags byte, body []byte) []byte {
+	tag := append([]byte{'I', 'D', '3', version, 0, flags}, encodeSyncsafe(len(body))...)
+	return append(tag, body...)
+}
+
+// latin1 encodes an ISO-8859-1 text frame body
+func latin1(value string) []byte {
+	return append([]byte{0}, value...)
+}
+
+// utf16LE encodes a UTF-16 text frame body with a little endian byte order mark, whose 0xFF byte
+// needs unsynchronisation
+func utf16LE(values ...string) []byte {
+	body := []byte{1}
+	for i, value := range values {
+		if i > 0 {
+			body = append(body, 0, 0)
+		}
+		body = append(body, 0xFF, 0xFE)
+		for _, unit := range utf16.Encode([]rune(value)) {
+			body = binary.LittleEndian.AppendUint16(body, unit)
+		}
+	}
+	return body
+}
+
+// unsynchronise inserts a null byte after every 0xFF byte
+func unsynchronise(data []byte) []byte {
+	return bytes.ReplaceAll(data, []byte{0xFF}, []byte{0xFF, 0x00})
+}
+
+// id3v1Tag encodes an ID3v1.1 tag
+func id3v1Tag(title, artist, year string, track, genre byte) []byte {
+	tag := make([]byte, id3v1Size)
+	copy(tag, "TAG")
+	copy(tag[3:33], title)
+	copy(tag[33:63], artist)
+	copy(tag[63:93], "V1 Album")
+	copy(tag[93:97], year)
+	copy(tag[97:125], "V1 comment")
+	tag[126] = track
+	tag[127] = genre
+	return tag
+}
+
+// mp3Frames stands in for the audio of an MP3 file
+var mp3Frames = append([]byte{0xFF, 0xFB, 0x90, 0x64}, make([]byte, 413)...)
+
+func concat(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestReadID3v23WithUnsynchronisation(t *testing.T) {
+	frames := concat(
+		id3Frame(3, "TIT2", 0, utf16LE("Opening")),
+		id3Frame(3, "TPE1", 0, utf16LE("First Artist")),
+		id3Frame(3, "TALB", 0, latin1("Debut")),
+		id3Frame(3, "TCON", 0, latin1("(35)")),
+		id3Frame(3, "TYER", 0, latin1("2019")),
+		id3Frame(3, "TRCK", 0, latin1("3/12")),
+		id3Frame(3, "TPOS", 0, latin1("1/2")),
+		id3Frame(3, "TBPM", 0, latin1("124")),
+		id3Frame(3, "TKEY", 0, latin1("Am")),
+		id3Frame(3, "COMM", 0, concat([]byte{0}, []byte("eng"), []byte("short\x00Good intro"))),
+		id3Frame(3, "TXXX", 0, concat(utf16LE("LABEL", "Label One"))),
+		// Compressed frames are skipped
+		id3Frame(3, "TCOM", 0x80, latin1("compressed")),
+		id3Frame(3, "TIT1", 0, latin1("Warmup")),
+	)
+	file := concat(id3Tag(3, id3Unsynchronisation, unsynchronise(append(frames, make([]byte, 64)...))), mp3Frames)
+
+	checkTags(t, readBytes(t, file), Tags{
+		Format:      FormatMP3,
+		Title:       "Opening",
+		Artist:      "First Artist",
+		Album:       "Debut",
+		Genre:       "House",
+		Grouping:    "Warmup",
+		Comment:     "Good intro",
+		Label:       "Label One",
+		Key:         "Am",
+		Bpm:         124,
+		Year:        2019,
+		TrackNumber: 3,
+		DiscNumber:  1,
+	})
+}
+
+func TestReadID3v24(t *testing.T) {
+	// The data length indicator gives the size of the frame before unsynchronisation
+	title := utf16LE("Opening")
+	unsynchronisedTitle := concat(encodeSyncsafe(len(title)), unsynchronise(title))
+
+	frames := concat(
+		id3Frame(4, "TIT2", id3FrameUnsynchronisation|id3FrameDataLength, unsynchronisedTitle),
+		id3Frame(4, "TPE1", 0, append([]byte{3}, "First Artist\x00Second Artist"...)),
+		id3Frame(4, "TCON", 0, append([]byte{3}, "(18)Techno Refined"...)),
+		id3Frame(4, "TDRC", 0, append([]byte{3}, "2019-05-01"...)),
+		id3Frame(4, "TPE4", 0, append([]byte{2}, 0, 'R', 0, 'e', 0, 'm', 0, 'i', 0, 'x')),
+		id3Frame(4, "TXXX", 0, append([]byte{3}, "INITIALKEY\x00Am"...)),
+		id3Frame(4, "TPUB", id3FrameEncryption, latin1("encrypted")),
+	)
+	// An extended header of six bytes: its syncsafe size, one flag byte and no flags
+	extended := concat(encodeSyncsafe(6), []byte{1, 0})
+	file := concat(id3Tag(4, id3ExtendedHeader, concat(extended, frames)), mp3Frames)
+
+	checkTags(t, readBytes(t, file), Tags{
+		Format:  FormatMP3,
+		Title:   "Opening",
+		Artist:  "First Artist, Second Artist",
+		Genre:   "Techno Refined",
+		Remixer: "Remix",
+		Key:     "Am",
+		Year:    2019,
+	})
+}
+
+func TestReadID3v22(t *testing.T) {
+	frames := concat(
+		id3Frame(2, "TT2", 0, latin1("Opening")),
+		id3Frame(2, "TP1", 0, latin1("Caf\xe9")),
+		id3Frame(2, "TCO", 0, latin1("17")),
+		id3Frame(2, "TBP", 0, latin1("124")),
+		id3Frame(2, "COM", 0, concat([]byte{0}, []byte("eng"), []byte("\x00Good intro"))),
+	)
+	checkTags(t, readBytes(t, concat(id3Tag(2, 0, frames), mp3Frames)), Tags{
+		Format:  FormatMP3,
+		Title:   "Opening",
+		Artist:  "Café",
+		Genre:   "Rock",
+		Comment: "Good intro",
+		Bpm:     124,
+	})
+}
+
+func TestReadID3v1(t *testing.T) {
+	tests := []struct {
+		name string
+		file []byte
+		want Tags
+	}{
+		{
+			name: "ID3v1.1 only",
+			file: concat(mp3Frames, id3v1Tag("V1 Title", "V1 Artist", "1999", 7, 35)),
+			want: Tags{Format: FormatMP3, Title: "V1 Title", Artist: "V1 Artist", Album: "V1 Album",
+				Comment: "V1 comment", Genre: "House", Year: 1999, TrackNumber: 7},
+		},
+		{
+			name: "ID3v2 takes priority",
+			file: concat(id3Tag(3, 0, id3Frame(3, "TIT2", 0, latin1("V2 Title"))), mp3Frames,
+				id3v1Tag("V1 Title", "V1 Artist", "1999", 0, 255)),
+			want: Tags{Format: FormatMP3, Title: "V2 Title", Artist: "V1 Artist", Album: "V1 Album",
+				Comment: "V1 comment", Year: 1999},
+		},
+		{
+			name: "no tags",
+			file: mp3Frames,
+			want: Tags{Format: FormatMP3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkTags(t, readBytes(t, tt.file), tt.want)
+		})
+	}
+}
+
+func TestReadMalformedID3v2(t *testing.T) {
+	title := id3Frame(3, "TIT2", 0, latin1("Opening"))
+	valid := id3Tag(3, 0, title)
+
+	tests := []struct {
+		name string
+		file []byte
+		err  error
+	}{
+		{"truncated header", []byte("ID3\x03\x00"), io.ErrUnexpectedEOF},
+		{"truncated tag", valid[:len(valid)-4], io.ErrUnexpectedEOF},
+		{"unknown version", id3Tag(5, 0, title), ErrInvalidTag},
+		{"extended header overrun", id3Tag(3, id3ExtendedHeader, []byte{0, 0, 0, 200, 0, 0}), ErrInvalidTag},
+		{"extended header truncated", id3Tag(4, id3ExtendedHeader, []byte{0, 0}), ErrInvalidTag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(tt.file)); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+
+	// Frames after a frame whose size overruns the tag are ignored, earlier frames are kept
+	overrun := concat(title, []byte("TPE1\x00\x00\x10\x00\x00\x00Artist"))
+	checkTags(t, readBytes(t, id3Tag(3, 0, overrun)), Tags{Format: FormatMP3, Title: "Opening"})
+
+	// Text frames without a value or with a truncated UTF-16 value do not fail the tag
+	broken := concat(
+		id3Frame(3, "TIT2", 0, nil),
+		id3Frame(3, "TPE1", 0, []byte{1, 0xFF, 0xFE, 'A'}),
+		id3Frame(3, "COMM", 0, []byte{0, 'e'}),
+		id3Frame(3, "TXXX", 0, []byte{0}),
+		id3Frame(3, "TALB", 0, latin1("Debut")),
+	)
+	checkTags(t, readBytes(t, id3Tag(3, 0, broken)), Tags{Format: FormatMP3, Album: "Debut"})
+}
+
+func TestResolveGenre(t *testing.T) {
+	tests := map[string]string{
+		"House":            "House",
+		"(35)":             "House",
+		"35":               "House",
+		"(17)Rock & Roll":  "Rock & Roll",
+		"(999)":            "",
+		"Drum & Bass (UK)": "Drum & Bass (UK)",
+		"(RX)":             "(RX)",
+	}
+	for value, want := range tests {
+		if got := resolveGenre(value); got != want {
+			t.Errorf("resolveGenre(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
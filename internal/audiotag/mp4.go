@@ -0,0 +1,236 @@
+package audiotag
+
+import (
+	"encoding/binary"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Well-known data types of MP4 "data" atoms
+const (
+	mp4TypeImplicit = 0
+	mp4TypeUTF8     = 1
+	mp4TypeInteger  = 21
+)
+
+// mp4Atom is the position of an atom's payload in the file
+type mp4Atom struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+// readMP4 reads the iTunes style metadata of an MP4 file, found in moov/udta/meta/ilst or moov/meta/ilst
+func readMP4(r io.ReadSeeker) (*Tags, error) {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+
+	tags := &Tags{Format: FormatMP4}
+	moov, err := findMP4Atom(r, 0, end, "moov")
+	if err != nil || moov == nil {
+		return tags, err
+	}
+
+	for _, path := range [][]string{{"udta", "meta"}, {"meta"}} {
+		parent := moov
+		for _, name := range path {
+			if parent == nil {
+				break
+			}
+			parent, err = findMP4Atom(r, parent.offset, parent.offset+parent.size, name)
+			if err != nil {
+				return nil, err
+			}
+		}
+		if parent == nil || parent.size < 4 {
+			continue
+		}
+		// The meta atom starts with a version and flags
+		ilst, err := findMP4Atom(r, parent.offset+4, parent.offset+parent.size, "ilst")
+		if err != nil {
+			return nil, err
+		}
+		if ilst == nil {
+			continue
+		}
+		data, err := readMP4Payload(r, ilst)
+		if err != nil {
+			return nil, err
+		}
+		tags.parseMP4Items(data)
+	}
+	return tags, nil
+}
+
+// findMP4Atom returns the first atom with the given name between two offsets, or nil if there is none
+func findMP4Atom(r io.ReadSeeker, start, end int64, name string) (*mp4Atom, error) {
+	header := make([]byte, 16)
+	for offset := start; offset+8 <= end; {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+		if _, err := io.ReadFull(r, header[:8]); err != nil {
+			return nil, err
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		headerSize := int64(8)
+		switch size {
+		case 0:
+			// The atom extends to the end of the file
+			size = end - offset
+		case 1:
+			if _, err := io.ReadFull(r, header[8:16]); err != nil {
+				return nil, err
+			}
+			size = int64(binary.BigEndian.Uint64(header[8:16]))
+			headerSize = 16
+		}
+		if size < headerSize || offset+size > end {
+			return nil, ErrInvalidTag
+		}
+		if string(header[4:8]) == name {
+			return &mp4Atom{name: name, offset: offset + headerSize, size: size - headerSize}, nil
+		}
+		offset += size
+	}
+	return nil, nil
+}
+
+// readMP4Payload reads the payload of an atom into memory
+func readMP4Payload(r io.ReadSeeker, atom *mp4Atom) ([]byte, error) {
+	if _, err := r.Seek(atom.offset, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return readBlock(r, atom.size)
+}
+
+// splitMP4Atoms splits an in-memory list of atoms into their names and payloads
+func splitMP4Atoms(data []byte) []mp4Item {
+	var items []mp4Item
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		if size < 8 || size > len(data) {
+			break
+		}
+		items = append(items, mp4Item{name: string(data[4:8]), payload: data[8:size]})
+		data = data[size:]
+	}
+	return items
+}
+
+// mp4Item is an atom held in memory
+type mp4Item struct {
+	name    string
+	payload []byte
+}
+
+// parseMP4Items parses the metadata items of an ilst atom
+func (t *Tags) parseMP4Items(data []byte) {
+	for _, item := range splitMP4Atoms(data) {
+		if item.name == "----" {
+			t.parseMP4Freeform(item.payload)
+			continue
+		}
+
+		dataType, value, ok := mp4Data(item.payload)
+		if !ok {
+			continue
+		}
+		switch item.name {
+		case "\xa9nam":
+			t.setField("TITLE", string(value))
+		case "\xa9ART":
+			t.setField("ARTIST", string(value))
+		case "\xa9alb":
+			t.setField("ALBUM", string(value))
+		case "\xa9gen":
+			t.setField("GENRE", string(value))
+		case "gnre":
+			// ID3v1 genre index plus one
+			if len(value) >= 2 {
+				t.setField("GENRE", genreName(int(binary.BigEndian.Uint16(value))-1))
+			}
+		case "\xa9wrt":
+			t.setField("COMPOSER", string(value))
+		case "\xa9grp":
+			t.setField("GROUPING", string(value))
+		case "\xa9cmt", "desc":
+			t.setField("COMMENT", string(value))
+		case "\xa9day":
+			t.setField("YEAR", string(value))
+		case "trkn":
+			if len(value) >= 4 {
+				t.setField("TRACKNUMBER", strconv.Itoa(int(binary.BigEndian.Uint16(value[2:4]))))
+			}
+		case "disk":
+			if len(value) >= 4 {
+				t.setField("DISCNUMBER", strconv.Itoa(int(binary.BigEndian.Uint16(value[2:4]))))
+			}
+		case "tmpo":
+			if dataType == mp4TypeInteger || dataType == mp4TypeImplicit {
+				if bpm := mp4Integer(value); bpm > 0 {
+					t.setField("BPM", strconv.Itoa(bpm))
+				}
+			}
+		}
+	}
+}
+
+// parseMP4Freeform parses a "----" item, whose name is given by its "mean" and "name" atoms,
+// e.g. com.apple.iTunes:initialkey
+func (t *Tags) parseMP4Freeform(payload []byte) {
+	var name string
+	var value []byte
+	for _, item := range splitMP4Atoms(payload) {
+		switch item.name {
+		case "name":
+			if len(item.payload) >= 4 {
+				name = string(item.payload[4:])
+			}
+		case "data":
+			if dataType, data, ok := mp4DataPayload(item.payload); ok && dataType == mp4TypeUTF8 {
+				value = data
+			}
+		}
+	}
+	if name != "" && value != nil {
+		t.setField(strings.ReplaceAll(name, " ", ""), string(value))
+	}
+}
+
+// mp4Data returns the type and value of the first "data" atom of an item
+func mp4Data(payload []byte) (uint32, []byte, bool) {
+	for _, item := range splitMP4Atoms(payload) {
+		if item.name == "data" {
+			return mp4DataPayload(item.payload)
+		}
+	}
+	return 0, nil, false
+}
+
+// mp4DataPayload splits the payload of a "data" atom into its type and value, skipping the locale
+func mp4DataPayload(payload []byte) (uint32, []byte, bool) {
+	if len(payload) < 8 {
+		return 0, nil, false
+	}
+	return binary.BigEndian.Uint32(payload[:4]) & 0xFFFFFF, payload[8:], true
+}
+
+// mp4Integer decodes a big endian integer of 1, 2, 4 or 8 bytes
+func mp4Integer(value []byte) int {
+	switch len(value) {
+	case 1:
+		return int(value[0])
+	case 2:
+		return int(binary.BigEndian.Uint16(value))
+	case 4:
+		return int(binary.BigEndian.Uint32(value))
+	case 8:
+		return int(binary.BigEndian.Uint64(value))
+	default:
+		return 0
+	}
+}
@@ -0,0 +1,136 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+// mp4Box encodes an atom around its payload
+func mp4Box(name string, payload ...[]byte) []byte {
+	body := concat(payload...)
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(body)))
+	box = append(box, name...)
+	return append(box, body...)
+}
+
+// mp4Value encodes a metadata item with a single data atom of the given type
+func mp4Value(name string, dataType uint32, value []byte) []byte {
+	return mp4Box(name, mp4Box("data", binary.BigEndian.AppendUint32(nil, dataType), make([]byte, 4), value))
+}
+
+// mp4Text encodes a UTF-8 metadata item
+func mp4Text(name, value string) []byte {
+	return mp4Value(name, mp4TypeUTF8, []byte(value))
+}
+
+// mp4Freeform encodes a "----" item named mean:name
+func mp4Freeform(mean, name, value string) []byte {
+	return mp4Box("----",
+		mp4Box("mean", make([]byte, 4), []byte(mean)),
+		mp4Box("name", make([]byte, 4), []byte(name)),
+		mp4Box("data", binary.BigEndian.AppendUint32(nil, mp4TypeUTF8), make([]byte, 4), []byte(value)),
+	)
+}
+
+// mp4File encodes an M4A file whose moov atom holds the given atoms
+func mp4File(moov ...[]byte) []byte {
+	return concat(
+		mp4Box("ftyp", []byte("M4A \x00\x00\x02\x00M4A isom")),
+		mp4Box("moov", append([][]byte{mp4Box("mvhd", make([]byte, 100))}, moov...)...),
+		mp4Box("mdat", make([]byte, 64)),
+	)
+}
+
+// mp4Meta encodes a meta atom, which starts with a version and flags, around an ilst atom
+func mp4Meta(items ...[]byte) []byte {
+	return mp4Box("meta", make([]byte, 4), mp4Box("hdlr", make([]byte, 25)), mp4Box("ilst", items...))
+}
+
+func TestReadMP4(t *testing.T) {
+	items := []([]byte){
+		mp4Text("\xa9nam", "Opening"),
+		mp4Text("\xa9ART", "First Artist"),
+		mp4Text("\xa9alb", "Debut"),
+		mp4Value("gnre", mp4TypeImplicit, []byte{0, 36}),
+		mp4Text("\xa9wrt", "Writer"),
+		mp4Text("\xa9grp", "Warmup"),
+		mp4Text("\xa9cmt", "Good intro"),
+		mp4Text("\xa9day", "2019-05-01T07:00:00Z"),
+		mp4Value("trkn", mp4TypeImplicit, []byte{0, 0, 0, 3, 0, 12, 0, 0}),
+		mp4Value("disk", mp4TypeImplicit, []byte{0, 0, 0, 1, 0, 2}),
+		mp4Value("tmpo", mp4TypeInteger, []byte{0, 124}),
+		mp4Value("covr", 13, []byte{0xFF, 0xD8, 0xFF}),
+		mp4Freeform("com.apple.iTunes", "initialkey", "Am"),
+		mp4Freeform("com.apple.iTunes", "LABEL", "Label One"),
+	}
+
+	tests := []struct {
+		name string
+		file []byte
+	}{
+		{"moov/udta/meta", mp4File(mp4Box("udta", mp4Meta(items...)))},
+		{"moov/meta", mp4File(mp4Meta(items...))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkTags(t, readBytes(t, tt.file), Tags{
+				Format:      FormatMP4,
+				Title:       "Opening",
+				Artist:      "First Artist",
+				Album:       "Debut",
+				Genre:       "House",
+				Composer:    "Writer",
+				Grouping:    "Warmup",
+				Comment:     "Good intro",
+				Label:       "Label One",
+				Key:         "Am",
+				Bpm:         124,
+				Year:        2019,
+				TrackNumber: 3,
+				DiscNumber:  1,
+			})
+		})
+	}
+}
+
+func TestReadMP4LargeAtoms(t *testing.T) {
+	// An mdat atom with a 64 bit size before moov, and a last atom that extends to the end of the file
+	mdat := concat([]byte{0, 0, 0, 1}, []byte("mdat"), binary.BigEndian.AppendUint64(nil, 16+32), make([]byte, 32))
+	moov := mp4Box("moov", mp4Box("udta", mp4Meta(mp4Text("\xa9nam", "Opening"))))
+	binary.BigEndian.PutUint32(moov, 0)
+	file := concat(mp4Box("ftyp", []byte("M4A \x00\x00\x02\x00")), mdat, moov)
+
+	checkTags(t, readBytes(t, file), Tags{Format: FormatMP4, Title: "Opening"})
+}
+
+func TestReadMalformedMP4(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("M4A \x00\x00\x02\x00"))
+
+	// Truncated and overlapping atoms are rejected
+	overrun := concat(ftyp, []byte{0, 0, 1, 0}, []byte("moov"), make([]byte, 16))
+	tooSmall := concat(ftyp, []byte{0, 0, 0, 4}, []byte("moov"))
+	for name, file := range map[string][]byte{"overrun": overrun, "too small": tooSmall} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(file)); !errors.Is(err, ErrInvalidTag) {
+				t.Errorf("err = %v, want ErrInvalidTag", err)
+			}
+		})
+	}
+
+	// Files without metadata and items without usable data have no tags
+	tests := map[string][]byte{
+		"no moov":       concat(ftyp, mp4Box("mdat", make([]byte, 16))),
+		"no ilst":       mp4File(mp4Box("udta", mp4Box("meta", make([]byte, 4)))),
+		"empty meta":    mp4File(mp4Box("meta")),
+		"short data":    mp4File(mp4Meta(mp4Box("\xa9nam", mp4Box("data", []byte{0, 0, 0, 1})))),
+		"short numbers": mp4File(mp4Meta(mp4Value("trkn", 0, []byte{0, 0}), mp4Value("tmpo", 21, []byte{0, 0, 1}))),
+		"broken item":   mp4File(mp4Meta([]byte{0, 0, 0, 200, 'a', 'b', 'c', 'd'})),
+	}
+	for name, file := range tests {
+		t.Run(name, func(t *testing.T) {
+			checkTags(t, readBytes(t, file), Tags{Format: FormatMP4})
+		})
+	}
+}
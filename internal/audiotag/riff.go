@@ -0,0 +1,149 @@
+package audiotag
+
+import (
+	"encoding/binary"
+	"io"
+)
+
+// riffInfoFields maps RIFF INFO chunk identifiers to common field names
+var riffInfoFields = map[string]string{
+	"INAM": "TITLE",
+	"IART": "ARTIST",
+	"IPRD": "ALBUM",
+	"IGNR": "GENRE",
+	"IMUS": "COMPOSER",
+	"ICMT": "COMMENT",
+	"ICRD": "DATE",
+	"ITRK": "TRACKNUMBER",
+	"IPRT": "TRACKNUMBER",
+	"IPUB": "LABEL",
+	"IKEY": "KEY",
+	"TBPM": "BPM",
+}
+
+// readWAV reads the ID3 and LIST/INFO chunks of a WAV file. ID3 tags take priority over INFO chunks.
+func readWAV(r io.ReadSeeker) (*Tags, error) {
+	tags := &Tags{Format: FormatWAV}
+	info := &Tags{}
+
+	err := walkChunks(r, 12, binary.LittleEndian, func(id string, data func() ([]byte, error)) error {
+		switch id {
+		case "id3 ", "ID3 ":
+			payload, err := data()
+			if err != nil {
+				return err
+			}
+			if id3, err := readID3v2(payload); err == nil {
+				tags.merge(id3)
+			}
+		case "LIST":
+			payload, err := data()
+			if err != nil {
+				return err
+			}
+			if len(payload) >= 4 && string(payload[:4]) == "INFO" {
+				info.parseRIFFInfo(payload[4:])
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags.merge(info)
+	return tags, nil
+}
+
+// readAIFF reads the ID3 and text chunks of an AIFF or AIFF-C file. ID3 tags take priority over text chunks.
+func readAIFF(r io.ReadSeeker) (*Tags, error) {
+	tags := &Tags{Format: FormatAIFF}
+	text := &Tags{}
+
+	err := walkChunks(r, 12, binary.BigEndian, func(id string, data func() ([]byte, error)) error {
+		field := ""
+		switch id {
+		case "ID3 ", "id3 ":
+			payload, err := data()
+			if err != nil {
+				return err
+			}
+			if id3, err := readID3v2(payload); err == nil {
+				tags.merge(id3)
+			}
+			return nil
+		case "NAME":
+			field = "TITLE"
+		case "AUTH":
+			field = "ARTIST"
+		case "ANNO":
+			field = "COMMENT"
+		default:
+			return nil
+		}
+		payload, err := data()
+		if err != nil {
+			return err
+		}
+		text.setField(field, string(payload))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tags.merge(text)
+	return tags, nil
+}
+
+// walkChunks calls fn for every chunk from the given offset to the end of the file. Chunk payloads are
+// only read when fn asks for them.
+func walkChunks(r io.ReadSeeker, offset int64, order binary.ByteOrder, fn func(id string, data func() ([]byte, error)) error) error {
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 8)
+	for offset+8 <= end {
+		if _, err := r.Seek(offset, io.SeekStart); err != nil {
+			return err
+		}
+		if _, err := io.ReadFull(r, header); err != nil {
+			return err
+		}
+		size := int64(order.Uint32(header[4:8]))
+		if offset+8+size > end {
+			// Truncated files often have a wrong size in their last chunk
+			size = end - offset - 8
+		}
+		data := func() ([]byte, error) {
+			return readBlock(r, size)
+		}
+		if err := fn(string(header[:4]), data); err != nil {
+			return err
+		}
+		// Chunks are padded to an even size
+		offset += 8 + size + size%2
+	}
+	return nil
+}
+
+// parseRIFFInfo parses the subchunks of a LIST/INFO chunk
+func (t *Tags) parseRIFFInfo(data []byte) {
+	for len(data) >= 8 {
+		id := string(data[:4])
+		size := int(binary.LittleEndian.Uint32(data[4:8]))
+		if size > len(data)-8 {
+			size = len(data) - 8
+		}
+		if field, ok := riffInfoFields[id]; ok {
+			t.setField(field, string(data[8:8+size]))
+		}
+		next := 8 + size + size%2
+		if next > len(data) {
+			break
+		}
+		data = data[next:]
+	}
+}
@@ -0,0 +1,134 @@
+package audiotag
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// riffChunk encodes a chunk with a size in the given byte order, padded to an even size
+func riffChunk(order binary.ByteOrder, id string, data []byte) []byte {
+	chunk := append([]byte(id), make([]byte, 4)...)
+	order.PutUint32(chunk[4:], uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 != 0 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+// wavFile encodes a WAV file with a format chunk followed by the given chunks
+func wavFile(chunks ...[]byte) []byte {
+	body := concat(append([][]byte{[]byte("WAVE"), riffChunk(binary.LittleEndian, "fmt ", make([]byte, 16))}, chunks...)...)
+	header := binary.LittleEndian.AppendUint32([]byte("RIFF"), uint32(len(body)))
+	return append(header, body...)
+}
+
+// infoList encodes a LIST/INFO chunk from pairs of identifiers and values
+func infoList(pairs ...string) []byte {
+	data := []byte("INFO")
+	for i := 0; i+1 < len(pairs); i += 2 {
+		data = append(data, riffChunk(binary.LittleEndian, pairs[i], []byte(pairs[i+1]+"\x00"))...)
+	}
+	return riffChunk(binary.LittleEndian, "LIST", data)
+}
+
+func TestReadWAV(t *testing.T) {
+	info := infoList(
+		"INAM", "Info Title",
+		"IART", "Info Artist",
+		"IPRD", "Debut",
+		"IGNR", "House",
+		"ICRD", "2019-05-01",
+		"ITRK", "3",
+		"IKEY", "Am",
+		"TBPM", "124",
+		"ISFT", "Some Encoder",
+	)
+	id3 := id3Tag(3, 0, concat(
+		id3Frame(3, "TIT2", 0, latin1("Opening")),
+		id3Frame(3, "TPUB", 0, latin1("Label One")),
+	))
+	data := riffChunk(binary.LittleEndian, "data", make([]byte, 33))
+
+	tests := []struct {
+		name string
+		file []byte
+		want Tags
+	}{
+		{
+			name: "INFO only",
+			file: wavFile(info, data),
+			want: Tags{Format: FormatWAV, Title: "Info Title", Artist: "Info Artist", Album: "Debut", Genre: "House",
+				Key: "Am", Bpm: 124, Year: 2019, TrackNumber: 3},
+		},
+		{
+			name: "ID3 takes priority",
+			file: wavFile(data, info, riffChunk(binary.LittleEndian, "id3 ", id3)),
+			want: Tags{Format: FormatWAV, Title: "Opening", Artist: "Info Artist", Album: "Debut", Genre: "House",
+				Label: "Label One", Key: "Am", Bpm: 124, Year: 2019, TrackNumber: 3},
+		},
+		{
+			name: "upper case ID3 chunk",
+			file: wavFile(riffChunk(binary.LittleEndian, "ID3 ", id3)),
+			want: Tags{Format: FormatWAV, Title: "Opening", Label: "Label One"},
+		},
+		{
+			name: "invalid ID3 chunk is ignored",
+			file: wavFile(riffChunk(binary.LittleEndian, "id3 ", []byte("ID3\x07\x00\x00\x00\x00\x00\x00")), info),
+			want: Tags{Format: FormatWAV, Title: "Info Title", Artist: "Info Artist", Album: "Debut", Genre: "House",
+				Key: "Am", Bpm: 124, Year: 2019, TrackNumber: 3},
+		},
+		{
+			name: "other LIST chunk",
+			file: wavFile(riffChunk(binary.LittleEndian, "LIST", []byte("adtlxxxx"))),
+			want: Tags{Format: FormatWAV},
+		},
+		{
+			// Files cut short often keep the size of the complete last chunk
+			name: "truncated last chunk",
+			file: wavFile(info[:len(info)-20]),
+			want: Tags{Format: FormatWAV, Title: "Info Title", Artist: "Info Artist", Album: "Debut", Genre: "House",
+				Key: "Am", Bpm: 124, Year: 2019, TrackNumber: 3},
+		},
+		{
+			name: "truncated INFO subchunk",
+			file: wavFile(riffChunk(binary.LittleEndian, "LIST", []byte("INFOINAM\x40\x00\x00\x00Cut"))),
+			want: Tags{Format: FormatWAV, Title: "Cut"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkTags(t, readBytes(t, tt.file), tt.want)
+		})
+	}
+}
+
+func TestReadAIFF(t *testing.T) {
+	aiffFile := func(formType string, chunks ...[]byte) []byte {
+		body := concat(append([][]byte{[]byte(formType), riffChunk(binary.BigEndian, "COMM", make([]byte, 18))}, chunks...)...)
+		header := binary.BigEndian.AppendUint32([]byte("FORM"), uint32(len(body)))
+		return append(header, body...)
+	}
+	text := concat(
+		riffChunk(binary.BigEndian, "NAME", []byte("Text Title")),
+		riffChunk(binary.BigEndian, "AUTH", []byte("Text Artist")),
+		riffChunk(binary.BigEndian, "ANNO", []byte("Good intro")),
+		riffChunk(binary.BigEndian, "SSND", make([]byte, 21)),
+	)
+	id3 := riffChunk(binary.BigEndian, "ID3 ", id3Tag(4, 0, id3Frame(4, "TIT2", 0, latin1("Opening"))))
+
+	tests := []struct {
+		name string
+		file []byte
+		want Tags
+	}{
+		{"text chunks", aiffFile("AIFF", text), Tags{Format: FormatAIFF, Title: "Text Title", Artist: "Text Artist", Comment: "Good intro"}},
+		{"ID3 takes priority", aiffFile("AIFC", text, id3), Tags{Format: FormatAIFF, Title: "Opening", Artist: "Text Artist", Comment: "Good intro"}},
+		{"no tags", aiffFile("AIFF"), Tags{Format: FormatAIFF}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkTags(t, readBytes(t, tt.file), tt.want)
+		})
+	}
+}
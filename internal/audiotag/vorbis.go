@@ -0,0 +1,160 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+)
+
+// flacVorbisComment is the FLAC metadata block type holding Vorbis comments
+const flacVorbisComment = 4
+
+// maxOggPages bounds the number of pages read while looking for the comment header
+const maxOggPages = 1024
+
+// readFLAC reads the Vorbis comments from the metadata blocks following the "fLaC" marker
+func readFLAC(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	tags := &Tags{Format: FormatFLAC}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7F
+		size := int64(header[1])<<16 | int64(header[2])<<8 | int64(header[3])
+
+		if blockType == flacVorbisComment {
+			data, err := readBlock(r, size)
+			if err != nil {
+				return nil, err
+			}
+			if err := tags.parseVorbisComments(data); err != nil {
+				return nil, err
+			}
+			return tags, nil
+		}
+		if last {
+			return tags, nil
+		}
+		if _, err := r.Seek(size, io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readOgg reads the Vorbis comments of an Ogg Vorbis or Opus stream. The comment header is the second packet
+// of the first logical stream and may span several pages.
+func readOgg(r io.ReadSeeker) (*Tags, error) {
+	tags := &Tags{Format: FormatOgg}
+
+	var serial uint32
+	var packet []byte
+	packets := 0
+	header := make([]byte, 27)
+	for page := 0; page < maxOggPages; page++ {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(header[:4], []byte("OggS")) {
+			return nil, ErrInvalidTag
+		}
+		pageSerial := binary.LittleEndian.Uint32(header[14:18])
+		if page == 0 {
+			serial = pageSerial
+		}
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, err
+		}
+		var pageSize int64
+		for _, segment := range segments {
+			pageSize += int64(segment)
+		}
+		if pageSerial != serial {
+			// Pages of other multiplexed streams
+			if _, err := r.Seek(pageSize, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		body, err := readBlock(r, pageSize)
+		if err != nil {
+			return nil, err
+		}
+		for _, segment := range segments {
+			if packets == 1 {
+				packet = append(packet, body[:segment]...)
+				if len(packet) > maxTagSize {
+					return nil, ErrInvalidTag
+				}
+			}
+			body = body[segment:]
+			// A segment shorter than 255 bytes ends the packet
+			if segment < 255 {
+				packets++
+				if packets == 2 {
+					if err := tags.parseOggComments(packet); err != nil {
+						return nil, err
+					}
+					return tags, nil
+				}
+			}
+		}
+	}
+	return nil, ErrInvalidTag
+}
+
+// parseOggComments parses a Vorbis or Opus comment header packet
+func (t *Tags) parseOggComments(packet []byte) error {
+	switch {
+	case bytes.HasPrefix(packet, []byte("\x03vorbis")):
+		return t.parseVorbisComments(packet[7:])
+	case bytes.HasPrefix(packet, []byte("OpusTags")):
+		return t.parseVorbisComments(packet[8:])
+	default:
+		return ErrInvalidTag
+	}
+}
+
+// parseVorbisComments parses a vendor string followed by a list of "NAME=value" comments, all length prefixed
+// in little endian order
+func (t *Tags) parseVorbisComments(data []byte) error {
+	next := func() (string, bool) {
+		if len(data) < 4 {
+			return "", false
+		}
+		size := binary.LittleEndian.Uint32(data)
+		if uint64(size) > uint64(len(data)-4) {
+			return "", false
+		}
+		value := string(data[4 : 4+size])
+		data = data[4+size:]
+		return value, true
+	}
+
+	if _, ok := next(); !ok {
+		return ErrInvalidTag
+	}
+	if len(data) < 4 {
+		return ErrInvalidTag
+	}
+	count := binary.LittleEndian.Uint32(data)
+	data = data[4:]
+	for i := uint32(0); i < count; i++ {
+		comment, ok := next()
+		if !ok {
+			return ErrInvalidTag
+		}
+		if name, value, found := strings.Cut(comment, "="); found {
+			t.setField(name, value)
+		}
+	}
+	return nil
+}
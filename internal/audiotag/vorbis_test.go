@@ -0,0 +1,174 @@
+package audiotag
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// vorbisComments encodes a vendor string and a list of comments
+func vorbisComments(comments ...string) []byte {
+	data := binary.LittleEndian.AppendUint32(nil, uint32(len("musync")))
+	data = append(data, "musync"...)
+	data = binary.LittleEndian.AppendUint32(data, uint32(len(comments)))
+	for _, comment := range comments {
+		data = binary.LittleEndian.AppendUint32(data, uint32(len(comment)))
+		data = append(data, comment...)
+	}
+	return data
+}
+
+// flacBlock encodes a FLAC metadata block
+func flacBlock(blockType byte, last bool, data []byte) []byte {
+	if last {
+		blockType |= 0x80
+	}
+	return append([]byte{blockType, byte(len(data) >> 16), byte(len(data) >> 8), byte(len(data))}, data...)
+}
+
+// oggPage encodes an Ogg page holding the given segments of packet data
+func oggPage(serial uint32, sequence uint32, segments []byte, body []byte) []byte {
+	page := []byte("OggS\x00\x00")
+	page = append(page, make([]byte, 8)...) // Granule position
+	page = binary.LittleEndian.AppendUint32(page, serial)
+	page = binary.LittleEndian.AppendUint32(page, sequence)
+	page = append(page, 0, 0, 0, 0) // Checksum, not verified by the reader
+	page = append(page, byte(len(segments)))
+	page = append(page, segments...)
+	return append(page, body...)
+}
+
+// lacing returns the segment table of a packet that is completed on its page
+func lacing(size int) []byte {
+	segments := bytes.Repeat([]byte{255}, size/255)
+	return append(segments, byte(size%255))
+}
+
+var tagComments = []string{
+	"TITLE=Opening",
+	"artist=First Artist",
+	"ALBUM=Debut",
+	"GENRE=House",
+	"DATE=2019-05-01",
+	"TRACKNUMBER=3/12",
+	"DISCNUMBER=1",
+	"BPM=124,5",
+	"INITIALKEY=Am",
+	"ORGANIZATION=Label One",
+	"MIXARTIST=Someone",
+	"DESCRIPTION=Good intro",
+	"TITLE=Second title",
+	"no separator",
+}
+
+var wantCommentTags = Tags{
+	Title:       "Opening",
+	Artist:      "First Artist",
+	Album:       "Debut",
+	Genre:       "House",
+	Comment:     "Good intro",
+	Label:       "Label One",
+	Remixer:     "Someone",
+	Key:         "Am",
+	Bpm:         124.5,
+	Year:        2019,
+	TrackNumber: 3,
+	DiscNumber:  1,
+}
+
+func TestReadFLAC(t *testing.T) {
+	file := concat(
+		[]byte("fLaC"),
+		flacBlock(0, false, make([]byte, 34)),  // STREAMINFO
+		flacBlock(1, false, make([]byte, 100)), // PADDING
+		flacBlock(flacVorbisComment, true, vorbisComments(tagComments...)),
+		make([]byte, 64),
+	)
+
+	want := wantCommentTags
+	want.Format = FormatFLAC
+	checkTags(t, readBytes(t, file), want)
+
+	// A stream without a comment block has no tags
+	bare := concat([]byte("fLaC"), flacBlock(0, true, make([]byte, 34)), make([]byte, 64))
+	checkTags(t, readBytes(t, bare), Tags{Format: FormatFLAC})
+}
+
+func TestReadMalformedFLAC(t *testing.T) {
+	comments := vorbisComments("TITLE=Opening")
+	tests := []struct {
+		name string
+		file []byte
+		err  error
+	}{
+		{"truncated block header", []byte("fLaC\x00\x00"), io.ErrUnexpectedEOF},
+		{"truncated comment block", concat([]byte("fLaC"), flacBlock(flacVorbisComment, true, comments)[:20]), io.ErrUnexpectedEOF},
+		{"missing last block", concat([]byte("fLaC"), flacBlock(0, false, make([]byte, 34))), io.EOF},
+		{"comment count overrun", concat([]byte("fLaC"), flacBlock(flacVorbisComment, true, concat(comments[:10], []byte{2, 0, 0, 0}, comments[14:]))), ErrInvalidTag},
+		{"comment size overrun", concat([]byte("fLaC"), flacBlock(flacVorbisComment, true, concat(comments[:14], []byte{0xFF, 0, 0, 0}, []byte("TITLE")))), ErrInvalidTag},
+		{"missing comment count", concat([]byte("fLaC"), flacBlock(flacVorbisComment, true, comments[:10])), ErrInvalidTag},
+		{"vendor size overrun", concat([]byte("fLaC"), flacBlock(flacVorbisComment, true, []byte{0xFF, 0xFF, 0, 0, 'x'})), ErrInvalidTag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(tt.file)); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestReadOgg(t *testing.T) {
+	identification := append([]byte("\x01vorbis"), make([]byte, 23)...)
+	// A long comment makes the comment packet span two pages
+	comments := append([]string{"COMMENT=" + strings.Repeat("x", 300)}, tagComments...)
+	comment := append([]byte("\x03vorbis"), vorbisComments(comments...)...)
+	split := 255 * 2
+
+	const serial, other = 0x1234, 0x9999
+	file := concat(
+		oggPage(serial, 0, lacing(len(identification)), identification),
+		oggPage(other, 0, lacing(10), make([]byte, 10)), // A page of another multiplexed stream
+		oggPage(serial, 1, []byte{255, 255}, comment[:split]),
+		oggPage(serial, 2, lacing(len(comment)-split), comment[split:]),
+	)
+
+	want := wantCommentTags
+	want.Format = FormatOgg
+	want.Comment = strings.Repeat("x", 300)
+	checkTags(t, readBytes(t, file), want)
+
+	// Opus streams carry the same comments after an OpusTags marker
+	head := append([]byte("OpusHead"), make([]byte, 11)...)
+	tags := append([]byte("OpusTags"), vorbisComments("TITLE=Opening")...)
+	opus := concat(oggPage(serial, 0, lacing(len(head)), head), oggPage(serial, 1, lacing(len(tags)), tags))
+	checkTags(t, readBytes(t, opus), Tags{Format: FormatOgg, Title: "Opening"})
+}
+
+func TestReadMalformedOgg(t *testing.T) {
+	identification := append([]byte("\x01vorbis"), make([]byte, 23)...)
+	first := oggPage(1, 0, lacing(len(identification)), identification)
+	unknown := []byte("\x05unknown")
+
+	tests := []struct {
+		name string
+		file []byte
+		err  error
+	}{
+		{"truncated page header", first[:20], io.ErrUnexpectedEOF},
+		{"truncated page", first[:len(first)-5], io.ErrUnexpectedEOF},
+		{"no comment packet", first, io.EOF},
+		{"lost capture pattern", concat(first, []byte("Oggs"), make([]byte, 30)), ErrInvalidTag},
+		{"unknown comment header", concat(first, oggPage(1, 1, lacing(len(unknown)), unknown)), ErrInvalidTag},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Read(bytes.NewReader(tt.file)); !errors.Is(err, tt.err) {
+				t.Errorf("err = %v, want %v", err, tt.err)
+			}
+		})
+	}
+}
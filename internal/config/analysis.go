@@ -0,0 +1,15 @@
+package config
+
+// AnalysisConfig holds configuration for background tempo and key analysis
+type AnalysisConfig struct {
+	Workers int // Number of tracks that are analysed concurrently
+	PerUser int // Number of tracks of a single user that are analysed concurrently
+}
+
+// loadAnalysisConfig loads analysis configuration from environment variables
+func loadAnalysisConfig() AnalysisConfig {
+	return AnalysisConfig{
+		Workers: GetEnvInt("ANALYSIS_WORKERS", 2),
+		PerUser: GetEnvInt("ANALYSIS_PER_USER", 1),
+	}
+}
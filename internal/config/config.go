@@ -11,11 +11,17 @@ import (
 
 // Config holds all configuration for the application
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	Auth     AuthConfig
-	Redis    RedisConfig
-	Email    EmailConfig
+	Server      ServerConfig
+	Database    DatabaseConfig
+	Auth        AuthConfig
+	Redis       RedisConfig
+	Email       EmailConfig
+	Import      ImportConfig
+	Waveform    WaveformConfig
+	Analysis    AnalysisConfig
+	Storage     StorageConfig
+	HealthCheck HealthCheckConfig
+	Relocate    RelocateConfig
 }
 
 // Load loads configuration from environment variables
@@ -26,11 +32,17 @@ func Load() (*Config, error) {
 	}
 
 	cfg := &Config{
-		Server:   loadServerConfig(),
-		Database: loadDatabaseConfig(),
-		Auth:     loadAuthConfig(),
-		Redis:    loadRedisConfig(),
-		Email:    loadEmailConfig(),
+		Server:      loadServerConfig(),
+		Database:    loadDatabaseConfig(),
+		Auth:        loadAuthConfig(),
+		Redis:       loadRedisConfig(),
+		Email:       loadEmailConfig(),
+		Import:      loadImportConfig(),
+		Waveform:    loadWaveformConfig(),
+		Analysis:    loadAnalysisConfig(),
+		Storage:     loadStorageConfig(),
+		HealthCheck: loadHealthCheckConfig(),
+		Relocate:    loadRelocateConfig(),
 	}
 
 	return cfg, nil
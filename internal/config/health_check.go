@@ -0,0 +1,13 @@
+package config
+
+// HealthCheckConfig holds configuration for background library health checks
+type HealthCheckConfig struct {
+	Workers int // Number of libraries that are checked concurrently
+}
+
+// loadHealthCheckConfig loads health check configuration from environment variables
+func loadHealthCheckConfig() HealthCheckConfig {
+	return HealthCheckConfig{
+		Workers: GetEnvInt("HEALTH_CHECK_WORKERS", 1),
+	}
+}
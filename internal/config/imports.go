@@ -0,0 +1,25 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ImportConfig holds configuration for background library imports
+type ImportConfig struct {
+	Dir     string // Directory where uploads are staged until their import finishes
+	Workers int    // Number of imports that run concurrently
+	// Maximum size in bytes of an uploaded file, and of the library file once decompressed
+	MaxUploadSize  int64
+	MaxLibrarySize int64
+}
+
+// loadImportConfig loads import configuration from environment variables
+func loadImportConfig() ImportConfig {
+	return ImportConfig{
+		Dir:            GetEnv("IMPORT_DIR", filepath.Join(os.TempDir(), "musync-imports")),
+		Workers:        GetEnvInt("IMPORT_WORKERS", 2),
+		MaxUploadSize:  int64(GetEnvInt("IMPORT_MAX_UPLOAD_SIZE", 512<<20)),
+		MaxLibrarySize: int64(GetEnvInt("IMPORT_MAX_LIBRARY_SIZE", 2<<30)),
+	}
+}
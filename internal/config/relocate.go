@@ -0,0 +1,13 @@
+package config
+
+// RelocateConfig holds configuration for background library relocation
+type RelocateConfig struct {
+	Workers int // Number of libraries that are relocated concurrently
+}
+
+// loadRelocateConfig loads relocation configuration from environment variables
+func loadRelocateConfig() RelocateConfig {
+	return RelocateConfig{
+		Workers: GetEnvInt("RELOCATE_WORKERS", 1),
+	}
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// StorageConfig holds configuration for the backends track files are stored in
+type StorageConfig struct {
+	LocalRoot   string // Directory local track files are served from, each user's below users/<id>; never the file system root
+	ManagedDir  string // Directory uploaded track files are stored in
+	UploadDir   string // Directory uploads are staged in until they are complete
+	MaxFileSize int64  // Maximum size in bytes of an uploaded track file
+	UploadTTL   int    // Hours an unfinished upload is kept after its last chunk, 0 to keep it until it is cancelled
+	S3          S3Config
+	PCloud      PCloudConfig
+}
+
+// S3Config holds configuration for an S3-compatible object store. The backend is only enabled if an
+// endpoint is set.
+type S3Config struct {
+	Endpoint  string // Base URL, e.g. "https://s3.eu-west-1.amazonaws.com" or "http://localhost:9000"
+	Region    string
+	AccessKey string
+	SecretKey string
+	PathStyle bool // Address buckets in the path instead of the host name, as MinIO and most stand-ins require
+}
+
+// PCloudConfig holds configuration for pCloud. The backend is only enabled if an access token is set.
+type PCloudConfig struct {
+	APIURL      string // "https://api.pcloud.com", or "https://eapi.pcloud.com" for accounts in the EU region
+	AccessToken string // OAuth 2 access token
+}
+
+// loadStorageConfig loads storage configuration from environment variables
+func loadStorageConfig() StorageConfig {
+	return StorageConfig{
+		LocalRoot:   GetEnv("STORAGE_LOCAL_ROOT", filepath.Join("data", "music")),
+		ManagedDir:  GetEnv("STORAGE_MANAGED_DIR", filepath.Join("data", "files")),
+		UploadDir:   GetEnv("STORAGE_UPLOAD_DIR", filepath.Join(os.TempDir(), "musync-uploads")),
+		MaxFileSize: int64(GetEnvInt("STORAGE_MAX_FILE_SIZE", 1<<30)),
+		UploadTTL:   GetEnvInt("STORAGE_UPLOAD_TTL_HOURS", 24),
+		S3: S3Config{
+			Endpoint:  GetEnv("S3_ENDPOINT", ""),
+			Region:    GetEnv("S3_REGION", "us-east-1"),
+			AccessKey: GetEnv("S3_ACCESS_KEY", ""),
+			SecretKey: GetEnv("S3_SECRET_KEY", ""),
+			PathStyle: GetEnvBool("S3_PATH_STYLE", true),
+		},
+		PCloud: PCloudConfig{
+			APIURL:      GetEnv("PCLOUD_API_URL", "https://api.pcloud.com"),
+			AccessToken: GetEnv("PCLOUD_ACCESS_TOKEN", ""),
+		},
+	}
+}
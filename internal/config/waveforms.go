@@ -0,0 +1,13 @@
+package config
+
+// WaveformConfig holds configuration for background waveform generation
+type WaveformConfig struct {
+	Workers int // Number of waveforms that are generated concurrently
+}
+
+// loadWaveformConfig loads waveform configuration from environment variables
+func loadWaveformConfig() WaveformConfig {
+	return WaveformConfig{
+		Workers: GetEnvInt("WAVEFORM_WORKERS", 1),
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"github.com/dinis/musync/internal/models"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // DB is a wrapper around gorm.DB that provides context support
@@ -43,6 +44,14 @@ func InitDB(cfg config.DatabaseConfig) *DB {
 	sqlDB.SetMaxIdleConns(cfg.MaxIdle)
 	sqlDB.SetConnMaxLifetime(time.Duration(cfg.Lifetime) * time.Second)
 
+	// Renumber duplicate library versions before versions are made unique
+	if err := migrateSnapshotVersions(db); err != nil {
+		log.Fatal("Failed to renumber library versions:", err)
+	}
+
+	// Playlist entries stored before entries had a position are numbered once the column is added
+	numberPlaylistEntries := !db.Migrator().HasColumn(&models.PlaylistTrack{}, "position")
+
 	// Auto migrate the schema
 	err = db.AutoMigrate(
 		&models.User{},
@@ -55,13 +64,44 @@ func InitDB(cfg config.DatabaseConfig) *DB {
 		&models.MusicLibrary{},
 		&models.Track{},
 		&models.Tempo{},
+		&models.CuePoint{},
 		&models.Playlist{},
 		&models.PlaylistTrack{},
+		&models.LibrarySnapshot{},
+		&models.ImportJob{},
+		&models.TrackWaveform{},
+		&models.AnalysisJob{},
+		&models.TrackFile{},
+		&models.FileUpload{},
+		&models.PathRemap{},
+		&models.HealthCheck{},
+		&models.RelocateJob{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Set up the full-text and trigram search columns and indexes
+	if err := migrateSearch(db); err != nil {
+		log.Fatal("Failed to set up search:", err)
+	}
+
+	// Tracks stored before keys were normalized get their normalized key
+	if err := migrateTrackKeys(db); err != nil {
+		log.Fatal("Failed to normalize track keys:", err)
+	}
+
+	// Tracks stored before tracks had a duplicate key get theirs
+	if err := migrateDuplicateKeys(db); err != nil {
+		log.Fatal("Failed to fill in duplicate keys:", err)
+	}
+
+	if numberPlaylistEntries {
+		if err := migratePlaylistPositions(db); err != nil {
+			log.Fatal("Failed to number playlist entries:", err)
+		}
+	}
+
 	dbWrapper := &DB{DB: db, ctx: context.Background(), unscoped: false}
 	GlobalDB = dbWrapper // Set global DB instance for backward compatibility
 	log.Println("Database connection established and migrations completed")
@@ -87,6 +127,15 @@ func (db *DB) Create(ctx context.Context, value interface{}) error {
 	return nil
 }
 
+// CreateInBatches creates the records of a slice with multi-row inserts of up to batchSize rows
+func (db *DB) CreateInBatches(ctx context.Context, value interface{}, batchSize int) error {
+	result := db.WithContext(ctx).DB.CreateInBatches(value, batchSize)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failed to create records")
+	}
+	return nil
+}
+
 // CreateWithoutContext creates a new record using the stored context
 func (db *DB) CreateWithoutContext(value interface{}) error {
 	return db.Create(db.ctx, value)
@@ -137,6 +186,15 @@ func (db *DB) SaveWithoutContext(value interface{}) error {
 	return db.Save(db.ctx, value)
 }
 
+// UpdateColumns updates columns of the matched records of a model and returns the number of rows affected
+func (db *DB) UpdateColumns(ctx context.Context, model interface{}, values interface{}) (int64, error) {
+	result := db.WithContext(ctx).DB.Model(model).UpdateColumns(values)
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "failed to update records")
+	}
+	return result.RowsAffected, nil
+}
+
 // Delete deletes a record with context support
 func (db *DB) Delete(ctx context.Context, value interface{}, conds ...interface{}) error {
 	var result *gorm.DB
@@ -166,9 +224,58 @@ func (db *DB) WhereWithoutContext(query interface{}, args ...interface{}) *DB {
 	return db.Where(db.ctx, query, args...)
 }
 
+// Order adds an order clause to the query
+func (db *DB) Order(value interface{}) *DB {
+	return &DB{DB: db.DB.Order(value), ctx: db.ctx, unscoped: db.unscoped}
+}
+
+// Joins adds a join clause to the query
+func (db *DB) Joins(query string, args ...interface{}) *DB {
+	return &DB{DB: db.DB.Joins(query, args...), ctx: db.ctx, unscoped: db.unscoped}
+}
+
+// Limit limits the number of records returned by the query
+func (db *DB) Limit(limit int) *DB {
+	return &DB{DB: db.DB.Limit(limit), ctx: db.ctx, unscoped: db.unscoped}
+}
+
+// Offset skips the given number of records of the query
+func (db *DB) Offset(offset int) *DB {
+	return &DB{DB: db.DB.Offset(offset), ctx: db.ctx, unscoped: db.unscoped}
+}
+
+// Count counts the records of a model that match the query
+func (db *DB) Count(ctx context.Context, model interface{}) (int64, error) {
+	var count int64
+	result := db.WithContext(ctx).DB.Model(model).Count(&count)
+	if result.Error != nil {
+		return 0, errors.Wrap(result.Error, "failed to count records")
+	}
+	return count, nil
+}
+
+// ForUpdate locks the selected rows until the end of the transaction
+func (db *DB) ForUpdate() *DB {
+	return &DB{DB: db.DB.Clauses(clause.Locking{Strength: "UPDATE"}), ctx: db.ctx, unscoped: db.unscoped}
+}
+
+// Omit excludes the given columns from the query
+func (db *DB) Omit(columns ...string) *DB {
+	return &DB{DB: db.DB.Omit(columns...), ctx: db.ctx, unscoped: db.unscoped}
+}
+
 // Transaction starts a transaction with context support
 func (db *DB) Transaction(ctx context.Context, fn func(tx *DB) error) error {
 	return db.WithContext(ctx).DB.Transaction(func(tx *gorm.DB) error {
 		return fn(&DB{DB: tx, unscoped: db.unscoped})
 	})
 }
+
+// Raw runs a raw SQL query and scans its rows into dest
+func (db *DB) Raw(ctx context.Context, dest interface{}, sql string, values ...interface{}) error {
+	result := db.WithContext(ctx).DB.Raw(sql, values...).Scan(dest)
+	if result.Error != nil {
+		return errors.Wrap(result.Error, "failed to run query")
+	}
+	return nil
+}
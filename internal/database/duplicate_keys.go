@@ -0,0 +1,25 @@
+package database
+
+import (
+	"strings"
+
+	"github.com/dinis/musync/internal/models"
+	"gorm.io/gorm"
+)
+
+// migrateDuplicateKeys fills in the duplicate key of the tracks stored before tracks had one, which have no
+// duplicate key once the column is added. It only touches tracks without one, so a backfill that failed is
+// finished on the next start.
+func migrateDuplicateKeys(db *gorm.DB) error {
+	var tracks []models.Track
+	result := db.Select("id", "artist", "name", "mix").Where("duplicate_key IS NULL").FindInBatches(&tracks, trackKeyBatchSize, func(tx *gorm.DB, batch int) error {
+		values := make([]string, 0, len(tracks))
+		args := make([]interface{}, 0, 2*len(tracks))
+		for _, track := range tracks {
+			values = append(values, "(?::bigint, ?)")
+			args = append(args, track.ID, models.DuplicateKey(track.Artist, track.Name, track.Mix))
+		}
+		return db.Exec("UPDATE tracks SET duplicate_key = v.key FROM (VALUES "+strings.Join(values, ", ")+") AS v(id, key) WHERE tracks.id = v.id", args...).Error
+	})
+	return result.Error
+}
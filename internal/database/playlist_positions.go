@@ -0,0 +1,16 @@
+package database
+
+import "gorm.io/gorm"
+
+// migratePlaylistPositions numbers the entries of playlists stored before positions were recorded, which
+// all have position 0 once the column is added. Entries keep their insertion order.
+func migratePlaylistPositions(db *gorm.DB) error {
+	return db.Exec(`
+		UPDATE playlist_tracks SET position = numbered.position
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY playlist_id ORDER BY id) - 1 AS position
+			FROM playlist_tracks
+			WHERE deleted_at IS NULL
+		) AS numbered
+		WHERE playlist_tracks.id = numbered.id AND playlist_tracks.position <> numbered.position`).Error
+}
@@ -0,0 +1,36 @@
+package database
+
+import "gorm.io/gorm"
+
+// Track fields covered by search, with their full-text weights. Titles and artists rank above
+// album and remixer matches, which rank above labels, groupings and comments.
+const trackSearchVector = `setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce(artist, '')), 'A') ||
+	setweight(to_tsvector('simple', coalesce(remixer, '')), 'B') ||
+	setweight(to_tsvector('simple', coalesce(album, '')), 'B') ||
+	setweight(to_tsvector('simple', coalesce(label, '')), 'C') ||
+	setweight(to_tsvector('simple', coalesce("grouping", '')), 'C') ||
+	setweight(to_tsvector('simple', coalesce(comments, '')), 'D')`
+
+// trackSearchText concatenates the searched fields for trigram matching
+const trackSearchText = `lower(coalesce(name, '') || ' ' || coalesce(artist, '') || ' ' || coalesce(remixer, '') || ' ' ||
+	coalesce(album, '') || ' ' || coalesce(label, '') || ' ' || coalesce("grouping", '') || ' ' || coalesce(comments, ''))`
+
+// migrateSearch adds the generated search columns of the tracks table and the indexes used by search.
+// The columns are maintained by Postgres, so they are not part of the Track model.
+func migrateSearch(db *gorm.DB) error {
+	statements := []string{
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`ALTER TABLE tracks ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (` + trackSearchVector + `) STORED`,
+		`ALTER TABLE tracks ADD COLUMN IF NOT EXISTS search_text text GENERATED ALWAYS AS (` + trackSearchText + `) STORED`,
+		`CREATE INDEX IF NOT EXISTS idx_tracks_search_vector ON tracks USING gin (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_tracks_search_text ON tracks USING gin (search_text gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_playlists_name_trgm ON playlists USING gin (name gin_trgm_ops)`,
+	}
+	for _, statement := range statements {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,26 @@
+package database
+
+import (
+	"github.com/dinis/musync/internal/models"
+	"gorm.io/gorm"
+)
+
+// snapshotVersionIndex is the unique index on the versions of a library
+const snapshotVersionIndex = "idx_library_snapshots_library_version"
+
+// migrateSnapshotVersions renumbers library versions recorded twice under the same number by concurrent
+// changes, before the unique index on versions is created. Versions keep their order, and the duplicates
+// of a number are ordered by when they were recorded.
+func migrateSnapshotVersions(db *gorm.DB) error {
+	migrator := db.Migrator()
+	if !migrator.HasTable(&models.LibrarySnapshot{}) || migrator.HasIndex(&models.LibrarySnapshot{}, snapshotVersionIndex) {
+		return nil
+	}
+	return db.Exec(`
+		UPDATE library_snapshots SET version = numbered.version
+		FROM (
+			SELECT id, ROW_NUMBER() OVER (PARTITION BY library_id ORDER BY version, id) AS version
+			FROM library_snapshots
+		) AS numbered
+		WHERE library_snapshots.id = numbered.id AND library_snapshots.version <> numbered.version`).Error
+}
@@ -0,0 +1,34 @@
+package database
+
+import (
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+	"gorm.io/gorm"
+)
+
+// trackKeyBatchSize is the number of tracks normalized per batch
+const trackKeyBatchSize = 1000
+
+// migrateTrackKeys fills in the normalized key of tracks stored before keys were normalized, which have no
+// normalized key once the column is added. Tracks whose tonality is not a recognized key get an empty
+// normalized key. It only touches tracks without one, so a backfill that failed is finished on the next start.
+func migrateTrackKeys(db *gorm.DB) error {
+	var tracks []models.Track
+	result := db.Select("id", "tonality").Where("camelot_key IS NULL").FindInBatches(&tracks, trackKeyBatchSize, func(tx *gorm.DB, batch int) error {
+		ids := make(map[string][]uint)
+		for _, track := range tracks {
+			camelot := ""
+			if key, ok := musickey.Parse(track.Tonality); ok {
+				camelot = key.Camelot()
+			}
+			ids[camelot] = append(ids[camelot], track.ID)
+		}
+		for key, trackIDs := range ids {
+			if err := db.Model(&models.Track{}).Where("id IN ?", trackIDs).UpdateColumn("camelot_key", key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return result.Error
+}
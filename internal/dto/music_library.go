@@ -1,9 +1,13 @@
 package dto
 
 import (
+	"encoding/json"
+	"math"
 	"time"
 
 	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+	"github.com/dinis/musync/internal/waveform"
 )
 
 // LibraryResponse represents the response structure for a music library
@@ -30,17 +34,53 @@ type TrackResponse struct {
 	StorageType string    `json:"storage_type"`
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
+
+	// Set for the full field set only
+	*TrackMetadataResponse
+}
+
+// TrackMetadataResponse holds the track metadata that is only returned with the full field set
+type TrackMetadataResponse struct {
+	SourceID    string    `json:"source_id"`
+	Composer    string    `json:"composer"`
+	Grouping    string    `json:"grouping"`
+	Kind        string    `json:"kind"`
+	Size        int64     `json:"size"`
+	DiscNumber  int       `json:"disc_number"`
+	TrackNumber int       `json:"track_number"`
+	Bpm         float64   `json:"bpm"`
+	BpmSource   string    `json:"bpm_source,omitempty"` // "analyzed" if the tempo was estimated from the audio
+	Key         string    `json:"key"`
+	KeySource   string    `json:"key_source,omitempty"` // "analyzed" if the key was estimated from the audio
+	KeyCamelot  string    `json:"key_camelot,omitempty"`
+	KeyOpenKey  string    `json:"key_open_key,omitempty"`
+	Rating      int       `json:"rating"`
+	PlayCount   int       `json:"play_count"`
+	Remixer     string    `json:"remixer"`
+	Label       string    `json:"label"`
+	Mix         string    `json:"mix"`
+	BitRate     int       `json:"bit_rate"`
+	SampleRate  int       `json:"sample_rate"`
+	Comments    string    `json:"comments"`
+	DateAdded   time.Time `json:"date_added"`
 }
 
+// Field sets selectable with the fields query parameter of track list endpoints
+const (
+	TrackFieldsBasic = "basic"
+	TrackFieldsFull  = "full"
+)
+
 // PlaylistResponse represents the response structure for a playlist
 type PlaylistResponse struct {
-	ID        uint      `json:"id"`
-	LibraryID uint      `json:"library_id"`
-	Name      string    `json:"name"`
-	Type      int       `json:"type"`
-	ParentID  *uint     `json:"parent_id,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uint            `json:"id"`
+	LibraryID uint            `json:"library_id"`
+	Name      string          `json:"name"`
+	Type      int             `json:"type"`
+	Rules     json.RawMessage `json:"rules,omitempty"` // Rule tree of a smart playlist
+	ParentID  *uint           `json:"parent_id,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+	UpdatedAt time.Time       `json:"updated_at"`
 }
 
 // ToLibraryResponse converts a MusicLibrary model to a LibraryResponse DTO
@@ -82,18 +122,163 @@ func ToTrackResponse(track models.Track) TrackResponse {
 	}
 }
 
+// ToFullTrackResponse converts a Track model to a TrackResponse DTO that includes all stored metadata
+func ToFullTrackResponse(track models.Track) TrackResponse {
+	response := ToTrackResponse(track)
+	response.TrackMetadataResponse = &TrackMetadataResponse{
+		SourceID:    track.TrackID,
+		Composer:    track.Composer,
+		Grouping:    track.Grouping,
+		Kind:        track.Kind,
+		Size:        track.Size,
+		DiscNumber:  track.DiscNumber,
+		TrackNumber: track.TrackNumber,
+		Bpm:         track.AverageBpm,
+		BpmSource:   track.BpmSource,
+		Key:         track.Tonality,
+		KeySource:   track.KeySource,
+		KeyCamelot:  track.CamelotKey,
+		KeyOpenKey:  OpenKey(track.CamelotKey),
+		Rating:      track.Rating,
+		PlayCount:   track.PlayCount,
+		Remixer:     track.Remixer,
+		Label:       track.Label,
+		Mix:         track.Mix,
+		BitRate:     track.BitRate,
+		SampleRate:  track.SampleRate,
+		Comments:    track.Comments,
+		DateAdded:   track.DateAdded,
+	}
+	return response
+}
+
+// OpenKey returns the Open Key notation of a key in Camelot notation, or an empty string if the key is unknown
+func OpenKey(camelot string) string {
+	if key, ok := musickey.Parse(camelot); ok {
+		return key.OpenKey()
+	}
+	return ""
+}
+
 // ToTrackResponses converts a slice of Track models to a slice of TrackResponse DTOs
 func ToTrackResponses(tracks []models.Track) []TrackResponse {
+	return ToTrackResponsesWithFields(tracks, TrackFieldsBasic)
+}
+
+// ToTrackResponsesWithFields converts a slice of Track models to a slice of TrackResponse DTOs with the given field set
+func ToTrackResponsesWithFields(tracks []models.Track, fields string) []TrackResponse {
 	responses := make([]TrackResponse, len(tracks))
 	for i, track := range tracks {
-		responses[i] = ToTrackResponse(track)
+		responses[i] = ToTrackResponseWithFields(track, fields)
 	}
 	return responses
 }
 
+// ToTrackResponseWithFields converts a Track model to a TrackResponse DTO with the given field set
+func ToTrackResponseWithFields(track models.Track, fields string) TrackResponse {
+	if fields == TrackFieldsFull {
+		return ToFullTrackResponse(track)
+	}
+	return ToTrackResponse(track)
+}
+
+// TrackDetailResponse represents the response structure for a single track with all its metadata,
+// its beatgrid, its cue points and the playlists it appears in
+type TrackDetailResponse struct {
+	TrackResponse
+	Beatgrid  []TempoResponse         `json:"beatgrid"`
+	CuePoints []CuePointResponse      `json:"cue_points"`
+	Playlists []TrackPlaylistResponse `json:"playlists"`
+}
+
+// TempoResponse represents the response structure for a beatgrid tempo marker
+type TempoResponse struct {
+	Start         float64 `json:"start"` // In seconds
+	Bpm           float64 `json:"bpm"`
+	TimeSignature string  `json:"time_signature"`
+	Beat          int     `json:"beat"`             // Beat of the bar the marker falls on
+	Source        string  `json:"source,omitempty"` // "analyzed" if the marker was estimated from the audio
+}
+
+// TrackPlaylistResponse represents a playlist a track appears in
+type TrackPlaylistResponse struct {
+	PlaylistID uint   `json:"playlist_id"`
+	Name       string `json:"name"`
+	Path       string `json:"path"`
+	Positions  []int  `json:"positions"`
+}
+
+// CuePointResponse represents the response structure for a memory cue, hot cue or loop
+type CuePointResponse struct {
+	ID     uint     `json:"id"`
+	Type   string   `json:"type"`
+	Start  float64  `json:"start"`
+	End    *float64 `json:"end,omitempty"`
+	HotCue *int     `json:"hot_cue,omitempty"` // Omitted for memory cues
+	Color  string   `json:"color,omitempty"`
+	Name   string   `json:"name"`
+}
+
+// cueTypeNames maps cue point types to their names in responses
+var cueTypeNames = map[int]string{
+	models.CueTypeCue:     "cue",
+	models.CueTypeFadeIn:  "fade_in",
+	models.CueTypeFadeOut: "fade_out",
+	models.CueTypeLoad:    "load",
+	models.CueTypeLoop:    "loop",
+}
+
+// ToTrackDetailResponse converts a Track model with its tempo markers and cue points, and the
+// playlists it appears in, to a TrackDetailResponse DTO
+func ToTrackDetailResponse(track models.Track, playlists []TrackPlaylistResponse) TrackDetailResponse {
+	beatgrid := make([]TempoResponse, len(track.Tempo))
+	for i, tempo := range track.Tempo {
+		beatgrid[i] = TempoResponse{
+			Start:         tempo.Inizio,
+			Bpm:           tempo.Bpm,
+			TimeSignature: tempo.Metro,
+			Beat:          tempo.Battito,
+			Source:        tempo.Source,
+		}
+	}
+
+	cues := make([]CuePointResponse, len(track.CuePoints))
+	for i, cue := range track.CuePoints {
+		cues[i] = CuePointResponse{
+			ID:    cue.ID,
+			Type:  cueTypeNames[cue.Type],
+			Start: cue.Start,
+			End:   cue.End,
+			Color: cue.Color,
+			Name:  cue.Name,
+		}
+		if cues[i].Type == "" {
+			cues[i].Type = "cue"
+		}
+		if cue.HotCue >= 0 {
+			hotCue := cue.HotCue
+			cues[i].HotCue = &hotCue
+		}
+	}
+
+	return TrackDetailResponse{
+		TrackResponse: ToFullTrackResponse(track),
+		Beatgrid:      beatgrid,
+		CuePoints:     cues,
+		Playlists:     playlists,
+	}
+}
+
+// PlaylistTrackResponse represents a track at a position in a playlist
+type PlaylistTrackResponse struct {
+	TrackResponse
+	EntryID  uint `json:"entry_id"`
+	Position int  `json:"position"`
+}
+
 // ToPlaylistResponse converts a Playlist model to a PlaylistResponse DTO
 func ToPlaylistResponse(playlist models.Playlist) PlaylistResponse {
-	return PlaylistResponse{
+	response := PlaylistResponse{
 		ID:        playlist.ID,
 		LibraryID: playlist.LibraryID,
 		Name:      playlist.Name,
@@ -102,6 +287,10 @@ func ToPlaylistResponse(playlist models.Playlist) PlaylistResponse {
 		CreatedAt: playlist.CreatedAt,
 		UpdatedAt: playlist.UpdatedAt,
 	}
+	if playlist.Rules != "" {
+		response.Rules = json.RawMessage(playlist.Rules)
+	}
+	return response
 }
 
 // ToPlaylistResponses converts a slice of Playlist models to a slice of PlaylistResponse DTOs
@@ -112,3 +301,448 @@ func ToPlaylistResponses(playlists []models.Playlist) []PlaylistResponse {
 	}
 	return responses
 }
+
+// CompatibleTrackResponse represents a track that mixes harmonically with another one
+type CompatibleTrackResponse struct {
+	TrackResponse
+	Bpm         float64 `json:"bpm"`
+	KeyCamelot  string  `json:"key_camelot"`
+	KeyOpenKey  string  `json:"key_open_key"`
+	KeyRelation string  `json:"key_relation"` // same, adjacent or relative
+	TempoRatio  float64 `json:"tempo_ratio"`  // 1, or 2 or 0.5 for double and half time
+	BpmOffset   float64 `json:"bpm_offset"`   // Percent difference of the tempo-adjusted BPM
+}
+
+// DuplicateGroupResponse represents a group of tracks that are likely copies of the same recording
+type DuplicateGroupResponse struct {
+	Confidence float64         `json:"confidence"`
+	Reasons    []string        `json:"reasons"`
+	Tracks     []TrackResponse `json:"tracks"`
+}
+
+// DuplicateMergeResponse represents the result of merging duplicate tracks into a keeper
+type DuplicateMergeResponse struct {
+	KeeperID         uint   `json:"keeper_id"`
+	PlaylistsChanged []uint `json:"playlists_changed"`
+	EntriesRepointed int    `json:"entries_repointed"`
+	EntriesRemoved   int    `json:"entries_removed"`
+	TracksDeleted    []uint `json:"tracks_deleted"`
+}
+
+// TrackRescanResponse represents the result of comparing a track with the tags embedded in its file
+type TrackRescanResponse struct {
+	Track       TrackResponse           `json:"track"`
+	Format      string                  `json:"format"`
+	Differences []TagDifferenceResponse `json:"differences"`
+	Applied     []string                `json:"applied"`
+}
+
+// TagDifferenceResponse represents a track field whose embedded tag differs from the library
+type TagDifferenceResponse struct {
+	Field        string      `json:"field"`
+	LibraryValue interface{} `json:"library_value"`
+	FileValue    interface{} `json:"file_value"`
+}
+
+// WaveformResponse represents the waveform overview of a track. Peaks and RMS hold the amplitude of each point
+// from 0 to 255, and are only set once the overview has been generated.
+type WaveformResponse struct {
+	TrackID    uint    `json:"track_id"`
+	State      string  `json:"state"`
+	Error      string  `json:"error,omitempty"`
+	Format     string  `json:"format,omitempty"`
+	SampleRate int     `json:"sample_rate,omitempty"`
+	Duration   float64 `json:"duration,omitempty"`
+	Resolution int     `json:"resolution,omitempty"`
+	Peaks      []int   `json:"peaks,omitempty"`
+	RMS        []int   `json:"rms,omitempty"`
+}
+
+// ToWaveformResponse converts a track waveform to a WaveformResponse DTO, with the overview if it is given
+func ToWaveformResponse(cached *models.TrackWaveform, overview *waveform.Overview) WaveformResponse {
+	response := WaveformResponse{
+		TrackID: cached.TrackID,
+		State:   cached.State,
+		Error:   cached.Error,
+	}
+	if overview != nil {
+		response.Format = overview.Format
+		response.SampleRate = overview.SampleRate
+		response.Duration = math.Round(overview.Duration*1000) / 1000
+		response.Resolution = len(overview.Peaks)
+		response.Peaks = make([]int, len(overview.Peaks))
+		response.RMS = make([]int, len(overview.RMS))
+		for i := range overview.Peaks {
+			response.Peaks[i] = int(overview.Peaks[i])
+			response.RMS[i] = int(overview.RMS[i])
+		}
+	}
+	return response
+}
+
+// SearchResponse represents the response structure for a search across a user's libraries
+type SearchResponse struct {
+	Tracks    []TrackHitResponse    `json:"tracks"`
+	Playlists []PlaylistHitResponse `json:"playlists"`
+}
+
+// TrackHitResponse represents a track matching a search. Highlights maps the matched fields to
+// HTML-escaped fragments with the matched words wrapped in mark elements.
+type TrackHitResponse struct {
+	TrackResponse
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
+}
+
+// PlaylistHitResponse represents a playlist or folder whose name matches a search
+type PlaylistHitResponse struct {
+	PlaylistResponse
+	Rank float64 `json:"rank"`
+}
+
+// ImportSummaryResponse represents the response structure for an incremental library re-import
+type ImportSummaryResponse struct {
+	LibraryID        uint     `json:"library_id"`
+	TracksAdded      []uint   `json:"tracks_added"`
+	TracksChanged    []uint   `json:"tracks_changed"`
+	TracksRemoved    []uint   `json:"tracks_removed"`
+	TracksUnchanged  int      `json:"tracks_unchanged"`
+	PlaylistsAdded   []uint   `json:"playlists_added"`
+	PlaylistsChanged []uint   `json:"playlists_changed"`
+	PlaylistsRemoved []uint   `json:"playlists_removed"`
+	Warnings         []string `json:"warnings"`
+}
+
+// NonNilIDs makes sure ID lists are encoded as empty JSON arrays rather than null
+func NonNilIDs(ids []uint) []uint {
+	if ids == nil {
+		return []uint{}
+	}
+	return ids
+}
+
+// LibrarySnapshotResponse represents the response structure for a library version
+type LibrarySnapshotResponse struct {
+	Version       int       `json:"version"`
+	Reason        string    `json:"reason"`
+	TrackCount    int       `json:"track_count"`
+	PlaylistCount int       `json:"playlist_count"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// SnapshotTrackResponse identifies a track in a version diff
+type SnapshotTrackResponse struct {
+	TrackID string `json:"track_id"`
+	Title   string `json:"title"`
+	Artist  string `json:"artist"`
+}
+
+// FieldChangeResponse represents a changed track field in a version diff
+type FieldChangeResponse struct {
+	Field string `json:"field"`
+	From  string `json:"from"`
+	To    string `json:"to"`
+}
+
+// TrackChangeResponse represents a track whose metadata changed between two versions
+type TrackChangeResponse struct {
+	SnapshotTrackResponse
+	Fields []FieldChangeResponse `json:"fields"`
+}
+
+// PlaylistChangeResponse represents a playlist whose tracks changed between two versions
+type PlaylistChangeResponse struct {
+	Path          string   `json:"path"`
+	TracksAdded   []string `json:"tracks_added"`
+	TracksRemoved []string `json:"tracks_removed"`
+	Reordered     bool     `json:"reordered"`
+}
+
+// SnapshotDiffResponse represents the response structure for a diff between two library versions
+type SnapshotDiffResponse struct {
+	LibraryID        uint                     `json:"library_id"`
+	FromVersion      int                      `json:"from_version"`
+	ToVersion        int                      `json:"to_version"`
+	TracksAdded      []SnapshotTrackResponse  `json:"tracks_added"`
+	TracksRemoved    []SnapshotTrackResponse  `json:"tracks_removed"`
+	TracksChanged    []TrackChangeResponse    `json:"tracks_changed"`
+	PlaylistsAdded   []string                 `json:"playlists_added"`
+	PlaylistsRemoved []string                 `json:"playlists_removed"`
+	PlaylistsChanged []PlaylistChangeResponse `json:"playlists_changed"`
+}
+
+// ToLibrarySnapshotResponses converts a slice of LibrarySnapshot models to a slice of LibrarySnapshotResponse DTOs
+func ToLibrarySnapshotResponses(snapshots []models.LibrarySnapshot) []LibrarySnapshotResponse {
+	responses := make([]LibrarySnapshotResponse, len(snapshots))
+	for i, snapshot := range snapshots {
+		responses[i] = LibrarySnapshotResponse{
+			Version:       snapshot.Version,
+			Reason:        snapshot.Reason,
+			TrackCount:    snapshot.TrackCount,
+			PlaylistCount: snapshot.PlaylistCount,
+			CreatedAt:     snapshot.CreatedAt,
+		}
+	}
+	return responses
+}
+
+// NonNilStrings makes sure string lists are encoded as empty JSON arrays rather than null
+func NonNilStrings(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
+
+// ImportJobResponse represents the response structure for a background library import
+type ImportJobResponse struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name"`
+	Source          string     `json:"source"`
+	State           string     `json:"state"`
+	TracksProcessed int        `json:"tracks_processed"`
+	TracksTotal     int        `json:"tracks_total"`
+	BytesProcessed  int64      `json:"bytes_processed"`
+	BytesTotal      int64      `json:"bytes_total"`
+	Warnings        []string   `json:"warnings"`
+	Error           string     `json:"error,omitempty"`
+	LibraryID       *uint      `json:"library_id,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	StartedAt       *time.Time `json:"started_at,omitempty"`
+	FinishedAt      *time.Time `json:"finished_at,omitempty"`
+}
+
+// ToImportJobResponse converts an ImportJob model to an ImportJobResponse DTO
+func ToImportJobResponse(job models.ImportJob) ImportJobResponse {
+	return ImportJobResponse{
+		ID:              job.ID,
+		Name:            job.Name,
+		Source:          job.Source,
+		State:           job.State,
+		TracksProcessed: job.TracksProcessed,
+		TracksTotal:     job.TracksTotal,
+		BytesProcessed:  job.BytesProcessed,
+		BytesTotal:      job.BytesTotal,
+		Warnings:        NonNilStrings(job.Warnings),
+		Error:           job.Error,
+		LibraryID:       job.LibraryID,
+		CreatedAt:       job.CreatedAt,
+		StartedAt:       job.StartedAt,
+		FinishedAt:      job.FinishedAt,
+	}
+}
+
+// AnalysisJobResponse represents the response structure for a background tempo and key analysis
+type AnalysisJobResponse struct {
+	ID         uint       `json:"id"`
+	TrackID    uint       `json:"track_id"`
+	State      string     `json:"state"`
+	Overwrite  bool       `json:"overwrite"`
+	Bpm        float64    `json:"bpm,omitempty"` // Estimated tempo, omitted if none was found
+	Key        string     `json:"key,omitempty"` // Estimated key, omitted if none was found
+	KeyCamelot string     `json:"key_camelot,omitempty"`
+	Applied    []string   `json:"applied"` // Track fields the results were written to: "bpm", "key" and "beatgrid"
+	Error      string     `json:"error,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// ToAnalysisJobResponse converts an AnalysisJob model to an AnalysisJobResponse DTO
+func ToAnalysisJobResponse(job models.AnalysisJob) AnalysisJobResponse {
+	response := AnalysisJobResponse{
+		ID:         job.ID,
+		TrackID:    job.TrackID,
+		State:      job.State,
+		Overwrite:  job.Overwrite,
+		Bpm:        job.Bpm,
+		Key:        job.Key,
+		Applied:    NonNilStrings(job.Applied),
+		Error:      job.Error,
+		CreatedAt:  job.CreatedAt,
+		StartedAt:  job.StartedAt,
+		FinishedAt: job.FinishedAt,
+	}
+	if key, ok := musickey.Parse(job.Key); ok {
+		response.KeyCamelot = key.Camelot()
+	}
+	return response
+}
+
+// FileUploadResponse represents the response structure for a resumable track file upload
+type FileUploadResponse struct {
+	ID           uint      `json:"id"`
+	Name         string    `json:"name"`
+	Path         string    `json:"path,omitempty"`
+	Size         int64     `json:"size"`
+	Offset       int64     `json:"offset"` // Number of bytes received, where the next chunk starts
+	State        string    `json:"state"`
+	SHA256       string    `json:"sha256,omitempty"`
+	TrackFileID  *uint     `json:"track_file_id,omitempty"`
+	LinkedTracks []uint    `json:"linked_tracks"` // Tracks streamed from the file once completed
+	Error        string    `json:"error,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// ToFileUploadResponse converts a FileUpload model to a FileUploadResponse DTO
+func ToFileUploadResponse(upload models.FileUpload) FileUploadResponse {
+	return FileUploadResponse{
+		ID:           upload.ID,
+		Name:         upload.Name,
+		Path:         upload.Path,
+		Size:         upload.Size,
+		Offset:       upload.Offset,
+		State:        upload.State,
+		SHA256:       upload.Hash,
+		TrackFileID:  upload.TrackFileID,
+		LinkedTracks: NonNilIDs(upload.LinkedTracks),
+		Error:        upload.Error,
+		CreatedAt:    upload.CreatedAt,
+		UpdatedAt:    upload.UpdatedAt,
+	}
+}
+
+// PathRemapResponse represents a path remap rule of a library
+type PathRemapResponse struct {
+	ID   uint   `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ToPathRemapResponses converts PathRemap models to PathRemapResponse DTOs in the order they are tried
+func ToPathRemapResponses(rules []models.PathRemap) []PathRemapResponse {
+	responses := make([]PathRemapResponse, len(rules))
+	for i, rule := range rules {
+		responses[i] = PathRemapResponse{
+			ID:   rule.ID,
+			From: rule.From,
+			To:   rule.To,
+		}
+	}
+	return responses
+}
+
+// RelocateJobResponse represents the response structure for relocating a library in the background, with its
+// report of which tracks resolve to a file once the library's path remap rules are applied
+type RelocateJobResponse struct {
+	ID          uint                     `json:"id"`
+	LibraryID   uint                     `json:"library_id"`
+	State       string                   `json:"state"`
+	Found       int                      `json:"found"`
+	Missing     int                      `json:"missing"`
+	Unreachable int                      `json:"unreachable"`
+	Tracks      []RelocatedTrackResponse `json:"tracks"`
+	Error       string                   `json:"error,omitempty"`
+	CreatedAt   time.Time                `json:"created_at"`
+	StartedAt   *time.Time               `json:"started_at,omitempty"`
+	FinishedAt  *time.Time               `json:"finished_at,omitempty"`
+}
+
+// RelocatedTrackResponse represents the remapped location of a track and whether its file exists there
+type RelocatedTrackResponse struct {
+	TrackID          uint   `json:"track_id"`
+	Title            string `json:"title"`
+	Artist           string `json:"artist"`
+	Location         string `json:"location"`
+	ResolvedLocation string `json:"resolved_location"`
+	RuleID           *uint  `json:"rule_id,omitempty"` // Rule that rewrote the location, omitted if none matched
+	Status           string `json:"status"`            // "found", "missing" or "unreachable"
+	Error            string `json:"error,omitempty"`
+}
+
+// ToRelocateJobResponse converts a RelocateJob model to a RelocateJobResponse DTO, listing the tracks with
+// the given status only if status is not empty
+func ToRelocateJobResponse(job models.RelocateJob, status string) RelocateJobResponse {
+	response := RelocateJobResponse{
+		ID:          job.ID,
+		LibraryID:   job.LibraryID,
+		State:       job.State,
+		Found:       job.Found,
+		Missing:     job.Missing,
+		Unreachable: job.Unreachable,
+		Tracks:      []RelocatedTrackResponse{},
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		FinishedAt:  job.FinishedAt,
+	}
+	for _, track := range job.Tracks {
+		if status != "" && track.Status != status {
+			continue
+		}
+		response.Tracks = append(response.Tracks, RelocatedTrackResponse{
+			TrackID:          track.TrackID,
+			Title:            track.Title,
+			Artist:           track.Artist,
+			Location:         track.Location,
+			ResolvedLocation: track.ResolvedLocation,
+			RuleID:           track.RuleID,
+			Status:           track.Status,
+			Error:            track.Error,
+		})
+	}
+	return response
+}
+
+// HealthCheckResponse represents the response structure for a library health check and its report
+type HealthCheckResponse struct {
+	ID               uint                  `json:"id"`
+	LibraryID        uint                  `json:"library_id"`
+	State            string                `json:"state"`
+	TracksChecked    int                   `json:"tracks_checked"`
+	PlaylistsChecked int                   `json:"playlists_checked"`
+	IssueCounts      map[string]int        `json:"issue_counts"` // Number of issues of each type
+	Issues           []HealthIssueResponse `json:"issues"`
+	Error            string                `json:"error,omitempty"`
+	CreatedAt        time.Time             `json:"created_at"`
+	StartedAt        *time.Time            `json:"started_at,omitempty"`
+	FinishedAt       *time.Time            `json:"finished_at,omitempty"`
+}
+
+// HealthIssueResponse represents an issue found by a health check, with a suggestion how to fix it
+type HealthIssueResponse struct {
+	Type       string `json:"type"`
+	TrackID    uint   `json:"track_id,omitempty"`
+	PlaylistID uint   `json:"playlist_id,omitempty"`
+	EntryID    uint   `json:"entry_id,omitempty"`
+	TrackKey   string `json:"track_key,omitempty"`
+	Location   string `json:"location,omitempty"`
+	Detail     string `json:"detail"`
+	Suggestion string `json:"suggestion"`
+}
+
+// ToHealthCheckResponse converts a HealthCheck model to a HealthCheckResponse DTO, listing the issues of
+// the given type only if issueType is not empty
+func ToHealthCheckResponse(check models.HealthCheck, issueType string) HealthCheckResponse {
+	response := HealthCheckResponse{
+		ID:               check.ID,
+		LibraryID:        check.LibraryID,
+		State:            check.State,
+		TracksChecked:    check.TracksChecked,
+		PlaylistsChecked: check.PlaylistsChecked,
+		IssueCounts:      make(map[string]int),
+		Issues:           []HealthIssueResponse{},
+		Error:            check.Error,
+		CreatedAt:        check.CreatedAt,
+		StartedAt:        check.StartedAt,
+		FinishedAt:       check.FinishedAt,
+	}
+	for _, issue := range check.Issues {
+		response.IssueCounts[issue.Type]++
+		if issueType != "" && issue.Type != issueType {
+			continue
+		}
+		response.Issues = append(response.Issues, HealthIssueResponse{
+			Type:       issue.Type,
+			TrackID:    issue.TrackID,
+			PlaylistID: issue.PlaylistID,
+			EntryID:    issue.EntryID,
+			TrackKey:   issue.TrackKey,
+			Location:   issue.Location,
+			Detail:     issue.Detail,
+			Suggestion: issue.Suggestion,
+		})
+	}
+	return response
+}
@@ -0,0 +1,76 @@
+package exporters
+
+import (
+	"io"
+	"strconv"
+
+	"github.com/dinis/musync/internal/importers"
+)
+
+// Supported export formats
+const (
+	FormatRekordbox = "rekordbox"
+	FormatTraktor   = "traktor"
+)
+
+// Exporter serializes a neutral library into a DJ software format
+type Exporter interface {
+	// Name returns the format identifier used in export requests
+	Name() string
+	// ContentType returns the MIME type of the exported document
+	ContentType() string
+	// FileExtension returns the extension, including the dot, for exported files
+	FileExtension() string
+	// Export writes the library to w
+	Export(w io.Writer, library *importers.Library) error
+}
+
+// Registry holds the available exporters
+type Registry struct {
+	exporters []Exporter
+}
+
+// NewRegistry creates a Registry with the given exporters
+func NewRegistry(exporters ...Exporter) *Registry {
+	return &Registry{exporters: exporters}
+}
+
+// DefaultRegistry creates a Registry with all built-in exporters
+func DefaultRegistry() *Registry {
+	return NewRegistry(
+		NewRekordboxExporter(),
+		NewTraktorExporter(),
+	)
+}
+
+// Get returns the exporter registered under name
+func (r *Registry) Get(name string) (Exporter, bool) {
+	for _, exporter := range r.exporters {
+		if exporter.Name() == name {
+			return exporter, true
+		}
+	}
+	return nil, false
+}
+
+// topLevelNodes returns the library's playlist tree without a source specific root folder
+// such as Rekordbox's "ROOT" or Traktor's "$ROOT"
+func topLevelNodes(library *importers.Library) []importers.Node {
+	if len(library.Playlists) == 1 {
+		root := library.Playlists[0]
+		if root.Type == importers.NodeTypeFolder && (root.Name == "ROOT" || root.Name == "$ROOT") {
+			return root.Children
+		}
+	}
+	return library.Playlists
+}
+
+// formatFloat formats a value with the given number of decimals, falling back to
+// the shortest exact representation when the rounded value would not parse back identically
+func formatFloat(value float64, decimals int) string {
+	formatted := strconv.FormatFloat(value, 'f', decimals, 64)
+	if parsed, err := strconv.ParseFloat(formatted, 64); err == nil && parsed == value {
+		return formatted
+	}
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
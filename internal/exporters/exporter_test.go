@@ -0,0 +1,198 @@
+package exporters
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+)
+
+// update rewrites the golden files in testdata with the current output
+var update = os.Getenv("UPDATE_GOLDEN") != ""
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+// testLibrary returns a small library imported from a source other than the export formats
+func testLibrary() *importers.Library {
+	return &importers.Library{
+		Source: importers.SourceM3U,
+		Tracks: []models.Track{
+			{
+				TrackID:     "/Users/dj/Music/Opening Track.mp3",
+				Name:        "Opening",
+				Artist:      "First Artist",
+				Composer:    "Writer",
+				Album:       "Debut",
+				Genre:       "House",
+				Kind:        "MP3 File",
+				Size:        8392704,
+				TotalTime:   241,
+				TrackNumber: 3,
+				Year:        2019,
+				AverageBpm:  124,
+				DateAdded:   time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC),
+				BitRate:     320,
+				SampleRate:  44100,
+				Comments:    "Good intro",
+				PlayCount:   12,
+				Rating:      204,
+				Location:    "file://localhost/Users/dj/Music/Opening%20Track.mp3",
+				Remixer:     "Someone",
+				Tonality:    "Am",
+				Label:       "Label One",
+				Mix:         "Extended Mix",
+				Tempo: []models.Tempo{
+					{Inizio: 0.509, Bpm: 124, Metro: "4/4", Battito: 3},
+				},
+				CuePoints: []models.CuePoint{
+					{Type: models.CueTypeCue, Start: 32.283, HotCue: 0, Color: "#E62828", Name: "Drop"},
+					{Type: models.CueTypeLoop, Start: 64.541, End: floatPtr(72.283), HotCue: -1},
+				},
+			},
+			{
+				TrackID:  "C:/Sets/second.wav",
+				Name:     "Second & Last",
+				Artist:   "Second Artist",
+				Kind:     "WAV File",
+				Location: "file://localhost/C:/Sets/second.wav",
+				Tonality: "unknown",
+			},
+		},
+		Playlists: []importers.Node{{
+			Name: "ROOT",
+			Type: importers.NodeTypeFolder,
+			Children: []importers.Node{
+				{Name: "Sets", Type: importers.NodeTypeFolder, Children: []importers.Node{
+					{Name: "Friday", Type: importers.NodeTypePlaylist, TrackKeys: []string{
+						"C:/Sets/second.wav",
+						"/Users/dj/Music/Opening Track.mp3",
+						"/Users/dj/Music/Gone.mp3",
+					}},
+				}},
+				{Name: "Empty", Type: importers.NodeTypePlaylist},
+			},
+		}},
+	}
+}
+
+// traktorUUIDPattern matches the random playlist identifiers of NML documents
+var traktorUUIDPattern = regexp.MustCompile(`UUID="[0-9a-f]{32}"`)
+
+func TestExportGolden(t *testing.T) {
+	registry := DefaultRegistry()
+	tests := []struct {
+		format string
+		golden string
+	}{
+		{FormatRekordbox, "rekordbox.xml"},
+		{FormatTraktor, "traktor.nml"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			exporter, ok := registry.Get(tt.format)
+			if !ok {
+				t.Fatalf("no exporter for %s", tt.format)
+			}
+			var buf bytes.Buffer
+			if err := exporter.Export(&buf, testLibrary()); err != nil {
+				t.Fatal(err)
+			}
+			got := traktorUUIDPattern.ReplaceAll(buf.Bytes(), []byte(`UUID="00000000000000000000000000000000"`))
+
+			path := filepath.Join("testdata", tt.golden)
+			if update {
+				if err := os.WriteFile(path, got, 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Errorf("export differs from %s:\n%s", path, got)
+			}
+		})
+	}
+}
+
+// TestExportRoundTrip imports an exported library again and checks that the library survives the trip
+func TestExportRoundTrip(t *testing.T) {
+	tests := []struct {
+		exporter Exporter
+		importer importers.Importer
+	}{
+		{NewRekordboxExporter(), importers.NewRekordboxImporter()},
+		{NewTraktorExporter(), importers.NewTraktorImporter()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.exporter.Name(), func(t *testing.T) {
+			original := testLibrary()
+			var buf bytes.Buffer
+			if err := tt.exporter.Export(&buf, original); err != nil {
+				t.Fatal(err)
+			}
+			library, err := tt.importer.Parse(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(library.Tracks) != len(original.Tracks) {
+				t.Fatalf("got %d tracks, want %d", len(library.Tracks), len(original.Tracks))
+			}
+			keys := make(map[string]string, len(library.Tracks))
+			for i, track := range library.Tracks {
+				want := original.Tracks[i]
+				keys[track.TrackID] = want.TrackID
+				if track.Name != want.Name || track.Artist != want.Artist || track.Location != want.Location ||
+					track.TotalTime != want.TotalTime || track.AverageBpm != want.AverageBpm || !track.DateAdded.Equal(want.DateAdded) {
+					t.Errorf("track %d = %+v,\nwant %+v", i, track, want)
+				}
+				if len(track.CuePoints) != len(want.CuePoints) {
+					t.Errorf("track %d has %d cues, want %d", i, len(track.CuePoints), len(want.CuePoints))
+				}
+			}
+
+			if len(library.Playlists) != 1 || len(library.Playlists[0].Children) != 2 {
+				t.Fatalf("playlists = %+v, want a root folder with two nodes", library.Playlists)
+			}
+			friday := library.Playlists[0].Children[0].Children[0]
+			var got []string
+			for _, key := range friday.TrackKeys {
+				got = append(got, keys[key])
+			}
+			want := []string{"C:/Sets/second.wav", "/Users/dj/Music/Opening Track.mp3"}
+			if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] {
+				t.Errorf("playlist %q holds %q, want %q without the missing track", friday.Name, got, want)
+			}
+			if len(library.Warnings) != 0 {
+				t.Errorf("warnings = %q", library.Warnings)
+			}
+		})
+	}
+}
+
+func TestFormatFloat(t *testing.T) {
+	tests := []struct {
+		value    float64
+		decimals int
+		want     string
+	}{
+		{124, 2, "124.00"},
+		{0.025, 3, "0.025"},
+		{0.0255, 3, "0.0255"},
+		{123.456789, 2, "123.456789"},
+	}
+	for _, tt := range tests {
+		if got := formatFloat(tt.value, tt.decimals); got != tt.want {
+			t.Errorf("formatFloat(%v, %d) = %q, want %q", tt.value, tt.decimals, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package exporters
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+)
+
+// rekordboxXMLVersion is the DJ_PLAYLISTS schema version written by Rekordbox 6 and later
+const rekordboxXMLVersion = "1.0.0"
+
+// RekordboxExporter writes Rekordbox DJ_PLAYLISTS XML documents
+type RekordboxExporter struct{}
+
+// NewRekordboxExporter creates a new RekordboxExporter
+func NewRekordboxExporter() *RekordboxExporter {
+	return &RekordboxExporter{}
+}
+
+// Name returns the format identifier for Rekordbox
+func (e *RekordboxExporter) Name() string {
+	return FormatRekordbox
+}
+
+// ContentType returns the MIME type of Rekordbox XML
+func (e *RekordboxExporter) ContentType() string {
+	return "application/xml"
+}
+
+// FileExtension returns the extension for Rekordbox XML files
+func (e *RekordboxExporter) FileExtension() string {
+	return ".xml"
+}
+
+// Export writes the library as a Rekordbox XML document that Rekordbox can import
+func (e *RekordboxExporter) Export(w io.Writer, library *importers.Library) error {
+	doc := importers.RekordboxXML{
+		Version: rekordboxXMLVersion,
+		Product: importers.RekordboxProduct{
+			Name:    "musync",
+			Company: "musync",
+		},
+	}
+
+	// Keep the original product information when the library came from Rekordbox
+	if library.Source == importers.SourceRekordbox {
+		doc.Version = library.Version
+		doc.Product = importers.RekordboxProduct{
+			Name:    library.ProductName,
+			Company: library.Company,
+		}
+	}
+
+	keys := rekordboxTrackIDs(library.Tracks)
+
+	doc.Collection.Entries = len(library.Tracks)
+	doc.Collection.Tracks = make([]importers.RekordboxTrack, 0, len(library.Tracks))
+	for _, track := range library.Tracks {
+		doc.Collection.Tracks = append(doc.Collection.Tracks, toRekordboxTrack(track, keys[track.TrackID]))
+	}
+
+	children := topLevelNodes(library)
+	root := importers.RekordboxNode{
+		Type:  strconv.Itoa(importers.NodeTypeFolder),
+		Name:  "ROOT",
+		Count: strconv.Itoa(len(children)),
+	}
+	for _, child := range children {
+		root.Nodes = append(root.Nodes, toRekordboxNode(child, keys))
+	}
+	doc.Playlists.Nodes = []importers.RekordboxNode{root}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// rekordboxTrackIDs maps source track IDs to Rekordbox TrackIDs.
+// Rekordbox requires numeric IDs, so libraries imported from other sources are renumbered.
+func rekordboxTrackIDs(tracks []models.Track) map[string]string {
+	keys := make(map[string]string, len(tracks))
+
+	numeric := true
+	for _, track := range tracks {
+		if _, err := strconv.ParseUint(track.TrackID, 10, 32); err != nil {
+			numeric = false
+			break
+		}
+	}
+
+	for i, track := range tracks {
+		if numeric {
+			keys[track.TrackID] = track.TrackID
+		} else {
+			keys[track.TrackID] = strconv.Itoa(i + 1)
+		}
+	}
+	return keys
+}
+
+// toRekordboxTrack converts a Track model, including its tempo markers and cue points, to a RekordboxTrack
+func toRekordboxTrack(track models.Track, trackID string) importers.RekordboxTrack {
+	dateAdded := ""
+	if !track.DateAdded.IsZero() {
+		dateAdded = track.DateAdded.UTC().Format("2006-01-02")
+	}
+
+	tempo := make([]importers.RekordboxTempo, 0, len(track.Tempo))
+	for _, marker := range track.Tempo {
+		tempo = append(tempo, importers.RekordboxTempo{
+			Inizio:  formatFloat(marker.Inizio, 3),
+			Bpm:     formatFloat(marker.Bpm, 2),
+			Metro:   marker.Metro,
+			Battito: strconv.Itoa(marker.Battito),
+		})
+	}
+
+	marks := make([]importers.RekordboxMark, 0, len(track.CuePoints))
+	for _, cue := range track.CuePoints {
+		marks = append(marks, toRekordboxMark(cue))
+	}
+
+	return importers.RekordboxTrack{
+		TrackID:     trackID,
+		Name:        track.Name,
+		Artist:      track.Artist,
+		Composer:    track.Composer,
+		Album:       track.Album,
+		Grouping:    track.Grouping,
+		Genre:       track.Genre,
+		Kind:        track.Kind,
+		Size:        strconv.FormatInt(track.Size, 10),
+		TotalTime:   strconv.Itoa(track.TotalTime),
+		DiscNumber:  strconv.Itoa(track.DiscNumber),
+		TrackNumber: strconv.Itoa(track.TrackNumber),
+		Year:        strconv.Itoa(track.Year),
+		AverageBpm:  formatFloat(track.AverageBpm, 2),
+		DateAdded:   dateAdded,
+		BitRate:     strconv.Itoa(track.BitRate),
+		SampleRate:  strconv.Itoa(track.SampleRate),
+		Comments:    track.Comments,
+		PlayCount:   strconv.Itoa(track.PlayCount),
+		Rating:      strconv.Itoa(track.Rating),
+		Location:    track.Location,
+		Remixer:     track.Remixer,
+		Tonality:    track.Tonality,
+		Label:       track.Label,
+		Mix:         track.Mix,
+		Tempo:       tempo,
+		Marks:       marks,
+	}
+}
+
+// toRekordboxMark converts a CuePoint model to a RekordboxMark
+func toRekordboxMark(cue models.CuePoint) importers.RekordboxMark {
+	mark := importers.RekordboxMark{
+		Name:  cue.Name,
+		Type:  strconv.Itoa(cue.Type),
+		Start: formatFloat(cue.Start, 3),
+		Num:   strconv.Itoa(cue.HotCue),
+	}
+	if cue.End != nil {
+		mark.End = formatFloat(*cue.End, 3)
+	}
+
+	var red, green, blue uint8
+	if _, err := fmt.Sscanf(cue.Color, "#%02x%02x%02x", &red, &green, &blue); err == nil {
+		mark.Red = strconv.Itoa(int(red))
+		mark.Green = strconv.Itoa(int(green))
+		mark.Blue = strconv.Itoa(int(blue))
+	}
+	return mark
+}
+
+// toRekordboxNode recursively converts a playlist or folder to a RekordboxNode
+func toRekordboxNode(node importers.Node, keys map[string]string) importers.RekordboxNode {
+	rbNode := importers.RekordboxNode{
+		Type: strconv.Itoa(node.Type),
+		Name: node.Name,
+	}
+
+	if node.Type == importers.NodeTypeFolder {
+		rbNode.Count = strconv.Itoa(len(node.Children))
+		for _, child := range node.Children {
+			rbNode.Nodes = append(rbNode.Nodes, toRekordboxNode(child, keys))
+		}
+		return rbNode
+	}
+
+	// KeyType 0 means playlist entries reference the collection by TrackID
+	rbNode.KeyType = "0"
+	for _, key := range node.TrackKeys {
+		trackID, ok := keys[key]
+		if !ok {
+			continue
+		}
+		rbNode.Tracks = append(rbNode.Tracks, importers.RekordboxPlaylistTrack{Key: trackID})
+	}
+	rbNode.Entries = strconv.Itoa(len(rbNode.Tracks))
+
+	return rbNode
+}
@@ -0,0 +1,58 @@
+package exporters
+
+import (
+	"testing"
+
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestRekordboxTrackIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		tracks []string
+		want   []string
+	}{
+		{"numeric IDs are kept", []string{"7", "3", "12"}, []string{"7", "3", "12"}},
+		{"other IDs are renumbered", []string{"7", "/Music/a.mp3", "12"}, []string{"1", "2", "3"}},
+		{"IDs out of range are renumbered", []string{"1", "4294967296"}, []string{"1", "2"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tracks := make([]models.Track, len(tt.tracks))
+			for i, id := range tt.tracks {
+				tracks[i].TrackID = id
+			}
+			keys := rekordboxTrackIDs(tracks)
+			for i, id := range tt.tracks {
+				if keys[id] != tt.want[i] {
+					t.Errorf("TrackID of %q = %q, want %q", id, keys[id], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestToRekordboxMark(t *testing.T) {
+	tests := []struct {
+		name string
+		cue  models.CuePoint
+		want importers.RekordboxMark
+	}{
+		{"memory cue", models.CuePoint{Start: 1.5, HotCue: -1},
+			importers.RekordboxMark{Type: "0", Start: "1.500", Num: "-1"}},
+		{"coloured hot cue", models.CuePoint{Start: 2, HotCue: 1, Color: "#10b176", Name: "B"},
+			importers.RekordboxMark{Name: "B", Type: "0", Start: "2.000", Num: "1", Red: "16", Green: "177", Blue: "118"}},
+		{"invalid colour", models.CuePoint{Start: 2, HotCue: 1, Color: "teal"},
+			importers.RekordboxMark{Type: "0", Start: "2.000", Num: "1"}},
+		{"loop", models.CuePoint{Type: models.CueTypeLoop, Start: 8, End: floatPtr(16), HotCue: -1},
+			importers.RekordboxMark{Type: "4", Start: "8.000", End: "16.000", Num: "-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toRekordboxMark(tt.cue); got != tt.want {
+				t.Errorf("toRekordboxMark = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
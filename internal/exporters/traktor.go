@@ -0,0 +1,291 @@
+package exporters
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// Traktor NML defaults used when the library was not imported from Traktor
+const (
+	traktorNMLVersion   = "19"
+	traktorCompany      = "www.native-instruments.com"
+	traktorProgram      = "Traktor"
+	traktorSystemVolume = "Macintosh HD"
+)
+
+// TraktorExporter writes Traktor NML collections
+type TraktorExporter struct{}
+
+// NewTraktorExporter creates a new TraktorExporter
+func NewTraktorExporter() *TraktorExporter {
+	return &TraktorExporter{}
+}
+
+// Name returns the format identifier for Traktor
+func (e *TraktorExporter) Name() string {
+	return FormatTraktor
+}
+
+// ContentType returns the MIME type of NML documents
+func (e *TraktorExporter) ContentType() string {
+	return "application/xml"
+}
+
+// FileExtension returns the extension for NML files
+func (e *TraktorExporter) FileExtension() string {
+	return ".nml"
+}
+
+// Export writes the library as a Traktor NML collection
+func (e *TraktorExporter) Export(w io.Writer, library *importers.Library) error {
+	doc := importers.TraktorNML{
+		Version: traktorNMLVersion,
+		Head: importers.TraktorHead{
+			Company: traktorCompany,
+			Program: traktorProgram,
+		},
+	}
+	if library.Source == importers.SourceTraktor && library.Version != "" {
+		doc.Version = library.Version
+	}
+
+	// Traktor references collection entries from playlists by their location
+	keys := make(map[string]string, len(library.Tracks))
+
+	doc.Collection.Entries = len(library.Tracks)
+	doc.Collection.Tracks = make([]importers.TraktorEntry, 0, len(library.Tracks))
+	for _, track := range library.Tracks {
+		entry := toTraktorEntry(track)
+		keys[track.TrackID] = importers.TraktorTrackKey(entry.Location)
+		doc.Collection.Tracks = append(doc.Collection.Tracks, entry)
+	}
+
+	children := topLevelNodes(library)
+	root := importers.TraktorNode{
+		Type: "FOLDER",
+		Name: "$ROOT",
+		Subnodes: &importers.TraktorSubnodes{
+			Count: strconv.Itoa(len(children)),
+		},
+	}
+	for _, child := range children {
+		root.Subnodes.Nodes = append(root.Subnodes.Nodes, toTraktorNode(child, keys))
+	}
+	doc.Playlists.Nodes = []importers.TraktorNode{root}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(doc); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+// toTraktorEntry converts a Track model to a Traktor collection entry
+func toTraktorEntry(track models.Track) importers.TraktorEntry {
+	entry := importers.TraktorEntry{
+		Title:    track.Name,
+		Artist:   track.Artist,
+		Location: traktorLocationFromURL(track.Location),
+		Album: importers.TraktorAlbum{
+			Title: track.Album,
+		},
+		Info: importers.TraktorInfo{
+			Genre:    track.Genre,
+			Label:    track.Label,
+			Comment:  track.Comments,
+			Key:      track.Tonality,
+			Mix:      track.Mix,
+			Remixer:  track.Remixer,
+			Producer: track.Composer,
+		},
+	}
+
+	if track.TrackNumber > 0 {
+		entry.Album.Track = strconv.Itoa(track.TrackNumber)
+	}
+	if track.BitRate > 0 {
+		entry.Info.Bitrate = strconv.Itoa(track.BitRate * 1000)
+	}
+	if track.PlayCount > 0 {
+		entry.Info.PlayCount = strconv.Itoa(track.PlayCount)
+	}
+	if track.TotalTime > 0 {
+		entry.Info.Playtime = strconv.Itoa(track.TotalTime)
+	}
+	if track.Rating > 0 {
+		entry.Info.Ranking = strconv.Itoa(track.Rating)
+	}
+	if !track.DateAdded.IsZero() {
+		entry.Info.ImportDate = track.DateAdded.UTC().Format("2006/1/2")
+	}
+	if track.Year > 0 {
+		entry.Info.ReleaseDate = strconv.Itoa(track.Year) + "/1/1"
+	}
+	if track.Size > 0 {
+		entry.Info.FileSize = strconv.FormatInt(track.Size/1024, 10)
+	}
+
+	bpm := track.AverageBpm
+	if bpm == 0 && len(track.Tempo) > 0 {
+		bpm = track.Tempo[0].Bpm
+	}
+	if bpm > 0 {
+		entry.Tempo = &importers.TraktorTempo{
+			Bpm:        formatFloat(bpm, 6),
+			BpmQuality: "100",
+		}
+	}
+
+	if key, ok := musickey.Parse(track.Tonality); ok {
+		entry.MusicalKey = &importers.TraktorMusicalKey{Value: strconv.Itoa(key.Traktor())}
+	}
+
+	// Traktor keeps a single BPM per track, so only the first tempo marker becomes the grid anchor. The anchor
+	// is not a hot cue, as hot cue 0 would take the first hot cue slot.
+	if len(track.Tempo) > 0 && track.Tempo[0].Bpm > 0 {
+		entry.Cues = append(entry.Cues, importers.TraktorCue{
+			Name:         "AutoGrid",
+			DisplayOrder: "0",
+			Type:         "4",
+			Start:        formatFloat(traktorGridStart(track.Tempo[0])*1000, 6),
+			Len:          "0",
+			Repeats:      "-1",
+			HotCue:       "-1",
+		})
+	}
+
+	for i, cue := range track.CuePoints {
+		entry.Cues = append(entry.Cues, toTraktorCue(cue, i+1))
+	}
+
+	return entry
+}
+
+// toTraktorCue converts a CuePoint model to a Traktor cue. Traktor has no cue colours, so the colour is dropped.
+func toTraktorCue(cue models.CuePoint, displayOrder int) importers.TraktorCue {
+	traktorCue := importers.TraktorCue{
+		Name:         cue.Name,
+		DisplayOrder: strconv.Itoa(displayOrder),
+		Type:         strconv.Itoa(cue.Type),
+		Start:        formatFloat(cue.Start*1000, 6),
+		Len:          "0",
+		Repeats:      "-1",
+		HotCue:       strconv.Itoa(cue.HotCue),
+	}
+	if traktorCue.Name == "" {
+		traktorCue.Name = "n.n."
+	}
+
+	// Loops are type 5 in Traktor, where type 4 is the grid marker
+	if cue.Type == models.CueTypeLoop {
+		traktorCue.Type = "5"
+		if cue.End != nil && *cue.End > cue.Start {
+			traktorCue.Len = formatFloat((*cue.End-cue.Start)*1000, 6)
+		}
+	}
+	return traktorCue
+}
+
+// traktorGridStart returns the position in seconds of the first downbeat at or after a tempo marker.
+// Rekordbox markers may start on any beat of the bar while Traktor grid markers sit on a downbeat.
+func traktorGridStart(tempo models.Tempo) float64 {
+	if tempo.Battito <= 1 {
+		return tempo.Inizio
+	}
+	beatsToDownbeat := (4 - (tempo.Battito - 1)) % 4
+	return tempo.Inizio + float64(beatsToDownbeat)*60/tempo.Bpm
+}
+
+// traktorLocationFromURL converts a file://localhost URL to a Traktor LOCATION element
+func traktorLocationFromURL(location string) importers.TraktorLocation {
+	path := strings.TrimPrefix(location, "file://localhost")
+	if decoded, err := url.PathUnescape(path); err == nil {
+		path = decoded
+	}
+
+	volume := traktorSystemVolume
+	switch {
+	case len(path) > 3 && path[0] == '/' && path[2] == ':':
+		// Windows drive, e.g. /C:/Music/track.mp3
+		volume = path[1:3]
+		path = path[3:]
+	case strings.HasPrefix(path, "/Volumes/"):
+		// External macOS volume, e.g. /Volumes/USB/Music/track.mp3
+		rest := strings.TrimPrefix(path, "/Volumes/")
+		name, remainder, _ := strings.Cut(rest, "/")
+		volume = name
+		path = "/" + remainder
+	}
+
+	dir, file := "/", path
+	if i := strings.LastIndex(path, "/"); i >= 0 {
+		dir, file = path[:i+1], path[i+1:]
+	}
+
+	return importers.TraktorLocation{
+		Dir:    strings.ReplaceAll(dir, "/", "/:"),
+		File:   file,
+		Volume: volume,
+	}
+}
+
+// toTraktorNode recursively converts a playlist or folder to a Traktor NODE
+func toTraktorNode(node importers.Node, keys map[string]string) importers.TraktorNode {
+	if node.Type == importers.NodeTypeFolder {
+		traktorNode := importers.TraktorNode{
+			Type: "FOLDER",
+			Name: node.Name,
+			Subnodes: &importers.TraktorSubnodes{
+				Count: strconv.Itoa(len(node.Children)),
+			},
+		}
+		for _, child := range node.Children {
+			traktorNode.Subnodes.Nodes = append(traktorNode.Subnodes.Nodes, toTraktorNode(child, keys))
+		}
+		return traktorNode
+	}
+
+	playlist := &importers.TraktorPlaylist{
+		Type: "LIST",
+		UUID: newTraktorUUID(),
+	}
+	for _, key := range node.TrackKeys {
+		primaryKey, ok := keys[key]
+		if !ok {
+			continue
+		}
+		playlist.Tracks = append(playlist.Tracks, importers.TraktorPlaylistEntry{
+			PrimaryKey: importers.TraktorPrimaryKey{Type: "TRACK", Key: primaryKey},
+		})
+	}
+	playlist.Entries = strconv.Itoa(len(playlist.Tracks))
+
+	return importers.TraktorNode{
+		Type:     "PLAYLIST",
+		Name:     node.Name,
+		Playlist: playlist,
+	}
+}
+
+// newTraktorUUID generates the 32 hex digit playlist identifier Traktor expects
+func newTraktorUUID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
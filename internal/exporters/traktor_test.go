@@ -0,0 +1,64 @@
+package exporters
+
+import (
+	"testing"
+
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestTraktorLocationFromURL(t *testing.T) {
+	tests := []struct {
+		location string
+		want     importers.TraktorLocation
+	}{
+		{"file://localhost/Users/dj/Music/Opening%20Track.mp3", importers.TraktorLocation{Dir: "/:Users/:dj/:Music/:", File: "Opening Track.mp3", Volume: "Macintosh HD"}},
+		{"file://localhost/Volumes/USB/Sets/b.mp3", importers.TraktorLocation{Dir: "/:Sets/:", File: "b.mp3", Volume: "USB"}},
+		{"file://localhost/C:/Music/d.wav", importers.TraktorLocation{Dir: "/:Music/:", File: "d.wav", Volume: "C:"}},
+		{"file://localhost/e.flac", importers.TraktorLocation{Dir: "/:", File: "e.flac", Volume: "Macintosh HD"}},
+	}
+	for _, tt := range tests {
+		if got := traktorLocationFromURL(tt.location); got != tt.want {
+			t.Errorf("traktorLocationFromURL(%q) = %+v, want %+v", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestTraktorGridStart(t *testing.T) {
+	tests := []struct {
+		tempo models.Tempo
+		want  float64
+	}{
+		{models.Tempo{Inizio: 0.5, Bpm: 120, Battito: 1}, 0.5},
+		{models.Tempo{Inizio: 0.5, Bpm: 120, Battito: 0}, 0.5},
+		{models.Tempo{Inizio: 0.5, Bpm: 120, Battito: 2}, 2},
+		{models.Tempo{Inizio: 0.5, Bpm: 120, Battito: 4}, 1},
+	}
+	for _, tt := range tests {
+		if got := traktorGridStart(tt.tempo); got != tt.want {
+			t.Errorf("traktorGridStart(%+v) = %v, want %v", tt.tempo, got, tt.want)
+		}
+	}
+}
+
+func TestToTraktorCue(t *testing.T) {
+	tests := []struct {
+		name string
+		cue  models.CuePoint
+		want importers.TraktorCue
+	}{
+		{"unnamed memory cue", models.CuePoint{Start: 1.5, HotCue: -1},
+			importers.TraktorCue{Name: "n.n.", DisplayOrder: "1", Type: "0", Start: "1500.000000", Len: "0", Repeats: "-1", HotCue: "-1"}},
+		{"loop", models.CuePoint{Type: models.CueTypeLoop, Start: 8, End: floatPtr(16), HotCue: 2, Name: "Break"},
+			importers.TraktorCue{Name: "Break", DisplayOrder: "1", Type: "5", Start: "8000.000000", Len: "8000.000000", Repeats: "-1", HotCue: "2"}},
+		{"loop without end", models.CuePoint{Type: models.CueTypeLoop, Start: 8, HotCue: -1},
+			importers.TraktorCue{Name: "n.n.", DisplayOrder: "1", Type: "5", Start: "8000.000000", Len: "0", Repeats: "-1", HotCue: "-1"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := toTraktorCue(tt.cue, 1); got != tt.want {
+				t.Errorf("toTraktorCue = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
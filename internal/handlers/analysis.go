@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// AudioAnalysisHandler handles HTTP requests for what is computed from the audio of tracks: waveform
+// overviews, and tempo and key analyses
+type AudioAnalysisHandler struct {
+	waveforms *services.WaveformService
+	analyses  *services.AnalysisService
+}
+
+// NewAudioAnalysisHandler creates a new AudioAnalysisHandler
+func NewAudioAnalysisHandler(waveforms *services.WaveformService, analyses *services.AnalysisService) *AudioAnalysisHandler {
+	return &AudioAnalysisHandler{
+		waveforms: waveforms,
+		analyses:  analyses,
+	}
+}
+
+// AnalyzeRequest represents the request for analysing the tempo and key of tracks. Without overwrite only
+// the tempo, key and beatgrid the tracks lack are filled in.
+type AnalyzeRequest struct {
+	Overwrite bool `json:"overwrite"`
+}
+
+// bindAnalyzeRequest reads the optional body of an analysis request
+func bindAnalyzeRequest(c *gin.Context) (AnalyzeRequest, bool) {
+	var req AnalyzeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return req, false
+		}
+	}
+	return req, true
+}
+
+// AnalyzeTrack handles queueing the tempo and key analysis of a track.
+// The analysis runs in the background; the response carries the job to poll at GET /api/analysis/:id.
+func (h *AudioAnalysisHandler) AnalyzeTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get track ID from URL
+	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	req, ok := bindAnalyzeRequest(c)
+	if !ok {
+		return
+	}
+
+	// Queue the analysis
+	job, err := h.analyses.EnqueueTrack(c.Request.Context(), userID.(uint), uint(trackID), req.Overwrite)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue analysis: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.ToAnalysisJobResponse(*job))
+}
+
+// AnalyzeLibrary handles queueing the tempo and key analysis of the tracks of a library that lack them,
+// or of all its tracks with overwrite
+func (h *AudioAnalysisHandler) AnalyzeLibrary(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	req, ok := bindAnalyzeRequest(c)
+	if !ok {
+		return
+	}
+
+	// Queue the analyses
+	queued, err := h.analyses.EnqueueLibrary(c.Request.Context(), userID.(uint), uint(libraryID), req.Overwrite)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue analysis: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"queued": queued})
+}
+
+// GetAnalysisJob returns the state and results of a tempo and key analysis
+func (h *AudioAnalysisHandler) GetAnalysisJob(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get job ID from URL
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid analysis ID"})
+		return
+	}
+
+	// Get job
+	job, err := h.analyses.GetJob(c.Request.Context(), userID.(uint), uint(jobID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Analysis not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToAnalysisJobResponse(*job))
+}
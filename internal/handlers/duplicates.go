@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// MergeDuplicatesRequest represents the request for merging duplicate tracks into a keeper.
+// With delete_duplicates set the duplicates are deleted once their playlist entries point at the keeper.
+type MergeDuplicatesRequest struct {
+	KeeperID         uint   `json:"keeper_id" binding:"required"`
+	TrackIDs         []uint `json:"track_ids" binding:"required"`
+	DeleteDuplicates bool   `json:"delete_duplicates"`
+}
+
+// GetDuplicates handles listing groups of likely duplicate tracks in the user's libraries.
+// Optional query parameters: library_id to search one library, duration_tolerance in seconds and
+// min_confidence between 0 and 1.
+func (h *MusicLibraryHandler) GetDuplicates(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	fields, ok := trackFieldsParam(c)
+	if !ok {
+		return
+	}
+
+	var libraryID *uint
+	if raw := c.Query("library_id"); raw != "" {
+		id, err := strconv.ParseUint(raw, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+			return
+		}
+		value := uint(id)
+		libraryID = &value
+	}
+
+	tolerance := services.DefaultDuplicateDurationTolerance
+	if param, err := queryInt(c, "duration_tolerance"); err != nil || (param != nil && (*param < 0 || *param > services.MaxDuplicateDurationTolerance)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("duration_tolerance must be between 0 and %d seconds", services.MaxDuplicateDurationTolerance)})
+		return
+	} else if param != nil {
+		tolerance = *param
+	}
+
+	var minConfidence float64
+	if param, err := queryFloat(c, "min_confidence"); err != nil || (param != nil && (*param < 0 || *param > 1)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "min_confidence must be between 0 and 1"})
+		return
+	} else if param != nil {
+		minConfidence = *param
+	}
+
+	// Find the duplicates
+	groups, err := h.libraryService.FindDuplicates(c.Request.Context(), userID.(uint), libraryID, tolerance, minConfidence)
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find duplicates: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toDuplicateGroupResponses(groups, fields))
+}
+
+// MergeDuplicates handles re-pointing the playlist entries of duplicate tracks to the keeper the user picked
+func (h *MusicLibraryHandler) MergeDuplicates(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req MergeDuplicatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Merge the duplicates
+	merge, err := h.libraryService.MergeDuplicates(c.Request.Context(), userID.(uint), req.KeeperID, req.TrackIDs, req.DeleteDuplicates)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrNoDuplicates), errors.Is(err, services.ErrDuplicateLibraryMismatch):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Keeper track not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to merge duplicates: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toDuplicateMergeResponse(merge))
+}
+
+// toDuplicateGroupResponses converts duplicate groups to DuplicateGroupResponse DTOs with the given track field set
+func toDuplicateGroupResponses(groups []services.DuplicateGroup, fields string) []dto.DuplicateGroupResponse {
+	responses := make([]dto.DuplicateGroupResponse, len(groups))
+	for i, group := range groups {
+		responses[i] = dto.DuplicateGroupResponse{
+			Confidence: math.Round(group.Confidence*100) / 100,
+			Reasons:    group.Reasons,
+			Tracks:     dto.ToTrackResponsesWithFields(group.Tracks, fields),
+		}
+	}
+	return responses
+}
+
+// toDuplicateMergeResponse converts a DuplicateMerge to a DuplicateMergeResponse DTO
+func toDuplicateMergeResponse(merge *services.DuplicateMerge) dto.DuplicateMergeResponse {
+	return dto.DuplicateMergeResponse{
+		KeeperID:         merge.KeeperID,
+		PlaylistsChanged: dto.NonNilIDs(merge.PlaylistsChanged),
+		EntriesRepointed: merge.EntriesRepointed,
+		EntriesRemoved:   merge.EntriesRemoved,
+		TracksDeleted:    dto.NonNilIDs(merge.TracksDeleted),
+	}
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// GetCompatibleTracks handles suggesting tracks from the user's libraries that mix harmonically with a track.
+// The bpm_tolerance query parameter sets the allowed BPM difference in percent, limit the maximum number of tracks.
+func (h *MusicLibraryHandler) GetCompatibleTracks(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get track ID from URL
+	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	fields, ok := trackFieldsParam(c)
+	if !ok {
+		return
+	}
+
+	tolerance := services.DefaultBpmTolerance
+	if param, err := queryFloat(c, "bpm_tolerance"); err != nil || (param != nil && (*param <= 0 || *param > services.MaxBpmTolerance)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bpm_tolerance must be a percentage above 0 and up to %g", services.MaxBpmTolerance)})
+		return
+	} else if param != nil {
+		tolerance = *param
+	}
+
+	limit := services.DefaultCompatibleLimit
+	if param, err := queryInt(c, "limit"); err != nil || (param != nil && (*param < 1 || *param > services.MaxCompatibleLimit)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxCompatibleLimit)})
+		return
+	} else if param != nil {
+		limit = *param
+	}
+
+	// Find the compatible tracks
+	tracks, err := h.libraryService.GetCompatibleTracks(c.Request.Context(), userID.(uint), uint(trackID), tolerance, limit)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTrackKeyUnknown):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get compatible tracks: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toCompatibleTrackResponses(tracks, fields))
+}
+
+// toCompatibleTrackResponses converts compatible tracks to CompatibleTrackResponse DTOs with the given track field set
+func toCompatibleTrackResponses(tracks []services.CompatibleTrack, fields string) []dto.CompatibleTrackResponse {
+	responses := make([]dto.CompatibleTrackResponse, len(tracks))
+	for i, compatible := range tracks {
+		responses[i] = dto.CompatibleTrackResponse{
+			TrackResponse: dto.ToTrackResponseWithFields(compatible.Track, fields),
+			Bpm:           compatible.Track.AverageBpm,
+			KeyCamelot:    compatible.Track.CamelotKey,
+			KeyOpenKey:    dto.OpenKey(compatible.Track.CamelotKey),
+			KeyRelation:   compatible.KeyRelation,
+			TempoRatio:    compatible.TempoRatio,
+			BpmOffset:     compatible.BpmOffset,
+		}
+	}
+	return responses
+}
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// HealthCheckHandler handles HTTP requests that check the files and playlists of libraries
+type HealthCheckHandler struct {
+	healthChecks *services.HealthCheckService
+}
+
+// NewHealthCheckHandler creates a new HealthCheckHandler
+func NewHealthCheckHandler(healthChecks *services.HealthCheckService) *HealthCheckHandler {
+	return &HealthCheckHandler{
+		healthChecks: healthChecks,
+	}
+}
+
+// CheckLibraryHealth handles queueing a health check of a library, which looks for missing files, size
+// mismatches, unsupported kinds, dangling playlist entries and empty playlists.
+// The check runs in the background; the response carries the check to poll at GET /api/health-checks/:id.
+func (h *HealthCheckHandler) CheckLibraryHealth(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	// Queue the check
+	check, err := h.healthChecks.Enqueue(c.Request.Context(), userID.(uint), uint(libraryID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue health check: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.ToHealthCheckResponse(*check, ""))
+}
+
+// GetLibraryHealth returns the latest health check of a library.
+// The optional type query parameter limits the issues listed; the counts always cover the whole report.
+func (h *HealthCheckHandler) GetLibraryHealth(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	// Get the latest check
+	check, err := h.healthChecks.GetLatestCheck(c.Request.Context(), userID.(uint), uint(libraryID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Health check not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToHealthCheckResponse(*check, c.Query("type")))
+}
+
+// GetHealthCheck returns the state and report of a library health check.
+// The optional type query parameter limits the issues listed; the counts always cover the whole report.
+func (h *HealthCheckHandler) GetHealthCheck(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get check ID from URL
+	checkID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid health check ID"})
+		return
+	}
+
+	// Get check
+	check, err := h.healthChecks.GetCheck(c.Request.Context(), userID.(uint), uint(checkID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Health check not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToHealthCheckResponse(*check, c.Query("type")))
+}
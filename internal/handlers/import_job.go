@@ -0,0 +1,150 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ImportJobHandler handles HTTP requests that upload library files to import or re-import
+type ImportJobHandler struct {
+	importJobs *services.ImportJobService
+}
+
+// NewImportJobHandler creates a new ImportJobHandler
+func NewImportJobHandler(importJobs *services.ImportJobService) *ImportJobHandler {
+	return &ImportJobHandler{
+		importJobs: importJobs,
+	}
+}
+
+// UploadLibraryRequest represents the JSON request for uploading or re-importing a library.
+// Multipart and raw uploads are preferred, as they avoid the base64 overhead.
+type UploadLibraryRequest struct {
+	Name     string `json:"name"`                         // Required when uploading a new library
+	FileData string `json:"file_data" binding:"required"` // Base64 encoded library file
+	Source   string `json:"source"`                       // Optional: e.g. "rekordbox" or "traktor", detected when empty
+}
+
+// UploadLibrary handles the upload of a music library export.
+// The import runs in the background; the response carries the job ID to poll at GET /api/imports/:id.
+func (h *ImportJobHandler) UploadLibrary(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Stage the uploaded file
+	upload, err := h.readLibraryUpload(c)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Validate library name
+	if upload.Name == "" {
+		h.importJobs.Discard(upload.File)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
+		return
+	}
+
+	// Queue the import
+	job, err := h.importJobs.Enqueue(c.Request.Context(), userID.(uint), upload.Name, upload.Source, upload.File)
+	if err != nil {
+		status := uploadErrorStatus(err)
+		if status == http.StatusInternalServerError {
+			c.JSON(status, gin.H{"error": "Failed to upload library: " + err.Error()})
+			return
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Library import queued", "job_id": job.ID})
+}
+
+// GetImportJob returns the state and progress of a library import
+func (h *ImportJobHandler) GetImportJob(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get job ID from URL
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	// Get job
+	job, err := h.importJobs.GetJob(c.Request.Context(), userID.(uint), uint(jobID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToImportJobResponse(*job))
+}
+
+// ReimportLibrary applies a new export of a library to an existing library and returns a summary of the changes
+func (h *ImportJobHandler) ReimportLibrary(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	// Stage the uploaded file
+	upload, err := h.readLibraryUpload(c)
+	if err != nil {
+		c.JSON(uploadErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// Re-import the library
+	summary, err := h.importJobs.Reimport(c.Request.Context(), userID.(uint), uint(libraryID), upload.Source, upload.File)
+	if err != nil {
+		switch status := uploadErrorStatus(err); status {
+		case http.StatusNotFound:
+			c.JSON(status, gin.H{"error": "Library not found"})
+		case http.StatusInternalServerError:
+			c.JSON(status, gin.H{"error": "Failed to re-import library: " + err.Error()})
+		default:
+			c.JSON(status, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toImportSummaryResponse(*summary))
+}
+
+// toImportSummaryResponse converts an ImportSummary to an ImportSummaryResponse DTO
+func toImportSummaryResponse(summary services.ImportSummary) dto.ImportSummaryResponse {
+	return dto.ImportSummaryResponse{
+		LibraryID:        summary.LibraryID,
+		TracksAdded:      dto.NonNilIDs(summary.TracksAdded),
+		TracksChanged:    dto.NonNilIDs(summary.TracksChanged),
+		TracksRemoved:    dto.NonNilIDs(summary.TracksRemoved),
+		TracksUnchanged:  summary.TracksUnchanged,
+		PlaylistsAdded:   dto.NonNilIDs(summary.PlaylistsAdded),
+		PlaylistsChanged: dto.NonNilIDs(summary.PlaylistsChanged),
+		PlaylistsRemoved: dto.NonNilIDs(summary.PlaylistsRemoved),
+		Warnings:         summary.Warnings,
+	}
+}
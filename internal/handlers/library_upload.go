@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// maxUploadFieldSize limits the size of the text fields of a multipart library upload
+const maxUploadFieldSize = 1024
+
+// maxUploadJSONOverhead is the room for the fields besides the file data of a JSON library upload
+const maxUploadJSONOverhead = 4 * maxUploadFieldSize
+
+// errInvalidUpload reports a malformed library upload request
+var errInvalidUpload = errors.New("invalid upload")
+
+// libraryUpload is a library file received in one of the supported request formats
+type libraryUpload struct {
+	Name   string
+	Source string
+	File   *services.StagedUpload
+}
+
+// readLibraryUpload stages the library file sent with a request. Three request formats are accepted:
+//   - multipart/form-data with a "file" part and optional "name" and "source" fields
+//   - a raw body, e.g. application/xml, application/gzip or application/zip, with "name" and "source" query parameters
+//   - JSON with the base64 encoded file in "file_data", see UploadLibraryRequest
+//
+// The file is streamed to disk as it arrives; gzip and zip compressed files are decompressed when imported.
+func (h *ImportJobHandler) readLibraryUpload(c *gin.Context) (*libraryUpload, error) {
+	switch c.ContentType() {
+	case "multipart/form-data":
+		return h.readMultipartUpload(c)
+
+	case "application/json":
+		// The request is decoded in memory, so it may not be larger than the largest file base64 encoded
+		limit := int64(base64.StdEncoding.EncodedLen(int(h.importJobs.MaxUploadSize()))) + maxUploadJSONOverhead
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+		var req UploadLibraryRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				return nil, services.ErrLibraryTooLarge
+			}
+			return nil, fmt.Errorf("%w: invalid request format", errInvalidUpload)
+		}
+		file, err := h.importJobs.Stage(base64.NewDecoder(base64.StdEncoding, strings.NewReader(req.FileData)))
+		if err != nil {
+			var corrupt base64.CorruptInputError
+			if errors.As(err, &corrupt) {
+				return nil, fmt.Errorf("%w: invalid file data encoding", errInvalidUpload)
+			}
+			return nil, err
+		}
+		return &libraryUpload{Name: req.Name, Source: req.Source, File: file}, nil
+
+	default:
+		file, err := h.importJobs.Stage(c.Request.Body)
+		if err != nil {
+			return nil, err
+		}
+		return &libraryUpload{Name: c.Query("name"), Source: c.Query("source"), File: file}, nil
+	}
+}
+
+// readMultipartUpload stages the "file" part of a multipart/form-data upload without buffering the form in memory
+func (h *ImportJobHandler) readMultipartUpload(c *gin.Context) (*libraryUpload, error) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidUpload, err)
+	}
+
+	upload := &libraryUpload{}
+	fail := func(err error) (*libraryUpload, error) {
+		if upload.File != nil {
+			h.importJobs.Discard(upload.File)
+		}
+		return nil, err
+	}
+
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fail(fmt.Errorf("%w: %v", errInvalidUpload, err))
+		}
+
+		switch part.FormName() {
+		case "name", "source":
+			value, err := io.ReadAll(io.LimitReader(part, maxUploadFieldSize))
+			if err != nil {
+				return fail(fmt.Errorf("%w: %v", errInvalidUpload, err))
+			}
+			if part.FormName() == "name" {
+				upload.Name = string(value)
+			} else {
+				upload.Source = string(value)
+			}
+
+		case "file":
+			if upload.File != nil {
+				return fail(fmt.Errorf("%w: only one file can be uploaded", errInvalidUpload))
+			}
+			if upload.File, err = h.importJobs.Stage(part); err != nil {
+				return fail(err)
+			}
+		}
+		part.Close()
+	}
+
+	if upload.File == nil {
+		return nil, fmt.Errorf("%w: missing file", errInvalidUpload)
+	}
+	return upload, nil
+}
+
+// uploadErrorStatus maps errors of library uploads and imports to HTTP status codes
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, services.ErrLibraryTooLarge):
+		return http.StatusRequestEntityTooLarge
+	case errors.Is(err, errInvalidUpload),
+		errors.Is(err, services.ErrInvalidLibraryFile),
+		errors.Is(err, services.ErrUnsupportedLibrarySource):
+		return http.StatusBadRequest
+	case errors.Is(err, apperrors.ErrNotFound):
+		return http.StatusNotFound
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -1,91 +1,32 @@
 package handlers
 
 import (
-	"encoding/base64"
-	"fmt"
-	"io"
+	"bytes"
+	"errors"
+	"mime"
 	"net/http"
-	"os"
 	"strconv"
-	"strings"
 
 	"github.com/dinis/musync/internal/database"
 	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
 	"github.com/dinis/musync/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
-// MusicLibraryHandler handles HTTP requests related to music libraries
+// MusicLibraryHandler handles HTTP requests related to the libraries, playlists and tracks of a user
 type MusicLibraryHandler struct {
 	libraryService *services.MusicLibraryService
-	fileService    *services.FileStorageService
 }
 
 // NewMusicLibraryHandler creates a new MusicLibraryHandler
-func NewMusicLibraryHandler() *MusicLibraryHandler {
-	libraryService := services.NewMusicLibraryService(database.GlobalDB)
-	fileService := services.NewFileStorageService(database.GlobalDB)
+func NewMusicLibraryHandler(fileService *services.FileStorageService) *MusicLibraryHandler {
+	libraryService := services.NewMusicLibraryService(database.GlobalDB, fileService)
 	return &MusicLibraryHandler{
 		libraryService: libraryService,
-		fileService:    fileService,
 	}
 }
 
-// UploadLibraryRequest represents the JSON request for uploading a library
-type UploadLibraryRequest struct {
-	Name     string `json:"name" binding:"required"`
-	FileData string `json:"file_data" binding:"required"` // Base64 encoded XML file
-}
-
-// UploadLibrary handles the upload of a music library XML file
-func (h *MusicLibraryHandler) UploadLibrary(c *gin.Context) {
-	// Get user ID from context
-	userID, exists := c.Get("user_id")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
-		return
-	}
-
-	// Parse JSON request
-	var req UploadLibraryRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request format"})
-		return
-	}
-
-	// Validate library name
-	if req.Name == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Library name is required"})
-		return
-	}
-
-	// Decode base64 file data
-	fileData, err := base64.StdEncoding.DecodeString(req.FileData)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file data encoding"})
-		return
-	}
-
-	// Check if the data appears to be XML
-	fileDataStr := string(fileData)
-	if !strings.HasPrefix(fileDataStr, "<?xml") && !strings.HasPrefix(fileDataStr, "<DJ_PLAYLISTS") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File must be an XML file"})
-		return
-	}
-
-	// Create a reader from the decoded data
-	xmlReader := strings.NewReader(fileDataStr)
-
-	// Upload the library
-	libraryID, err := h.libraryService.UploadLibrary(c.Request.Context(), userID.(uint), req.Name, xmlReader)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload library: " + err.Error()})
-		return
-	}
-
-	c.JSON(http.StatusCreated, gin.H{"message": "Library uploaded successfully", "library_id": libraryID})
-}
-
 // GetLibraries returns all music libraries for the authenticated user
 func (h *MusicLibraryHandler) GetLibraries(c *gin.Context) {
 	// Get user ID from context
@@ -135,7 +76,7 @@ func (h *MusicLibraryHandler) GetLibrary(c *gin.Context) {
 	c.JSON(http.StatusOK, libraryResponse)
 }
 
-// GetTracks returns all tracks in a library
+// GetTracks returns a page of the tracks in a library
 func (h *MusicLibraryHandler) GetTracks(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("user_id")
@@ -151,18 +92,67 @@ func (h *MusicLibraryHandler) GetTracks(c *gin.Context) {
 		return
 	}
 
+	// Get the requested field set, filters, sorting and page
+	fields, ok := trackFieldsParam(c)
+	if !ok {
+		return
+	}
+	query, err := parseTrackQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get tracks
-	tracks, err := h.libraryService.GetTracks(c.Request.Context(), userID.(uint), uint(libraryID))
+	tracks, total, err := h.libraryService.GetTracks(c.Request.Context(), userID.(uint), uint(libraryID), query)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		writeTrackListError(c, err, "Library not found")
 		return
 	}
 
 	// Convert to response DTOs
-	trackResponses := dto.ToTrackResponses(tracks)
+	trackResponses := dto.ToTrackResponsesWithFields(tracks, fields)
+	c.Header(totalCountHeader, strconv.FormatInt(total, 10))
 	c.JSON(http.StatusOK, trackResponses)
 }
 
+// GetTrack returns a single track with all its metadata, beatgrid, cue points and playlists
+func (h *MusicLibraryHandler) GetTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get track ID from URL
+	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	// Get the track
+	track, err := h.libraryService.GetTrack(c.Request.Context(), userID.(uint), uint(trackID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get track: " + err.Error()})
+		return
+	}
+
+	// Get the playlists the track appears in
+	playlists, err := h.libraryService.GetTrackPlaylists(c.Request.Context(), track)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get track playlists: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToTrackDetailResponse(*track, toTrackPlaylistResponses(playlists)))
+}
+
 // GetPlaylists returns all playlists in a library
 func (h *MusicLibraryHandler) GetPlaylists(c *gin.Context) {
 	// Get user ID from context
@@ -191,7 +181,7 @@ func (h *MusicLibraryHandler) GetPlaylists(c *gin.Context) {
 	c.JSON(http.StatusOK, playlistResponses)
 }
 
-// GetPlaylistTracks returns all tracks in a playlist
+// GetPlaylistTracks returns a page of the tracks in a playlist
 func (h *MusicLibraryHandler) GetPlaylistTracks(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("user_id")
@@ -207,19 +197,30 @@ func (h *MusicLibraryHandler) GetPlaylistTracks(c *gin.Context) {
 		return
 	}
 
-	// Get tracks
-	tracks, err := h.libraryService.GetPlaylistTracks(c.Request.Context(), userID.(uint), uint(playlistID))
+	// Get the requested field set, filters, sorting and page
+	fields, ok := trackFieldsParam(c)
+	if !ok {
+		return
+	}
+	query, err := parseTrackQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Get tracks in playlist order
+	entries, total, err := h.libraryService.GetPlaylistEntries(c.Request.Context(), userID.(uint), uint(playlistID), query)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist not found"})
+		writeTrackListError(c, err, "Playlist not found")
 		return
 	}
 
 	// Convert to response DTOs
-	trackResponses := dto.ToTrackResponses(tracks)
-	c.JSON(http.StatusOK, trackResponses)
+	c.Header(totalCountHeader, strconv.FormatInt(total, 10))
+	c.JSON(http.StatusOK, toPlaylistTrackResponses(entries, fields))
 }
 
-// GetFolderTracks returns all tracks in all playlists within a folder (recursively)
+// GetFolderTracks returns a page of the tracks in all playlists within a folder (recursively)
 func (h *MusicLibraryHandler) GetFolderTracks(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("user_id")
@@ -235,24 +236,32 @@ func (h *MusicLibraryHandler) GetFolderTracks(c *gin.Context) {
 		return
 	}
 
+	// Get the requested field set, filters, sorting and page
+	fields, ok := trackFieldsParam(c)
+	if !ok {
+		return
+	}
+	query, err := parseTrackQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Get tracks
-	tracks, err := h.libraryService.GetFolderTracks(c.Request.Context(), userID.(uint), uint(folderID))
+	tracks, total, err := h.libraryService.GetFolderTracks(c.Request.Context(), userID.(uint), uint(folderID), query)
 	if err != nil {
-		if err.Error() == "specified ID is not a folder" {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
-			return
-		}
-		c.JSON(http.StatusNotFound, gin.H{"error": "Folder not found"})
+		writeTrackListError(c, err, "Folder not found")
 		return
 	}
 
 	// Convert to response DTOs
-	trackResponses := dto.ToTrackResponses(tracks)
+	trackResponses := dto.ToTrackResponsesWithFields(tracks, fields)
+	c.Header(totalCountHeader, strconv.FormatInt(total, 10))
 	c.JSON(http.StatusOK, trackResponses)
 }
 
-// StreamTrack streams a track's audio file or returns track information for local files
-func (h *MusicLibraryHandler) StreamTrack(c *gin.Context) {
+// GetLibraryVersions returns the version history of a music library
+func (h *MusicLibraryHandler) GetLibraryVersions(c *gin.Context) {
 	// Get user ID from context
 	userID, exists := c.Get("user_id")
 	if !exists {
@@ -260,95 +269,141 @@ func (h *MusicLibraryHandler) StreamTrack(c *gin.Context) {
 		return
 	}
 
-	// Get track ID from URL
-	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
 		return
 	}
 
-	// Stream the file through the backend
-	// This ensures compatibility with web browsers that can't access local files directly
-	fileStream, contentType, err := h.fileService.GetFileStream(c.Request.Context(), userID.(uint), uint(trackID))
+	// Get versions
+	snapshots, err := h.libraryService.ListSnapshots(c.Request.Context(), userID.(uint), uint(libraryID))
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "Failed to get track: " + err.Error()})
+		c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
 		return
 	}
-	defer fileStream.Close()
 
-	// Set content type header
-	c.Header("Content-Type", contentType)
+	c.JSON(http.StatusOK, dto.ToLibrarySnapshotResponses(snapshots))
+}
 
-	// Add headers to enable seeking and other audio controls
-	c.Header("Accept-Ranges", "bytes")
-	c.Header("Cache-Control", "no-cache")
-	c.Header("Access-Control-Allow-Origin", "*")
-	c.Header("Access-Control-Allow-Methods", "GET, OPTIONS")
-	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+// DiffLibraryVersions compares two versions of a music library (e.g. ?from=1&to=3)
+func (h *MusicLibraryHandler) DiffLibraryVersions(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-	// Check if this is a range request
-	rangeHeader := c.Request.Header.Get("Range")
-	if rangeHeader != "" {
-		// Parse the range header
-		rangeStr := strings.Replace(rangeHeader, "bytes=", "", 1)
-		rangeParts := strings.Split(rangeStr, "-")
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
 
-		// Get the start position
-		startPos, err := strconv.ParseInt(rangeParts[0], 10, 64)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid range header"})
-			return
-		}
+	// Get the versions to compare from the query
+	fromVersion, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from version"})
+		return
+	}
+	toVersion, err := strconv.Atoi(c.Query("to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to version"})
+		return
+	}
 
-		// Get file size
-		fileInfo, err := fileStream.(interface{ Stat() (os.FileInfo, error) }).Stat()
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get file info"})
-			return
-		}
-		fileSize := fileInfo.Size()
-
-		// Calculate end position
-		endPos := fileSize - 1
-		if len(rangeParts) > 1 && rangeParts[1] != "" {
-			endPos, err = strconv.ParseInt(rangeParts[1], 10, 64)
-			if err != nil {
-				endPos = fileSize - 1
-			}
+	// Compare the versions
+	diff, err := h.libraryService.DiffSnapshots(c.Request.Context(), userID.(uint), uint(libraryID), fromVersion, toVersion)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSnapshotNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare library versions: " + err.Error()})
 		}
+		return
+	}
 
-		// Ensure endPos is not greater than fileSize
-		if endPos >= fileSize {
-			endPos = fileSize - 1
-		}
+	c.JSON(http.StatusOK, toSnapshotDiffResponse(*diff))
+}
 
-		// Calculate content length
-		contentLength := endPos - startPos + 1
+// RollbackLibrary restores a music library to an earlier version
+func (h *MusicLibraryHandler) RollbackLibrary(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
 
-		// Seek to the requested position
-		_, err = fileStream.Seek(startPos, io.SeekStart)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to seek to position"})
-			return
+	// Get library ID and version from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+	version, err := strconv.Atoi(c.Param("version"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	// Roll the library back
+	summary, err := h.libraryService.RollbackLibrary(c.Request.Context(), userID.(uint), uint(libraryID), version)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrSnapshotNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back library: " + err.Error()})
 		}
+		return
+	}
 
-		// Set headers for partial content
-		c.Header("Content-Range", fmt.Sprintf("bytes %d-%d/%d", startPos, endPos, fileSize))
-		c.Header("Content-Length", fmt.Sprintf("%d", contentLength))
-		c.Status(http.StatusPartialContent)
-	} else {
-		// Full content request
-		c.Status(http.StatusOK)
+	c.JSON(http.StatusOK, toImportSummaryResponse(*summary))
+}
+
+// ExportLibrary serializes a music library into a DJ software format (e.g. ?format=rekordbox or ?format=traktor)
+func (h *MusicLibraryHandler) ExportLibrary(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
 	}
 
-	// Stream the file
-	_, err = io.Copy(c.Writer, fileStream)
+	format := c.DefaultQuery("format", "rekordbox")
+
+	// Export into a buffer so that errors can still be reported as JSON
+	var buf bytes.Buffer
+	export, err := h.libraryService.ExportLibrary(c.Request.Context(), userID.(uint), uint(libraryID), format, &buf)
 	if err != nil {
-		// The response has already started, so we can't return a JSON error
-		// Just log the error and return
-		c.Status(http.StatusInternalServerError)
+		switch {
+		case errors.Is(err, services.ErrUnsupportedExportFormat):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to export library: " + err.Error()})
+		}
 		return
 	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": export.FileName}))
+	c.Data(http.StatusOK, export.ContentType, buf.Bytes())
 }
 
 // DeleteLibrary handles the deletion of a music library and all its associated resources
@@ -376,3 +431,68 @@ func (h *MusicLibraryHandler) DeleteLibrary(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Library deleted successfully"})
 }
+
+// toSnapshotDiffResponse converts a SnapshotDiff to a SnapshotDiffResponse DTO
+func toSnapshotDiffResponse(diff services.SnapshotDiff) dto.SnapshotDiffResponse {
+	response := dto.SnapshotDiffResponse{
+		LibraryID:        diff.LibraryID,
+		FromVersion:      diff.FromVersion,
+		ToVersion:        diff.ToVersion,
+		TracksAdded:      toSnapshotTrackResponses(diff.TracksAdded),
+		TracksRemoved:    toSnapshotTrackResponses(diff.TracksRemoved),
+		TracksChanged:    make([]dto.TrackChangeResponse, len(diff.TracksChanged)),
+		PlaylistsAdded:   dto.NonNilStrings(diff.PlaylistsAdded),
+		PlaylistsRemoved: dto.NonNilStrings(diff.PlaylistsRemoved),
+		PlaylistsChanged: make([]dto.PlaylistChangeResponse, len(diff.PlaylistsChanged)),
+	}
+
+	for i, change := range diff.TracksChanged {
+		fields := make([]dto.FieldChangeResponse, len(change.Fields))
+		for j, field := range change.Fields {
+			fields[j] = dto.FieldChangeResponse{Field: field.Field, From: field.From, To: field.To}
+		}
+		response.TracksChanged[i] = dto.TrackChangeResponse{
+			SnapshotTrackResponse: toSnapshotTrackResponse(change.SnapshotTrack),
+			Fields:                fields,
+		}
+	}
+
+	for i, change := range diff.PlaylistsChanged {
+		response.PlaylistsChanged[i] = dto.PlaylistChangeResponse{
+			Path:          change.Path,
+			TracksAdded:   dto.NonNilStrings(change.TracksAdded),
+			TracksRemoved: dto.NonNilStrings(change.TracksRemoved),
+			Reordered:     change.Reordered,
+		}
+	}
+
+	return response
+}
+
+// toSnapshotTrackResponse converts a SnapshotTrack to a SnapshotTrackResponse DTO
+func toSnapshotTrackResponse(track services.SnapshotTrack) dto.SnapshotTrackResponse {
+	return dto.SnapshotTrackResponse{TrackID: track.TrackID, Title: track.Name, Artist: track.Artist}
+}
+
+// toSnapshotTrackResponses converts a slice of SnapshotTrack to a slice of SnapshotTrackResponse DTOs
+func toSnapshotTrackResponses(tracks []services.SnapshotTrack) []dto.SnapshotTrackResponse {
+	responses := make([]dto.SnapshotTrackResponse, len(tracks))
+	for i, track := range tracks {
+		responses[i] = toSnapshotTrackResponse(track)
+	}
+	return responses
+}
+
+// toTrackPlaylistResponses converts the playlists a track appears in to TrackPlaylistResponse DTOs
+func toTrackPlaylistResponses(playlists []services.TrackPlaylist) []dto.TrackPlaylistResponse {
+	responses := make([]dto.TrackPlaylistResponse, len(playlists))
+	for i, playlist := range playlists {
+		responses[i] = dto.TrackPlaylistResponse{
+			PlaylistID: playlist.PlaylistID,
+			Name:       playlist.Name,
+			Path:       playlist.Path,
+			Positions:  playlist.Positions,
+		}
+	}
+	return responses
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// PathRemapRequest represents a path remap rule: locations starting with From are read from To instead.
+// From may be a drive letter such as "C:" to remap a whole drive.
+type PathRemapRequest struct {
+	From string `json:"from" binding:"required"`
+	To   string `json:"to" binding:"required"`
+}
+
+// SetPathRemapsRequest represents the request for replacing the path remap rules of a library.
+// Rules are tried in order and the first match applies.
+type SetPathRemapsRequest struct {
+	Rules []PathRemapRequest `json:"rules" binding:"dive"`
+}
+
+// GetPathRemaps returns the path remap rules of a library
+func (h *MusicLibraryHandler) GetPathRemaps(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	// Get the rules
+	rules, err := h.libraryService.GetPathRemaps(c.Request.Context(), userID.(uint), uint(libraryID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get path remaps: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToPathRemapResponses(rules))
+}
+
+// SetPathRemaps handles replacing the path remap rules of a library, which rewrite track locations when
+// tracks are streamed and the library is exported
+func (h *MusicLibraryHandler) SetPathRemaps(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	var req SetPathRemapsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rules := make([]models.PathRemap, len(req.Rules))
+	for i, rule := range req.Rules {
+		rules[i] = models.PathRemap{From: rule.From, To: rule.To}
+	}
+
+	// Replace the rules
+	stored, err := h.libraryService.SetPathRemaps(c.Request.Context(), userID.(uint), uint(libraryID), rules)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidPathRemap):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to set path remaps: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToPathRemapResponses(stored))
+}
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// ReorderPlaylistRequest represents the request for putting the entries of a playlist in a new order
+type ReorderPlaylistRequest struct {
+	EntryIDs []uint `json:"entry_ids" binding:"required"`
+}
+
+// AddPlaylistTrackRequest represents the request for adding a track to a playlist.
+// Without a position the track is added at the end.
+type AddPlaylistTrackRequest struct {
+	TrackID  uint `json:"track_id" binding:"required"`
+	Position *int `json:"position"`
+}
+
+// MovePlaylistTrackRequest represents the request for moving a playlist entry to a new position
+type MovePlaylistTrackRequest struct {
+	Position *int `json:"position" binding:"required"`
+}
+
+// ReorderPlaylist handles putting the entries of a playlist in a new order
+func (h *MusicLibraryHandler) ReorderPlaylist(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get playlist ID from URL
+	playlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+		return
+	}
+
+	var req ReorderPlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Reorder the playlist
+	entries, err := h.libraryService.ReorderPlaylist(c.Request.Context(), userID.(uint), uint(playlistID), req.EntryIDs)
+	if err != nil {
+		writePlaylistOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toPlaylistTrackResponses(entries, dto.TrackFieldsBasic))
+}
+
+// AddPlaylistTrack handles adding a track of the playlist's library to a playlist
+func (h *MusicLibraryHandler) AddPlaylistTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get playlist ID from URL
+	playlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+		return
+	}
+
+	var req AddPlaylistTrackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Add the track
+	entries, err := h.libraryService.AddPlaylistEntry(c.Request.Context(), userID.(uint), uint(playlistID), req.TrackID, req.Position)
+	if err != nil {
+		writePlaylistOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, toPlaylistTrackResponses(entries, dto.TrackFieldsBasic))
+}
+
+// MovePlaylistTrack handles moving a playlist entry to a new position
+func (h *MusicLibraryHandler) MovePlaylistTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get playlist and entry ID from URL
+	playlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+		return
+	}
+	entryID, err := strconv.ParseUint(c.Param("entryId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	var req MovePlaylistTrackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Move the entry
+	entries, err := h.libraryService.MovePlaylistEntry(c.Request.Context(), userID.(uint), uint(playlistID), uint(entryID), *req.Position)
+	if err != nil {
+		writePlaylistOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toPlaylistTrackResponses(entries, dto.TrackFieldsBasic))
+}
+
+// RemovePlaylistTrack handles removing an entry from a playlist
+func (h *MusicLibraryHandler) RemovePlaylistTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get playlist and entry ID from URL
+	playlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+		return
+	}
+	entryID, err := strconv.ParseUint(c.Param("entryId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid entry ID"})
+		return
+	}
+
+	// Remove the entry
+	entries, err := h.libraryService.RemovePlaylistEntry(c.Request.Context(), userID.(uint), uint(playlistID), uint(entryID))
+	if err != nil {
+		writePlaylistOrderError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, toPlaylistTrackResponses(entries, dto.TrackFieldsBasic))
+}
+
+// writePlaylistOrderError writes the response for an error of a playlist order update
+func writePlaylistOrderError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrInvalidPlaylistOrder), errors.Is(err, services.ErrNotAPlaylist), errors.Is(err, services.ErrSmartPlaylistReadOnly):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, services.ErrPlaylistEntryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, apperrors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playlist or track not found"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update playlist: " + err.Error()})
+	}
+}
+
+// toPlaylistTrackResponses converts a slice of playlist entries to a slice of PlaylistTrackResponse DTOs with the given field set
+func toPlaylistTrackResponses(entries []services.PlaylistEntry, fields string) []dto.PlaylistTrackResponse {
+	responses := make([]dto.PlaylistTrackResponse, len(entries))
+	for i, entry := range entries {
+		responses[i] = dto.PlaylistTrackResponse{
+			TrackResponse: dto.ToTrackResponseWithFields(entry.Track, fields),
+			EntryID:       entry.Entry.ID,
+			Position:      entry.Entry.Position,
+		}
+	}
+	return responses
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RelocateJobHandler handles HTTP requests that check which track files of libraries are found once their
+// path remap rules are applied
+type RelocateJobHandler struct {
+	relocateJobs *services.RelocateJobService
+}
+
+// NewRelocateJobHandler creates a new RelocateJobHandler
+func NewRelocateJobHandler(relocateJobs *services.RelocateJobService) *RelocateJobHandler {
+	return &RelocateJobHandler{
+		relocateJobs: relocateJobs,
+	}
+}
+
+// RelocateLibrary handles queueing a job that applies the path remap rules of a library to its tracks and
+// checks which of the resulting locations hold a file.
+// The job runs in the background; the response carries the job to poll at GET /api/relocate-jobs/:id.
+func (h *RelocateJobHandler) RelocateLibrary(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	// Queue the job
+	job, err := h.relocateJobs.Enqueue(c.Request.Context(), userID.(uint), uint(libraryID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Library not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue relocation: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, dto.ToRelocateJobResponse(*job, ""))
+}
+
+// GetLibraryRelocation returns the latest relocate job of a library.
+// The optional status query parameter ("found", "missing" or "unreachable") limits the tracks listed;
+// the counts always cover the whole library.
+func (h *RelocateJobHandler) GetLibraryRelocation(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	status, ok := relocateStatus(c)
+	if !ok {
+		return
+	}
+
+	// Get the latest job
+	job, err := h.relocateJobs.GetLatestJob(c.Request.Context(), userID.(uint), uint(libraryID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Relocation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get relocation: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToRelocateJobResponse(*job, status))
+}
+
+// GetRelocateJob returns the state and report of a relocate job.
+// The optional status query parameter limits the tracks listed; the counts always cover the whole library.
+func (h *RelocateJobHandler) GetRelocateJob(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get job ID from URL
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid relocate job ID"})
+		return
+	}
+
+	status, ok := relocateStatus(c)
+	if !ok {
+		return
+	}
+
+	// Get job
+	job, err := h.relocateJobs.GetJob(c.Request.Context(), userID.(uint), uint(jobID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Relocate job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get relocate job: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToRelocateJobResponse(*job, status))
+}
+
+// relocateStatus returns the status query parameter of a relocate report, responding with an error if it is
+// not a relocate status
+func relocateStatus(c *gin.Context) (string, bool) {
+	status := c.Query("status")
+	switch status {
+	case "", services.RelocateFound, services.RelocateMissing, services.RelocateUnreachable:
+		return status, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be found, missing or unreachable"})
+		return "", false
+	}
+}
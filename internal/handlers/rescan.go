@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// RescanTrackRequest represents the request for rescanning a track's file. Without apply the differences
+// are only reported. Fields restricts the applied differences, all of them are applied if it is empty.
+type RescanTrackRequest struct {
+	Apply  bool     `json:"apply"`
+	Fields []string `json:"fields"`
+}
+
+// RescanTrack handles comparing a track with the tags embedded in its file, and optionally updating it from them
+func (h *MusicLibraryHandler) RescanTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get track ID from URL
+	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	// The body is optional, an empty one reports the differences
+	var req RescanTrackRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	// Rescan the track
+	rescan, err := h.libraryService.RescanTrack(c.Request.Context(), userID.(uint), uint(trackID), req.Apply, req.Fields)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrUnknownTagField):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		case errors.Is(err, services.ErrTrackFileUnavailable), errors.Is(err, services.ErrUnreadableTags):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to rescan track: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, toTrackRescanResponse(rescan))
+}
+
+// toTrackRescanResponse converts a TrackRescan to a TrackRescanResponse DTO with the full track field set
+func toTrackRescanResponse(rescan *services.TrackRescan) dto.TrackRescanResponse {
+	differences := make([]dto.TagDifferenceResponse, len(rescan.Differences))
+	for i, difference := range rescan.Differences {
+		differences[i] = dto.TagDifferenceResponse{
+			Field:        difference.Field,
+			LibraryValue: difference.LibraryValue,
+			FileValue:    difference.FileValue,
+		}
+	}
+	applied := rescan.Applied
+	if applied == nil {
+		applied = []string{}
+	}
+	return dto.TrackRescanResponse{
+		Track:       dto.ToFullTrackResponse(rescan.Track),
+		Format:      rescan.Format,
+		Differences: differences,
+		Applied:     applied,
+	}
+}
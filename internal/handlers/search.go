@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/dinis/musync/internal/dto"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// Search handles searching the tracks and playlists of all the user's libraries.
+// The q query parameter holds the search text, limit the maximum number of tracks returned.
+func (h *MusicLibraryHandler) Search(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	text := c.Query("q")
+	if text == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+		return
+	}
+
+	fields, ok := trackFieldsParam(c)
+	if !ok {
+		return
+	}
+
+	limit := services.DefaultSearchLimit
+	if param, err := queryInt(c, "limit"); err != nil || (param != nil && (*param < 1 || *param > services.MaxSearchLimit)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", services.MaxSearchLimit)})
+		return
+	} else if param != nil {
+		limit = *param
+	}
+
+	// Run the search
+	results, err := h.libraryService.Search(c.Request.Context(), userID.(uint), text, limit)
+	if err != nil {
+		if errors.Is(err, services.ErrEmptySearchQuery) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Search query is required"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, toSearchResponse(results, fields))
+}
+
+// toSearchResponse converts search results to a SearchResponse DTO with the given track field set
+func toSearchResponse(results *services.SearchResults, fields string) dto.SearchResponse {
+	response := dto.SearchResponse{
+		Tracks:    make([]dto.TrackHitResponse, len(results.Tracks)),
+		Playlists: make([]dto.PlaylistHitResponse, len(results.Playlists)),
+	}
+	for i, hit := range results.Tracks {
+		response.Tracks[i] = dto.TrackHitResponse{
+			TrackResponse: dto.ToTrackResponseWithFields(hit.Track, fields),
+			Rank:          hit.Rank,
+			Highlights:    hit.Highlights,
+		}
+	}
+	for i, hit := range results.Playlists {
+		response.Playlists[i] = dto.PlaylistHitResponse{
+			PlaylistResponse: dto.ToPlaylistResponse(hit.Playlist),
+			Rank:             hit.Rank,
+		}
+	}
+	return response
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// CreateSmartPlaylistRequest represents the request for creating a smart playlist.
+// Without a parent ID the playlist is added at the top of the library.
+type CreateSmartPlaylistRequest struct {
+	Name     string              `json:"name" binding:"required"`
+	ParentID *uint               `json:"parent_id"`
+	Rules    *services.SmartRule `json:"rules" binding:"required"`
+}
+
+// UpdateSmartPlaylistRequest represents the request for replacing the rules of a smart playlist.
+// The playlist keeps its name unless a new one is given.
+type UpdateSmartPlaylistRequest struct {
+	Name  string              `json:"name"`
+	Rules *services.SmartRule `json:"rules" binding:"required"`
+}
+
+// CreateSmartPlaylist handles creating a smart playlist in a library
+func (h *MusicLibraryHandler) CreateSmartPlaylist(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get library ID from URL
+	libraryID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid library ID"})
+		return
+	}
+
+	var req CreateSmartPlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Create the playlist
+	playlist, err := h.libraryService.CreateSmartPlaylist(c.Request.Context(), userID.(uint), uint(libraryID), req.Name, req.ParentID, *req.Rules)
+	if err != nil {
+		writeSmartPlaylistError(c, err, "Library or folder not found")
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.ToPlaylistResponse(*playlist))
+}
+
+// UpdateSmartPlaylist handles replacing the rules of a smart playlist
+func (h *MusicLibraryHandler) UpdateSmartPlaylist(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get playlist ID from URL
+	playlistID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid playlist ID"})
+		return
+	}
+
+	var req UpdateSmartPlaylistRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Update the rules
+	playlist, err := h.libraryService.UpdateSmartPlaylist(c.Request.Context(), userID.(uint), uint(playlistID), req.Name, *req.Rules)
+	if err != nil {
+		writeSmartPlaylistError(c, err, "Playlist not found")
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToPlaylistResponse(*playlist))
+}
+
+// writeSmartPlaylistError writes the response for an error of a smart playlist update
+func writeSmartPlaylistError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidSmartRules), errors.Is(err, services.ErrNotAFolder), errors.Is(err, services.ErrNotAPlaylist), errors.Is(err, services.ErrNotASmartPlaylist):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, apperrors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save smart playlist: " + err.Error()})
+	}
+}
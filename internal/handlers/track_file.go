@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/services"
+	"github.com/dinis/musync/internal/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// UploadOffsetHeader carries the offset of an upload chunk in requests, and the number of bytes received
+// in responses
+const UploadOffsetHeader = "Upload-Offset"
+
+// TrackFileHandler handles HTTP requests that stream track files and upload them to the server
+type TrackFileHandler struct {
+	fileService *services.FileStorageService
+	trackFiles  *services.TrackFileService
+}
+
+// NewTrackFileHandler creates a new TrackFileHandler
+func NewTrackFileHandler(fileService *services.FileStorageService, trackFiles *services.TrackFileService) *TrackFileHandler {
+	return &TrackFileHandler{
+		fileService: fileService,
+		trackFiles:  trackFiles,
+	}
+}
+
+// StreamTrack streams a track's audio file from whichever storage backend holds it.
+// Range requests are served as HTTP specifies them, including suffix and multiple ranges, and are
+// answered with 416 if unsatisfiable. ETag and Last-Modified allow conditional and If-Range requests.
+func (h *TrackFileHandler) StreamTrack(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get track ID from URL
+	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	// Stream the file through the backend
+	// This ensures compatibility with web browsers that can't access local files directly
+	fileStream, err := h.fileService.OpenFileStream(c.Request.Context(), userID.(uint), uint(trackID))
+	if err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		case errors.Is(err, storage.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track file not found"})
+		default:
+			logging.GetLogger().Error("Failed to open file of track %d: %v", trackID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to stream track"})
+		}
+		return
+	}
+	defer fileStream.Close()
+
+	// Set content type and validator headers
+	c.Header("Content-Type", fileStream.ContentType)
+	c.Header("ETag", fileStream.ETag)
+
+	// Revalidate on every request, the CORS middleware lets browsers send ranges and read the range headers
+	c.Header("Cache-Control", "no-cache")
+
+	// Serve the requested ranges. The file is opened at the first byte of each range, so that remote
+	// backends do not download what precedes it.
+	http.ServeContent(c.Writer, c.Request, "", fileStream.ModTime, fileStream)
+}
+
+// CreateFileUploadRequest represents the request for starting a track file upload. The file is linked to
+// the given tracks, and to the tracks whose location is its path on the client or that match its size,
+// name or hash.
+type CreateFileUploadRequest struct {
+	Name     string `json:"name" binding:"required"`
+	Path     string `json:"path"`
+	Size     int64  `json:"size" binding:"required,gt=0"`
+	SHA256   string `json:"sha256" binding:"omitempty,len=64,hexadecimal"` // Checked once the upload completes
+	TrackIDs []uint `json:"track_ids"`
+}
+
+// CreateFileUpload handles starting a resumable upload of a track file.
+// The file is then sent in chunks with PATCH /api/files/uploads/:id.
+func (h *TrackFileHandler) CreateFileUpload(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	var req CreateFileUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// Start the upload
+	upload, err := h.trackFiles.CreateUpload(c.Request.Context(), userID.(uint), services.NewFileUpload{
+		Name:     req.Name,
+		Path:     req.Path,
+		Size:     req.Size,
+		Hash:     req.SHA256,
+		TrackIDs: req.TrackIDs,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTrackFileTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start upload: " + err.Error()})
+		}
+		return
+	}
+
+	c.Header(UploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	c.JSON(http.StatusCreated, dto.ToFileUploadResponse(*upload))
+}
+
+// GetFileUpload returns the progress of a track file upload, telling the client where to resume it
+func (h *TrackFileHandler) GetFileUpload(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get upload ID from URL
+	uploadID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return
+	}
+
+	// Get upload
+	upload, err := h.trackFiles.GetUpload(c.Request.Context(), userID.(uint), uint(uploadID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return
+	}
+
+	c.Header(UploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	c.JSON(http.StatusOK, dto.ToFileUploadResponse(*upload))
+}
+
+// UploadFileChunk handles appending a chunk, sent as the raw request body, to a track file upload.
+// The Upload-Offset header must equal the number of bytes received so far. The chunk completing the file
+// stores it and links it to its tracks.
+func (h *TrackFileHandler) UploadFileChunk(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get upload ID from URL
+	uploadID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader(UploadOffsetHeader), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid " + UploadOffsetHeader + " header"})
+		return
+	}
+
+	// Append the chunk
+	upload, err := h.trackFiles.AppendChunk(c.Request.Context(), userID.(uint), uint(uploadID), offset, c.Request.Body)
+	if upload != nil {
+		c.Header(UploadOffsetHeader, strconv.FormatInt(upload.Offset, 10))
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		case errors.Is(err, services.ErrUploadOffsetMismatch), errors.Is(err, services.ErrUploadFinished):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error(), "offset": upload.Offset})
+		case errors.Is(err, services.ErrUploadExceedsSize):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+		case errors.Is(err, services.ErrUploadHashMismatch):
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload chunk: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToFileUploadResponse(*upload))
+}
+
+// CancelFileUpload handles cancelling an unfinished track file upload
+func (h *TrackFileHandler) CancelFileUpload(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get upload ID from URL
+	uploadID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid upload ID"})
+		return
+	}
+
+	// Cancel the upload
+	if err := h.trackFiles.CancelUpload(c.Request.Context(), userID.(uint), uint(uploadID)); err != nil {
+		switch {
+		case errors.Is(err, apperrors.ErrNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		case errors.Is(err, services.ErrUploadFinished):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel upload: " + err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Upload cancelled"})
+}
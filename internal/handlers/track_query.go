@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// totalCountHeader carries the number of items matching a paginated listing
+const totalCountHeader = "X-Total-Count"
+
+// trackFieldsParam reads the fields query parameter of track list endpoints, which selects the
+// basic (default) or full field set, and writes an error response if the value is unknown
+func trackFieldsParam(c *gin.Context) (string, bool) {
+	switch fields := c.DefaultQuery("fields", dto.TrackFieldsBasic); fields {
+	case dto.TrackFieldsBasic, dto.TrackFieldsFull:
+		return fields, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid fields, expected " + dto.TrackFieldsBasic + " or " + dto.TrackFieldsFull})
+		return "", false
+	}
+}
+
+// parseTrackQuery reads the filter, sort and pagination query parameters of track list endpoints:
+//
+//	genre, key              any of the given values, may be repeated or comma separated
+//	artist, label           substring match
+//	bpm_min, bpm_max        BPM range
+//	year_min, year_max      release year range
+//	rating_min, rating_max  rating range in stars, 0 to 5
+//	added_from, added_to    date added range as YYYY-MM-DD, both inclusive
+//	sort                    comma separated fields, prefixed with - for descending order
+//	limit, offset           page size and number of tracks to skip
+func parseTrackQuery(c *gin.Context) (services.TrackQuery, error) {
+	query := services.TrackQuery{
+		Genres: queryList(c, "genre"),
+		Keys:   queryList(c, "key"),
+		Artist: c.Query("artist"),
+		Label:  c.Query("label"),
+	}
+
+	var err error
+	if query.BpmMin, err = queryFloat(c, "bpm_min"); err != nil {
+		return query, err
+	}
+	if query.BpmMax, err = queryFloat(c, "bpm_max"); err != nil {
+		return query, err
+	}
+	if query.YearMin, err = queryInt(c, "year_min"); err != nil {
+		return query, err
+	}
+	if query.YearMax, err = queryInt(c, "year_max"); err != nil {
+		return query, err
+	}
+	if query.RatingMin, err = queryInt(c, "rating_min"); err != nil {
+		return query, err
+	}
+	if query.RatingMax, err = queryInt(c, "rating_max"); err != nil {
+		return query, err
+	}
+	if query.AddedFrom, err = queryDate(c, "added_from"); err != nil {
+		return query, err
+	}
+	if query.AddedTo, err = queryDate(c, "added_to"); err != nil {
+		return query, err
+	}
+	if query.AddedTo != nil {
+		// The service takes an exclusive upper bound
+		next := query.AddedTo.AddDate(0, 0, 1)
+		query.AddedTo = &next
+	}
+
+	for _, field := range queryList(c, "sort") {
+		sort := services.TrackSort{Field: field}
+		if strings.HasPrefix(field, "-") {
+			sort = services.TrackSort{Field: field[1:], Descending: true}
+		}
+		query.Sort = append(query.Sort, sort)
+	}
+
+	limit, err := queryInt(c, "limit")
+	if err != nil {
+		return query, err
+	}
+	if limit != nil {
+		if *limit < 1 || *limit > services.MaxTrackPageSize {
+			return query, fmt.Errorf("limit must be between 1 and %d", services.MaxTrackPageSize)
+		}
+		query.Limit = *limit
+	}
+	offset, err := queryInt(c, "offset")
+	if err != nil {
+		return query, err
+	}
+	if offset != nil {
+		if *offset < 0 {
+			return query, errors.New("offset must not be negative")
+		}
+		query.Offset = *offset
+	}
+
+	return query, nil
+}
+
+// writeTrackListError writes the response for an error of a track listing
+func writeTrackListError(c *gin.Context, err error, notFound string) {
+	switch {
+	case errors.Is(err, services.ErrInvalidTrackQuery), errors.Is(err, services.ErrNotAFolder), errors.Is(err, services.ErrNotAPlaylist):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, apperrors.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": notFound})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get tracks: " + err.Error()})
+	}
+}
+
+// queryList returns the values of a query parameter that may be repeated or comma separated
+func queryList(c *gin.Context, name string) []string {
+	var values []string
+	for _, param := range c.QueryArray(name) {
+		for _, value := range strings.Split(param, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// queryInt returns the value of an integer query parameter, or nil if it is not set
+func queryInt(c *gin.Context, name string) (*int, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &value, nil
+}
+
+// queryFloat returns the value of a decimal query parameter, or nil if it is not set
+func queryFloat(c *gin.Context, name string) (*float64, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := strconv.ParseFloat(raw, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s", name)
+	}
+	return &value, nil
+}
+
+// queryDate returns the value of a YYYY-MM-DD query parameter, or nil if it is not set
+func queryDate(c *gin.Context, name string) (*time.Time, error) {
+	raw := c.Query(name)
+	if raw == "" {
+		return nil, nil
+	}
+	value, err := time.Parse("2006-01-02", raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s, expected YYYY-MM-DD", name)
+	}
+	return &value, nil
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/dinis/musync/internal/dto"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/services"
+	"github.com/dinis/musync/internal/waveform"
+	"github.com/gin-gonic/gin"
+)
+
+// GetWaveform handles serving the waveform overview of a track at the resolution query parameter, in points.
+// Overviews are generated in the background: until the overview is ready, 202 Accepted is returned with its
+// state. With format=binary the overview is returned as little endian uint32 point count, duration in
+// milliseconds and sample rate, followed by the peak and RMS byte of each point.
+func (h *AudioAnalysisHandler) GetWaveform(c *gin.Context) {
+	// Get user ID from context
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+
+	// Get track ID from URL
+	trackID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid track ID"})
+		return
+	}
+
+	resolution := services.DefaultWaveformResolution
+	if param, err := queryInt(c, "resolution"); err != nil || (param != nil && (*param < waveform.MinResolution || *param > waveform.MaxResolution)) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("resolution must be between %d and %d", waveform.MinResolution, waveform.MaxResolution)})
+		return
+	} else if param != nil {
+		resolution = *param
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "binary" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or binary"})
+		return
+	}
+
+	// Get the waveform, queueing its generation if needed
+	cached, err := h.waveforms.GetWaveform(c.Request.Context(), userID.(uint), uint(trackID))
+	if err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Track not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get waveform: " + err.Error()})
+		return
+	}
+
+	switch cached.State {
+	case services.WaveformCompleted:
+	case services.WaveformFailed:
+		c.JSON(http.StatusUnprocessableEntity, dto.ToWaveformResponse(cached, nil))
+		return
+	default:
+		c.JSON(http.StatusAccepted, dto.ToWaveformResponse(cached, nil))
+		return
+	}
+
+	overview := waveformOverview(cached).Resample(resolution)
+	if format == "binary" {
+		body := make([]byte, 12, 12+2*len(overview.Peaks))
+		binary.LittleEndian.PutUint32(body[0:4], uint32(len(overview.Peaks)))
+		binary.LittleEndian.PutUint32(body[4:8], uint32(math.Round(overview.Duration*1000)))
+		binary.LittleEndian.PutUint32(body[8:12], uint32(overview.SampleRate))
+		for i := range overview.Peaks {
+			body = append(body, overview.Peaks[i], overview.RMS[i])
+		}
+		c.Data(http.StatusOK, "application/octet-stream", body)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ToWaveformResponse(cached, overview))
+}
+
+// waveformOverview returns the overview stored with a completed track waveform
+func waveformOverview(cached *models.TrackWaveform) *waveform.Overview {
+	return &waveform.Overview{
+		Format:     cached.Format,
+		SampleRate: cached.SampleRate,
+		Duration:   cached.Duration,
+		Peaks:      cached.Peaks,
+		RMS:        cached.RMS,
+	}
+}
@@ -0,0 +1,231 @@
+package importers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// Supported library sources
+const (
+	SourceRekordbox = "rekordbox"
+	SourceTraktor   = "traktor"
+	SourceSerato    = "serato"
+	SourceITunes    = "itunes"
+	SourceM3U       = "m3u"
+)
+
+// Playlist node types, matching models.Playlist.Type
+const (
+	NodeTypeFolder   = 0
+	NodeTypePlaylist = 1
+)
+
+// Importer errors
+var (
+	ErrUnknownFormat = errors.New("unknown library format")
+	ErrInvalidFile   = errors.New("invalid library file")
+)
+
+// DetectSize is the number of leading bytes handed to Importer.Detect
+const DetectSize = 4096
+
+// Importer parses a DJ software library export into a neutral Library
+type Importer interface {
+	// Name returns the source identifier stored on models.MusicLibrary.Source
+	Name() string
+	// Detect reports whether the leading bytes of a file look like this importer's format
+	Detect(header []byte) bool
+	// Parse reads a complete library export
+	Parse(r io.Reader) (*Library, error)
+}
+
+// StreamImporter is implemented by importers that can hand over tracks while they are parsed,
+// so that large collections never have to be held in memory as a whole
+type StreamImporter interface {
+	Importer
+	// Stream reads a complete library export, calling onTrack for every track in document order.
+	// The returned Library carries the metadata, playlists and warnings but no tracks.
+	// Errors returned by onTrack abort the import and are returned unchanged.
+	Stream(r io.Reader, onTrack func(track models.Track) error) (*Library, error)
+}
+
+// TrackCounter is implemented by importers whose format declares the number of tracks near the start of a file
+type TrackCounter interface {
+	// CountTracks returns the number of tracks declared in the leading bytes of a file
+	CountTracks(header []byte) (int, bool)
+}
+
+// Stream parses r with importer, using its streaming decoder when it has one.
+// Other importers parse the whole file first and then hand over their tracks one by one.
+func Stream(importer Importer, r io.Reader, onTrack func(track models.Track) error) (*Library, error) {
+	if streamer, ok := importer.(StreamImporter); ok {
+		return streamer.Stream(r, onTrack)
+	}
+
+	library, err := importer.Parse(r)
+	if err != nil {
+		if !errors.Is(err, ErrInvalidFile) {
+			err = fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+		return nil, err
+	}
+
+	tracks := library.Tracks
+	library.Tracks = nil
+	for _, track := range tracks {
+		if err := onTrack(track); err != nil {
+			return nil, err
+		}
+	}
+	return library, nil
+}
+
+// Library is the format independent result of an import
+type Library struct {
+	Source      string
+	Version     string
+	ProductName string
+	Company     string
+	Tracks      []models.Track // Track.TrackID is the source key; Track.Tempo holds the beatgrid and Track.CuePoints the cues
+	Playlists   []Node
+	Warnings    []string
+}
+
+// Node is a playlist or folder in the library's playlist tree
+type Node struct {
+	Name      string
+	Type      int      // NodeTypeFolder or NodeTypePlaylist
+	TrackKeys []string // Source TrackIDs of the playlist's entries
+	Children  []Node
+}
+
+// Warnf records a non-fatal problem found while importing
+func (l *Library) Warnf(format string, args ...interface{}) {
+	l.Warnings = append(l.Warnings, fmt.Sprintf(format, args...))
+}
+
+// checkPlaylistKeys warns about playlist entries that reference tracks missing from the collection
+func (l *Library) checkPlaylistKeys() {
+	keys := make(map[string]bool, len(l.Tracks))
+	for _, track := range l.Tracks {
+		keys[track.TrackID] = true
+	}
+	l.checkPlaylistKeysIn(keys)
+}
+
+// checkPlaylistKeysIn warns about playlist entries that reference keys missing from the given set
+func (l *Library) checkPlaylistKeysIn(keys map[string]bool) {
+	var walk func(nodes []Node)
+	walk = func(nodes []Node) {
+		for _, node := range nodes {
+			missing := 0
+			for _, key := range node.TrackKeys {
+				if !keys[key] {
+					missing++
+				}
+			}
+			if missing > 0 {
+				l.Warnf("playlist %q references %d track(s) missing from the collection", node.Name, missing)
+			}
+			walk(node.Children)
+		}
+	}
+	walk(l.Playlists)
+}
+
+// Registry holds the available importers in detection order
+type Registry struct {
+	importers []Importer
+}
+
+// NewRegistry creates a Registry with the given importers
+func NewRegistry(importers ...Importer) *Registry {
+	return &Registry{importers: importers}
+}
+
+// DefaultRegistry creates a Registry with all built-in importers
+func DefaultRegistry() *Registry {
+	return NewRegistry(
+		NewRekordboxImporter(),
+		NewTraktorImporter(),
+		NewSeratoImporter(),
+		NewITunesImporter(),
+		NewM3UImporter(),
+	)
+}
+
+// Register adds an importer to the registry, replacing any importer with the same name
+func (r *Registry) Register(importer Importer) {
+	for i, existing := range r.importers {
+		if existing.Name() == importer.Name() {
+			r.importers[i] = importer
+			return
+		}
+	}
+	r.importers = append(r.importers, importer)
+}
+
+// Get returns the importer registered under name
+func (r *Registry) Get(name string) (Importer, bool) {
+	for _, importer := range r.importers {
+		if importer.Name() == name {
+			return importer, true
+		}
+	}
+	return nil, false
+}
+
+// Detect returns the first importer that recognises the header
+func (r *Registry) Detect(header []byte) (Importer, error) {
+	if len(header) > DetectSize {
+		header = header[:DetectSize]
+	}
+	for _, importer := range r.importers {
+		if importer.Detect(header) {
+			return importer, nil
+		}
+	}
+	return nil, ErrUnknownFormat
+}
+
+// Names returns the names of all registered importers
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.importers))
+	for i, importer := range r.importers {
+		names[i] = importer.Name()
+	}
+	return names
+}
+
+// fileURL converts an absolute file system path to a file://localhost URL as used by Rekordbox
+func fileURL(path string) string {
+	path = strings.ReplaceAll(path, "\\", "/")
+	if len(path) >= 2 && path[1] == ':' {
+		// Windows drive letter paths get a leading slash, e.g. /C:/Music
+		path = "/" + path
+	}
+
+	u := url.URL{Scheme: "file", Host: "localhost", Path: path}
+	return u.String()
+}
+
+// fileKind derives a Rekordbox style file kind (e.g. "MP3 File") from a file name
+func fileKind(file string) string {
+	ext := strings.TrimPrefix(filepath.Ext(file), ".")
+	if ext == "" {
+		return ""
+	}
+	return strings.ToUpper(ext) + " File"
+}
+
+// fileTitle derives a track title from a file name by stripping its directory and extension
+func fileTitle(path string) string {
+	name := path[strings.LastIndexAny(path, `/\`)+1:]
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
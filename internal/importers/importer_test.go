@@ -0,0 +1,170 @@
+package importers
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// readFixture reads a file from testdata
+func readFixture(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// checkLibrary compares an imported library with the expected one, field by field for readable failures
+func checkLibrary(t *testing.T, got, want *Library) {
+	t.Helper()
+	if got.Source != want.Source || got.Version != want.Version || got.ProductName != want.ProductName || got.Company != want.Company {
+		t.Errorf("metadata = %q %q %q %q, want %q %q %q %q",
+			got.Source, got.Version, got.ProductName, got.Company, want.Source, want.Version, want.ProductName, want.Company)
+	}
+	if len(got.Tracks) != len(want.Tracks) {
+		t.Fatalf("got %d tracks, want %d", len(got.Tracks), len(want.Tracks))
+	}
+	for i := range want.Tracks {
+		if !reflect.DeepEqual(got.Tracks[i], want.Tracks[i]) {
+			t.Errorf("track %d = %+v,\nwant %+v", i, got.Tracks[i], want.Tracks[i])
+		}
+	}
+	if !reflect.DeepEqual(got.Playlists, want.Playlists) {
+		t.Errorf("playlists = %+v,\nwant %+v", got.Playlists, want.Playlists)
+	}
+	if !reflect.DeepEqual(got.Warnings, want.Warnings) {
+		t.Errorf("warnings = %q,\nwant %q", got.Warnings, want.Warnings)
+	}
+}
+
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+func TestRegistryDetect(t *testing.T) {
+	registry := DefaultRegistry()
+	tests := []struct {
+		name    string
+		header  []byte
+		want    string
+		wantErr error
+	}{
+		{"rekordbox", readFixture(t, "rekordbox.xml"), SourceRekordbox, nil},
+		{"traktor", readFixture(t, "traktor.nml"), SourceTraktor, nil},
+		{"itunes", readFixture(t, "itunes.xml"), SourceITunes, nil},
+		{"serato", seratoDatabase(), SourceSerato, nil},
+		{"m3u", readFixture(t, "playlist.m3u"), SourceM3U, nil},
+		{"plain m3u", []byte("/Music/a.mp3\n"), "", ErrUnknownFormat},
+		{"plist without tracks", []byte(`<?xml version="1.0"?><plist><dict></dict></plist>`), "", ErrUnknownFormat},
+		{"other xml", []byte(`<?xml version="1.0"?><html></html>`), "", ErrUnknownFormat},
+		{"empty", nil, "", ErrUnknownFormat},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			importer, err := registry.Detect(tt.header)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && importer.Name() != tt.want {
+				t.Errorf("detected %s, want %s", importer.Name(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCountTracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		importer TrackCounter
+		header   string
+		want     int
+		ok       bool
+	}{
+		{"rekordbox", NewRekordboxImporter(), string(readFixture(t, "rekordbox.xml")), 2, true},
+		{"traktor", NewTraktorImporter(), string(readFixture(t, "traktor.nml")), 2, true},
+		{"rekordbox without count", NewRekordboxImporter(), `<DJ_PLAYLISTS><COLLECTION>`, 0, false},
+		{"rekordbox negative count", NewRekordboxImporter(), `<DJ_PLAYLISTS><COLLECTION Entries="-1">`, 0, false},
+		{"traktor without collection", NewTraktorImporter(), `<NML VERSION="19"><HEAD/>`, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.importer.CountTracks([]byte(tt.header))
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("CountTracks = %d, %v, want %d, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestStreamHandsOverTracks(t *testing.T) {
+	tests := []struct {
+		name     string
+		importer Importer
+		fixture  string
+		want     []string
+	}{
+		{"streaming importer", NewRekordboxImporter(), "rekordbox.xml", []string{"1", "2"}},
+		{"streaming NML importer", NewTraktorImporter(), "traktor.nml", []string{"Macintosh HD/:Users/:dj/:Music/:Opening Track.mp3", "USB/:Sets/:elsewhere.wav"}},
+		{"parsing importer", NewITunesImporter(), "itunes.xml", []string{"9", "101"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var keys []string
+			library, err := Stream(tt.importer, strings.NewReader(string(readFixture(t, tt.fixture))), func(track models.Track) error {
+				keys = append(keys, track.TrackID)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(keys, tt.want) {
+				t.Errorf("tracks %q, want %q", keys, tt.want)
+			}
+			if library.Tracks != nil {
+				t.Errorf("library keeps %d tracks", len(library.Tracks))
+			}
+			if len(library.Playlists) == 0 {
+				t.Error("library has no playlists")
+			}
+
+			abort := errors.New("abort")
+			_, err = Stream(tt.importer, strings.NewReader(string(readFixture(t, tt.fixture))), func(models.Track) error {
+				return abort
+			})
+			if err != abort {
+				t.Errorf("err = %v, want the error of onTrack", err)
+			}
+		})
+	}
+}
+
+func TestStreamWrapsParseErrors(t *testing.T) {
+	for _, importer := range []Importer{NewRekordboxImporter(), NewTraktorImporter(), NewITunesImporter(), NewSeratoImporter()} {
+		t.Run(importer.Name(), func(t *testing.T) {
+			_, err := Stream(importer, strings.NewReader("vrsn\x00\x00\x00\x10<not a library"), func(models.Track) error { return nil })
+			if !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("err = %v, want ErrInvalidFile", err)
+			}
+		})
+	}
+}
+
+func TestFileURL(t *testing.T) {
+	tests := map[string]string{
+		"/Users/dj/Music/My Track.mp3": "file://localhost/Users/dj/Music/My%20Track.mp3",
+		`C:\Music\a#1.mp3`:             "file://localhost/C:/Music/a%231.mp3",
+		"D:/Music/b.wav":               "file://localhost/D:/Music/b.wav",
+	}
+	for path, want := range tests {
+		if got := fileURL(path); got != want {
+			t.Errorf("fileURL(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// ITunesImporter imports iTunes / Apple Music "Library.xml" property lists
+type ITunesImporter struct{}
+
+// NewITunesImporter creates a new ITunesImporter
+func NewITunesImporter() *ITunesImporter {
+	return &ITunesImporter{}
+}
+
+// Name returns the source identifier for iTunes
+func (i *ITunesImporter) Name() string {
+	return SourceITunes
+}
+
+// Detect reports whether the header starts a property list that contains a track dictionary
+func (i *ITunesImporter) Detect(header []byte) bool {
+	return xmlRootElement(header) == "plist" && bytes.Contains(header, []byte("<key>Tracks</key>"))
+}
+
+// Parse parses an iTunes library property list
+func (i *ITunesImporter) Parse(r io.Reader) (*Library, error) {
+	root, err := decodePlist(r)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+	}
+
+	dict, ok := root.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: property list root is not a dictionary", ErrInvalidFile)
+	}
+
+	library := &Library{
+		Source:      SourceITunes,
+		Version:     plistString(dict, "Application Version"),
+		ProductName: "iTunes",
+		Company:     "Apple Inc.",
+	}
+
+	// The track dictionary is keyed by track ID; sort the keys so imports are deterministic
+	tracks, _ := dict["Tracks"].(map[string]interface{})
+	trackKeys := make([]string, 0, len(tracks))
+	for key := range tracks {
+		trackKeys = append(trackKeys, key)
+	}
+	sort.Slice(trackKeys, func(a, b int) bool {
+		if len(trackKeys[a]) != len(trackKeys[b]) {
+			return len(trackKeys[a]) < len(trackKeys[b])
+		}
+		return trackKeys[a] < trackKeys[b]
+	})
+
+	for _, key := range trackKeys {
+		trackDict, ok := tracks[key].(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		track := convertITunesTrack(trackDict)
+		if track.Location == "" {
+			// Streaming-only and cloud tracks have no local file
+			library.Warnf("skipped track %s (%q) without a local file", track.TrackID, track.Name)
+			continue
+		}
+		library.Tracks = append(library.Tracks, track)
+	}
+
+	playlists, _ := dict["Playlists"].([]interface{})
+	library.Playlists = convertITunesPlaylists(playlists)
+
+	library.checkPlaylistKeys()
+	return library, nil
+}
+
+// convertITunesTrack converts an iTunes track dictionary to a Track model
+func convertITunesTrack(dict map[string]interface{}) models.Track {
+	dateAdded, _ := dict["Date Added"].(time.Time)
+
+	return models.Track{
+		TrackID:     strconv.FormatInt(plistInt(dict, "Track ID"), 10),
+		Name:        plistString(dict, "Name"),
+		Artist:      plistString(dict, "Artist"),
+		Composer:    plistString(dict, "Composer"),
+		Album:       plistString(dict, "Album"),
+		Grouping:    plistString(dict, "Grouping"),
+		Genre:       plistString(dict, "Genre"),
+		Kind:        plistString(dict, "Kind"),
+		Size:        plistInt(dict, "Size"),
+		TotalTime:   int(plistInt(dict, "Total Time") / 1000),
+		DiscNumber:  int(plistInt(dict, "Disc Number")),
+		TrackNumber: int(plistInt(dict, "Track Number")),
+		Year:        int(plistInt(dict, "Year")),
+		AverageBpm:  float64(plistInt(dict, "BPM")),
+		DateAdded:   dateAdded,
+		BitRate:     int(plistInt(dict, "Bit Rate")),
+		SampleRate:  int(plistInt(dict, "Sample Rate")),
+		Comments:    plistString(dict, "Comments"),
+		PlayCount:   int(plistInt(dict, "Play Count")),
+		Rating:      int(plistInt(dict, "Rating") * 255 / 100), // iTunes rates 0-100, Rekordbox 0-255
+		Location:    plistString(dict, "Location"),
+	}
+}
+
+// convertITunesPlaylists builds the playlist tree from iTunes' flat playlist array
+func convertITunesPlaylists(playlists []interface{}) []Node {
+	type entry struct {
+		node     Node
+		parentID string
+	}
+
+	var order []string
+	entries := make(map[string]*entry)
+	for _, value := range playlists {
+		dict, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		// Skip the master library and built-in playlists such as "Music" and "Podcasts"
+		if plistBool(dict, "Master") || dict["Distinguished Kind"] != nil || (dict["Visible"] != nil && !plistBool(dict, "Visible")) {
+			continue
+		}
+
+		node := Node{Name: plistString(dict, "Name"), Type: NodeTypePlaylist}
+		if plistBool(dict, "Folder") {
+			node.Type = NodeTypeFolder
+		} else {
+			items, _ := dict["Playlist Items"].([]interface{})
+			for _, item := range items {
+				if itemDict, ok := item.(map[string]interface{}); ok {
+					node.TrackKeys = append(node.TrackKeys, strconv.FormatInt(plistInt(itemDict, "Track ID"), 10))
+				}
+			}
+		}
+
+		id := plistString(dict, "Playlist Persistent ID")
+		order = append(order, id)
+		entries[id] = &entry{node: node, parentID: plistString(dict, "Parent Persistent ID")}
+	}
+
+	// Attach children to their parents, keeping the library's playlist order
+	var build func(id string) Node
+	build = func(id string) Node {
+		node := entries[id].node
+		for _, childID := range order {
+			if entries[childID].parentID == id {
+				node.Children = append(node.Children, build(childID))
+			}
+		}
+		return node
+	}
+
+	var roots []Node
+	for _, id := range order {
+		if _, hasParent := entries[entries[id].parentID]; !hasParent {
+			roots = append(roots, build(id))
+		}
+	}
+	return roots
+}
+
+// decodePlist decodes an XML property list into maps, slices and scalar values
+func decodePlist(r io.Reader) (interface{}, error) {
+	decoder := xml.NewDecoder(r)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local != "plist" {
+				return nil, fmt.Errorf("unexpected root element %q", start.Name.Local)
+			}
+			return decodePlistChild(decoder)
+		}
+	}
+}
+
+// decodePlistChild decodes the next value element, or returns nil at the end of the enclosing element
+func decodePlistChild(decoder *xml.Decoder) (interface{}, error) {
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			return decodePlistValue(decoder, t)
+		case xml.EndElement:
+			return nil, nil
+		}
+	}
+}
+
+// decodePlistValue decodes the value element that starts with start
+func decodePlistValue(decoder *xml.Decoder, start xml.StartElement) (interface{}, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := make(map[string]interface{})
+		for {
+			token, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := token.(type) {
+			case xml.StartElement:
+				if t.Name.Local != "key" {
+					return nil, fmt.Errorf("expected key, found %q", t.Name.Local)
+				}
+				var key string
+				if err := decoder.DecodeElement(&key, &t); err != nil {
+					return nil, err
+				}
+				value, err := decodePlistChild(decoder)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = value
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		var array []interface{}
+		for {
+			value, err := decodePlistChild(decoder)
+			if err != nil {
+				return nil, err
+			}
+			if value == nil {
+				return array, nil
+			}
+			array = append(array, value)
+		}
+	case "true", "false":
+		if err := decoder.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	}
+
+	var text string
+	if err := decoder.DecodeElement(&text, &start); err != nil {
+		return nil, err
+	}
+	text = strings.TrimSpace(text)
+
+	switch start.Name.Local {
+	case "integer":
+		return strconv.ParseInt(text, 10, 64)
+	case "real":
+		return strconv.ParseFloat(text, 64)
+	case "date":
+		return time.Parse(time.RFC3339, text)
+	default:
+		// string and data values are kept as text
+		return text, nil
+	}
+}
+
+// plistString returns the string value stored under key
+func plistString(dict map[string]interface{}, key string) string {
+	value, _ := dict[key].(string)
+	return value
+}
+
+// plistInt returns the integer value stored under key
+func plistInt(dict map[string]interface{}, key string) int64 {
+	value, _ := dict[key].(int64)
+	return value
+}
+
+// plistBool returns the boolean value stored under key
+func plistBool(dict map[string]interface{}, key string) bool {
+	value, _ := dict[key].(bool)
+	return value
+}
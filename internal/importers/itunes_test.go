@@ -0,0 +1,81 @@
+package importers
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestITunesImporterParse(t *testing.T) {
+	library, err := NewITunesImporter().Parse(bytes.NewReader(readFixture(t, "itunes.xml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkLibrary(t, library, &Library{
+		Source:      SourceITunes,
+		Version:     "12.9.5.5",
+		ProductName: "iTunes",
+		Company:     "Apple Inc.",
+		Tracks: []models.Track{
+			{
+				TrackID:   "9",
+				Name:      "Second",
+				Artist:    "Second Artist",
+				TotalTime: 180,
+				Location:  "file://localhost/Users/dj/Music/second.m4a",
+			},
+			{
+				TrackID:     "101",
+				Name:        "Opening",
+				Artist:      "First Artist",
+				Album:       "Debut",
+				Genre:       "House",
+				Kind:        "MPEG audio file",
+				Size:        8392704,
+				TotalTime:   241,
+				TrackNumber: 3,
+				Year:        2019,
+				AverageBpm:  124,
+				DateAdded:   time.Date(2023, 4, 5, 10, 0, 0, 0, time.UTC),
+				BitRate:     320,
+				SampleRate:  44100,
+				PlayCount:   12,
+				Rating:      204,
+				Location:    "file://localhost/Users/dj/Music/Opening%20Track.mp3",
+			},
+		},
+		Playlists: []Node{
+			{Name: "Sets", Type: NodeTypeFolder, Children: []Node{
+				{Name: "Friday", Type: NodeTypePlaylist, TrackKeys: []string{"9", "101", "102"}},
+			}},
+		},
+		Warnings: []string{
+			`skipped track 102 ("Streamed") without a local file`,
+			`playlist "Friday" references 1 track(s) missing from the collection`,
+		},
+	})
+}
+
+func TestITunesImporterRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"empty":          "",
+		"other root":     `<?xml version="1.0"?><NML VERSION="19"></NML>`,
+		"array root":     `<plist version="1.0"><array><string>a</string></array></plist>`,
+		"key missing":    `<plist version="1.0"><dict><string>a</string></dict></plist>`,
+		"bad integer":    `<plist version="1.0"><dict><key>Tracks</key><integer>many</integer></dict></plist>`,
+		"bad date":       `<plist version="1.0"><dict><key>Date</key><date>yesterday</date></dict></plist>`,
+		"truncated dict": `<plist version="1.0"><dict><key>Tracks</key><dict><key>1</key>`,
+	}
+	for name, document := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewITunesImporter().Parse(strings.NewReader(document)); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("err = %v, want ErrInvalidFile", err)
+			}
+		})
+	}
+}
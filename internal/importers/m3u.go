@@ -0,0 +1,126 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// defaultM3UPlaylistName is used when an M3U file carries no #PLAYLIST directive
+const defaultM3UPlaylistName = "M3U Playlist"
+
+// M3UImporter imports extended M3U / M3U8 playlists as a single-playlist library
+type M3UImporter struct{}
+
+// NewM3UImporter creates a new M3UImporter
+func NewM3UImporter() *M3UImporter {
+	return &M3UImporter{}
+}
+
+// Name returns the source identifier for M3U
+func (i *M3UImporter) Name() string {
+	return SourceM3U
+}
+
+// Detect reports whether the header starts with the #EXTM3U marker.
+// Plain M3U files have no marker and must be imported with an explicit source.
+func (i *M3UImporter) Detect(header []byte) bool {
+	header = bytes.TrimPrefix(header, []byte("\xef\xbb\xbf"))
+	return bytes.HasPrefix(header, []byte("#EXTM3U"))
+}
+
+// Parse parses an M3U playlist. Each distinct path becomes a track; the playlist keeps the file's order.
+func (i *M3UImporter) Parse(r io.Reader) (*Library, error) {
+	library := &Library{Source: SourceM3U}
+	playlist := Node{Name: defaultM3UPlaylistName, Type: NodeTypePlaylist}
+
+	seen := make(map[string]bool)
+	var info *models.Track
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if lineNumber == 1 {
+			line = strings.TrimPrefix(line, "\ufeff")
+		}
+
+		switch {
+		case line == "" || line == "#EXTM3U":
+			continue
+		case strings.HasPrefix(line, "#PLAYLIST:"):
+			playlist.Name = strings.TrimSpace(strings.TrimPrefix(line, "#PLAYLIST:"))
+			continue
+		case strings.HasPrefix(line, "#EXTINF:"):
+			info = parseExtInf(strings.TrimPrefix(line, "#EXTINF:"))
+			continue
+		case strings.HasPrefix(line, "#"):
+			// Other directives and comments are ignored
+			continue
+		}
+
+		track := models.Track{}
+		if info != nil {
+			track = *info
+			info = nil
+		}
+
+		key := strings.ReplaceAll(line, `\`, "/")
+		playlist.TrackKeys = append(playlist.TrackKeys, key)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		track.TrackID = key
+		track.Kind = fileKind(line)
+		if track.Name == "" {
+			track.Name = fileTitle(line)
+		}
+
+		switch {
+		case strings.Contains(line, "://"):
+			track.Location = line
+		case strings.HasPrefix(key, "/") || (len(key) >= 2 && key[1] == ':'):
+			track.Location = fileURL(line)
+		default:
+			library.Warnf("line %d: relative path %q cannot be resolved", lineNumber, line)
+			track.Location = line
+		}
+
+		library.Tracks = append(library.Tracks, track)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	library.Playlists = []Node{playlist}
+	return library, nil
+}
+
+// parseExtInf parses the "<seconds>,<artist> - <title>" payload of an #EXTINF directive
+func parseExtInf(value string) *models.Track {
+	duration, title, _ := strings.Cut(value, ",")
+
+	// Attributes such as tvg-id="..." may follow the duration
+	if fields := strings.Fields(duration); len(fields) > 0 {
+		duration = fields[0]
+	}
+
+	track := &models.Track{Name: strings.TrimSpace(title)}
+	if seconds, err := strconv.ParseFloat(duration, 64); err == nil && seconds > 0 {
+		track.TotalTime = int(seconds)
+	}
+	if artist, name, found := strings.Cut(track.Name, " - "); found {
+		track.Artist = strings.TrimSpace(artist)
+		track.Name = strings.TrimSpace(name)
+	}
+	return track
+}
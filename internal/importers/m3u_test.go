@@ -0,0 +1,93 @@
+package importers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestM3UImporterParse(t *testing.T) {
+	library, err := NewM3UImporter().Parse(bytes.NewReader(readFixture(t, "playlist.m3u")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkLibrary(t, library, &Library{
+		Source: SourceM3U,
+		Tracks: []models.Track{
+			{
+				TrackID:   "/Users/dj/Music/Opening Track.mp3",
+				Name:      "Opening",
+				Artist:    "First Artist",
+				Kind:      "MP3 File",
+				TotalTime: 241,
+				Location:  "file://localhost/Users/dj/Music/Opening%20Track.mp3",
+			},
+			{
+				TrackID:  "C:/Music/second.wav",
+				Name:     "Untitled",
+				Kind:     "WAV File",
+				Location: "file://localhost/C:/Music/second.wav",
+			},
+			{
+				TrackID:  "relative/third.flac",
+				Name:     "third",
+				Kind:     "FLAC File",
+				Location: "relative/third.flac",
+			},
+			{
+				TrackID:  "http://example.com/stream.mp3",
+				Name:     "stream",
+				Kind:     "MP3 File",
+				Location: "http://example.com/stream.mp3",
+			},
+		},
+		Playlists: []Node{{
+			Name: "Friday",
+			Type: NodeTypePlaylist,
+			TrackKeys: []string{
+				"/Users/dj/Music/Opening Track.mp3",
+				"C:/Music/second.wav",
+				"relative/third.flac",
+				"http://example.com/stream.mp3",
+				"/Users/dj/Music/Opening Track.mp3",
+			},
+		}},
+		Warnings: []string{
+			`line 9: relative path "relative/third.flac" cannot be resolved`,
+		},
+	})
+}
+
+func TestM3UImporterWithoutDirectives(t *testing.T) {
+	library, err := NewM3UImporter().Parse(strings.NewReader("/Music/a.mp3\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(library.Playlists) != 1 || library.Playlists[0].Name != defaultM3UPlaylistName {
+		t.Errorf("playlists = %+v, want a single %q", library.Playlists, defaultM3UPlaylistName)
+	}
+	if len(library.Tracks) != 1 || library.Tracks[0].Name != "a" {
+		t.Errorf("tracks = %+v, want a track named after its file", library.Tracks)
+	}
+}
+
+func TestParseExtInf(t *testing.T) {
+	tests := []struct {
+		value string
+		want  models.Track
+	}{
+		{"241,First Artist - Opening", models.Track{Name: "Opening", Artist: "First Artist", TotalTime: 241}},
+		{"241.7,Opening", models.Track{Name: "Opening", TotalTime: 241}},
+		{`-1 tvg-id="x" tvg-name="y",A - B - C`, models.Track{Name: "B - C", Artist: "A"}},
+		{"abc,", models.Track{}},
+		{"", models.Track{}},
+	}
+	for _, tt := range tests {
+		if got := parseExtInf(tt.value); got.Name != tt.want.Name || got.Artist != tt.want.Artist || got.TotalTime != tt.want.TotalTime {
+			t.Errorf("parseExtInf(%q) = %+v, want %+v", tt.value, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// XML structures for parsing Rekordbox XML
+type RekordboxXML struct {
+	XMLName    xml.Name            `xml:"DJ_PLAYLISTS"`
+	Version    string              `xml:"Version,attr"`
+	Product    RekordboxProduct    `xml:"PRODUCT"`
+	Collection RekordboxCollection `xml:"COLLECTION"`
+	Playlists  RekordboxPlaylists  `xml:"PLAYLISTS"`
+}
+
+type RekordboxProduct struct {
+	Name    string `xml:"Name,attr"`
+	Version string `xml:"Version,attr"`
+	Company string `xml:"Company,attr"`
+}
+
+type RekordboxCollection struct {
+	Entries int              `xml:"Entries,attr"`
+	Tracks  []RekordboxTrack `xml:"TRACK"`
+}
+
+type RekordboxTrack struct {
+	TrackID     string           `xml:"TrackID,attr"`
+	Name        string           `xml:"Name,attr"`
+	Artist      string           `xml:"Artist,attr"`
+	Composer    string           `xml:"Composer,attr"`
+	Album       string           `xml:"Album,attr"`
+	Grouping    string           `xml:"Grouping,attr"`
+	Genre       string           `xml:"Genre,attr"`
+	Kind        string           `xml:"Kind,attr"`
+	Size        string           `xml:"Size,attr"`
+	TotalTime   string           `xml:"TotalTime,attr"`
+	DiscNumber  string           `xml:"DiscNumber,attr"`
+	TrackNumber string           `xml:"TrackNumber,attr"`
+	Year        string           `xml:"Year,attr"`
+	AverageBpm  string           `xml:"AverageBpm,attr"`
+	DateAdded   string           `xml:"DateAdded,attr"`
+	BitRate     string           `xml:"BitRate,attr"`
+	SampleRate  string           `xml:"SampleRate,attr"`
+	Comments    string           `xml:"Comments,attr"`
+	PlayCount   string           `xml:"PlayCount,attr"`
+	Rating      string           `xml:"Rating,attr"`
+	Location    string           `xml:"Location,attr"`
+	Remixer     string           `xml:"Remixer,attr"`
+	Tonality    string           `xml:"Tonality,attr"`
+	Label       string           `xml:"Label,attr"`
+	Mix         string           `xml:"Mix,attr"`
+	Tempo       []RekordboxTempo `xml:"TEMPO"`
+	Marks       []RekordboxMark  `xml:"POSITION_MARK"`
+}
+
+type RekordboxTempo struct {
+	Inizio  string `xml:"Inizio,attr"`
+	Bpm     string `xml:"Bpm,attr"`
+	Metro   string `xml:"Metro,attr"`
+	Battito string `xml:"Battito,attr"`
+}
+
+// RekordboxMark is a memory cue, hot cue or loop. Num is -1 for memory cues and the hot cue number otherwise.
+type RekordboxMark struct {
+	Name  string `xml:"Name,attr"`
+	Type  string `xml:"Type,attr"`
+	Start string `xml:"Start,attr"`
+	End   string `xml:"End,attr,omitempty"`
+	Num   string `xml:"Num,attr"`
+	Red   string `xml:"Red,attr,omitempty"`
+	Green string `xml:"Green,attr,omitempty"`
+	Blue  string `xml:"Blue,attr,omitempty"`
+}
+
+type RekordboxPlaylists struct {
+	Nodes []RekordboxNode `xml:"NODE"`
+}
+
+type RekordboxNode struct {
+	Type    string                   `xml:"Type,attr"`
+	Name    string                   `xml:"Name,attr"`
+	Count   string                   `xml:"Count,attr,omitempty"`
+	KeyType string                   `xml:"KeyType,attr,omitempty"`
+	Entries string                   `xml:"Entries,attr,omitempty"`
+	Nodes   []RekordboxNode          `xml:"NODE"`
+	Tracks  []RekordboxPlaylistTrack `xml:"TRACK"`
+}
+
+type RekordboxPlaylistTrack struct {
+	Key string `xml:"Key,attr"`
+}
+
+// RekordboxImporter imports Rekordbox DJ_PLAYLISTS XML exports
+type RekordboxImporter struct{}
+
+// NewRekordboxImporter creates a new RekordboxImporter
+func NewRekordboxImporter() *RekordboxImporter {
+	return &RekordboxImporter{}
+}
+
+// Name returns the source identifier for Rekordbox
+func (i *RekordboxImporter) Name() string {
+	return SourceRekordbox
+}
+
+// Detect reports whether the header starts a DJ_PLAYLISTS document
+func (i *RekordboxImporter) Detect(header []byte) bool {
+	return xmlRootElement(header) == "DJ_PLAYLISTS"
+}
+
+// CountTracks returns the number of tracks declared by the COLLECTION element
+func (i *RekordboxImporter) CountTracks(header []byte) (int, bool) {
+	return xmlCollectionEntries(header, "Entries")
+}
+
+// Parse parses a Rekordbox XML document
+func (i *RekordboxImporter) Parse(r io.Reader) (*Library, error) {
+	var tracks []models.Track
+	library, err := i.Stream(r, func(track models.Track) error {
+		tracks = append(tracks, track)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	library.Tracks = tracks
+	return library, nil
+}
+
+// Stream parses a Rekordbox XML document token by token, decoding one collection TRACK at a time
+func (i *RekordboxImporter) Stream(r io.Reader, onTrack func(track models.Track) error) (*Library, error) {
+	library := &Library{Source: SourceRekordbox}
+
+	// Only the track keys are kept to check playlist entries against the collection
+	keys := make(map[string]bool)
+
+	decoder := xml.NewDecoder(r)
+	root := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !root {
+			if start.Name.Local != "DJ_PLAYLISTS" {
+				return nil, fmt.Errorf("%w: expected element DJ_PLAYLISTS, found %s", ErrInvalidFile, start.Name.Local)
+			}
+			root = true
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "Version" {
+					library.Version = attr.Value
+				}
+			}
+			continue
+		}
+
+		switch start.Name.Local {
+		case "PRODUCT":
+			var product RekordboxProduct
+			if err := decoder.DecodeElement(&product, &start); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			library.ProductName = product.Name
+			library.Company = product.Company
+
+		case "TRACK":
+			// Playlist TRACK entries are consumed with their PLAYLISTS element, so this is a collection track
+			var rbTrack RekordboxTrack
+			if err := decoder.DecodeElement(&rbTrack, &start); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			if rbTrack.Location == "" {
+				library.Warnf("track %s (%q) has no location", rbTrack.TrackID, rbTrack.Name)
+			}
+			keys[rbTrack.TrackID] = true
+			if err := onTrack(convertRekordboxTrack(rbTrack)); err != nil {
+				return nil, err
+			}
+
+		case "PLAYLISTS":
+			var playlists RekordboxPlaylists
+			if err := decoder.DecodeElement(&playlists, &start); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			for _, rbNode := range playlists.Nodes {
+				library.Playlists = append(library.Playlists, convertRekordboxNode(rbNode))
+			}
+		}
+	}
+
+	if !root {
+		return nil, fmt.Errorf("%w: missing DJ_PLAYLISTS element", ErrInvalidFile)
+	}
+
+	library.checkPlaylistKeysIn(keys)
+	return library, nil
+}
+
+// convertRekordboxTrack converts a RekordboxTrack to a Track model, including its tempo markers and cue points
+func convertRekordboxTrack(rbTrack RekordboxTrack) models.Track {
+	size, _ := strconv.ParseInt(rbTrack.Size, 10, 64)
+	totalTime, _ := strconv.Atoi(rbTrack.TotalTime)
+	discNumber, _ := strconv.Atoi(rbTrack.DiscNumber)
+	trackNumber, _ := strconv.Atoi(rbTrack.TrackNumber)
+	year, _ := strconv.Atoi(rbTrack.Year)
+	averageBpm, _ := strconv.ParseFloat(rbTrack.AverageBpm, 64)
+	bitRate, _ := strconv.Atoi(rbTrack.BitRate)
+	sampleRate, _ := strconv.Atoi(rbTrack.SampleRate)
+	playCount, _ := strconv.Atoi(rbTrack.PlayCount)
+	rating, _ := strconv.Atoi(rbTrack.Rating)
+
+	dateAdded, _ := time.Parse("2006-01-02", rbTrack.DateAdded)
+
+	tempo := make([]models.Tempo, 0, len(rbTrack.Tempo))
+	for _, rbTempo := range rbTrack.Tempo {
+		tempo = append(tempo, convertRekordboxTempo(rbTempo))
+	}
+
+	cues := make([]models.CuePoint, 0, len(rbTrack.Marks))
+	for _, mark := range rbTrack.Marks {
+		cues = append(cues, convertRekordboxMark(mark))
+	}
+
+	return models.Track{
+		TrackID:     rbTrack.TrackID,
+		Name:        rbTrack.Name,
+		Artist:      rbTrack.Artist,
+		Composer:    rbTrack.Composer,
+		Album:       rbTrack.Album,
+		Grouping:    rbTrack.Grouping,
+		Genre:       rbTrack.Genre,
+		Kind:        rbTrack.Kind,
+		Size:        size,
+		TotalTime:   totalTime,
+		DiscNumber:  discNumber,
+		TrackNumber: trackNumber,
+		Year:        year,
+		AverageBpm:  averageBpm,
+		DateAdded:   dateAdded,
+		BitRate:     bitRate,
+		SampleRate:  sampleRate,
+		Comments:    rbTrack.Comments,
+		PlayCount:   playCount,
+		Rating:      rating,
+		Location:    rbTrack.Location,
+		Remixer:     rbTrack.Remixer,
+		Tonality:    rbTrack.Tonality,
+		Label:       rbTrack.Label,
+		Mix:         rbTrack.Mix,
+		Tempo:       tempo,
+		CuePoints:   cues,
+	}
+}
+
+// convertRekordboxTempo converts a RekordboxTempo to a Tempo model
+func convertRekordboxTempo(rbTempo RekordboxTempo) models.Tempo {
+	inizio, _ := strconv.ParseFloat(rbTempo.Inizio, 64)
+	bpm, _ := strconv.ParseFloat(rbTempo.Bpm, 64)
+	battito, _ := strconv.Atoi(rbTempo.Battito)
+
+	return models.Tempo{
+		Inizio:  inizio,
+		Bpm:     bpm,
+		Metro:   rbTempo.Metro,
+		Battito: battito,
+	}
+}
+
+// convertRekordboxMark converts a RekordboxMark to a CuePoint model
+func convertRekordboxMark(mark RekordboxMark) models.CuePoint {
+	cueType, _ := strconv.Atoi(mark.Type)
+	start, _ := strconv.ParseFloat(mark.Start, 64)
+	hotCue, err := strconv.Atoi(mark.Num)
+	if err != nil {
+		hotCue = -1
+	}
+
+	cue := models.CuePoint{
+		Type:   cueType,
+		Start:  start,
+		HotCue: hotCue,
+		Name:   mark.Name,
+	}
+	if end, err := strconv.ParseFloat(mark.End, 64); err == nil {
+		cue.End = &end
+	}
+
+	// The colour is only present when all three components are
+	red, redErr := strconv.ParseUint(mark.Red, 10, 8)
+	green, greenErr := strconv.ParseUint(mark.Green, 10, 8)
+	blue, blueErr := strconv.ParseUint(mark.Blue, 10, 8)
+	if redErr == nil && greenErr == nil && blueErr == nil {
+		cue.Color = fmt.Sprintf("#%02X%02X%02X", red, green, blue)
+	}
+
+	return cue
+}
+
+// convertRekordboxNode recursively converts a RekordboxNode (playlist or folder)
+func convertRekordboxNode(rbNode RekordboxNode) Node {
+	nodeType, _ := strconv.Atoi(rbNode.Type)
+
+	node := Node{
+		Name: rbNode.Name,
+		Type: nodeType,
+	}
+
+	if nodeType == NodeTypePlaylist {
+		for _, rbTrack := range rbNode.Tracks {
+			node.TrackKeys = append(node.TrackKeys, rbTrack.Key)
+		}
+	}
+
+	for _, childNode := range rbNode.Nodes {
+		node.Children = append(node.Children, convertRekordboxNode(childNode))
+	}
+
+	return node
+}
+
+// xmlRootElement returns the local name of the first element in an XML document, or "" if there is none
+func xmlRootElement(header []byte) string {
+	decoder := xml.NewDecoder(bytes.NewReader(header))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return ""
+		}
+		if start, ok := token.(xml.StartElement); ok {
+			return start.Name.Local
+		}
+	}
+}
+
+// xmlCollectionEntries reads an entry count attribute from the first COLLECTION element in a header
+func xmlCollectionEntries(header []byte, attrName string) (int, bool) {
+	decoder := xml.NewDecoder(bytes.NewReader(header))
+	decoder.Strict = false
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return 0, false
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != "COLLECTION" {
+			continue
+		}
+		for _, attr := range start.Attr {
+			if attr.Name.Local == attrName {
+				entries, err := strconv.Atoi(attr.Value)
+				return entries, err == nil && entries >= 0
+			}
+		}
+		return 0, false
+	}
+}
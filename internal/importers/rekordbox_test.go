@@ -0,0 +1,275 @@
+package importers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestRekordboxImporterParse(t *testing.T) {
+	library, err := NewRekordboxImporter().Parse(bytes.NewReader(readFixture(t, "rekordbox.xml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkLibrary(t, library, &Library{
+		Source:      SourceRekordbox,
+		Version:     "1.0.0",
+		ProductName: "rekordbox",
+		Company:     "AlphaTheta",
+		Tracks: []models.Track{
+			{
+				TrackID:     "1",
+				Name:        "Opening",
+				Artist:      "First Artist",
+				Composer:    "Writer",
+				Album:       "Debut",
+				Grouping:    "Warmup",
+				Genre:       "House",
+				Kind:        "MP3 File",
+				Size:        8392704,
+				TotalTime:   241,
+				DiscNumber:  1,
+				TrackNumber: 3,
+				Year:        2019,
+				AverageBpm:  124,
+				DateAdded:   time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC),
+				BitRate:     320,
+				SampleRate:  44100,
+				Comments:    "Good intro",
+				PlayCount:   12,
+				Rating:      204,
+				Location:    "file://localhost/Users/dj/Music/Opening%20Track.mp3",
+				Remixer:     "Someone",
+				Tonality:    "Am",
+				Label:       "Label One",
+				Mix:         "Extended Mix",
+				Tempo: []models.Tempo{
+					{Inizio: 0.025, Bpm: 124, Metro: "4/4", Battito: 1},
+					{Inizio: 120.025, Bpm: 126, Metro: "4/4", Battito: 3},
+				},
+				CuePoints: []models.CuePoint{
+					{Type: models.CueTypeCue, Start: 0.025, HotCue: -1},
+					{Type: models.CueTypeCue, Start: 32.283, HotCue: 0, Color: "#E62828", Name: "Drop"},
+					{Type: models.CueTypeLoop, Start: 64.541, End: floatPtr(72.283), HotCue: 1, Color: "#10B176", Name: "Loop"},
+				},
+			},
+			{
+				TrackID:    "2",
+				Name:       "No File",
+				Artist:     "Second Artist",
+				Kind:       "WAV File",
+				TotalTime:  180,
+				AverageBpm: 128.5,
+				Tempo:      []models.Tempo{},
+				CuePoints:  []models.CuePoint{},
+			},
+		},
+		Playlists: []Node{{
+			Name: "ROOT",
+			Type: NodeTypeFolder,
+			Children: []Node{
+				{Name: "Sets", Type: NodeTypeFolder, Children: []Node{
+					{Name: "Friday", Type: NodeTypePlaylist, TrackKeys: []string{"2", "1", "99"}},
+				}},
+				{Name: "Empty", Type: NodeTypePlaylist},
+			},
+		}},
+		Warnings: []string{
+			`track 2 ("No File") has no location`,
+			`playlist "Friday" references 1 track(s) missing from the collection`,
+		},
+	})
+}
+
+func TestRekordboxImporterRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"empty":                 "",
+		"other root":            `<?xml version="1.0"?><NML VERSION="19"></NML>`,
+		"malformed":             `<DJ_PLAYLISTS Version="1.0.0"><COLLECTION><TRACK TrackID="1"></COLLECTION></DJ_PLAYLISTS>`,
+		"truncated":             `<DJ_PLAYLISTS Version="1.0.0"><COLLECTION Entries="1"><TRACK TrackID="1" Name="A`,
+		"malformed playlists":   `<DJ_PLAYLISTS><PLAYLISTS><NODE Type="0"></PLAYLISTS></DJ_PLAYLISTS>`,
+		"text without elements": "just text",
+	}
+	for name, document := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewRekordboxImporter().Parse(strings.NewReader(document)); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("err = %v, want ErrInvalidFile", err)
+			}
+		})
+	}
+}
+
+func TestConvertRekordboxMark(t *testing.T) {
+	tests := []struct {
+		name string
+		mark RekordboxMark
+		want models.CuePoint
+	}{
+		{"memory cue", RekordboxMark{Type: "0", Start: "1.5", Num: "-1"}, models.CuePoint{Start: 1.5, HotCue: -1}},
+		{"missing number", RekordboxMark{Type: "0", Start: "1.5"}, models.CuePoint{Start: 1.5, HotCue: -1}},
+		{"hot cue", RekordboxMark{Type: "0", Start: "2", Num: "3", Name: "C"}, models.CuePoint{Start: 2, HotCue: 3, Name: "C"}},
+		{"partial colour", RekordboxMark{Type: "0", Start: "2", Num: "0", Red: "255", Green: "0"}, models.CuePoint{Start: 2}},
+		{"colour out of range", RekordboxMark{Type: "0", Start: "2", Num: "0", Red: "256", Green: "0", Blue: "0"}, models.CuePoint{Start: 2}},
+		{"loop", RekordboxMark{Type: "4", Start: "8", End: "16", Num: "-1", Red: "0", Green: "0", Blue: "255"},
+			models.CuePoint{Type: models.CueTypeLoop, Start: 8, End: floatPtr(16), HotCue: -1, Color: "#0000FF"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := convertRekordboxMark(tt.mark)
+			if got.Type != tt.want.Type || got.Start != tt.want.Start || got.HotCue != tt.want.HotCue ||
+				got.Color != tt.want.Color || got.Name != tt.want.Name || (got.End == nil) != (tt.want.End == nil) ||
+				(got.End != nil && *got.End != *tt.want.End) {
+				t.Errorf("convertRekordboxMark = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// Size of the generated collection the import benchmarks run on
+const (
+	benchmarkTracks    = 50000
+	benchmarkPlaylists = 200
+)
+
+var (
+	benchmarkFixtureOnce sync.Once
+	benchmarkFixture     []byte
+)
+
+// rekordboxBenchmarkFixture returns the generated collection, creating it on first use
+func rekordboxBenchmarkFixture(b *testing.B) []byte {
+	benchmarkFixtureOnce.Do(func() {
+		var buf bytes.Buffer
+		if err := writeRekordboxFixture(&buf, benchmarkTracks, benchmarkPlaylists); err != nil {
+			b.Fatal(err)
+		}
+		benchmarkFixture = buf.Bytes()
+	})
+	return benchmarkFixture
+}
+
+// writeRekordboxFixture writes a Rekordbox collection with beatgridded tracks and playlists of 100 tracks each
+func writeRekordboxFixture(w io.Writer, tracks, playlists int) error {
+	genres := []string{"House", "Techno", "Drum &amp; Bass", "Disco", "Ambient"}
+	keys := []string{"Am", "Em", "C", "G", "F#m", "Db"}
+
+	if _, err := fmt.Fprintf(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<DJ_PLAYLISTS Version=\"1.0.0\">\n"+
+		"  <PRODUCT Name=\"rekordbox\" Version=\"6.8.5\" Company=\"AlphaTheta\"/>\n  <COLLECTION Entries=\"%d\">\n", tracks); err != nil {
+		return err
+	}
+
+	for i := 1; i <= tracks; i++ {
+		bpm := 118 + float64(i%20)
+		if _, err := fmt.Fprintf(w,
+			"    <TRACK TrackID=\"%d\" Name=\"Track %d\" Artist=\"Artist %d\" Album=\"Album %d\" Genre=\"%s\" Kind=\"MP3 File\" "+
+				"Size=\"%d\" TotalTime=\"%d\" Year=\"%d\" AverageBpm=\"%.2f\" DateAdded=\"2024-01-%02d\" BitRate=\"320\" SampleRate=\"44100\" "+
+				"PlayCount=\"%d\" Rating=\"%d\" Location=\"file://localhost/Users/dj/Music/Artist%%20%d/Track%%20%d.mp3\" Tonality=\"%s\" Label=\"Label %d\">\n"+
+				"      <TEMPO Inizio=\"0.025\" Bpm=\"%.2f\" Metro=\"4/4\" Battito=\"1\"/>\n"+
+				"      <TEMPO Inizio=\"%.3f\" Bpm=\"%.2f\" Metro=\"4/4\" Battito=\"1\"/>\n"+
+				"    </TRACK>\n",
+			i, i, i%5000, i%10000, genres[i%len(genres)],
+			8000000+i, 300+i%200, 1990+i%35, bpm, 1+i%28,
+			i%50, (i%6)*51, i%5000, i, keys[i%len(keys)], i%300,
+			bpm, 120+float64(i%60), bpm); err != nil {
+			return err
+		}
+	}
+
+	if _, err := fmt.Fprintf(w, "  </COLLECTION>\n  <PLAYLISTS>\n    <NODE Type=\"0\" Name=\"ROOT\" Count=\"%d\">\n", playlists); err != nil {
+		return err
+	}
+	for p := 0; p < playlists; p++ {
+		if _, err := fmt.Fprintf(w, "      <NODE Name=\"Playlist %d\" Type=\"1\" KeyType=\"0\" Entries=\"100\">\n", p+1); err != nil {
+			return err
+		}
+		for e := 0; e < 100; e++ {
+			if _, err := fmt.Fprintf(w, "        <TRACK Key=\"%d\"/>\n", 1+(p*100+e*37)%max(tracks, 1)); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, "      </NODE>\n"); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(w, "    </NODE>\n  </PLAYLISTS>\n</DJ_PLAYLISTS>\n")
+	return err
+}
+
+// reportPeakHeap runs fn once more outside the timed loop and reports the largest heap growth seen by its
+// samples, in megabytes above the heap after a garbage collection
+func reportPeakHeap(b *testing.B, fn func(sample func())) {
+	b.StopTimer()
+	runtime.GC()
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	base := stats.HeapAlloc
+	peak := base
+	fn(func() {
+		runtime.ReadMemStats(&stats)
+		if stats.HeapAlloc > peak {
+			peak = stats.HeapAlloc
+		}
+	})
+	b.ReportMetric(float64(peak-base)/(1<<20), "peak-MB")
+}
+
+// BenchmarkImportRekordboxParse decodes the whole collection into memory
+func BenchmarkImportRekordboxParse(b *testing.B) {
+	fixture := rekordboxBenchmarkFixture(b)
+	importer := NewRekordboxImporter()
+	b.SetBytes(int64(len(fixture)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := importer.Parse(bytes.NewReader(fixture)); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reportPeakHeap(b, func(sample func()) {
+		library, err := importer.Parse(bytes.NewReader(fixture))
+		if err != nil {
+			b.Fatal(err)
+		}
+		sample()
+		runtime.KeepAlive(library)
+	})
+}
+
+// BenchmarkImportRekordboxStream hands the tracks of the collection over one at a time
+func BenchmarkImportRekordboxStream(b *testing.B) {
+	fixture := rekordboxBenchmarkFixture(b)
+	importer := NewRekordboxImporter()
+	b.SetBytes(int64(len(fixture)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := importer.Stream(bytes.NewReader(fixture), func(models.Track) error { return nil }); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reportPeakHeap(b, func(sample func()) {
+		count := 0
+		_, err := importer.Stream(bytes.NewReader(fixture), func(models.Track) error {
+			if count++; count%1000 == 0 {
+				sample()
+			}
+			return nil
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// seratoField is a single tag/length/value record from a Serato database file
+type seratoField struct {
+	Tag  string
+	Data []byte
+}
+
+// SeratoImporter imports Serato "database V2" files
+type SeratoImporter struct{}
+
+// NewSeratoImporter creates a new SeratoImporter
+func NewSeratoImporter() *SeratoImporter {
+	return &SeratoImporter{}
+}
+
+// Name returns the source identifier for Serato
+func (i *SeratoImporter) Name() string {
+	return SourceSerato
+}
+
+// Detect reports whether the header starts with a Serato version record
+func (i *SeratoImporter) Detect(header []byte) bool {
+	return bytes.HasPrefix(header, []byte("vrsn"))
+}
+
+// Parse parses a Serato database V2 file. Crates are stored in separate files and are not part of the database.
+func (i *SeratoImporter) Parse(r io.Reader) (*Library, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	fields, err := parseSeratoFields(data)
+	if err != nil {
+		return nil, err
+	}
+
+	library := &Library{
+		Source:      SourceSerato,
+		ProductName: "Serato",
+		Company:     "Serato",
+	}
+
+	for _, field := range fields {
+		switch field.Tag {
+		case "vrsn":
+			library.Version = seratoString(field.Data)
+		case "otrk":
+			trackFields, err := parseSeratoFields(field.Data)
+			if err != nil {
+				return nil, err
+			}
+
+			track := convertSeratoTrack(trackFields)
+			if track.TrackID == "" {
+				library.Warnf("skipped a track without a file path")
+				continue
+			}
+			library.Tracks = append(library.Tracks, track)
+		}
+	}
+
+	return library, nil
+}
+
+// parseSeratoFields splits a buffer into its tag/length/value records
+func parseSeratoFields(data []byte) ([]seratoField, error) {
+	var fields []seratoField
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return nil, fmt.Errorf("%w: truncated Serato record header", ErrInvalidFile)
+		}
+
+		tag := string(data[:4])
+		length := binary.BigEndian.Uint32(data[4:8])
+		data = data[8:]
+		if uint64(length) > uint64(len(data)) {
+			return nil, fmt.Errorf("%w: Serato record %q overruns the file", ErrInvalidFile, tag)
+		}
+
+		fields = append(fields, seratoField{Tag: tag, Data: data[:length]})
+		data = data[length:]
+	}
+	return fields, nil
+}
+
+// seratoString decodes a UTF-16 big endian Serato text value
+func seratoString(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = binary.BigEndian.Uint16(data[i*2:])
+	}
+	return strings.TrimRight(string(utf16.Decode(units)), "\x00")
+}
+
+// seratoUint32 decodes a big endian Serato integer value
+func seratoUint32(data []byte) int64 {
+	if len(data) < 4 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint32(data))
+}
+
+// convertSeratoTrack converts the fields of an otrk record to a Track model
+func convertSeratoTrack(fields []seratoField) models.Track {
+	var track models.Track
+	var path string
+
+	for _, field := range fields {
+		switch field.Tag {
+		case "pfil":
+			path = seratoString(field.Data)
+		case "tsng":
+			track.Name = seratoString(field.Data)
+		case "tart":
+			track.Artist = seratoString(field.Data)
+		case "talb":
+			track.Album = seratoString(field.Data)
+		case "tgen":
+			track.Genre = seratoString(field.Data)
+		case "tcom":
+			track.Comments = seratoString(field.Data)
+		case "tgrp":
+			track.Grouping = seratoString(field.Data)
+		case "trmx":
+			track.Remixer = seratoString(field.Data)
+		case "tlbl":
+			track.Label = seratoString(field.Data)
+		case "tcmp":
+			track.Composer = seratoString(field.Data)
+		case "tkey":
+			track.Tonality = seratoString(field.Data)
+		case "ttyr":
+			track.Year, _ = strconv.Atoi(seratoString(field.Data))
+		case "tbpm":
+			track.AverageBpm, _ = strconv.ParseFloat(seratoString(field.Data), 64)
+		case "tlen":
+			track.TotalTime = parseSeratoLength(seratoString(field.Data))
+		case "tbit":
+			bitRate, _ := strconv.ParseFloat(strings.TrimSuffix(seratoString(field.Data), "kbps"), 64)
+			track.BitRate = int(bitRate)
+		case "tsmp":
+			sampleRate, _ := strconv.ParseFloat(strings.TrimSuffix(seratoString(field.Data), "k"), 64)
+			track.SampleRate = int(sampleRate * 1000)
+		case "uadd":
+			track.DateAdded = time.Unix(seratoUint32(field.Data), 0).UTC()
+		case "ufsb":
+			track.Size = seratoUint32(field.Data)
+		}
+	}
+
+	if path == "" {
+		return track
+	}
+
+	// Serato stores paths relative to the root of the volume they live on
+	if !strings.HasPrefix(path, "/") && !(len(path) >= 2 && path[1] == ':') {
+		path = "/" + path
+	}
+
+	track.TrackID = path
+	track.Location = fileURL(path)
+	track.Kind = fileKind(path)
+	if track.Name == "" {
+		track.Name = fileTitle(path)
+	}
+
+	return track
+}
+
+// parseSeratoLength parses a "mm:ss.xx" duration into whole seconds
+func parseSeratoLength(value string) int {
+	minutes, seconds, found := strings.Cut(value, ":")
+	if !found {
+		return 0
+	}
+	m, _ := strconv.Atoi(minutes)
+	s, _ := strconv.ParseFloat(seconds, 64)
+	return m*60 + int(s)
+}
@@ -0,0 +1,140 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// seratoRecord encodes a tag/length/value record
+func seratoRecord(tag string, data []byte) []byte {
+	record := make([]byte, 8, 8+len(data))
+	copy(record, tag)
+	binary.BigEndian.PutUint32(record[4:], uint32(len(data)))
+	return append(record, data...)
+}
+
+// seratoText encodes a UTF-16 big endian text record
+func seratoText(tag, value string) []byte {
+	units := utf16.Encode([]rune(value))
+	data := make([]byte, 2*len(units))
+	for i, unit := range units {
+		binary.BigEndian.PutUint16(data[2*i:], unit)
+	}
+	return seratoRecord(tag, data)
+}
+
+// seratoInt encodes a big endian integer record
+func seratoInt(tag string, value uint32) []byte {
+	data := make([]byte, 4)
+	binary.BigEndian.PutUint32(data, value)
+	return seratoRecord(tag, data)
+}
+
+// seratoDatabase builds a small "database V2" file with two tracks and one track without a path
+func seratoDatabase() []byte {
+	var track bytes.Buffer
+	for _, field := range [][]byte{
+		seratoText("pfil", "Users/dj/Music/Opening Track.mp3"),
+		seratoText("tsng", "Opening"),
+		seratoText("tart", "First Artist"),
+		seratoText("talb", "Debut"),
+		seratoText("tgen", "House"),
+		seratoText("tcom", "Good intro"),
+		seratoText("tkey", "Am"),
+		seratoText("ttyr", "2019"),
+		seratoText("tbpm", "124.00"),
+		seratoText("tlen", "04:01.53"),
+		seratoText("tbit", "320.0kbps"),
+		seratoText("tsmp", "44.1k"),
+		seratoInt("uadd", 1680688800),
+		seratoInt("ufsb", 8392704),
+		seratoRecord("bhrt", []byte{1}),
+	} {
+		track.Write(field)
+	}
+
+	var database bytes.Buffer
+	database.Write(seratoText("vrsn", "2.0/Serato Scratch LIVE Database"))
+	database.Write(seratoRecord("otrk", track.Bytes()))
+	database.Write(seratoRecord("otrk", seratoText("pfil", "C:/Sets/second.wav")))
+	database.Write(seratoRecord("otrk", seratoText("tsng", "Pathless")))
+	return database.Bytes()
+}
+
+func TestSeratoImporterParse(t *testing.T) {
+	library, err := NewSeratoImporter().Parse(bytes.NewReader(seratoDatabase()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkLibrary(t, library, &Library{
+		Source:      SourceSerato,
+		Version:     "2.0/Serato Scratch LIVE Database",
+		ProductName: "Serato",
+		Company:     "Serato",
+		Tracks: []models.Track{
+			{
+				TrackID:    "/Users/dj/Music/Opening Track.mp3",
+				Name:       "Opening",
+				Artist:     "First Artist",
+				Album:      "Debut",
+				Genre:      "House",
+				Kind:       "MP3 File",
+				Size:       8392704,
+				TotalTime:  241,
+				Year:       2019,
+				AverageBpm: 124,
+				DateAdded:  time.Date(2023, 4, 5, 10, 0, 0, 0, time.UTC),
+				BitRate:    320,
+				SampleRate: 44100,
+				Comments:   "Good intro",
+				Location:   "file://localhost/Users/dj/Music/Opening%20Track.mp3",
+				Tonality:   "Am",
+			},
+			{
+				TrackID:  "C:/Sets/second.wav",
+				Name:     "second",
+				Kind:     "WAV File",
+				Location: "file://localhost/C:/Sets/second.wav",
+			},
+		},
+		Warnings: []string{"skipped a track without a file path"},
+	})
+}
+
+func TestSeratoImporterRejectsInvalidFiles(t *testing.T) {
+	database := seratoDatabase()
+	tests := map[string][]byte{
+		"truncated header":  database[:5],
+		"truncated record":  database[:20],
+		"overrunning track": append(seratoText("vrsn", "2.0"), seratoRecord("otrk", []byte("tsng\x00\x00\x01\x00"))...),
+	}
+	for name, data := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewSeratoImporter().Parse(bytes.NewReader(data)); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("err = %v, want ErrInvalidFile", err)
+			}
+		})
+	}
+}
+
+func TestParseSeratoLength(t *testing.T) {
+	tests := map[string]int{
+		"04:01.53": 241,
+		"00:59.99": 59,
+		"61:00":    3660,
+		"241":      0,
+		"":         0,
+	}
+	for value, want := range tests {
+		if got := parseSeratoLength(value); got != want {
+			t.Errorf("parseSeratoLength(%q) = %d, want %d", value, got, want)
+		}
+	}
+}
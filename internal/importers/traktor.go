@@ -0,0 +1,418 @@
+package importers
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// XML structures for parsing Traktor NML collections
+type TraktorNML struct {
+	XMLName    xml.Name          `xml:"NML"`
+	Version    string            `xml:"VERSION,attr"`
+	Head       TraktorHead       `xml:"HEAD"`
+	Collection TraktorCollection `xml:"COLLECTION"`
+	Playlists  TraktorPlaylists  `xml:"PLAYLISTS"`
+}
+
+type TraktorHead struct {
+	Company string `xml:"COMPANY,attr"`
+	Program string `xml:"PROGRAM,attr"`
+}
+
+type TraktorCollection struct {
+	Entries int            `xml:"ENTRIES,attr"`
+	Tracks  []TraktorEntry `xml:"ENTRY"`
+}
+
+type TraktorEntry struct {
+	AudioID    string             `xml:"AUDIO_ID,attr,omitempty"`
+	Title      string             `xml:"TITLE,attr,omitempty"`
+	Artist     string             `xml:"ARTIST,attr,omitempty"`
+	Location   TraktorLocation    `xml:"LOCATION"`
+	Album      TraktorAlbum       `xml:"ALBUM"`
+	Info       TraktorInfo        `xml:"INFO"`
+	Tempo      *TraktorTempo      `xml:"TEMPO"`
+	MusicalKey *TraktorMusicalKey `xml:"MUSICAL_KEY"`
+	Cues       []TraktorCue       `xml:"CUE_V2"`
+}
+
+type TraktorLocation struct {
+	Dir      string `xml:"DIR,attr,omitempty"`
+	File     string `xml:"FILE,attr,omitempty"`
+	Volume   string `xml:"VOLUME,attr,omitempty"`
+	VolumeID string `xml:"VOLUMEID,attr,omitempty"`
+}
+
+type TraktorAlbum struct {
+	Track    string `xml:"TRACK,attr,omitempty"`
+	OfTracks string `xml:"OF_TRACKS,attr,omitempty"`
+	Title    string `xml:"TITLE,attr,omitempty"`
+}
+
+type TraktorInfo struct {
+	Bitrate     string `xml:"BITRATE,attr,omitempty"`
+	Genre       string `xml:"GENRE,attr,omitempty"`
+	Label       string `xml:"LABEL,attr,omitempty"`
+	Comment     string `xml:"COMMENT,attr,omitempty"`
+	Key         string `xml:"KEY,attr,omitempty"`
+	PlayCount   string `xml:"PLAYCOUNT,attr,omitempty"`
+	Playtime    string `xml:"PLAYTIME,attr,omitempty"`
+	Ranking     string `xml:"RANKING,attr,omitempty"`
+	ImportDate  string `xml:"IMPORT_DATE,attr,omitempty"`
+	ReleaseDate string `xml:"RELEASE_DATE,attr,omitempty"`
+	FileSize    string `xml:"FILESIZE,attr,omitempty"` // In kilobytes
+	Mix         string `xml:"MIX,attr,omitempty"`
+	Remixer     string `xml:"REMIXER,attr,omitempty"`
+	Producer    string `xml:"PRODUCER,attr,omitempty"`
+}
+
+type TraktorTempo struct {
+	Bpm        string `xml:"BPM,attr"`
+	BpmQuality string `xml:"BPM_QUALITY,attr"`
+}
+
+type TraktorMusicalKey struct {
+	Value string `xml:"VALUE,attr"`
+}
+
+type TraktorCue struct {
+	Name         string `xml:"NAME,attr,omitempty"`
+	DisplayOrder string `xml:"DISPL_ORDER,attr,omitempty"`
+	Type         string `xml:"TYPE,attr,omitempty"`
+	Start        string `xml:"START,attr,omitempty"` // In milliseconds
+	Len          string `xml:"LEN,attr,omitempty"`   // In milliseconds
+	Repeats      string `xml:"REPEATS,attr,omitempty"`
+	HotCue       string `xml:"HOTCUE,attr,omitempty"`
+}
+
+type TraktorPlaylists struct {
+	Nodes []TraktorNode `xml:"NODE"`
+}
+
+type TraktorNode struct {
+	Type     string           `xml:"TYPE,attr"` // "FOLDER" or "PLAYLIST"
+	Name     string           `xml:"NAME,attr"`
+	Subnodes *TraktorSubnodes `xml:"SUBNODES"`
+	Playlist *TraktorPlaylist `xml:"PLAYLIST"`
+}
+
+type TraktorSubnodes struct {
+	Count string        `xml:"COUNT,attr"`
+	Nodes []TraktorNode `xml:"NODE"`
+}
+
+type TraktorPlaylist struct {
+	Entries string                 `xml:"ENTRIES,attr,omitempty"`
+	Type    string                 `xml:"TYPE,attr,omitempty"`
+	UUID    string                 `xml:"UUID,attr,omitempty"`
+	Tracks  []TraktorPlaylistEntry `xml:"ENTRY"`
+}
+
+type TraktorPlaylistEntry struct {
+	PrimaryKey TraktorPrimaryKey `xml:"PRIMARYKEY"`
+}
+
+type TraktorPrimaryKey struct {
+	Type string `xml:"TYPE,attr"`
+	Key  string `xml:"KEY,attr"`
+}
+
+// Traktor cue types as stored in CUE_V2 TYPE attributes.
+// Types below the grid type match the CueType constants of the cue point model.
+const (
+	traktorCueTypeGrid = 4
+	traktorCueTypeLoop = 5
+)
+
+// traktorUnnamedCue is the name Traktor gives cues that were not named by the user
+const traktorUnnamedCue = "n.n."
+
+// windowsVolumePattern matches Traktor VOLUME attributes that are Windows drive letters
+var windowsVolumePattern = regexp.MustCompile(`^[A-Za-z]:$`)
+
+// TraktorTrackKey builds the key Traktor uses to reference a collection entry from a playlist
+func TraktorTrackKey(location TraktorLocation) string {
+	return location.Volume + location.Dir + location.File
+}
+
+// traktorLocationToURL converts a Traktor LOCATION element to a file://localhost URL as used by Rekordbox
+func traktorLocationToURL(location TraktorLocation) string {
+	dir := strings.ReplaceAll(location.Dir, "/:", "/")
+	path := dir + location.File
+
+	switch {
+	case windowsVolumePattern.MatchString(location.Volume):
+		path = "/" + location.Volume + path
+	case location.Volume != "" && location.Volume != "Macintosh HD":
+		// Non-system volumes are mounted under /Volumes on macOS
+		path = "/Volumes/" + location.Volume + path
+	}
+
+	return fileURL(path)
+}
+
+// parseTraktorDate parses the "2006/1/2" dates used in NML files
+func parseTraktorDate(value string) time.Time {
+	date, _ := time.Parse("2006/1/2", value)
+	return date
+}
+
+// TraktorImporter imports Traktor NML collections
+type TraktorImporter struct{}
+
+// NewTraktorImporter creates a new TraktorImporter
+func NewTraktorImporter() *TraktorImporter {
+	return &TraktorImporter{}
+}
+
+// Name returns the source identifier for Traktor
+func (i *TraktorImporter) Name() string {
+	return SourceTraktor
+}
+
+// Detect reports whether the header starts an NML document
+func (i *TraktorImporter) Detect(header []byte) bool {
+	return xmlRootElement(header) == "NML"
+}
+
+// CountTracks returns the number of tracks declared by the COLLECTION element
+func (i *TraktorImporter) CountTracks(header []byte) (int, bool) {
+	return xmlCollectionEntries(header, "ENTRIES")
+}
+
+// Parse parses a Traktor NML document
+func (i *TraktorImporter) Parse(r io.Reader) (*Library, error) {
+	var tracks []models.Track
+	library, err := i.Stream(r, func(track models.Track) error {
+		tracks = append(tracks, track)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	library.Tracks = tracks
+	return library, nil
+}
+
+// Stream parses a Traktor NML document token by token, decoding one collection ENTRY at a time
+func (i *TraktorImporter) Stream(r io.Reader, onTrack func(track models.Track) error) (*Library, error) {
+	library := &Library{Source: SourceTraktor}
+
+	// Only the track keys are kept to check playlist entries against the collection
+	keys := make(map[string]bool)
+
+	decoder := xml.NewDecoder(r)
+	root := false
+	inCollection := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+		}
+
+		if end, ok := token.(xml.EndElement); ok && end.Name.Local == "COLLECTION" {
+			inCollection = false
+			continue
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !root {
+			if start.Name.Local != "NML" {
+				return nil, fmt.Errorf("%w: expected element NML, found %s", ErrInvalidFile, start.Name.Local)
+			}
+			root = true
+			for _, attr := range start.Attr {
+				if attr.Name.Local == "VERSION" {
+					library.Version = attr.Value
+				}
+			}
+			continue
+		}
+
+		switch start.Name.Local {
+		case "HEAD":
+			var head TraktorHead
+			if err := decoder.DecodeElement(&head, &start); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			library.ProductName = head.Program
+			library.Company = head.Company
+
+		case "COLLECTION":
+			inCollection = true
+
+		case "ENTRY":
+			if !inCollection {
+				continue
+			}
+			var entry TraktorEntry
+			if err := decoder.DecodeElement(&entry, &start); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			if entry.Location.File == "" {
+				library.Warnf("track %q has no location", entry.Title)
+			}
+			track := convertTraktorEntry(entry)
+			keys[track.TrackID] = true
+			if err := onTrack(track); err != nil {
+				return nil, err
+			}
+
+		case "PLAYLISTS":
+			var playlists TraktorPlaylists
+			if err := decoder.DecodeElement(&playlists, &start); err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrInvalidFile, err)
+			}
+			for _, node := range playlists.Nodes {
+				library.Playlists = append(library.Playlists, convertTraktorNode(node))
+			}
+		}
+	}
+
+	if !root {
+		return nil, fmt.Errorf("%w: missing NML element", ErrInvalidFile)
+	}
+
+	library.checkPlaylistKeysIn(keys)
+	return library, nil
+}
+
+// convertTraktorEntry converts a TraktorEntry to a Track model, including its beatgrid and cue points
+func convertTraktorEntry(entry TraktorEntry) models.Track {
+	fileSize, _ := strconv.ParseInt(entry.Info.FileSize, 10, 64)
+	playtime, _ := strconv.Atoi(entry.Info.Playtime)
+	trackNumber, _ := strconv.Atoi(entry.Album.Track)
+	bitRate, _ := strconv.Atoi(entry.Info.Bitrate)
+	playCount, _ := strconv.Atoi(entry.Info.PlayCount)
+	ranking, _ := strconv.Atoi(entry.Info.Ranking)
+	var bpm float64
+	if entry.Tempo != nil {
+		bpm, _ = strconv.ParseFloat(entry.Tempo.Bpm, 64)
+	}
+
+	year := parseTraktorDate(entry.Info.ReleaseDate).Year()
+	if year <= 1 {
+		year = 0
+	}
+
+	// Prefer the numeric key over the display key, which depends on the user's notation setting
+	tonality := entry.Info.Key
+	if entry.MusicalKey != nil {
+		if value, err := strconv.Atoi(entry.MusicalKey.Value); err == nil {
+			if key, ok := musickey.FromTraktor(value); ok {
+				tonality = key.String()
+			}
+		}
+	}
+
+	// Traktor stores the beatgrid anchor as a grid cue next to the user's cues and loops
+	var tempo []models.Tempo
+	var cues []models.CuePoint
+	for _, cue := range entry.Cues {
+		cueType, _ := strconv.Atoi(cue.Type)
+		start, _ := strconv.ParseFloat(cue.Start, 64)
+
+		if cueType == traktorCueTypeGrid {
+			tempo = append(tempo, models.Tempo{
+				Inizio:  start / 1000,
+				Bpm:     bpm,
+				Metro:   "4/4",
+				Battito: 1,
+			})
+			continue
+		}
+		cues = append(cues, convertTraktorCue(cue, cueType, start))
+	}
+
+	return models.Track{
+		TrackID:     TraktorTrackKey(entry.Location),
+		Name:        entry.Title,
+		Artist:      entry.Artist,
+		Composer:    entry.Info.Producer,
+		Album:       entry.Album.Title,
+		Genre:       entry.Info.Genre,
+		Kind:        fileKind(entry.Location.File),
+		Size:        fileSize * 1024,
+		TotalTime:   playtime,
+		TrackNumber: trackNumber,
+		Year:        year,
+		AverageBpm:  bpm,
+		DateAdded:   parseTraktorDate(entry.Info.ImportDate),
+		BitRate:     bitRate / 1000,
+		Comments:    entry.Info.Comment,
+		PlayCount:   playCount,
+		Rating:      ranking,
+		Location:    traktorLocationToURL(entry.Location),
+		Remixer:     entry.Info.Remixer,
+		Tonality:    tonality,
+		Label:       entry.Info.Label,
+		Mix:         entry.Info.Mix,
+		Tempo:       tempo,
+		CuePoints:   cues,
+	}
+}
+
+// convertTraktorCue converts a Traktor cue other than a grid cue to a CuePoint model
+func convertTraktorCue(cue TraktorCue, cueType int, start float64) models.CuePoint {
+	hotCue, err := strconv.Atoi(cue.HotCue)
+	if err != nil {
+		hotCue = -1
+	}
+
+	cuePoint := models.CuePoint{
+		Type:   cueType,
+		Start:  start / 1000,
+		HotCue: hotCue,
+	}
+	if cue.Name != traktorUnnamedCue {
+		cuePoint.Name = cue.Name
+	}
+
+	if cueType == traktorCueTypeLoop {
+		cuePoint.Type = models.CueTypeLoop
+		length, _ := strconv.ParseFloat(cue.Len, 64)
+		end := (start + length) / 1000
+		cuePoint.End = &end
+	}
+	return cuePoint
+}
+
+// convertTraktorNode recursively converts a TraktorNode (playlist or folder)
+func convertTraktorNode(traktorNode TraktorNode) Node {
+	node := Node{
+		Name: traktorNode.Name,
+		Type: NodeTypeFolder,
+	}
+
+	if traktorNode.Type == "PLAYLIST" {
+		node.Type = NodeTypePlaylist
+		if traktorNode.Playlist != nil {
+			for _, entry := range traktorNode.Playlist.Tracks {
+				node.TrackKeys = append(node.TrackKeys, entry.PrimaryKey.Key)
+			}
+		}
+	}
+
+	if traktorNode.Subnodes != nil {
+		for _, childNode := range traktorNode.Subnodes.Nodes {
+			node.Children = append(node.Children, convertTraktorNode(childNode))
+		}
+	}
+
+	return node
+}
@@ -0,0 +1,240 @@
+package importers
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestTraktorImporterParse(t *testing.T) {
+	library, err := NewTraktorImporter().Parse(bytes.NewReader(readFixture(t, "traktor.nml")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkLibrary(t, library, &Library{
+		Source:      SourceTraktor,
+		Version:     "19",
+		ProductName: "Traktor",
+		Company:     "www.native-instruments.com",
+		Tracks: []models.Track{
+			{
+				TrackID:     "Macintosh HD/:Users/:dj/:Music/:Opening Track.mp3",
+				Name:        "Opening",
+				Artist:      "First Artist",
+				Composer:    "Writer",
+				Album:       "Debut",
+				Genre:       "House",
+				Kind:        "MP3 File",
+				Size:        8196 * 1024,
+				TotalTime:   241,
+				TrackNumber: 3,
+				Year:        2019,
+				AverageBpm:  124,
+				DateAdded:   time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC),
+				BitRate:     320,
+				Comments:    "Good intro",
+				PlayCount:   12,
+				Rating:      204,
+				Location:    "file://localhost/Users/dj/Music/Opening%20Track.mp3",
+				Remixer:     "Someone",
+				Tonality:    "Am",
+				Label:       "Label One",
+				Mix:         "Extended Mix",
+				Tempo: []models.Tempo{
+					{Inizio: 0.025, Bpm: 124, Metro: "4/4", Battito: 1},
+				},
+				CuePoints: []models.CuePoint{
+					{Type: models.CueTypeCue, Start: 32.283, HotCue: 1, Name: "Drop"},
+					{Type: models.CueTypeLoop, Start: 64.541, End: floatPtr(72.541), HotCue: 2},
+				},
+			},
+			{
+				TrackID:   "USB/:Sets/:elsewhere.wav",
+				Name:      "Elsewhere",
+				Artist:    "Second Artist",
+				Kind:      "WAV File",
+				TotalTime: 180,
+				Location:  "file://localhost/Volumes/USB/Sets/elsewhere.wav",
+				Tonality:  "5A",
+			},
+		},
+		Playlists: []Node{{
+			Name: "$ROOT",
+			Type: NodeTypeFolder,
+			Children: []Node{
+				{Name: "Sets", Type: NodeTypeFolder, Children: []Node{
+					{Name: "Friday", Type: NodeTypePlaylist, TrackKeys: []string{
+						"USB/:Sets/:elsewhere.wav",
+						"Macintosh HD/:Users/:dj/:Music/:Opening Track.mp3",
+						"Macintosh HD/:Gone.mp3",
+					}},
+				}},
+				{Name: "Empty", Type: NodeTypePlaylist},
+			},
+		}},
+		Warnings: []string{
+			`playlist "Friday" references 1 track(s) missing from the collection`,
+		},
+	})
+}
+
+func TestTraktorImporterRejectsInvalidFiles(t *testing.T) {
+	tests := map[string]string{
+		"empty":      "",
+		"other root": `<?xml version="1.0"?><DJ_PLAYLISTS Version="1.0.0"></DJ_PLAYLISTS>`,
+		"malformed":  `<NML VERSION="19"><COLLECTION><ENTRY TITLE="A"></COLLECTION></NML>`,
+		"truncated":  `<NML VERSION="19"><COLLECTION ENTRIES="1"><ENTRY TITLE="A`,
+	}
+	for name, document := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := NewTraktorImporter().Parse(strings.NewReader(document)); !errors.Is(err, ErrInvalidFile) {
+				t.Errorf("err = %v, want ErrInvalidFile", err)
+			}
+		})
+	}
+}
+
+func TestTraktorLocationToURL(t *testing.T) {
+	tests := []struct {
+		location TraktorLocation
+		want     string
+	}{
+		{TraktorLocation{Dir: "/:Users/:dj/:", File: "a.mp3", Volume: "Macintosh HD"}, "file://localhost/Users/dj/a.mp3"},
+		{TraktorLocation{Dir: "/:Sets/:", File: "b c.mp3", Volume: "USB"}, "file://localhost/Volumes/USB/Sets/b%20c.mp3"},
+		{TraktorLocation{Dir: "/:Music/:", File: "d.wav", Volume: "C:"}, "file://localhost/C:/Music/d.wav"},
+		{TraktorLocation{Dir: "/:home/:dj/:", File: "e.flac"}, "file://localhost/home/dj/e.flac"},
+	}
+	for _, tt := range tests {
+		if got := traktorLocationToURL(tt.location); got != tt.want {
+			t.Errorf("traktorLocationToURL(%+v) = %q, want %q", tt.location, got, tt.want)
+		}
+	}
+}
+
+func TestConvertTraktorEntry(t *testing.T) {
+	tests := []struct {
+		name  string
+		entry TraktorEntry
+		check func(t *testing.T, track models.Track)
+	}{
+		{
+			name: "numeric key over the display key",
+			entry: TraktorEntry{
+				Info:       TraktorInfo{Key: "1d"},
+				MusicalKey: &TraktorMusicalKey{Value: "0"},
+			},
+			check: func(t *testing.T, track models.Track) {
+				if track.Tonality != "C" {
+					t.Errorf("Tonality = %q, want C", track.Tonality)
+				}
+			},
+		},
+		{
+			name: "display key when the numeric key is invalid",
+			entry: TraktorEntry{
+				Info:       TraktorInfo{Key: "Fm"},
+				MusicalKey: &TraktorMusicalKey{Value: "24"},
+			},
+			check: func(t *testing.T, track models.Track) {
+				if track.Tonality != "Fm" {
+					t.Errorf("Tonality = %q, want Fm", track.Tonality)
+				}
+			},
+		},
+		{
+			name: "units of the collection",
+			entry: TraktorEntry{
+				Location: TraktorLocation{Dir: "/:Music/:", File: "a.flac", Volume: "Macintosh HD"},
+				Info:     TraktorInfo{Bitrate: "1411000", FileSize: "2048", Playtime: "300", ImportDate: "2024/12/31"},
+			},
+			check: func(t *testing.T, track models.Track) {
+				if track.BitRate != 1411 || track.Size != 2048*1024 || track.TotalTime != 300 {
+					t.Errorf("BitRate = %d, Size = %d, TotalTime = %d, want 1411, %d and 300", track.BitRate, track.Size, track.TotalTime, 2048*1024)
+				}
+				if want := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC); !track.DateAdded.Equal(want) {
+					t.Errorf("DateAdded = %v, want %v", track.DateAdded, want)
+				}
+				if track.Kind != "FLAC File" || track.TrackID != "Macintosh HD/:Music/:a.flac" {
+					t.Errorf("Kind = %q and TrackID = %q", track.Kind, track.TrackID)
+				}
+			},
+		},
+		{
+			name:  "missing and invalid values",
+			entry: TraktorEntry{Info: TraktorInfo{Bitrate: "fast", ReleaseDate: "someday", Ranking: ""}},
+			check: func(t *testing.T, track models.Track) {
+				if track.BitRate != 0 || track.Year != 0 || track.Rating != 0 || !track.DateAdded.IsZero() || track.AverageBpm != 0 {
+					t.Errorf("track = %+v, want zero values", track)
+				}
+			},
+		},
+		{
+			name: "grid cue becomes the tempo anchor",
+			entry: TraktorEntry{
+				Tempo: &TraktorTempo{Bpm: "128.000000"},
+				Cues: []TraktorCue{
+					{Name: "AutoGrid", Type: "4", Start: "250.5", HotCue: "-1"},
+					{Name: "n.n.", Type: "0", Start: "1000", HotCue: "x"},
+				},
+			},
+			check: func(t *testing.T, track models.Track) {
+				wantTempo := []models.Tempo{{Inizio: 0.2505, Bpm: 128, Metro: "4/4", Battito: 1}}
+				if !reflect.DeepEqual(track.Tempo, wantTempo) {
+					t.Errorf("Tempo = %+v, want %+v", track.Tempo, wantTempo)
+				}
+				wantCues := []models.CuePoint{{Type: models.CueTypeCue, Start: 1, HotCue: -1}}
+				if !reflect.DeepEqual(track.CuePoints, wantCues) {
+					t.Errorf("CuePoints = %+v, want %+v", track.CuePoints, wantCues)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.check(t, convertTraktorEntry(tt.entry))
+		})
+	}
+}
+
+func TestConvertTraktorNode(t *testing.T) {
+	node := TraktorNode{Type: "FOLDER", Name: "$ROOT", Subnodes: &TraktorSubnodes{Nodes: []TraktorNode{
+		{Type: "PLAYLIST", Name: "Set", Playlist: &TraktorPlaylist{Tracks: []TraktorPlaylistEntry{
+			{PrimaryKey: TraktorPrimaryKey{Type: "TRACK", Key: "USB/:b.mp3"}},
+			{PrimaryKey: TraktorPrimaryKey{Type: "TRACK", Key: "USB/:a.mp3"}},
+		}}},
+		{Type: "PLAYLIST", Name: "No entries"},
+		{Type: "FOLDER", Name: "Empty folder"},
+	}}}
+
+	want := Node{Name: "$ROOT", Type: NodeTypeFolder, Children: []Node{
+		{Name: "Set", Type: NodeTypePlaylist, TrackKeys: []string{"USB/:b.mp3", "USB/:a.mp3"}},
+		{Name: "No entries", Type: NodeTypePlaylist},
+		{Name: "Empty folder", Type: NodeTypeFolder},
+	}}
+	if got := convertTraktorNode(node); !reflect.DeepEqual(got, want) {
+		t.Errorf("convertTraktorNode = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseTraktorDate(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2023/4/5", time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)},
+		{"2023/04/05", time.Date(2023, 4, 5, 0, 0, 0, 0, time.UTC)},
+		{"", time.Time{}},
+		{"5.4.2023", time.Time{}},
+	}
+	for _, tt := range tests {
+		if got := parseTraktorDate(tt.value); !got.Equal(tt.want) {
+			t.Errorf("parseTraktorDate(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -22,8 +22,9 @@ func (m *CorsMiddleware) Cors() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Set CORS headers
 		c.Writer.Header().Set("Access-Control-Allow-Origin", m.config.AllowedOrigins[0])
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, Upload-Offset, Range, If-Range")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "X-Total-Count, Content-Disposition, Upload-Offset, Accept-Ranges, Content-Length, Content-Range, ETag")
 
 		// Handle preflight requests
 		if c.Request.Method == "OPTIONS" {
@@ -0,0 +1,24 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// AnalysisJob tracks the tempo and key analysis of a track, which runs in the background
+type AnalysisJob struct {
+	gorm.Model
+	UserID      uint     `gorm:"not null;index"`
+	TrackID     uint     `gorm:"not null;index"`
+	State       string   `gorm:"not null;index"` // "queued", "running", "completed" or "failed"
+	Overwrite   bool     // Whether to replace imported tempo and key, instead of only filling in missing ones
+	Bpm         float64  // Estimated tempo, 0 if none was found
+	Key         string   // Estimated key in the notation of Tonality, empty if none was found
+	Applied     []string `gorm:"serializer:json;type:text"` // Track fields the results were written to
+	Error       string
+	StartedAt   *time.Time
+	Runner      string     // Server running the job
+	HeartbeatAt *time.Time // Last time the server running the job reported it alive
+	FinishedAt  *time.Time
+}
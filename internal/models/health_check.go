@@ -0,0 +1,36 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// HealthCheck is a check of the files and playlists of a library, which runs in the background. Its
+// report is the list of issues found.
+type HealthCheck struct {
+	gorm.Model
+	UserID           uint          `gorm:"not null;index"`
+	LibraryID        uint          `gorm:"not null;index"`
+	State            string        `gorm:"not null;index"` // "queued", "running", "completed" or "failed"
+	TracksChecked    int           // Number of tracks whose files were checked
+	PlaylistsChecked int           // Number of playlists whose entries were checked
+	Issues           []HealthIssue `gorm:"serializer:json;type:text"`
+	Error            string
+	StartedAt        *time.Time
+	Runner           string     // Server running the job
+	HeartbeatAt      *time.Time // Last time the server running the job reported it alive
+	FinishedAt       *time.Time
+}
+
+// HealthIssue is a problem a health check found with a track or playlist, with a suggestion how to fix it
+type HealthIssue struct {
+	Type       string `json:"type"`                  // e.g. "missing_file" or "empty_playlist"
+	TrackID    uint   `json:"track_id,omitempty"`    // Track the issue is about
+	PlaylistID uint   `json:"playlist_id,omitempty"` // Playlist the issue is about
+	EntryID    uint   `json:"entry_id,omitempty"`    // Playlist entry the issue is about
+	TrackKey   string `json:"track_key,omitempty"`   // Source library ID of the track a playlist entry refers to
+	Location   string `json:"location,omitempty"`    // Location the track's file was looked for at
+	Detail     string `json:"detail"`
+	Suggestion string `json:"suggestion"`
+}
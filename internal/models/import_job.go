@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ImportJob tracks a library upload that is imported in the background
+type ImportJob struct {
+	gorm.Model
+	UserID          uint   `gorm:"not null;index"`
+	Name            string `gorm:"not null"`       // Name of the library to create
+	Source          string `gorm:"not null"`       // Importer used for the upload, e.g. "rekordbox"
+	State           string `gorm:"not null;index"` // "queued", "running", "completed" or "failed"
+	FilePath        string // Staged upload, removed once the job has finished
+	BytesTotal      int64
+	BytesProcessed  int64
+	TracksTotal     int // Number of tracks declared by the file, 0 if the format does not declare it
+	TracksProcessed int
+	Warnings        []string `gorm:"serializer:json;type:text"`
+	Error           string
+	LibraryID       *uint // Set once the job has completed
+	StartedAt       *time.Time
+	Runner          string     // Server running the job
+	HeartbeatAt     *time.Time // Last time the server running the job reported it alive
+	FinishedAt      *time.Time
+}
@@ -1,8 +1,12 @@
 package models
 
 import (
+	"regexp"
+	"strings"
 	"time"
+	"unicode"
 
+	"github.com/dinis/musync/internal/musickey"
 	"gorm.io/gorm"
 )
 
@@ -20,37 +24,95 @@ type MusicLibrary struct {
 	Playlists   []Playlist `gorm:"foreignKey:LibraryID"`
 }
 
+// MetadataSourceAnalyzed marks track metadata that was estimated from the audio instead of imported
+const MetadataSourceAnalyzed = "analyzed"
+
+// Storage types of track files
+const (
+	StorageTypeLocal   = "local"
+	StorageTypeCloud   = "cloud"
+	StorageTypeManaged = "managed" // Uploaded to the server, see TrackFile
+)
+
 // Track represents a music track in a library
 type Track struct {
 	gorm.Model
-	LibraryID   uint   `gorm:"not null"`
-	TrackID     string `gorm:"not null"` // Original ID from the source library
-	Name        string `gorm:"not null"`
-	Artist      string
-	Composer    string
-	Album       string
-	Grouping    string
-	Genre       string
-	Kind        string // File type (e.g., "WAV File")
-	Size        int64  // File size in bytes
-	TotalTime   int    // Duration in seconds
-	DiscNumber  int
-	TrackNumber int
-	Year        int
-	AverageBpm  float64
-	DateAdded   time.Time
-	BitRate     int
-	SampleRate  int
-	Comments    string
-	PlayCount   int
-	Rating      int
-	Location    string `gorm:"not null"`                 // File path or URL
-	StorageType string `gorm:"not null;default:'local'"` // Storage type: "local" or "cloud"
-	Remixer     string
-	Tonality    string // Musical key
-	Label       string
-	Mix         string
-	Tempo       []Tempo `gorm:"foreignKey:TrackID"` // One track can have multiple tempo markers
+	LibraryID    uint   `gorm:"not null"`
+	TrackID      string `gorm:"not null"` // Original ID from the source library
+	Name         string `gorm:"not null"`
+	Artist       string
+	Composer     string
+	Album        string
+	Grouping     string
+	Genre        string
+	Kind         string // File type (e.g., "WAV File")
+	Size         int64  // File size in bytes
+	TotalTime    int    // Duration in seconds
+	DiscNumber   int
+	TrackNumber  int
+	Year         int
+	AverageBpm   float64
+	BpmSource    string // MetadataSourceAnalyzed if AverageBpm was estimated from the audio, empty if imported
+	DateAdded    time.Time
+	BitRate      int
+	SampleRate   int
+	Comments     string
+	PlayCount    int
+	Rating       int
+	Location     string `gorm:"not null"`                 // File path or URL
+	StorageType  string `gorm:"not null;default:'local'"` // Storage type: "local", "cloud" or "managed"
+	FileHash     string `gorm:"index"`                    // SHA-256 of the uploaded file, set for managed storage
+	Remixer      string
+	Tonality     string // Musical key
+	CamelotKey   string `gorm:"index"` // Tonality normalized to Camelot notation, empty if it is not a recognized key
+	KeySource    string // MetadataSourceAnalyzed if Tonality was estimated from the audio, empty if imported
+	Label        string
+	Mix          string
+	DuplicateKey string     `gorm:"index"`              // Normalized artist, title and mix, see DuplicateKey
+	Tempo        []Tempo    `gorm:"foreignKey:TrackID"` // One track can have multiple tempo markers
+	CuePoints    []CuePoint `gorm:"foreignKey:TrackID"` // Memory cues, hot cues and loops
+}
+
+// BeforeSave keeps the normalized key of a track in sync with its tonality, and its duplicate key with its
+// artist, title and mix
+func (t *Track) BeforeSave(tx *gorm.DB) error {
+	t.CamelotKey = ""
+	if key, ok := musickey.Parse(t.Tonality); ok {
+		t.CamelotKey = key.Camelot()
+	}
+	t.DuplicateKey = DuplicateKey(t.Artist, t.Name, t.Mix)
+	return nil
+}
+
+// parenthesizedMix matches a mix name at the end of a title, e.g. "Title (Extended Mix)"
+var parenthesizedMix = regexp.MustCompile(`^(.*?)\s*[(\[]([^)\]]*)[)\]]\s*$`)
+
+// DuplicateKey returns the normalized artist, title and mix that tracks which are copies of the same recording
+// share, or an empty string if there is no title. A mix in parentheses at the end of the title counts as the
+// mix, and the original mix is the same as no mix.
+func DuplicateKey(artist, title, mix string) string {
+	if mix == "" {
+		if match := parenthesizedMix.FindStringSubmatch(title); match != nil && match[1] != "" {
+			title, mix = match[1], match[2]
+		}
+	}
+
+	title = NormalizeWords(title)
+	if title == "" {
+		return ""
+	}
+	mix = NormalizeWords(mix)
+	if mix == "original mix" || mix == "original" {
+		mix = ""
+	}
+	return NormalizeWords(artist) + "|" + title + "|" + mix
+}
+
+// NormalizeWords lowercases text and reduces it to its words
+func NormalizeWords(text string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
 }
 
 // Tempo represents a tempo marker in a track
@@ -61,14 +123,44 @@ type Tempo struct {
 	Bpm     float64
 	Metro   string // Time signature (e.g., "4/4")
 	Battito int    // Beat number
+	Source  string // MetadataSourceAnalyzed if the marker was estimated from the audio, empty if imported
+}
+
+// Cue point types, numbered as in Rekordbox POSITION_MARK elements
+const (
+	CueTypeCue     = 0
+	CueTypeFadeIn  = 1
+	CueTypeFadeOut = 2
+	CueTypeLoad    = 3
+	CueTypeLoop    = 4
+)
+
+// CuePoint represents a memory cue, hot cue or loop in a track
+type CuePoint struct {
+	gorm.Model
+	TrackID uint     `gorm:"not null;index"`
+	Type    int      // One of the CueType constants
+	Start   float64  // Start position in seconds
+	End     *float64 // End position in seconds, only set for loops
+	HotCue  int      // Hot cue number starting at 0 for A, or -1 for a memory cue
+	Color   string   // Colour as "#RRGGBB", empty if the cue has none
+	Name    string
 }
 
+// Playlist types
+const (
+	PlaylistTypeFolder   = 0
+	PlaylistTypePlaylist = 1
+	PlaylistTypeSmart    = 2 // Membership is evaluated from Rules instead of stored entries
+)
+
 // Playlist represents a playlist in a library
 type Playlist struct {
 	gorm.Model
 	LibraryID      uint            `gorm:"not null"`
 	Name           string          `gorm:"not null"`
-	Type           int             // 0 for folder, 1 for playlist
+	Type           int             // 0 for folder, 1 for playlist, 2 for smart playlist
+	Rules          string          `gorm:"type:text"` // Rule tree of a smart playlist as JSON
 	ParentID       *uint           // For nested playlists/folders
 	Parent         *Playlist       `gorm:"foreignKey:ParentID"`
 	PlaylistTracks []PlaylistTrack `gorm:"foreignKey:PlaylistID"`
@@ -77,6 +169,18 @@ type Playlist struct {
 // PlaylistTrack represents a track in a playlist
 type PlaylistTrack struct {
 	gorm.Model
-	PlaylistID uint   `gorm:"not null"`
-	TrackKey   string `gorm:"not null"` // References Track.TrackID
+	PlaylistID uint   `gorm:"not null;index:idx_playlist_tracks_position"`
+	Position   int    `gorm:"not null;default:0;index:idx_playlist_tracks_position"` // 0-based position in the playlist
+	TrackKey   string `gorm:"not null"`                                              // References Track.TrackID; a track may appear more than once
+}
+
+// LibrarySnapshot records the state of a library after an import, re-import, rollback or edit
+type LibrarySnapshot struct {
+	gorm.Model
+	LibraryID     uint   `gorm:"not null;index;uniqueIndex:idx_library_snapshots_library_version"`
+	Version       int    `gorm:"not null;uniqueIndex:idx_library_snapshots_library_version"` // Increments with every snapshot of the library, starting at 1
+	Reason        string `gorm:"not null"`                                                   // "import", "reimport", "rollback", "playlist_edit", "merge" or "rescan"
+	TrackCount    int
+	PlaylistCount int
+	Data          []byte // Gzip compressed JSON of the library's tracks and playlist tree
 }
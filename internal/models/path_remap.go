@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PathRemap rewrites the start of the track locations of a library, for music folders that are not where
+// the DJ software saw them, e.g. "/Volumes/USB/Music" to "/mnt/usb/Music". A From that is a drive letter,
+// e.g. "C:", maps every path on that drive. Rules are tried in order of position and the first match applies.
+type PathRemap struct {
+	gorm.Model
+	LibraryID uint   `gorm:"not null;index"`
+	Position  int    `gorm:"not null"`
+	From      string `gorm:"not null"` // Prefix of the locations to rewrite
+	To        string `gorm:"not null"` // Replacement of the prefix, a path or a storage URL
+}
+
+// RelocateJob applies the path remap rules of a library to its tracks in the background and checks which
+// of the resulting locations hold a file. Its report lists the outcome for every track.
+type RelocateJob struct {
+	gorm.Model
+	UserID      uint             `gorm:"not null;index"`
+	LibraryID   uint             `gorm:"not null;index"`
+	State       string           `gorm:"not null;index"` // "queued", "running", "completed" or "failed"
+	Found       int              // Number of tracks whose file exists
+	Missing     int              // Number of tracks whose storage backend has no file at the location
+	Unreachable int              // Number of tracks whose location no backend serves, or whose backend failed
+	Tracks      []RelocateResult `gorm:"serializer:json;type:text"`
+	Error       string
+	StartedAt   *time.Time
+	Runner      string     // Server running the job
+	HeartbeatAt *time.Time // Last time the server running the job reported it alive
+	FinishedAt  *time.Time
+}
+
+// RelocateResult is the remapped location of a track in a relocate report, and whether its file exists there
+type RelocateResult struct {
+	TrackID          uint   `json:"track_id"`
+	Title            string `json:"title"`
+	Artist           string `json:"artist"`
+	Location         string `json:"location"`          // Location the library records
+	ResolvedLocation string `json:"resolved_location"` // Location after the remap rules are applied
+	RuleID           *uint  `json:"rule_id,omitempty"` // Rule that rewrote the location, nil if none matched
+	Status           string `json:"status"`            // "found", "missing" or "unreachable"
+	Error            string `json:"error,omitempty"`   // Why the file is unreachable
+}
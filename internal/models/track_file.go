@@ -0,0 +1,33 @@
+package models
+
+import (
+	"gorm.io/gorm"
+)
+
+// TrackFile is an audio file uploaded to server-managed storage. Files are stored under the SHA-256 hash
+// of their contents, so that uploading the same file twice stores it once.
+type TrackFile struct {
+	gorm.Model
+	UserID uint   `gorm:"not null;uniqueIndex:idx_track_files_user_hash"`
+	Hash   string `gorm:"not null;uniqueIndex:idx_track_files_user_hash"` // Hex encoded SHA-256
+	Size   int64
+	Name   string // File name of the latest upload
+}
+
+// FileUpload is a resumable upload of a track file. Chunks are staged until the
+// declared size is reached, after which the file is moved into managed storage.
+type FileUpload struct {
+	gorm.Model
+	UserID       uint   `gorm:"not null;index"`
+	State        string `gorm:"not null;index"` // "uploading", "completing", "completed" or "failed"
+	Name         string `gorm:"not null"`       // File name
+	Path         string // Path of the file on the client, used to find its tracks
+	Size         int64  // Declared size in bytes
+	Offset       int64  // Number of bytes received
+	Hash         string // SHA-256 declared by the client, or computed once completed
+	StagingPath  string // Staged file, followed by a file per chunk, removed once the upload has finished
+	TrackIDs     []uint `gorm:"serializer:json;type:text"` // Tracks to link the file to besides the matching ones
+	TrackFileID  *uint  // Set once completed
+	LinkedTracks []uint `gorm:"serializer:json;type:text"` // Tracks linked to the file once completed
+	Error        string
+}
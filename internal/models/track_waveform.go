@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TrackWaveform is the cached waveform overview of a track. It is generated in the background,
+// and regenerated when the location or size of the track's file changes.
+type TrackWaveform struct {
+	gorm.Model
+	TrackID     uint   `gorm:"not null;uniqueIndex"`
+	State       string `gorm:"not null;index"` // "queued", "running", "completed" or "failed"
+	Location    string // Location and size of the file the overview was generated from
+	Size        int64
+	Format      string // Container format of the file, e.g. "flac"
+	SampleRate  int
+	Duration    float64 // In seconds
+	Peaks       []byte  // Peak amplitude of each point, from 0 to 255
+	RMS         []byte  // RMS amplitude of each point, from 0 to 255
+	Error       string
+	StartedAt   *time.Time
+	Runner      string     // Server running the job
+	HeartbeatAt *time.Time // Last time the server running the job reported it alive
+	FinishedAt  *time.Time
+}
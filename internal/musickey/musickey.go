@@ -0,0 +1,170 @@
+package musickey
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Key is a musical key made of a root pitch class and a mode
+type Key struct {
+	Root  int // Pitch class: 0 = C, 1 = C#/Db, ... 11 = B
+	Minor bool
+}
+
+// Key names in the notation Rekordbox uses for its Tonality attribute, indexed by pitch class
+var (
+	majorNames = []string{"C", "Db", "D", "Eb", "E", "F", "F#", "G", "Ab", "A", "Bb", "B"}
+	minorNames = []string{"Cm", "C#m", "Dm", "Ebm", "Em", "Fm", "F#m", "Gm", "G#m", "Am", "Bbm", "Bm"}
+)
+
+// naturalPitches maps note letters to pitch classes
+var naturalPitches = map[byte]int{'C': 0, 'D': 2, 'E': 4, 'F': 5, 'G': 7, 'A': 9, 'B': 11}
+
+// Parse parses a key in standard notation, e.g. "Am", "F#", "Bbm", "A minor" or "Eb major",
+// in Camelot notation, e.g. "8A", or in Open Key notation, e.g. "1m"
+func Parse(value string) (Key, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return Key{}, false
+	}
+	if value[0] >= '0' && value[0] <= '9' {
+		return parseWheel(value)
+	}
+
+	root, ok := naturalPitches[strings.ToUpper(value[:1])[0]]
+	if !ok {
+		return Key{}, false
+	}
+	rest := value[1:]
+
+	// Accidentals
+	switch {
+	case strings.HasPrefix(rest, "#"):
+		root++
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "♯"):
+		root++
+		rest = strings.TrimPrefix(rest, "♯")
+	case strings.HasPrefix(rest, "b"):
+		root--
+		rest = rest[1:]
+	case strings.HasPrefix(rest, "♭"):
+		root--
+		rest = strings.TrimPrefix(rest, "♭")
+	}
+	root = (root + 12) % 12
+
+	// Mode
+	switch strings.ToLower(strings.TrimSpace(rest)) {
+	case "", "maj", "major":
+		return Key{Root: root}, true
+	case "m", "min", "minor":
+		return Key{Root: root, Minor: true}, true
+	default:
+		return Key{}, false
+	}
+}
+
+// FromTraktor converts a Traktor MUSICAL_KEY value (0-11 major, 12-23 minor) to a Key
+func FromTraktor(value int) (Key, bool) {
+	if value < 0 || value > 23 {
+		return Key{}, false
+	}
+	return Key{Root: value % 12, Minor: value >= 12}, true
+}
+
+// Traktor returns the Traktor MUSICAL_KEY value for the key
+func (k Key) Traktor() int {
+	if k.Minor {
+		return k.Root + 12
+	}
+	return k.Root
+}
+
+// String returns the key in Rekordbox's notation, e.g. "Am" or "Eb"
+func (k Key) String() string {
+	if k.Minor {
+		return minorNames[k.Root]
+	}
+	return majorNames[k.Root]
+}
+
+// parseWheel parses a key in Camelot ("1A" to "12B") or Open Key ("1m" to "12d") notation
+func parseWheel(value string) (Key, bool) {
+	digits := 0
+	for digits < len(value) && value[digits] >= '0' && value[digits] <= '9' {
+		digits++
+	}
+	number, err := strconv.Atoi(value[:digits])
+	if err != nil || number < 1 || number > 12 {
+		return Key{}, false
+	}
+
+	switch strings.TrimSpace(value[digits:]) {
+	case "A", "a":
+		return FromCamelot(number, true), true
+	case "B", "b":
+		return FromCamelot(number, false), true
+	case "m", "M":
+		return FromCamelot(openKeyToCamelot(number), true), true
+	case "d", "D":
+		return FromCamelot(openKeyToCamelot(number), false), true
+	default:
+		return Key{}, false
+	}
+}
+
+// FromCamelot returns the key at a position of the Camelot wheel, numbered 1 to 12,
+// in the inner (minor, "A") or outer (major, "B") ring
+func FromCamelot(number int, minor bool) Key {
+	// Each step around the wheel is a fifth (7 semitones), and 7 is its own inverse modulo 12
+	offset := 7
+	if minor {
+		offset = 4
+	}
+	return Key{Root: ((7*(number-1-offset))%12 + 12) % 12, Minor: minor}
+}
+
+// CamelotNumber returns the position of the key on the Camelot wheel, from 1 to 12
+func (k Key) CamelotNumber() int {
+	// 8B is C major and 8A is A minor
+	offset := 7
+	if k.Minor {
+		offset = 4
+	}
+	return (7*k.Root+offset)%12 + 1
+}
+
+// Camelot returns the key in Camelot notation, e.g. "8A" for A minor
+func (k Key) Camelot() string {
+	if k.Minor {
+		return strconv.Itoa(k.CamelotNumber()) + "A"
+	}
+	return strconv.Itoa(k.CamelotNumber()) + "B"
+}
+
+// OpenKey returns the key in Open Key notation, e.g. "1m" for A minor
+func (k Key) OpenKey() string {
+	number := (k.CamelotNumber()+4)%12 + 1
+	if k.Minor {
+		return strconv.Itoa(number) + "m"
+	}
+	return strconv.Itoa(number) + "d"
+}
+
+// openKeyToCamelot converts an Open Key number to the Camelot number of the same key
+func openKeyToCamelot(number int) int {
+	return (number+6)%12 + 1
+}
+
+// Compatible returns the keys that mix harmonically with the key: the key itself, its neighbours
+// one step either way around the wheel, and its relative major or minor
+func (k Key) Compatible() []Key {
+	number := k.CamelotNumber()
+	return []Key{
+		k,
+		FromCamelot(number%12+1, k.Minor),
+		FromCamelot((number+10)%12+1, k.Minor),
+		FromCamelot(number, !k.Minor),
+	}
+}
@@ -0,0 +1,152 @@
+package musickey
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value string
+		want  Key
+	}{
+		// Standard notation
+		{"C", Key{Root: 0}},
+		{"Am", Key{Root: 9, Minor: true}},
+		{"F#", Key{Root: 6}},
+		{"Bbm", Key{Root: 10, Minor: true}},
+		{"A minor", Key{Root: 9, Minor: true}},
+		{"Eb major", Key{Root: 3}},
+		{"d min", Key{Root: 2, Minor: true}},
+		{"G maj", Key{Root: 7}},
+		{" Em ", Key{Root: 4, Minor: true}},
+		{"F♯m", Key{Root: 6, Minor: true}},
+		{"B♭", Key{Root: 10}},
+
+		// Enharmonic spellings
+		{"C#m", Key{Root: 1, Minor: true}},
+		{"Dbm", Key{Root: 1, Minor: true}},
+		{"Gb", Key{Root: 6}},
+		{"Cb", Key{Root: 11}},
+		{"E#", Key{Root: 5}},
+		{"G#m", Key{Root: 8, Minor: true}},
+		{"Abm", Key{Root: 8, Minor: true}},
+
+		// Camelot notation
+		{"8A", Key{Root: 9, Minor: true}},
+		{"8B", Key{Root: 0}},
+		{"1A", Key{Root: 8, Minor: true}},
+		{"12B", Key{Root: 4}},
+		{"11a", Key{Root: 6, Minor: true}},
+		{"5b", Key{Root: 3}},
+
+		// Open Key notation
+		{"1m", Key{Root: 9, Minor: true}},
+		{"1d", Key{Root: 0}},
+		{"6m", Key{Root: 8, Minor: true}},
+		{"12d", Key{Root: 5}},
+		{"4M", Key{Root: 6, Minor: true}},
+	}
+	for _, tt := range tests {
+		got, ok := Parse(tt.value)
+		if !ok {
+			t.Errorf("Parse(%q) failed", tt.value)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestParseRejectsInvalidKeys(t *testing.T) {
+	for _, value := range []string{"", "  ", "H", "X#m", "Am7", "C dorian", "0A", "13B", "8C", "8", "1x", "A#b", "#A"} {
+		if key, ok := Parse(value); ok {
+			t.Errorf("Parse(%q) = %+v, want failure", value, key)
+		}
+	}
+}
+
+func TestNotationsRoundTrip(t *testing.T) {
+	for root := 0; root < 12; root++ {
+		for _, minor := range []bool{false, true} {
+			key := Key{Root: root, Minor: minor}
+			for _, value := range []string{key.String(), key.Camelot(), key.OpenKey()} {
+				if got, ok := Parse(value); !ok || got != key {
+					t.Errorf("Parse(%q) = %+v, %v, want %+v", value, got, ok, key)
+				}
+			}
+			if got, ok := FromTraktor(key.Traktor()); !ok || got != key {
+				t.Errorf("FromTraktor(%d) = %+v, %v, want %+v", key.Traktor(), got, ok, key)
+			}
+			if got := FromCamelot(key.CamelotNumber(), key.Minor); got != key {
+				t.Errorf("FromCamelot(%d, %v) = %+v, want %+v", key.CamelotNumber(), key.Minor, got, key)
+			}
+		}
+	}
+}
+
+func TestNotations(t *testing.T) {
+	tests := []struct {
+		key     Key
+		name    string
+		camelot string
+		openKey string
+		traktor int
+	}{
+		{Key{Root: 0}, "C", "8B", "1d", 0},
+		{Key{Root: 9, Minor: true}, "Am", "8A", "1m", 21},
+		{Key{Root: 7}, "G", "9B", "2d", 7},
+		{Key{Root: 6, Minor: true}, "F#m", "11A", "4m", 18},
+		{Key{Root: 3}, "Eb", "5B", "10d", 3},
+		{Key{Root: 8, Minor: true}, "G#m", "1A", "6m", 20},
+		{Key{Root: 4}, "E", "12B", "5d", 4},
+	}
+	for _, tt := range tests {
+		if got := tt.key.String(); got != tt.name {
+			t.Errorf("%+v.String() = %q, want %q", tt.key, got, tt.name)
+		}
+		if got := tt.key.Camelot(); got != tt.camelot {
+			t.Errorf("%+v.Camelot() = %q, want %q", tt.key, got, tt.camelot)
+		}
+		if got := tt.key.OpenKey(); got != tt.openKey {
+			t.Errorf("%+v.OpenKey() = %q, want %q", tt.key, got, tt.openKey)
+		}
+		if got := tt.key.Traktor(); got != tt.traktor {
+			t.Errorf("%+v.Traktor() = %d, want %d", tt.key, got, tt.traktor)
+		}
+	}
+}
+
+func TestFromTraktorRejectsOutOfRange(t *testing.T) {
+	for _, value := range []int{-1, 24, 100} {
+		if key, ok := FromTraktor(value); ok {
+			t.Errorf("FromTraktor(%d) = %+v, want failure", value, key)
+		}
+	}
+}
+
+func TestCompatible(t *testing.T) {
+	tests := []struct {
+		key  string
+		want []string
+	}{
+		{"8A", []string{"8A", "9A", "7A", "8B"}},
+		{"8B", []string{"8B", "9B", "7B", "8A"}},
+		{"12A", []string{"12A", "1A", "11A", "12B"}},
+		{"1B", []string{"1B", "2B", "12B", "1A"}},
+	}
+	for _, tt := range tests {
+		key, ok := Parse(tt.key)
+		if !ok {
+			t.Fatalf("Parse(%q) failed", tt.key)
+		}
+		var got []string
+		for _, compatible := range key.Compatible() {
+			got = append(got, compatible.Camelot())
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s compatible keys = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
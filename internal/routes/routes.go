@@ -4,10 +4,23 @@ import (
 	"github.com/dinis/musync/internal/config"
 	"github.com/dinis/musync/internal/handlers"
 	"github.com/dinis/musync/internal/middleware"
+	"github.com/dinis/musync/internal/services"
 	"github.com/gin-gonic/gin"
 )
 
-func SetupRoutes(r *gin.Engine, cfg *config.Config) {
+// Services are the services with background workers that the routes use. They are created and started by the
+// caller, which stops them on shutdown.
+type Services struct {
+	FileStorage  *services.FileStorageService
+	TrackFiles   *services.TrackFileService
+	ImportJobs   *services.ImportJobService
+	Waveforms    *services.WaveformService
+	Analysis     *services.AnalysisService
+	HealthChecks *services.HealthCheckService
+	Relocations  *services.RelocateJobService
+}
+
+func SetupRoutes(r *gin.Engine, cfg *config.Config, svc *Services) {
 	// Add a root handler
 	r.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -23,7 +36,12 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 	})
 
 	authHandler := handlers.NewAuthHandler(cfg.Auth, cfg.Email)
-	musicLibraryHandler := handlers.NewMusicLibraryHandler()
+	musicLibraryHandler := handlers.NewMusicLibraryHandler(svc.FileStorage)
+	importJobHandler := handlers.NewImportJobHandler(svc.ImportJobs)
+	audioAnalysisHandler := handlers.NewAudioAnalysisHandler(svc.Waveforms, svc.Analysis)
+	trackFileHandler := handlers.NewTrackFileHandler(svc.FileStorage, svc.TrackFiles)
+	healthCheckHandler := handlers.NewHealthCheckHandler(svc.HealthChecks)
+	relocateJobHandler := handlers.NewRelocateJobHandler(svc.Relocations)
 
 	// Public routes
 	public := r.Group("/api")
@@ -50,18 +68,69 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 		// Music library routes
 		library := protected.Group("/libraries")
 		{
-			library.POST("", musicLibraryHandler.UploadLibrary)
+			library.POST("", importJobHandler.UploadLibrary)
 			library.GET("", musicLibraryHandler.GetLibraries)
 			library.GET("/:id", musicLibraryHandler.GetLibrary)
 			library.GET("/:id/tracks", musicLibraryHandler.GetTracks)
 			library.GET("/:id/playlists", musicLibraryHandler.GetPlaylists)
+			library.POST("/:id/smart-playlists", musicLibraryHandler.CreateSmartPlaylist)
+			library.PUT("/:id/import", importJobHandler.ReimportLibrary)
+			library.GET("/:id/export", musicLibraryHandler.ExportLibrary)
+			library.POST("/:id/analyze", audioAnalysisHandler.AnalyzeLibrary)
+			library.GET("/:id/path-remaps", musicLibraryHandler.GetPathRemaps)
+			library.PUT("/:id/path-remaps", musicLibraryHandler.SetPathRemaps)
+			library.POST("/:id/relocate", relocateJobHandler.RelocateLibrary)
+			library.GET("/:id/relocate", relocateJobHandler.GetLibraryRelocation)
+			library.POST("/:id/health-check", healthCheckHandler.CheckLibraryHealth)
+			library.GET("/:id/health-check", healthCheckHandler.GetLibraryHealth)
+			library.GET("/:id/versions", musicLibraryHandler.GetLibraryVersions)
+			library.GET("/:id/versions/diff", musicLibraryHandler.DiffLibraryVersions)
+			library.POST("/:id/versions/:version/rollback", musicLibraryHandler.RollbackLibrary)
 			library.DELETE("/:id", musicLibraryHandler.DeleteLibrary)
 		}
 
+		// Import routes
+		imports := protected.Group("/imports")
+		{
+			imports.GET("/:id", importJobHandler.GetImportJob)
+		}
+
+		// Analysis routes
+		analyses := protected.Group("/analysis")
+		{
+			analyses.GET("/:id", audioAnalysisHandler.GetAnalysisJob)
+		}
+
+		// Health check routes
+		healthChecks := protected.Group("/health-checks")
+		{
+			healthChecks.GET("/:id", healthCheckHandler.GetHealthCheck)
+		}
+
+		// Relocate job routes
+		relocateJobs := protected.Group("/relocate-jobs")
+		{
+			relocateJobs.GET("/:id", relocateJobHandler.GetRelocateJob)
+		}
+
+		// Track file upload routes
+		uploads := protected.Group("/files/uploads")
+		{
+			uploads.POST("", trackFileHandler.CreateFileUpload)
+			uploads.GET("/:id", trackFileHandler.GetFileUpload)
+			uploads.PATCH("/:id", trackFileHandler.UploadFileChunk)
+			uploads.DELETE("/:id", trackFileHandler.CancelFileUpload)
+		}
+
 		// Playlist routes
 		playlist := protected.Group("/playlists")
 		{
 			playlist.GET("/:id/tracks", musicLibraryHandler.GetPlaylistTracks)
+			playlist.POST("/:id/tracks", musicLibraryHandler.AddPlaylistTrack)
+			playlist.PUT("/:id/tracks", musicLibraryHandler.ReorderPlaylist)
+			playlist.PUT("/:id/tracks/:entryId/position", musicLibraryHandler.MovePlaylistTrack)
+			playlist.DELETE("/:id/tracks/:entryId", musicLibraryHandler.RemovePlaylistTrack)
+			playlist.PUT("/:id/rules", musicLibraryHandler.UpdateSmartPlaylist)
 		}
 
 		// Folder routes
@@ -73,7 +142,23 @@ func SetupRoutes(r *gin.Engine, cfg *config.Config) {
 		// Track routes
 		track := protected.Group("/tracks")
 		{
-			track.GET("/:id/stream", musicLibraryHandler.StreamTrack)
+			track.GET("/:id", musicLibraryHandler.GetTrack)
+			track.GET("/:id/stream", trackFileHandler.StreamTrack)
+			track.HEAD("/:id/stream", trackFileHandler.StreamTrack)
+			track.GET("/:id/compatible", musicLibraryHandler.GetCompatibleTracks)
+			track.POST("/:id/rescan", musicLibraryHandler.RescanTrack)
+			track.GET("/:id/waveform", audioAnalysisHandler.GetWaveform)
+			track.POST("/:id/analyze", audioAnalysisHandler.AnalyzeTrack)
+		}
+
+		// Search route
+		protected.GET("/search", musicLibraryHandler.Search)
+
+		// Duplicate routes
+		duplicates := protected.Group("/duplicates")
+		{
+			duplicates.GET("", musicLibraryHandler.GetDuplicates)
+			duplicates.POST("/merge", musicLibraryHandler.MergeDuplicates)
 		}
 
 		// The following route groups are commented out to avoid unused variable warnings
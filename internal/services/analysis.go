@@ -0,0 +1,308 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dinis/musync/internal/analysis"
+	"github.com/dinis/musync/internal/config"
+	"github.com/dinis/musync/internal/database"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/models"
+)
+
+// Analysis job states
+const (
+	AnalysisQueued    = "queued"
+	AnalysisRunning   = "running"
+	AnalysisCompleted = "completed"
+	AnalysisFailed    = "failed"
+)
+
+// Track fields an analysis can write to, as listed in AnalysisJob.Applied
+const (
+	AnalysisFieldBpm      = "bpm"
+	AnalysisFieldKey      = "key"
+	AnalysisFieldBeatgrid = "beatgrid"
+)
+
+// AnalysisService estimates the tempo, beatgrid and key of tracks from their audio in the background.
+// Results fill in the metadata an import did not provide and are marked as analyzed. Jobs are persisted,
+// so that those interrupted by a restart are run again, and the jobs of a user run with limited concurrency.
+type AnalysisService struct {
+	db          *database.DB
+	fileStorage *FileStorageService
+	config      config.AnalysisConfig
+	jobs        *jobRunner[models.AnalysisJob]
+}
+
+// NewAnalysisService creates a new AnalysisService
+func NewAnalysisService(db *database.DB, cfg config.AnalysisConfig, fileStorage *FileStorageService) *AnalysisService {
+	s := &AnalysisService{
+		db:          db,
+		fileStorage: fileStorage,
+		config:      cfg,
+	}
+	s.jobs = newJobRunner(db, "analysis job", "analysis_jobs", s.run)
+	// Jobs are claimed within the per-user limit instead of oldest first
+	s.jobs.claim = s.claimNext
+	return s
+}
+
+// Start requeues interrupted jobs and starts the analysis workers, which run until ctx is cancelled
+func (s *AnalysisService) Start(ctx context.Context) error {
+	if err := s.jobs.requeue(ctx); err != nil {
+		return err
+	}
+	s.jobs.start(ctx, s.config.Workers)
+	return nil
+}
+
+// Wait blocks until the analysis workers have stopped after the context passed to Start was cancelled
+func (s *AnalysisService) Wait() {
+	s.jobs.wait()
+}
+
+// EnqueueTrack queues the analysis of a track of the user. If the track is already queued, that job is
+// returned instead. With overwrite, the results replace the tempo, key and beatgrid the track already has.
+func (s *AnalysisService) EnqueueTrack(ctx context.Context, userID, trackID uint, overwrite bool) (*models.AnalysisJob, error) {
+	track, err := s.fileStorage.GetTrackInfo(ctx, userID, trackID)
+	if err != nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	var job models.AnalysisJob
+	err = s.db.Where(ctx, "track_id = ? AND state = ?", track.ID, AnalysisQueued).First(ctx, &job)
+	switch {
+	case err == nil:
+		if overwrite && !job.Overwrite {
+			if _, err := s.db.Where(ctx, "id = ?", job.ID).UpdateColumns(ctx, &models.AnalysisJob{}, map[string]interface{}{"overwrite": true}); err != nil {
+				return nil, err
+			}
+			job.Overwrite = true
+		}
+		return &job, nil
+	case !errors.Is(err, apperrors.ErrNotFound):
+		return nil, err
+	}
+
+	job = models.AnalysisJob{UserID: userID, TrackID: track.ID, State: AnalysisQueued, Overwrite: overwrite}
+	if err := s.db.Create(ctx, &job); err != nil {
+		return nil, err
+	}
+	s.jobs.notify()
+	return &job, nil
+}
+
+// EnqueueLibrary queues the analysis of the tracks of a library of the user that lack a tempo, key or
+// beatgrid, or of all its tracks with overwrite. Tracks that are already queued are not queued again.
+// It returns the number of tracks queued.
+func (s *AnalysisService) EnqueueLibrary(ctx context.Context, userID, libraryID uint, overwrite bool) (int, error) {
+	var library models.MusicLibrary
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", libraryID, userID).First(ctx, &library); err != nil {
+		return 0, err
+	}
+
+	var queued int
+	err := s.db.Transaction(ctx, func(tx *database.DB) error {
+		pending := "SELECT track_id FROM analysis_jobs WHERE state = ? AND deleted_at IS NULL"
+		if overwrite {
+			// Tracks that are already queued are analysed with overwrite too
+			if _, err := tx.Where(ctx, "state = ? AND track_id IN (SELECT id FROM tracks WHERE library_id = ? AND deleted_at IS NULL)", AnalysisQueued, library.ID).UpdateColumns(ctx, &models.AnalysisJob{}, map[string]interface{}{"overwrite": true}); err != nil {
+				return err
+			}
+		}
+
+		query := tx.Where(ctx, "library_id = ? AND id NOT IN ("+pending+")", library.ID, AnalysisQueued)
+		if !overwrite {
+			query = query.Where(ctx, "average_bpm = 0 OR tonality = '' OR NOT EXISTS (SELECT 1 FROM tempos WHERE tempos.track_id = tracks.id AND tempos.deleted_at IS NULL)")
+		}
+		var tracks []models.Track
+		if err := query.Order("id").Find(ctx, &tracks); err != nil {
+			return err
+		}
+		if len(tracks) == 0 {
+			return nil
+		}
+
+		jobs := make([]models.AnalysisJob, len(tracks))
+		for i, track := range tracks {
+			jobs[i] = models.AnalysisJob{UserID: userID, TrackID: track.ID, State: AnalysisQueued, Overwrite: overwrite}
+		}
+		if err := tx.CreateInBatches(ctx, &jobs, 500); err != nil {
+			return err
+		}
+		queued = len(jobs)
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	if queued > 0 {
+		s.jobs.notify()
+	}
+	return queued, nil
+}
+
+// GetJob returns an analysis job of the user
+func (s *AnalysisService) GetJob(ctx context.Context, userID, jobID uint) (*models.AnalysisJob, error) {
+	var job models.AnalysisJob
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", jobID, userID).First(ctx, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// claimNext marks the oldest queued job of a user with fewer running jobs than the per-user limit as running
+// and returns it, or nil if there is no such job
+func (s *AnalysisService) claimNext(ctx context.Context) (*models.AnalysisJob, error) {
+	perUser := max(s.config.PerUser, 1)
+	for {
+		var job models.AnalysisJob
+		retry := false
+		err := s.db.Transaction(ctx, func(tx *database.DB) error {
+			err := tx.Where(ctx, "state = ? AND (SELECT COUNT(*) FROM analysis_jobs AS running WHERE running.user_id = analysis_jobs.user_id AND running.state = ? AND running.deleted_at IS NULL) < ?", AnalysisQueued, AnalysisRunning, perUser).Order("id").First(ctx, &job)
+			if err != nil {
+				return err
+			}
+
+			// Claims of the jobs of a user are serialized on the user's row, so that workers claiming
+			// concurrently cannot exceed the limit
+			var user models.User
+			if err := tx.Where(ctx, "id = ?", job.UserID).ForUpdate().First(ctx, &user); err != nil {
+				return err
+			}
+			running, err := tx.Where(ctx, "user_id = ? AND state = ?", job.UserID, AnalysisRunning).Count(ctx, &models.AnalysisJob{})
+			if err != nil {
+				return err
+			}
+			if running >= int64(perUser) {
+				retry = true
+				return nil
+			}
+
+			now := time.Now()
+			claimed, err := tx.Where(ctx, "id = ? AND state = ?", job.ID, AnalysisQueued).UpdateColumns(ctx, &models.AnalysisJob{}, map[string]interface{}{
+				"state":        AnalysisRunning,
+				"started_at":   now,
+				"runner":       jobRunnerID,
+				"heartbeat_at": now,
+			})
+			if err != nil {
+				return err
+			}
+			if claimed != 1 {
+				retry = true
+				return nil
+			}
+			job.State = AnalysisRunning
+			job.StartedAt = &now
+			return nil
+		})
+		switch {
+		case errors.Is(err, apperrors.ErrNotFound):
+			return nil, nil
+		case err != nil:
+			return nil, err
+		case !retry:
+			return &job, nil
+		}
+	}
+}
+
+// run analyses the track of a job, applies the results and records the outcome
+func (s *AnalysisService) run(ctx context.Context, job *models.AnalysisJob) {
+	logger := logging.GetLogger()
+
+	result, err := s.analyze(ctx, job)
+	if err == nil {
+		err = s.apply(ctx, job, result)
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		logger.Warn("Analysis of track %d failed: %v", job.TrackID, err)
+		job.State = AnalysisFailed
+		job.Error = err.Error()
+	} else {
+		job.State = AnalysisCompleted
+	}
+
+	if err := s.db.Save(ctx, job); err != nil {
+		logger.Error("Failed to record outcome of analysis job %d: %v", job.ID, err)
+	}
+}
+
+// analyze decodes the file of a job's track and estimates its tempo and key
+func (s *AnalysisService) analyze(ctx context.Context, job *models.AnalysisJob) (*analysis.Result, error) {
+	file, _, err := s.fileStorage.GetFileStream(ctx, job.UserID, job.TrackID)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return analysis.Analyze(file)
+}
+
+// apply writes the results of an analysis to the job and its track. Unless the job overwrites, only the
+// tempo, key and beatgrid the track lacks are written.
+func (s *AnalysisService) apply(ctx context.Context, job *models.AnalysisJob, result *analysis.Result) error {
+	job.Bpm = result.Bpm
+	job.Key = ""
+	if result.HasKey {
+		job.Key = result.Key.String()
+	}
+	job.Applied = []string{}
+
+	return s.db.Transaction(ctx, func(tx *database.DB) error {
+		var track models.Track
+		if err := tx.Where(ctx, "id = ?", job.TrackID).ForUpdate().First(ctx, &track); err != nil {
+			return err
+		}
+		markers, err := tx.Where(ctx, "track_id = ?", track.ID).Count(ctx, &models.Tempo{})
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		if result.Bpm > 0 && (job.Overwrite || track.AverageBpm == 0) {
+			track.AverageBpm, track.BpmSource = result.Bpm, models.MetadataSourceAnalyzed
+			job.Applied = append(job.Applied, AnalysisFieldBpm)
+			changed = true
+		}
+		if result.HasKey && (job.Overwrite || track.Tonality == "") {
+			track.Tonality, track.KeySource = job.Key, models.MetadataSourceAnalyzed
+			job.Applied = append(job.Applied, AnalysisFieldKey)
+			changed = true
+		}
+		if changed {
+			// Saving runs the hook that keeps the Camelot key in sync
+			if err := tx.Save(ctx, &track); err != nil {
+				return err
+			}
+		}
+
+		if result.Bpm > 0 && (job.Overwrite || markers == 0) {
+			if err := tx.Where(ctx, "track_id = ?", track.ID).Delete(ctx, &models.Tempo{}); err != nil {
+				return err
+			}
+			marker := models.Tempo{
+				TrackID: track.ID,
+				Inizio:  result.FirstBeat,
+				Bpm:     result.Bpm,
+				Metro:   "4/4",
+				Battito: 1,
+				Source:  models.MetadataSourceAnalyzed,
+			}
+			if err := tx.Create(ctx, &marker); err != nil {
+				return err
+			}
+			job.Applied = append(job.Applied, AnalysisFieldBeatgrid)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+)
+
+// Defaults of duplicate detection
+const (
+	DefaultDuplicateDurationTolerance = 3 // Seconds
+	MaxDuplicateDurationTolerance     = 60
+	maxDuplicateBucketSize            = 50 // Larger buckets are too generic to tell duplicates apart
+)
+
+// Reasons tracks are considered duplicates
+const (
+	DuplicateReasonMetadata = "metadata" // Same normalized artist, title and mix
+	DuplicateReasonSize     = "size"     // Same file size
+	DuplicateReasonLocation = "location" // Same file
+)
+
+// Confidence of each reason two tracks are duplicates. A shared file is certain; the other scores add up.
+const (
+	locationConfidence = 1.0
+	metadataConfidence = 0.6
+	sizeConfidence     = 0.3
+	durationConfidence = 0.1 // Both durations are known and within the tolerance
+)
+
+// DuplicateGroup is a set of tracks that are likely copies of the same recording
+type DuplicateGroup struct {
+	Confidence float64  // From 0 to 1, the confidence of the weakest link between the tracks
+	Reasons    []string // The reasons of the links between the tracks
+	Tracks     []models.Track
+}
+
+// DuplicateMerge summarizes the merge of duplicate tracks into a keeper
+type DuplicateMerge struct {
+	KeeperID         uint
+	PlaylistsChanged []uint
+	EntriesRepointed int
+	EntriesRemoved   int // Entries of playlists that already held the keeper
+	TracksDeleted    []uint
+}
+
+// duplicateEdge links two tracks that are likely duplicates
+type duplicateEdge struct {
+	a, b       int
+	confidence float64
+	reasons    []string
+}
+
+// duplicatePair is a pair of tracks that share a duplicate signal
+type duplicatePair struct {
+	TrackA uint
+	TrackB uint
+}
+
+// duplicateCandidatesQuery finds the pairs of tracks, within and across the libraries in scope, that have the same
+// duplicate key or file size and durations within a tolerance, or the same file location. Values that more than a
+// bucket size of tracks share are too generic to tell duplicates apart and are left out.
+const duplicateCandidatesQuery = `
+WITH candidates AS (
+	SELECT id, library_id, duplicate_key, size, total_time, lower(replace(location, '\', '/')) AS location_key,
+		COUNT(*) OVER (PARTITION BY duplicate_key) AS metadata_count,
+		COUNT(*) OVER (PARTITION BY size) AS size_count,
+		COUNT(*) OVER (PARTITION BY lower(replace(location, '\', '/'))) AS location_count
+	FROM tracks
+	WHERE library_id IN (SELECT id FROM music_libraries WHERE user_id = @user AND deleted_at IS NULL)
+		AND (@library = 0 OR library_id = @library) AND deleted_at IS NULL
+)
+SELECT a.id AS track_a, b.id AS track_b
+FROM candidates a JOIN candidates b ON b.duplicate_key = a.duplicate_key AND b.id > a.id
+WHERE a.duplicate_key <> '' AND a.metadata_count <= @bucket
+	AND (a.total_time = 0 OR b.total_time = 0 OR abs(a.total_time - b.total_time) <= @tolerance)
+UNION
+SELECT a.id, b.id
+FROM candidates a JOIN candidates b ON b.size = a.size AND b.id > a.id
+WHERE a.size > 0 AND a.size_count <= @bucket
+	AND a.total_time > 0 AND b.total_time > 0 AND abs(a.total_time - b.total_time) <= @tolerance
+UNION
+SELECT a.id, b.id
+FROM candidates a JOIN candidates b ON b.location_key = a.location_key AND b.id > a.id
+WHERE a.location_key <> '' AND a.location_count <= @bucket`
+
+// FindDuplicates groups the tracks of the user's libraries, or of one library if libraryID is set, that are likely
+// copies of the same recording: tracks with the same normalized artist, title and mix, tracks with the same file
+// size, and tracks pointing at the same file. Except for tracks sharing a file, tracks whose durations differ by
+// more than tolerance seconds are never grouped. Groups may span libraries, such as a record imported into both
+// a Rekordbox and a Traktor library, although only tracks of the same library can be merged. Groups below
+// minConfidence are left out.
+func (s *MusicLibraryService) FindDuplicates(ctx context.Context, userID uint, libraryID *uint, tolerance int, minConfidence float64) ([]DuplicateGroup, error) {
+	var library uint
+	if libraryID != nil {
+		// First check if the library belongs to the user
+		if _, err := s.GetLibrary(ctx, userID, *libraryID); err != nil {
+			return nil, err
+		}
+		library = *libraryID
+	}
+
+	// Candidate pairs are found by the database, so that only tracks sharing a signal are loaded and compared
+	var pairs []duplicatePair
+	if err := s.db.Raw(ctx, &pairs, duplicateCandidatesQuery, sql.Named("user", userID), sql.Named("library", library),
+		sql.Named("bucket", maxDuplicateBucketSize), sql.Named("tolerance", tolerance)); err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return []DuplicateGroup{}, nil
+	}
+
+	ids := make([]uint, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		ids = append(ids, pair.TrackA, pair.TrackB)
+	}
+	var tracks []models.Track
+	if err := s.db.Where(ctx, "id IN ?", ids).Order("id").Find(ctx, &tracks); err != nil {
+		return nil, err
+	}
+	index := make(map[uint]int, len(tracks))
+	for i, track := range tracks {
+		index[track.ID] = i
+	}
+
+	var edges []duplicateEdge
+	for _, pair := range pairs {
+		a, okA := index[pair.TrackA]
+		b, okB := index[pair.TrackB]
+		if !okA || !okB {
+			continue
+		}
+		if edge, ok := s.compareDuplicates(tracks, a, b, tolerance); ok {
+			edges = append(edges, edge)
+		}
+	}
+
+	// Link the strongest edges first, so that each group's confidence is the weakest link it needs
+	slices.SortStableFunc(edges, func(x, y duplicateEdge) int {
+		return cmp.Compare(y.confidence, x.confidence)
+	})
+	parent := make([]int, len(tracks))
+	for i := range parent {
+		parent[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	confidence := make(map[int]float64)
+	reasons := make(map[int]map[string]bool)
+	for _, edge := range edges {
+		rootA, rootB := find(edge.a), find(edge.b)
+		if rootA == rootB {
+			continue
+		}
+
+		merged := edge.confidence
+		mergedReasons := make(map[string]bool)
+		for _, root := range []int{rootA, rootB} {
+			if c, ok := confidence[root]; ok {
+				merged = min(merged, c)
+			}
+			for reason := range reasons[root] {
+				mergedReasons[reason] = true
+			}
+			delete(confidence, root)
+			delete(reasons, root)
+		}
+		for _, reason := range edge.reasons {
+			mergedReasons[reason] = true
+		}
+
+		parent[rootB] = rootA
+		confidence[rootA] = merged
+		reasons[rootA] = mergedReasons
+	}
+
+	members := make(map[int][]models.Track)
+	for i, track := range tracks {
+		root := find(i)
+		if _, ok := confidence[root]; ok {
+			track.Location = s.fileStorage.NormalizeTrackLocation(track.Location)
+			members[root] = append(members[root], track)
+		}
+	}
+
+	groups := make([]DuplicateGroup, 0, len(members))
+	for root, groupTracks := range members {
+		if confidence[root] < minConfidence {
+			continue
+		}
+		group := DuplicateGroup{Confidence: confidence[root], Tracks: groupTracks}
+		for _, reason := range []string{DuplicateReasonLocation, DuplicateReasonMetadata, DuplicateReasonSize} {
+			if reasons[root][reason] {
+				group.Reasons = append(group.Reasons, reason)
+			}
+		}
+		groups = append(groups, group)
+	}
+
+	slices.SortFunc(groups, func(x, y DuplicateGroup) int {
+		if c := cmp.Compare(y.Confidence, x.Confidence); c != 0 {
+			return c
+		}
+		return cmp.Compare(x.Tracks[0].ID, y.Tracks[0].ID)
+	})
+	return groups, nil
+}
+
+// compareDuplicates scores how likely two tracks are copies of the same recording.
+// A file size match alone is only taken into account when both durations are known.
+func (s *MusicLibraryService) compareDuplicates(tracks []models.Track, a, b int, tolerance int) (duplicateEdge, bool) {
+	x, y := tracks[a], tracks[b]
+	edge := duplicateEdge{a: a, b: b}
+
+	location := duplicateLocationKey(s.fileStorage.NormalizeTrackLocation(x.Location))
+	if location != "" && location == duplicateLocationKey(s.fileStorage.NormalizeTrackLocation(y.Location)) {
+		edge.confidence = locationConfidence
+		edge.reasons = append(edge.reasons, DuplicateReasonLocation)
+	}
+
+	durationsKnown := x.TotalTime > 0 && y.TotalTime > 0
+	if durationsKnown && abs(x.TotalTime-y.TotalTime) > tolerance {
+		return edge, edge.confidence > 0
+	}
+
+	sameMetadata := x.DuplicateKey != "" && x.DuplicateKey == y.DuplicateKey
+	sameSize := x.Size > 0 && x.Size == y.Size
+	if !sameMetadata && !(sameSize && durationsKnown) {
+		return edge, edge.confidence > 0
+	}
+
+	var score float64
+	if sameMetadata {
+		score += metadataConfidence
+		edge.reasons = append(edge.reasons, DuplicateReasonMetadata)
+	}
+	if sameSize {
+		score += sizeConfidence
+		edge.reasons = append(edge.reasons, DuplicateReasonSize)
+	}
+	if durationsKnown {
+		score += durationConfidence
+	}
+	edge.confidence = max(edge.confidence, min(score, 1))
+	return edge, true
+}
+
+// MergeDuplicates re-points the playlist entries of duplicate tracks to a keeper in the same library, and fails with
+// ErrDuplicateLibraryMismatch if a duplicate is in another library. Playlists that already hold the keeper lose their
+// entries of the duplicates instead. If deleteDuplicates is set, the duplicate
+// tracks are deleted afterwards. The merge is recorded as a version of the library.
+func (s *MusicLibraryService) MergeDuplicates(ctx context.Context, userID, keeperID uint, duplicateIDs []uint, deleteDuplicates bool) (*DuplicateMerge, error) {
+	keeper, err := s.GetTrack(ctx, userID, keeperID)
+	if err != nil {
+		return nil, err
+	}
+
+	var duplicates []models.Track
+	if err := s.db.Where(ctx, "id IN ? AND id <> ? AND library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", duplicateIDs, keeper.ID, userID).Find(ctx, &duplicates); err != nil {
+		return nil, err
+	}
+	if len(duplicates) == 0 {
+		return nil, ErrNoDuplicates
+	}
+	for _, duplicate := range duplicates {
+		if duplicate.LibraryID != keeper.LibraryID {
+			return nil, ErrDuplicateLibraryMismatch
+		}
+	}
+
+	merge := &DuplicateMerge{KeeperID: keeper.ID}
+	err = s.versionedEdit(ctx, keeper.LibraryID, SnapshotReasonMerge, func(tx *database.DB) error {
+		keys := make([]string, 0, len(duplicates))
+		isDuplicate := make(map[string]bool, len(duplicates))
+		for _, duplicate := range duplicates {
+			// A duplicate may share its key with the keeper, in which case its entries already point at the keeper
+			if duplicate.TrackID != keeper.TrackID && !isDuplicate[duplicate.TrackID] {
+				isDuplicate[duplicate.TrackID] = true
+				keys = append(keys, duplicate.TrackID)
+			}
+		}
+
+		var playlistIDs []uint
+		if len(keys) > 0 {
+			var entries []models.PlaylistTrack
+			if err := tx.Where(ctx, "track_key IN ? AND playlist_id IN (SELECT id FROM playlists WHERE library_id = ? AND deleted_at IS NULL)", keys, keeper.LibraryID).Order("playlist_id").Find(ctx, &entries); err != nil {
+				return err
+			}
+			for _, entry := range entries {
+				if len(playlistIDs) == 0 || playlistIDs[len(playlistIDs)-1] != entry.PlaylistID {
+					playlistIDs = append(playlistIDs, entry.PlaylistID)
+				}
+			}
+		}
+
+		for _, playlistID := range playlistIDs {
+			// Lock the playlist so that concurrent updates of its order are applied one after the other
+			var playlist models.Playlist
+			if err := tx.ForUpdate().Where(ctx, "id = ?", playlistID).First(ctx, &playlist); err != nil {
+				return err
+			}
+
+			entries, err := s.loadPlaylistEntries(ctx, tx, playlistID)
+			if err != nil {
+				return err
+			}
+			holdsKeeper := slices.ContainsFunc(entries, func(entry models.PlaylistTrack) bool {
+				return entry.TrackKey == keeper.TrackID
+			})
+
+			kept := entries[:0]
+			for _, entry := range entries {
+				switch {
+				case !isDuplicate[entry.TrackKey]:
+					kept = append(kept, entry)
+				case holdsKeeper:
+					if err := tx.Delete(ctx, &entry); err != nil {
+						return err
+					}
+					merge.EntriesRemoved++
+				default:
+					if _, err := tx.Where(ctx, "id = ?", entry.ID).UpdateColumns(ctx, &models.PlaylistTrack{}, map[string]interface{}{"track_key": keeper.TrackID}); err != nil {
+						return err
+					}
+					entry.TrackKey = keeper.TrackID
+					kept = append(kept, entry)
+					holdsKeeper = true
+					merge.EntriesRepointed++
+				}
+			}
+			if err := renumberEntries(ctx, tx, kept); err != nil {
+				return err
+			}
+			merge.PlaylistsChanged = append(merge.PlaylistsChanged, playlistID)
+		}
+
+		if deleteDuplicates {
+			for _, duplicate := range duplicates {
+				if err := tx.Where(ctx, "track_id = ?", duplicate.ID).Delete(ctx, &models.Tempo{}); err != nil {
+					return err
+				}
+				if err := tx.Where(ctx, "track_id = ?", duplicate.ID).Delete(ctx, &models.CuePoint{}); err != nil {
+					return err
+				}
+				if err := tx.Delete(ctx, &duplicate); err != nil {
+					return err
+				}
+				merge.TracksDeleted = append(merge.TracksDeleted, duplicate.ID)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return merge, nil
+}
+
+// duplicateLocationKey returns a file location for case-insensitive comparison with either path separator
+func duplicateLocationKey(location string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(location), `\`, "/"))
+}
+
+// abs returns the absolute value of an integer
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
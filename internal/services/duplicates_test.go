@@ -0,0 +1,110 @@
+package services
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+func TestCompareDuplicates(t *testing.T) {
+	tests := []struct {
+		name       string
+		a, b       models.Track
+		ok         bool
+		confidence float64
+		reasons    []string
+	}{
+		{
+			name:       "same file with other separators and case",
+			a:          models.Track{Location: `C:\Music\Track.mp3`, TotalTime: 300},
+			b:          models.Track{Location: "c:/music/track.MP3", TotalTime: 200},
+			ok:         true,
+			confidence: locationConfidence,
+			reasons:    []string{DuplicateReasonLocation},
+		},
+		{
+			name:       "same file and metadata",
+			a:          models.Track{Location: "/music/a.mp3", DuplicateKey: "artist title", TotalTime: 300},
+			b:          models.Track{Location: "/music/a.mp3", DuplicateKey: "artist title", TotalTime: 301},
+			ok:         true,
+			confidence: 1,
+			reasons:    []string{DuplicateReasonLocation, DuplicateReasonMetadata},
+		},
+		{
+			name:       "metadata and duration",
+			a:          models.Track{DuplicateKey: "artist title", TotalTime: 300},
+			b:          models.Track{DuplicateKey: "artist title", TotalTime: 302},
+			ok:         true,
+			confidence: metadataConfidence + durationConfidence,
+			reasons:    []string{DuplicateReasonMetadata},
+		},
+		{
+			name:       "metadata with an unknown duration",
+			a:          models.Track{DuplicateKey: "artist title", TotalTime: 300},
+			b:          models.Track{DuplicateKey: "artist title"},
+			ok:         true,
+			confidence: metadataConfidence,
+			reasons:    []string{DuplicateReasonMetadata},
+		},
+		{
+			name:       "metadata, size and duration",
+			a:          models.Track{DuplicateKey: "artist title", Size: 1234, TotalTime: 300},
+			b:          models.Track{DuplicateKey: "artist title", Size: 1234, TotalTime: 300},
+			ok:         true,
+			confidence: 1,
+			reasons:    []string{DuplicateReasonMetadata, DuplicateReasonSize},
+		},
+		{
+			name:       "size and duration",
+			a:          models.Track{Size: 1234, TotalTime: 300},
+			b:          models.Track{Size: 1234, TotalTime: 299},
+			ok:         true,
+			confidence: sizeConfidence + durationConfidence,
+			reasons:    []string{DuplicateReasonSize},
+		},
+		{
+			name: "size with an unknown duration",
+			a:    models.Track{Size: 1234, TotalTime: 300},
+			b:    models.Track{Size: 1234},
+		},
+		{
+			name: "metadata with durations beyond the tolerance",
+			a:    models.Track{DuplicateKey: "artist title", TotalTime: 300},
+			b:    models.Track{DuplicateKey: "artist title", TotalTime: 360},
+		},
+		{
+			name: "empty metadata",
+			a:    models.Track{TotalTime: 300},
+			b:    models.Track{TotalTime: 300},
+		},
+		{
+			name: "nothing in common",
+			a:    models.Track{Location: "/music/a.mp3", DuplicateKey: "a", Size: 1, TotalTime: 300},
+			b:    models.Track{Location: "/music/b.mp3", DuplicateKey: "b", Size: 2, TotalTime: 300},
+		},
+	}
+
+	s := &MusicLibraryService{fileStorage: &FileStorageService{}}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			edge, ok := s.compareDuplicates([]models.Track{tt.a, tt.b}, 0, 1, DefaultDuplicateDurationTolerance)
+			if ok != tt.ok {
+				t.Fatalf("compareDuplicates ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if edge.a != 0 || edge.b != 1 {
+				t.Errorf("edge links %d and %d, want 0 and 1", edge.a, edge.b)
+			}
+			if math.Abs(edge.confidence-tt.confidence) > 1e-9 {
+				t.Errorf("confidence = %v, want %v", edge.confidence, tt.confidence)
+			}
+			if !reflect.DeepEqual(edge.reasons, tt.reasons) {
+				t.Errorf("reasons = %v, want %v", edge.reasons, tt.reasons)
+			}
+		})
+	}
+}
@@ -14,4 +14,33 @@ var (
 	ErrVerificationCodeRequired = errors.New("verification code is required")
 	ErrInvalidVerificationCode  = errors.New("invalid verification code")
 	ErrInvalidResetCode         = errors.New("invalid or expired reset code")
+
+	// Music library service errors
+	ErrInvalidLibraryFile       = errors.New("failed to parse library file")
+	ErrUnsupportedLibrarySource = errors.New("unsupported library source")
+	ErrUnsupportedExportFormat  = errors.New("unsupported export format")
+	ErrSnapshotNotFound         = errors.New("library version not found")
+	ErrLibraryTooLarge          = errors.New("library file exceeds the size limit")
+	ErrNotAPlaylist             = errors.New("specified ID is not a playlist")
+	ErrNotAFolder               = errors.New("specified ID is not a folder")
+	ErrPlaylistEntryNotFound    = errors.New("playlist entry not found")
+	ErrInvalidPlaylistOrder     = errors.New("invalid playlist order")
+	ErrInvalidTrackQuery        = errors.New("invalid track query")
+	ErrEmptySearchQuery         = errors.New("search query is empty")
+	ErrInvalidSmartRules        = errors.New("invalid smart playlist rules")
+	ErrInvalidPathRemap         = errors.New("path remap rules need a from and a to prefix")
+	ErrNotASmartPlaylist        = errors.New("specified ID is not a smart playlist")
+	ErrSmartPlaylistReadOnly    = errors.New("smart playlist entries follow its rules and cannot be edited")
+	ErrTrackKeyUnknown          = errors.New("track has no recognized key")
+	ErrNoDuplicates             = errors.New("no duplicate tracks to merge")
+	ErrDuplicateLibraryMismatch = errors.New("duplicates can only be merged into a keeper of the same library")
+	ErrTrackFileUnavailable     = errors.New("track file is not available")
+	ErrUnreadableTags           = errors.New("failed to read the tags of the track file")
+	ErrUnknownTagField          = errors.New("unknown tag field")
+	ErrTrackFileTooLarge        = errors.New("track file exceeds the size limit")
+	ErrUploadOffsetMismatch     = errors.New("upload offset does not match the bytes received")
+	ErrUploadFinished           = errors.New("upload has already finished")
+	ErrUploadExceedsSize        = errors.New("chunk extends past the declared size of the upload")
+	ErrUploadHashMismatch       = errors.New("uploaded file does not match its declared hash")
+	ErrUploadExpired            = errors.New("upload expired before it was completed")
 )
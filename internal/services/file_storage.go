@@ -3,15 +3,17 @@ package services
 import (
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"net/url"
-	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/dinis/musync/internal/database"
 	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/storage"
 )
 
 // ReadSeekCloser combines io.ReadSeeker and io.Closer interfaces
@@ -22,13 +24,15 @@ type ReadSeekCloser interface {
 
 // FileStorageService handles operations related to file storage and retrieval
 type FileStorageService struct {
-	db *database.DB
+	db    *database.DB
+	files *storage.Registry // Storage backends track files are read from
 }
 
-// NewFileStorageService creates a new FileStorageService
-func NewFileStorageService(db *database.DB) *FileStorageService {
+// NewFileStorageService creates a new FileStorageService reading track files from the backends of a registry
+func NewFileStorageService(db *database.DB, files *storage.Registry) *FileStorageService {
 	return &FileStorageService{
-		db: db,
+		db:    db,
+		files: files,
 	}
 }
 
@@ -53,78 +57,115 @@ func (s *FileStorageService) GetTrackInfo(ctx context.Context, userID, trackID u
 	return &track, nil
 }
 
-// GetFileStream returns a reader for a track's file
+// FileStream is a track's file opened for reading, with the metadata HTTP responses need
+type FileStream struct {
+	ReadSeekCloser
+	ContentType string
+	Size        int64
+	ModTime     time.Time // Zero if the storage backend does not know it
+	ETag        string    // Entity tag of the file's contents, quoted as in HTTP
+}
+
+// GetFileStream returns a reader for a track's file, from the storage backend registered for the scheme of its location
 func (s *FileStorageService) GetFileStream(ctx context.Context, userID, trackID uint) (ReadSeekCloser, string, error) {
-	// Get the track info
-	track, err := s.GetTrackInfo(ctx, userID, trackID)
+	stream, err := s.OpenFileStream(ctx, userID, trackID)
 	if err != nil {
 		return nil, "", err
 	}
+	return stream, stream.ContentType, nil
+}
 
-	// Get content type based on file extension
-	contentType := s.getContentTypeFromLocation(track.Location)
-
-	// Handle different storage types
-	switch track.StorageType {
-	case "local":
-		// Local file - check if it's a valid local path
-		if strings.HasPrefix(track.Location, "file://localhost") {
-			return s.getLocalFileStream(track.Location)
-		}
-		return nil, contentType, errors.New("invalid local file path")
-	case "cloud":
-		// Cloud file - determine the provider from the location
-		if strings.HasPrefix(track.Location, "pcloud://") {
-			return s.getPCloudFileStream(track.Location)
-		}
-		return nil, contentType, errors.New("unsupported cloud provider")
-	default:
-		return nil, contentType, errors.New("unsupported storage type")
+// OpenFileStream opens a track's file, from the storage backend registered for the scheme of its location,
+// together with its size, modification time and entity tag
+func (s *FileStorageService) OpenFileStream(ctx context.Context, userID, trackID uint) (*FileStream, error) {
+	// Get the track info
+	track, err := s.GetTrackInfo(ctx, userID, trackID)
+	if err != nil {
+		return nil, err
 	}
-}
 
-// getLocalFileStream returns a reader for a local file
-func (s *FileStorageService) getLocalFileStream(location string) (ReadSeekCloser, string, error) {
-	// Use the NormalizeTrackLocation function to get a properly formatted path
-	path := s.NormalizeTrackLocation(location)
+	// Find the backend storing the file. Uploaded files are stored under their hash, the location
+	// still records where the file was in the source library, rewritten by the library's path remap rules.
+	rules, err := s.GetPathRemaps(ctx, track.LibraryID)
+	if err != nil {
+		return nil, err
+	}
+	resolver, err := s.newFileResolver(ctx, userID, []models.Track{*track})
+	if err != nil {
+		return nil, err
+	}
+	location, _ := s.storageLocation(track, rules)
+	backend, key, err := resolver.resolve(track, location)
+	if err != nil {
+		return nil, err
+	}
 
-	// Open the file
-	file, err := os.Open(path)
+	reader, err := storage.NewReader(ctx, backend, key)
 	if err != nil {
-		return nil, "", errors.New("failed to open file: " + err.Error())
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+
+	// Uploaded files are tagged with their hash. Otherwise the backend's tag is used, or one is derived from
+	// the modification time and size, as web servers do for static files.
+	etag := reader.ETag()
+	switch {
+	case track.StorageType == models.StorageTypeManaged:
+		etag = `"` + track.FileHash + `"`
+	case etag == "":
+		etag = fmt.Sprintf(`"%x-%x"`, reader.ModTime().UnixNano(), reader.Size())
 	}
 
-	contentType := s.getContentTypeFromLocation(location)
-	return file, contentType, nil
+	return &FileStream{
+		ReadSeekCloser: reader,
+		ContentType:    s.getContentTypeFromLocation(track.Location),
+		Size:           reader.Size(),
+		ModTime:        reader.ModTime(),
+		ETag:           etag,
+	}, nil
 }
 
-// getPCloudFileStream returns a reader for a pCloud file
-func (s *FileStorageService) getPCloudFileStream(location string) (ReadSeekCloser, string, error) {
-	// In a real implementation, this would use the pCloud API to get a download link
-	// and then return a reader for that link
-	// For now, we'll just return an error
-	return nil, "", errors.New("pCloud integration not implemented yet")
-
-	// Example of how this might be implemented:
-	/*
-		// Extract the file ID from the pCloud URL
-		fileID := strings.TrimPrefix(location, "pcloud://")
-
-		// Use the pCloud API to get a download link
-		downloadLink, err := s.pCloudClient.GetFileLink(fileID)
-		if err != nil {
-			return nil, "", errors.New("failed to get pCloud download link: " + err.Error())
-		}
+// fileResolver finds the files of a user's tracks
+type fileResolver struct {
+	scoped  *storage.Registry // Backends confined to the user's files
+	managed storage.StorageBackend
+	owned   map[string]bool // Hashes of the uploaded files of the tracks that the user uploaded
+}
+
+// newFileResolver creates a resolver for the files of tracks of a user
+func (s *FileStorageService) newFileResolver(ctx context.Context, userID uint, tracks []models.Track) (*fileResolver, error) {
+	resolver := &fileResolver{scoped: s.files.Scope(userID), owned: make(map[string]bool)}
+	resolver.managed, _ = s.files.Backend(storage.SchemeManaged)
 
-		// Make an HTTP request to get the file
-		resp, err := http.Get(downloadLink)
-		if err != nil {
-			return nil, "", errors.New("failed to download file from pCloud: " + err.Error())
+	var hashes []string
+	for _, track := range tracks {
+		if track.StorageType == models.StorageTypeManaged {
+			hashes = append(hashes, track.FileHash)
 		}
+	}
+	if len(hashes) == 0 {
+		return resolver, nil
+	}
+
+	var files []models.TrackFile
+	if err := s.db.Where(ctx, "user_id = ? AND hash IN ?", userID, hashes).Find(ctx, &files); err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		resolver.owned[file.Hash] = true
+	}
+	return resolver, nil
+}
 
-		contentType := resp.Header.Get("Content-Type")
-		return resp.Body, contentType, nil
-	*/
+// resolve returns the backend storing the file of a track at a location returned by storageLocation, and
+// the file's key in it. Uploaded files are only found if the user uploaded them.
+func (r *fileResolver) resolve(track *models.Track, location string) (storage.StorageBackend, string, error) {
+	if track.StorageType == models.StorageTypeManaged {
+		if r.managed == nil || !r.owned[track.FileHash] {
+			return nil, "", storage.ErrNotFound
+		}
+		return r.managed, storage.ContentKey(track.FileHash), nil
+	}
+	return r.scoped.Resolve(location)
 }
 
 // getContentTypeFromLocation returns the content type based on the file extension
@@ -175,7 +216,7 @@ func (s *FileStorageService) NormalizeTrackLocation(location string) string {
 		}
 	}
 
-	// Handle cloud storage URLs (e.g., pcloud://)
+	// Handle cloud storage URLs (e.g., pcloud:// or s3://)
 	// These are already in a format that doesn't need OS-specific normalization
 
 	return location
@@ -0,0 +1,118 @@
+package services
+
+import (
+	"context"
+	"math"
+
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+	"gorm.io/gorm/clause"
+)
+
+// Limits of compatible track suggestions
+const (
+	DefaultBpmTolerance    = 6.0 // Percent, the pitch range of most decks
+	MaxBpmTolerance        = 50.0
+	DefaultCompatibleLimit = 50
+	MaxCompatibleLimit     = 200
+)
+
+// Harmonic relations between the keys of two tracks
+const (
+	KeyRelationSame     = "same"     // Same key
+	KeyRelationAdjacent = "adjacent" // One step either way around the Camelot wheel
+	KeyRelationRelative = "relative" // Relative major or minor
+)
+
+// CompatibleTrack is a track that mixes harmonically with another one
+type CompatibleTrack struct {
+	Track       models.Track
+	KeyRelation string
+	TempoRatio  float64 // Tempo of the track relative to the other one: 1, or 2 or 0.5 for double and half time
+	BpmOffset   float64 // Difference of the tempo-adjusted BPM from the other track's BPM, in percent
+}
+
+// GetCompatibleTracks returns tracks from all of the user's libraries that mix harmonically with a track:
+// their key is the same, one step away on the Camelot wheel or the relative major or minor, and their BPM is
+// within tolerance percent of the track's BPM, or of double or half of it. If the track has no BPM, tracks match on key only.
+// The closest key relations come first, then the closest tempos.
+func (s *MusicLibraryService) GetCompatibleTracks(ctx context.Context, userID, trackID uint, tolerance float64, limit int) ([]CompatibleTrack, error) {
+	track, err := s.GetTrack(ctx, userID, trackID)
+	if err != nil {
+		return nil, err
+	}
+
+	key, ok := musickey.Parse(track.CamelotKey)
+	if !ok {
+		return nil, ErrTrackKeyUnknown
+	}
+
+	if tolerance <= 0 {
+		tolerance = DefaultBpmTolerance
+	}
+	tolerance = min(tolerance, MaxBpmTolerance)
+	if limit <= 0 {
+		limit = DefaultCompatibleLimit
+	}
+	limit = min(limit, MaxCompatibleLimit)
+
+	compatible := key.Compatible()
+	keys := make([]string, len(compatible))
+	for i, compatibleKey := range compatible {
+		keys[i] = compatibleKey.Camelot()
+	}
+
+	db := s.db.Where(ctx, "tracks.library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", userID).
+		Where(ctx, "tracks.id <> ? AND tracks.camelot_key IN ?", track.ID, keys)
+
+	// Same key first, then neighbours on the wheel, then the relative key, closest tempos first within each
+	orderSQL := "CASE tracks.camelot_key WHEN ? THEN 0 WHEN ? THEN 1 WHEN ? THEN 1 ELSE 2 END"
+	orderVars := []interface{}{keys[0], keys[1], keys[2]}
+
+	bpm := track.AverageBpm
+	if bpm > 0 {
+		ratio := tolerance / 100
+		db = db.Where(ctx, "(tracks.average_bpm BETWEEN ? AND ? OR tracks.average_bpm BETWEEN ? AND ? OR tracks.average_bpm BETWEEN ? AND ?)",
+			bpm*(1-ratio), bpm*(1+ratio),
+			2*bpm*(1-ratio), 2*bpm*(1+ratio),
+			bpm/2*(1-ratio), bpm/2*(1+ratio))
+		orderSQL += ", LEAST(ABS(tracks.average_bpm - ?), ABS(tracks.average_bpm / 2 - ?), ABS(tracks.average_bpm * 2 - ?))"
+		orderVars = append(orderVars, bpm, bpm, bpm)
+	}
+	order := clause.OrderBy{Expression: clause.Expr{SQL: orderSQL + ", tracks.id", Vars: orderVars, WithoutParentheses: true}}
+
+	var tracks []models.Track
+	if err := db.Order(order).Limit(limit).Find(ctx, &tracks); err != nil {
+		return nil, err
+	}
+
+	results := make([]CompatibleTrack, len(tracks))
+	for i, candidate := range tracks {
+		candidate.Location = s.fileStorage.NormalizeTrackLocation(candidate.Location)
+		result := CompatibleTrack{Track: candidate, KeyRelation: KeyRelationRelative, TempoRatio: 1}
+		switch candidate.CamelotKey {
+		case keys[0]:
+			result.KeyRelation = KeyRelationSame
+		case keys[1], keys[2]:
+			result.KeyRelation = KeyRelationAdjacent
+		}
+		if bpm > 0 && candidate.AverageBpm > 0 {
+			result.TempoRatio, result.BpmOffset = tempoMatch(bpm, candidate.AverageBpm)
+		}
+		results[i] = result
+	}
+	return results, nil
+}
+
+// tempoMatch returns whether a candidate BPM is closest to the same, double or half of a BPM,
+// and the difference of the adjusted candidate BPM from it in percent
+func tempoMatch(bpm, candidate float64) (float64, float64) {
+	bestRatio, bestOffset := 1.0, math.Inf(1)
+	for _, ratio := range []float64{1, 2, 0.5} {
+		offset := (candidate/ratio - bpm) / bpm * 100
+		if math.Abs(offset) < math.Abs(bestOffset) {
+			bestRatio, bestOffset = ratio, offset
+		}
+	}
+	return bestRatio, math.Round(bestOffset*100) / 100
+}
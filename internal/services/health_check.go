@@ -0,0 +1,293 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+	"github.com/dinis/musync/internal/database"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/models"
+)
+
+// Health check states
+const (
+	HealthCheckQueued    = "queued"
+	HealthCheckRunning   = "running"
+	HealthCheckCompleted = "completed"
+	HealthCheckFailed    = "failed"
+)
+
+// Types of the issues a health check reports
+const (
+	HealthIssueMissingFile     = "missing_file"     // No file at the track's location
+	HealthIssueUnreachableFile = "unreachable_file" // The storage backend of the location is not configured or failed
+	HealthIssueSizeMismatch    = "size_mismatch"    // The file's size differs from the size the library records
+	HealthIssueUnsupportedKind = "unsupported_kind" // The track's kind is not an audio format the server reads
+	HealthIssueMissingTrack    = "missing_track"    // A playlist entry refers to a track that is not in the library
+	HealthIssueEmptyPlaylist   = "empty_playlist"   // A playlist has no entries
+)
+
+// supportedKindWords are the words of track kinds, e.g. "MP3 File" or "AAC audio file", that name an
+// audio format the server streams and reads
+var supportedKindWords = map[string]bool{
+	"mp3": true, "mpeg": true, "wav": true, "wave": true, "aiff": true, "aif": true, "aifc": true,
+	"flac": true, "aac": true, "m4a": true, "alac": true, "lossless": true, "ogg": true,
+}
+
+// HealthCheckService checks the files and playlists of libraries in the background. Each check stats the
+// file of every track through the storage layer, after applying the library's path remap rules, and
+// persists a report of the issues found with suggestions how to fix them.
+type HealthCheckService struct {
+	db          *database.DB
+	fileStorage *FileStorageService
+	config      config.HealthCheckConfig
+	jobs        *jobRunner[models.HealthCheck]
+}
+
+// NewHealthCheckService creates a new HealthCheckService
+func NewHealthCheckService(db *database.DB, cfg config.HealthCheckConfig, fileStorage *FileStorageService) *HealthCheckService {
+	s := &HealthCheckService{
+		db:          db,
+		fileStorage: fileStorage,
+		config:      cfg,
+	}
+	s.jobs = newJobRunner(db, "health check", "health_checks", s.run)
+	return s
+}
+
+// Start requeues interrupted checks and starts the health check workers, which run until ctx is cancelled
+func (s *HealthCheckService) Start(ctx context.Context) error {
+	if err := s.jobs.requeue(ctx); err != nil {
+		return err
+	}
+	s.jobs.start(ctx, s.config.Workers)
+	return nil
+}
+
+// Wait blocks until the health check workers have stopped after the context passed to Start was cancelled
+func (s *HealthCheckService) Wait() {
+	s.jobs.wait()
+}
+
+// Enqueue queues a health check of a library of the user. If a check of the library is already queued or
+// running, that check is returned instead.
+func (s *HealthCheckService) Enqueue(ctx context.Context, userID, libraryID uint) (*models.HealthCheck, error) {
+	var library models.MusicLibrary
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", libraryID, userID).First(ctx, &library); err != nil {
+		return nil, err
+	}
+
+	var check models.HealthCheck
+	err := s.db.Where(ctx, "library_id = ? AND state IN ?", library.ID, []string{HealthCheckQueued, HealthCheckRunning}).Order("id").First(ctx, &check)
+	switch {
+	case err == nil:
+		return &check, nil
+	case !errors.Is(err, apperrors.ErrNotFound):
+		return nil, err
+	}
+
+	check = models.HealthCheck{UserID: userID, LibraryID: library.ID, State: HealthCheckQueued}
+	if err := s.db.Create(ctx, &check); err != nil {
+		return nil, err
+	}
+	s.jobs.notify()
+	return &check, nil
+}
+
+// GetCheck returns a health check of the user
+func (s *HealthCheckService) GetCheck(ctx context.Context, userID, checkID uint) (*models.HealthCheck, error) {
+	var check models.HealthCheck
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", checkID, userID).First(ctx, &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// GetLatestCheck returns the most recently queued health check of a library of the user
+func (s *HealthCheckService) GetLatestCheck(ctx context.Context, userID, libraryID uint) (*models.HealthCheck, error) {
+	var check models.HealthCheck
+	if err := s.db.Where(ctx, "library_id = ? AND user_id = ?", libraryID, userID).Order("id DESC").First(ctx, &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// run checks the library of a health check and records the report
+func (s *HealthCheckService) run(ctx context.Context, check *models.HealthCheck) {
+	logger := logging.GetLogger()
+
+	err := s.check(ctx, check)
+
+	now := time.Now()
+	check.FinishedAt = &now
+	if err != nil {
+		logger.Warn("Health check of library %d failed: %v", check.LibraryID, err)
+		check.State = HealthCheckFailed
+		check.Error = err.Error()
+	} else {
+		check.State = HealthCheckCompleted
+	}
+
+	if err := s.db.Save(ctx, check); err != nil {
+		logger.Error("Failed to record report of health check %d: %v", check.ID, err)
+	}
+}
+
+// check finds the issues with the track files and playlists of a library
+func (s *HealthCheckService) check(ctx context.Context, check *models.HealthCheck) error {
+	rules, err := s.fileStorage.GetPathRemaps(ctx, check.LibraryID)
+	if err != nil {
+		return err
+	}
+	var tracks []models.Track
+	if err := s.db.Where(ctx, "library_id = ?", check.LibraryID).Order("id").Find(ctx, &tracks); err != nil {
+		return err
+	}
+	relocated, err := s.fileStorage.relocateTracks(ctx, check.UserID, tracks, rules)
+	if err != nil {
+		return err
+	}
+
+	issues := []models.HealthIssue{}
+	trackKeys := make(map[string]bool, len(tracks))
+	for _, track := range relocated {
+		trackKeys[track.Track.TrackID] = true
+		issues = append(issues, trackHealthIssues(track)...)
+	}
+
+	var playlists []models.Playlist
+	if err := s.db.Where(ctx, "library_id = ? AND type = ?", check.LibraryID, models.PlaylistTypePlaylist).Order("id").Find(ctx, &playlists); err != nil {
+		return err
+	}
+	var entries []models.PlaylistTrack
+	if err := s.db.Where(ctx, "playlist_id IN (SELECT id FROM playlists WHERE library_id = ? AND type = ? AND deleted_at IS NULL)", check.LibraryID, models.PlaylistTypePlaylist).Order("position, id").Find(ctx, &entries); err != nil {
+		return err
+	}
+	playlistEntries := make(map[uint][]models.PlaylistTrack, len(playlists))
+	for _, entry := range entries {
+		playlistEntries[entry.PlaylistID] = append(playlistEntries[entry.PlaylistID], entry)
+	}
+
+	for _, playlist := range playlists {
+		if len(playlistEntries[playlist.ID]) == 0 {
+			issues = append(issues, models.HealthIssue{
+				Type:       HealthIssueEmptyPlaylist,
+				PlaylistID: playlist.ID,
+				Detail:     fmt.Sprintf("Playlist %q has no tracks", playlist.Name),
+				Suggestion: "Add tracks to the playlist or delete it",
+			})
+			continue
+		}
+		for _, entry := range playlistEntries[playlist.ID] {
+			if trackKeys[entry.TrackKey] {
+				continue
+			}
+			issues = append(issues, models.HealthIssue{
+				Type:       HealthIssueMissingTrack,
+				PlaylistID: playlist.ID,
+				EntryID:    entry.ID,
+				TrackKey:   entry.TrackKey,
+				Detail:     fmt.Sprintf("Playlist %q refers to track %q, which is not in the library", playlist.Name, entry.TrackKey),
+				Suggestion: "Remove the entry from the playlist, or re-import the library if the track should be in it",
+			})
+		}
+	}
+
+	check.TracksChecked = len(tracks)
+	check.PlaylistsChecked = len(playlists)
+	check.Issues = issues
+	return nil
+}
+
+// trackHealthIssues returns the issues with the file and kind of a relocated track
+func trackHealthIssues(track RelocatedTrack) []models.HealthIssue {
+	var issues []models.HealthIssue
+	issue := func(issueType, detail, suggestion string) {
+		issues = append(issues, models.HealthIssue{
+			Type:       issueType,
+			TrackID:    track.Track.ID,
+			Location:   track.Location,
+			Detail:     detail,
+			Suggestion: suggestion,
+		})
+	}
+
+	switch track.Status {
+	case RelocateMissing:
+		var suggestion string
+		switch {
+		case track.Track.StorageType == models.StorageTypeManaged:
+			suggestion = "The uploaded file is gone from managed storage; upload it again"
+		case track.Rule != nil:
+			suggestion = fmt.Sprintf("The path remap rule from %q to %q applies, but the file is not at the remapped location; check the rule, or upload the file", track.Rule.From, track.Rule.To)
+		default:
+			suggestion = fmt.Sprintf("Add a path remap rule from %q to the folder the files are in now, or upload the file", remapPrefixSuggestion(track.Location))
+		}
+		issue(HealthIssueMissingFile, "The file does not exist", suggestion)
+	case RelocateUnreachable:
+		issue(HealthIssueUnreachableFile, "The file could not be checked: "+track.Error,
+			"Configure the storage backend of the location, or add a path remap rule to a location the server can read")
+	case RelocateFound:
+		if track.Track.Size > 0 && track.Size != track.Track.Size {
+			issue(HealthIssueSizeMismatch, fmt.Sprintf("The file has %d bytes, the library records %d", track.Size, track.Track.Size),
+				"The file may have been replaced or re-encoded; rescan the track to refresh its tags, or re-import the library")
+		}
+	}
+
+	if supported, protected := kindSupported(track.Track.Kind); protected {
+		issue(HealthIssueUnsupportedKind, fmt.Sprintf("Kind %q is a copy protected format", track.Track.Kind),
+			"Replace the file with an unprotected copy and re-import the library")
+	} else if !supported {
+		issue(HealthIssueUnsupportedKind, fmt.Sprintf("Kind %q is not a supported audio format", track.Track.Kind),
+			"Convert the file to MP3, AAC, AIFF, WAV or FLAC and re-import the library")
+	}
+	return issues
+}
+
+// kindSupported reports whether a track kind names an audio format the server streams and reads, and
+// whether it is copy protected. Tracks without a kind are taken to be supported.
+func kindSupported(kind string) (supported, protected bool) {
+	words := strings.Fields(models.NormalizeWords(kind))
+	if len(words) == 0 {
+		return true, false
+	}
+	for i, word := range words {
+		switch {
+		case word == "protected", word == "apple" && i+1 < len(words) && words[i+1] == "music":
+			return false, true
+		case word == "video":
+			return false, false
+		case supportedKindWords[word]:
+			supported = true
+		}
+	}
+	return supported, false
+}
+
+// remapPrefixSuggestion returns the prefix of a location a path remap rule would likely need to rewrite:
+// its drive letter, its volume or user folder, or else its directory
+func remapPrefixSuggestion(location string) string {
+	filePath, fileURL := remapPath(location)
+	if hasDriveLetter(filePath) {
+		return filePath[:2]
+	}
+	if !fileURL && strings.Contains(filePath, "://") {
+		return filePath[:strings.LastIndex(filePath, "/")]
+	}
+	parts := strings.Split(filePath, "/")
+	if len(parts) > 3 && parts[0] == "" {
+		switch parts[1] {
+		case "Volumes", "Users", "home", "mnt":
+			return strings.Join(parts[:3], "/")
+		case "media":
+			return strings.Join(parts[:min(4, len(parts)-1)], "/")
+		}
+	}
+	return path.Dir(filePath)
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/models"
+)
+
+// Import job states
+const (
+	ImportJobQueued    = "queued"
+	ImportJobRunning   = "running"
+	ImportJobCompleted = "completed"
+	ImportJobFailed    = "failed"
+)
+
+// importProgressInterval limits how often a running job writes its byte progress
+const importProgressInterval = time.Second
+
+// ImportJobService imports uploaded libraries in the background.
+// Uploads are staged on disk and jobs are persisted, so that jobs interrupted by a
+// restart are resumed from the staged file, or marked failed if the file is gone.
+type ImportJobService struct {
+	db             *database.DB
+	libraryService *MusicLibraryService
+	config         config.ImportConfig
+	jobs           *jobRunner[models.ImportJob]
+}
+
+// NewImportJobService creates a new ImportJobService
+func NewImportJobService(db *database.DB, cfg config.ImportConfig, fileStorage *FileStorageService) *ImportJobService {
+	s := &ImportJobService{
+		db:             db,
+		libraryService: NewMusicLibraryService(db, fileStorage),
+		config:         cfg,
+	}
+	s.jobs = newJobRunner(db, "import job", "import_jobs", s.run)
+	s.jobs.recoverJobs = s.recoverJobs
+	return s
+}
+
+// Start recovers interrupted jobs and starts the import workers, which run until ctx is cancelled
+func (s *ImportJobService) Start(ctx context.Context) error {
+	if err := os.MkdirAll(s.config.Dir, 0o700); err != nil {
+		return err
+	}
+
+	if err := s.recoverJobs(ctx); err != nil {
+		return err
+	}
+
+	s.jobs.start(ctx, s.config.Workers)
+	return nil
+}
+
+// Wait blocks until the import workers have stopped after the context passed to Start was cancelled
+func (s *ImportJobService) Wait() {
+	s.jobs.wait()
+}
+
+// Enqueue queues a staged upload for import, taking ownership of the staged file.
+// If source is empty, the importer is detected from the start of the file.
+func (s *ImportJobService) Enqueue(ctx context.Context, userID uint, name, source string, upload *StagedUpload) (*models.ImportJob, error) {
+	// Resolve the importer now so that unsupported files are rejected right away
+	importer, header, size, err := s.detectStagedImporter(upload.Path, source)
+	if err != nil {
+		s.Discard(upload)
+		return nil, err
+	}
+
+	job := &models.ImportJob{
+		UserID:     userID,
+		Name:       name,
+		Source:     importer.Name(),
+		State:      ImportJobQueued,
+		FilePath:   upload.Path,
+		BytesTotal: size,
+	}
+	if counter, ok := importer.(importers.TrackCounter); ok {
+		if total, ok := counter.CountTracks(header); ok {
+			job.TracksTotal = total
+		}
+	}
+
+	if err := s.db.Create(ctx, job); err != nil {
+		s.Discard(upload)
+		return nil, err
+	}
+
+	s.jobs.notify()
+	return job, nil
+}
+
+// GetJob returns an import job of the user
+func (s *ImportJobService) GetJob(ctx context.Context, userID, jobID uint) (*models.ImportJob, error) {
+	var job models.ImportJob
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", jobID, userID).First(ctx, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// detectStagedImporter resolves the importer for a staged upload. It returns the importer together with
+// the leading bytes of the library file and the number of bytes its progress is reported in.
+func (s *ImportJobService) detectStagedImporter(path, source string) (importers.Importer, []byte, int64, error) {
+	opened, err := s.openStaged(path)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer opened.Close()
+
+	header := make([]byte, importers.DetectSize)
+	n, err := io.ReadFull(opened.reader, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		if errors.Is(err, ErrLibraryTooLarge) {
+			return nil, nil, 0, err
+		}
+		return nil, nil, 0, fmt.Errorf("%w: %v", ErrInvalidLibraryFile, err)
+	}
+	header = header[:n]
+
+	if source != "" {
+		importer, ok := s.libraryService.importers.Get(source)
+		if !ok {
+			return nil, nil, 0, ErrUnsupportedLibrarySource
+		}
+		return importer, header, opened.size, nil
+	}
+
+	importer, err := s.libraryService.importers.Detect(header)
+	if err != nil {
+		return nil, nil, 0, ErrUnsupportedLibrarySource
+	}
+	return importer, header, opened.size, nil
+}
+
+// recoverJobs requeues the running jobs whose lease expired, because the server running them stopped.
+// An import runs in a single transaction, so an interrupted job left nothing behind and can start over.
+func (s *ImportJobService) recoverJobs(ctx context.Context) error {
+	var jobs []models.ImportJob
+	if err := s.jobs.abandoned(ctx).Find(ctx, &jobs); err != nil {
+		return err
+	}
+
+	logger := logging.GetLogger()
+	for _, job := range jobs {
+		if _, err := os.Stat(job.FilePath); err != nil {
+			logger.Warn("Import job %d was interrupted and its upload is gone: %v", job.ID, err)
+			s.finish(ctx, &job, nil, errors.New("import was interrupted by a server restart"))
+			continue
+		}
+
+		logger.Info("Resuming import job %d after a server restart", job.ID)
+		values := map[string]interface{}{
+			"state":            ImportJobQueued,
+			"bytes_processed":  0,
+			"tracks_processed": 0,
+			"started_at":       nil,
+			"runner":           "",
+			"heartbeat_at":     nil,
+		}
+		if _, err := s.db.Where(ctx, "id = ?", job.ID).UpdateColumns(ctx, &models.ImportJob{}, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// run imports the staged upload of a job and records the outcome
+func (s *ImportJobService) run(ctx context.Context, job *models.ImportJob) {
+	logger := logging.GetLogger()
+	logger.Info("Running import job %d for user %d", job.ID, job.UserID)
+
+	importer, ok := s.libraryService.importers.Get(job.Source)
+	if !ok {
+		s.finish(ctx, job, nil, ErrUnsupportedLibrarySource)
+		return
+	}
+
+	opened, err := s.openStaged(job.FilePath)
+	if err != nil {
+		s.finish(ctx, job, nil, err)
+		return
+	}
+	defer opened.Close()
+
+	var lastUpdate time.Time
+	progress := func(tracks int) {
+		if time.Since(lastUpdate) < importProgressInterval {
+			return
+		}
+		lastUpdate = time.Now()
+
+		// Progress is written outside the import transaction so that it is visible while the job runs
+		values := map[string]interface{}{
+			"tracks_processed": tracks,
+			"bytes_processed":  opened.counter.Count(),
+		}
+		if _, err := s.db.Where(ctx, "id = ?", job.ID).UpdateColumns(ctx, &models.ImportJob{}, values); err != nil {
+			logger.Warn("Failed to update progress of import job %d: %v", job.ID, err)
+		}
+	}
+
+	var tracks int
+	libraryID, warnings, err := s.libraryService.storeLibrary(ctx, job.UserID, job.Name, importer, opened.reader, func(stored int) {
+		tracks = stored
+		progress(stored)
+	})
+	if err != nil {
+		s.finish(ctx, job, nil, err)
+		return
+	}
+
+	job.TracksProcessed = tracks
+	job.BytesProcessed = opened.counter.Count()
+	job.Warnings = warnings
+	s.finish(ctx, job, &libraryID, nil)
+}
+
+// finish records the outcome of a job and removes its staged upload
+func (s *ImportJobService) finish(ctx context.Context, job *models.ImportJob, libraryID *uint, jobErr error) {
+	logger := logging.GetLogger()
+	if jobErr != nil && ctx.Err() != nil {
+		// The server is shutting down: the job stays running with its upload, and is resumed once its lease expires
+		logger.Info("Import job %d was interrupted by the shutdown", job.ID)
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	job.LibraryID = libraryID
+	if jobErr != nil {
+		job.State = ImportJobFailed
+		job.Error = jobErr.Error()
+		logger.Warn("Import job %d failed: %v", job.ID, jobErr)
+	} else {
+		job.State = ImportJobCompleted
+		if job.TracksTotal < job.TracksProcessed {
+			job.TracksTotal = job.TracksProcessed
+		}
+		logger.Info("Import job %d completed with library %d", job.ID, *libraryID)
+	}
+
+	if err := os.Remove(job.FilePath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Warn("Failed to remove upload of import job %d: %v", job.ID, err)
+	}
+	job.FilePath = ""
+
+	if err := s.db.Save(ctx, job); err != nil {
+		logger.Error("Failed to record outcome of import job %d: %v", job.ID, err)
+	}
+}
@@ -0,0 +1,190 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/logging"
+)
+
+// States of a background job that the job runner moves it between. The services name the outcome states.
+const (
+	jobQueued  = "queued"
+	jobRunning = "running"
+)
+
+// jobIdleInterval is how long an idle worker waits for a notification before it looks for queued jobs again,
+// which picks up jobs queued by other servers
+const jobIdleInterval = time.Minute
+
+// Leases of running jobs: the server running a job renews its heartbeat at every interval, and a running job
+// whose heartbeat is older than the timeout was left behind by a server that stopped, and is requeued
+const (
+	jobHeartbeatInterval = 30 * time.Second
+	jobLeaseTimeout      = 2 * time.Minute
+)
+
+// jobRunnerID identifies this server in the runner column of the jobs it runs
+var jobRunnerID = newJobRunnerID()
+
+// newJobRunnerID returns an ID that is unique to this process
+func newJobRunnerID() string {
+	host, _ := os.Hostname()
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(suffix))
+}
+
+// jobRunner runs the persisted jobs of a table with a pool of workers. A job is a row with a state, a
+// started_at column and the runner and heartbeat_at columns of its lease: a worker claims the oldest queued
+// job by moving it to the running state, and the run function records its outcome.
+type jobRunner[T any] struct {
+	db          *database.DB
+	name        string // Describes a job in log messages, e.g. "import job"
+	table       string
+	claim       func(ctx context.Context) (*T, error) // Claims the next job, nil if none is ready
+	recoverJobs func(ctx context.Context) error       // Requeues the running jobs whose lease expired
+	run         func(ctx context.Context, job *T)
+	wake        chan struct{}
+	wg          sync.WaitGroup
+}
+
+// newJobRunner creates a jobRunner for the jobs of a table that claims them oldest first
+func newJobRunner[T any](db *database.DB, name, table string, run func(ctx context.Context, job *T)) *jobRunner[T] {
+	r := &jobRunner[T]{
+		db:    db,
+		name:  name,
+		table: table,
+		run:   run,
+		wake:  make(chan struct{}, 1),
+	}
+	r.claim = r.claimOldest
+	r.recoverJobs = r.requeue
+	return r
+}
+
+// abandoned selects the running jobs whose lease expired, because the server running them stopped
+func (r *jobRunner[T]) abandoned(ctx context.Context) *database.DB {
+	return r.db.Where(ctx, "state = ? AND (heartbeat_at IS NULL OR heartbeat_at < ?)", jobRunning, time.Now().Add(-jobLeaseTimeout))
+}
+
+// requeue moves the running jobs whose lease expired back to the queue. Jobs other servers are running
+// keep running.
+func (r *jobRunner[T]) requeue(ctx context.Context) error {
+	var model T
+	_, err := r.abandoned(ctx).UpdateColumns(ctx, &model, map[string]interface{}{
+		"state":        jobQueued,
+		"started_at":   nil,
+		"runner":       "",
+		"heartbeat_at": nil,
+	})
+	return err
+}
+
+// start starts the workers, and renews the leases of the jobs they run, until ctx is cancelled
+func (r *jobRunner[T]) start(ctx context.Context, workers int) {
+	for i := 0; i < max(workers, 1); i++ {
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			r.work(ctx)
+		}()
+	}
+	r.wg.Add(1)
+	go func() {
+		defer r.wg.Done()
+		r.keepLeases(ctx)
+	}()
+	r.notify()
+}
+
+// wait blocks until the workers have stopped. A job interrupted by the cancellation is left running, and
+// requeued once its lease expires.
+func (r *jobRunner[T]) wait() {
+	r.wg.Wait()
+}
+
+// keepLeases renews the heartbeat of the jobs this server runs and requeues the jobs whose lease expired,
+// until ctx is cancelled
+func (r *jobRunner[T]) keepLeases(ctx context.Context) {
+	logger := logging.GetLogger()
+	ticker := time.NewTicker(jobHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		var model T
+		if _, err := r.db.Where(ctx, "state = ? AND runner = ?", jobRunning, jobRunnerID).UpdateColumns(ctx, &model, map[string]interface{}{
+			"heartbeat_at": time.Now(),
+		}); err != nil && ctx.Err() == nil {
+			logger.Error("Failed to renew the leases of %ss: %v", r.name, err)
+		}
+		if err := r.recoverJobs(ctx); err != nil && ctx.Err() == nil {
+			logger.Error("Failed to requeue abandoned %ss: %v", r.name, err)
+		}
+		r.notify()
+	}
+}
+
+// notify wakes an idle worker
+func (r *jobRunner[T]) notify() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// work runs queued jobs one at a time until ctx is cancelled
+func (r *jobRunner[T]) work(ctx context.Context) {
+	for {
+		job, err := r.claim(ctx)
+		if err != nil {
+			logging.GetLogger().Error("Failed to claim %s: %v", r.name, err)
+		}
+		if job == nil {
+			select {
+			case <-r.wake:
+				continue
+			case <-time.After(jobIdleInterval):
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+
+		r.run(ctx, job)
+		// Another job may have been queued, or held back, while this one was running
+		r.notify()
+	}
+}
+
+// claimOldest marks the oldest queued job as running and returns it, or nil if no job is queued. Workers
+// skip the rows other workers are claiming, so that each job is claimed once.
+func (r *jobRunner[T]) claimOldest(ctx context.Context) (*T, error) {
+	var ids []uint
+	now := time.Now()
+	query := "UPDATE " + r.table + " SET state = ?, started_at = ?, runner = ?, heartbeat_at = ? WHERE id = (SELECT id FROM " + r.table +
+		" WHERE state = ? AND deleted_at IS NULL ORDER BY id LIMIT 1 FOR UPDATE SKIP LOCKED) RETURNING id"
+	if err := r.db.Raw(ctx, &ids, query, jobRunning, now, jobRunnerID, now, jobQueued); err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	var job T
+	if err := r.db.First(ctx, &job, ids[0]); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
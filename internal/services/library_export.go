@@ -0,0 +1,163 @@
+package services
+
+import (
+	"context"
+	"io"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+)
+
+// LibraryExport describes an exported library document
+type LibraryExport struct {
+	FileName    string
+	ContentType string
+}
+
+// ExportLibrary writes a stored library to w in the requested format
+func (s *MusicLibraryService) ExportLibrary(ctx context.Context, userID, libraryID uint, format string, w io.Writer) (*LibraryExport, error) {
+	exporter, ok := s.exporters.Get(format)
+	if !ok {
+		return nil, ErrUnsupportedExportFormat
+	}
+
+	// First check if the library belongs to the user
+	library, err := s.GetLibrary(ctx, userID, libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	tree, err := s.loadLibraryTree(ctx, s.db, library, true)
+	if err != nil {
+		return nil, err
+	}
+
+	// Export the locations the files are at now
+	rules, err := s.fileStorage.GetPathRemaps(ctx, library.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range tree.Tracks {
+		tree.Tracks[i].Location, _ = RemapLocation(tree.Tracks[i].Location, rules)
+	}
+
+	if err := exporter.Export(w, tree); err != nil {
+		return nil, err
+	}
+
+	return &LibraryExport{
+		FileName:    library.Name + exporter.FileExtension(),
+		ContentType: exporter.ContentType(),
+	}, nil
+}
+
+// loadLibraryTree loads a stored library with its tracks, tempo markers, cue points and playlist tree.
+// Smart playlists are only part of the tree if withSmartPlaylists is set, as static playlists of the tracks
+// currently matching their rules.
+func (s *MusicLibraryService) loadLibraryTree(ctx context.Context, db *database.DB, library *models.MusicLibrary, withSmartPlaylists bool) (*importers.Library, error) {
+	tree := &importers.Library{
+		Source:      library.Source,
+		Version:     library.Version,
+		ProductName: library.ProductName,
+		Company:     library.Company,
+	}
+
+	// Load tracks with their tempo markers and cue points
+	if err := db.Where(ctx, "library_id = ?", library.ID).Order("id").Find(ctx, &tree.Tracks); err != nil {
+		return nil, err
+	}
+
+	var tempos []models.Tempo
+	if err := db.Where(ctx, "track_id IN (SELECT id FROM tracks WHERE library_id = ? AND deleted_at IS NULL)", library.ID).Order("track_id, inizio").Find(ctx, &tempos); err != nil {
+		return nil, err
+	}
+
+	trackIndex := make(map[uint]int, len(tree.Tracks))
+	for i, track := range tree.Tracks {
+		trackIndex[track.ID] = i
+	}
+	for _, tempo := range tempos {
+		if i, ok := trackIndex[tempo.TrackID]; ok {
+			tree.Tracks[i].Tempo = append(tree.Tracks[i].Tempo, tempo)
+		}
+	}
+
+	var cues []models.CuePoint
+	if err := db.Where(ctx, "track_id IN (SELECT id FROM tracks WHERE library_id = ? AND deleted_at IS NULL)", library.ID).Order("track_id, id").Find(ctx, &cues); err != nil {
+		return nil, err
+	}
+	for _, cue := range cues {
+		if i, ok := trackIndex[cue.TrackID]; ok {
+			tree.Tracks[i].CuePoints = append(tree.Tracks[i].CuePoints, cue)
+		}
+	}
+
+	// Load playlists and their entries
+	var playlists []models.Playlist
+	if err := db.Where(ctx, "library_id = ?", library.ID).Order("id").Find(ctx, &playlists); err != nil {
+		return nil, err
+	}
+
+	var playlistTracks []models.PlaylistTrack
+	if err := db.Where(ctx, "playlist_id IN (SELECT id FROM playlists WHERE library_id = ? AND deleted_at IS NULL)", library.ID).Order("position, id").Find(ctx, &playlistTracks); err != nil {
+		return nil, err
+	}
+
+	trackKeys := make(map[uint][]string)
+	for _, pt := range playlistTracks {
+		trackKeys[pt.PlaylistID] = append(trackKeys[pt.PlaylistID], pt.TrackKey)
+	}
+
+	children := make(map[uint][]models.Playlist)
+	var roots []models.Playlist
+	for _, playlist := range playlists {
+		if playlist.ParentID == nil {
+			roots = append(roots, playlist)
+		} else {
+			children[*playlist.ParentID] = append(children[*playlist.ParentID], playlist)
+		}
+	}
+
+	var build func(playlist models.Playlist) (importers.Node, error)
+	build = func(playlist models.Playlist) (importers.Node, error) {
+		node := importers.Node{
+			Name:      playlist.Name,
+			Type:      playlist.Type,
+			TrackKeys: trackKeys[playlist.ID],
+		}
+		if playlist.Type == models.PlaylistTypeSmart {
+			// Smart playlists are written as static playlists of their current tracks
+			keys, err := s.smartPlaylistTrackKeys(ctx, db, playlist)
+			if err != nil {
+				return node, err
+			}
+			node.Type = importers.NodeTypePlaylist
+			node.TrackKeys = keys
+		}
+		for _, child := range children[playlist.ID] {
+			if child.Type == models.PlaylistTypeSmart && !withSmartPlaylists {
+				continue
+			}
+			childNode, err := build(child)
+			if err != nil {
+				return node, err
+			}
+			node.Children = append(node.Children, childNode)
+		}
+		return node, nil
+	}
+
+	for _, root := range roots {
+		if root.Type == models.PlaylistTypeSmart && !withSmartPlaylists {
+			continue
+		}
+		node, err := build(root)
+		if err != nil {
+			return nil, err
+		}
+		tree.Playlists = append(tree.Playlists, node)
+	}
+
+	return tree, nil
+}
@@ -0,0 +1,477 @@
+package services
+
+import (
+	"context"
+	"io"
+	"slices"
+	"strconv"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+)
+
+// ImportSummary describes the changes applied by an incremental re-import
+type ImportSummary struct {
+	LibraryID        uint
+	TracksAdded      []uint
+	TracksChanged    []uint
+	TracksRemoved    []uint
+	TracksUnchanged  int
+	PlaylistsAdded   []uint
+	PlaylistsChanged []uint
+	PlaylistsRemoved []uint
+	Warnings         []string
+}
+
+// ReimportLibrary applies a new export of a library to an existing MusicLibrary as a diff.
+// Tracks are matched by their source TrackID, falling back to their location. Changed tracks are
+// updated in place, new tracks are inserted, missing tracks are soft-deleted and playlists whose
+// entries changed are rebuilt, so that existing IDs stay stable. The result is recorded as a new library version.
+func (s *MusicLibraryService) ReimportLibrary(ctx context.Context, userID, libraryID uint, source string, reader io.Reader) (*ImportSummary, error) {
+	// First check if the library belongs to the user
+	library, err := s.GetLibrary(ctx, userID, libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	imported, err := s.parseLibrary(source, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary *ImportSummary
+	err = s.db.Transaction(ctx, func(tx *database.DB) error {
+		// Changes recorded as versions are applied one at a time
+		library, err := s.lockLibrary(ctx, tx, library.ID)
+		if err != nil {
+			return err
+		}
+
+		// Libraries imported before version history existed get their current state as a baseline
+		if err := s.ensureBaselineSnapshot(ctx, tx, library); err != nil {
+			return err
+		}
+
+		summary, err = s.applyLibrary(ctx, tx, library, imported)
+		if err != nil {
+			return err
+		}
+
+		// The version records the library as stored, which keeps analysis results the export lacks
+		tree, err := s.loadLibraryTree(ctx, tx, library, false)
+		if err != nil {
+			return err
+		}
+		_, err = s.recordSnapshot(ctx, tx, library.ID, SnapshotReasonReimport, tree)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Warnings = imported.Warnings
+	return summary, nil
+}
+
+// applyLibrary updates a stored library in place so that it matches the given library tree
+func (s *MusicLibraryService) applyLibrary(ctx context.Context, tx *database.DB, library *models.MusicLibrary, imported *importers.Library) (*ImportSummary, error) {
+	library.Source = imported.Source
+	library.Version = imported.Version
+	library.ProductName = imported.ProductName
+	library.Company = imported.Company
+	if err := tx.Save(ctx, library); err != nil {
+		return nil, err
+	}
+
+	summary := &ImportSummary{LibraryID: library.ID}
+	if err := s.diffTracks(ctx, tx, library.ID, imported.Tracks, summary); err != nil {
+		return nil, err
+	}
+	if err := s.diffPlaylists(ctx, tx, library.ID, imported.Playlists, summary); err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// diffTracks inserts, updates and soft-deletes tracks so that the library matches the imported tracks
+func (s *MusicLibraryService) diffTracks(ctx context.Context, tx *database.DB, libraryID uint, importedTracks []models.Track, summary *ImportSummary) error {
+	var existing []models.Track
+	if err := tx.Where(ctx, "library_id = ?", libraryID).Order("id").Find(ctx, &existing); err != nil {
+		return err
+	}
+
+	var storedTempos []models.Tempo
+	if err := tx.Where(ctx, "track_id IN (SELECT id FROM tracks WHERE library_id = ? AND deleted_at IS NULL)", libraryID).Order("track_id, inizio").Find(ctx, &storedTempos); err != nil {
+		return err
+	}
+
+	temposByTrack := make(map[uint][]models.Tempo)
+	for _, tempo := range storedTempos {
+		temposByTrack[tempo.TrackID] = append(temposByTrack[tempo.TrackID], tempo)
+	}
+
+	var storedCues []models.CuePoint
+	if err := tx.Where(ctx, "track_id IN (SELECT id FROM tracks WHERE library_id = ? AND deleted_at IS NULL)", libraryID).Order("track_id, id").Find(ctx, &storedCues); err != nil {
+		return err
+	}
+
+	cuesByTrack := make(map[uint][]models.CuePoint)
+	for _, cue := range storedCues {
+		cuesByTrack[cue.TrackID] = append(cuesByTrack[cue.TrackID], cue)
+	}
+
+	byTrackID := make(map[string]int, len(existing))
+	byLocation := make(map[string]int, len(existing))
+	for i, track := range existing {
+		byTrackID[track.TrackID] = i
+		if track.Location != "" {
+			byLocation[track.Location] = i
+		}
+	}
+
+	matched := make([]bool, len(existing))
+	for _, incoming := range importedTracks {
+		i, ok := byTrackID[incoming.TrackID]
+		if !ok || matched[i] {
+			i, ok = byLocation[incoming.Location]
+		}
+
+		if !ok || matched[i] {
+			track, err := s.createTrack(ctx, tx, libraryID, incoming)
+			if err != nil {
+				return err
+			}
+			summary.TracksAdded = append(summary.TracksAdded, track.ID)
+			continue
+		}
+
+		matched[i] = true
+		current := existing[i]
+		keepAnalyzedMetadata(&incoming, current, temposByTrack[current.ID])
+
+		metadataChanged := !trackMetadataEqual(current, incoming)
+		tempoChanged := !tempoMarkersEqual(temposByTrack[current.ID], incoming.Tempo)
+		cuesChanged := !cuePointsEqual(cuesByTrack[current.ID], incoming.CuePoints)
+		if !metadataChanged && !tempoChanged && !cuesChanged {
+			summary.TracksUnchanged++
+			continue
+		}
+
+		if metadataChanged {
+			copyTrackMetadata(&current, incoming)
+			if err := tx.Save(ctx, &current); err != nil {
+				return err
+			}
+		}
+
+		if tempoChanged {
+			if err := tx.Where(ctx, "track_id = ?", current.ID).Delete(ctx, &models.Tempo{}); err != nil {
+				return err
+			}
+			for _, marker := range incoming.Tempo {
+				marker.TrackID = current.ID
+				if err := tx.Create(ctx, &marker); err != nil {
+					return err
+				}
+			}
+		}
+
+		if cuesChanged {
+			if err := tx.Where(ctx, "track_id = ?", current.ID).Delete(ctx, &models.CuePoint{}); err != nil {
+				return err
+			}
+			for _, cue := range incoming.CuePoints {
+				cue.TrackID = current.ID
+				if err := tx.Create(ctx, &cue); err != nil {
+					return err
+				}
+			}
+		}
+
+		summary.TracksChanged = append(summary.TracksChanged, current.ID)
+	}
+
+	// Soft-delete tracks that are no longer part of the export
+	for i, track := range existing {
+		if matched[i] {
+			continue
+		}
+		if err := tx.Delete(ctx, &track); err != nil {
+			return err
+		}
+		summary.TracksRemoved = append(summary.TracksRemoved, track.ID)
+	}
+
+	return nil
+}
+
+// diffPlaylists creates, rebuilds and removes playlists so that the library matches the imported tree.
+// Playlists are matched by their path of names from the root, so renamed playlists are replaced.
+func (s *MusicLibraryService) diffPlaylists(ctx context.Context, tx *database.DB, libraryID uint, nodes []importers.Node, summary *ImportSummary) error {
+	var playlists []models.Playlist
+	if err := tx.Where(ctx, "library_id = ?", libraryID).Order("id").Find(ctx, &playlists); err != nil {
+		return err
+	}
+
+	var entries []models.PlaylistTrack
+	if err := tx.Where(ctx, "playlist_id IN (SELECT id FROM playlists WHERE library_id = ? AND deleted_at IS NULL)", libraryID).Order("position, id").Find(ctx, &entries); err != nil {
+		return err
+	}
+
+	trackKeys := make(map[uint][]string)
+	for _, entry := range entries {
+		trackKeys[entry.PlaylistID] = append(trackKeys[entry.PlaylistID], entry.TrackKey)
+	}
+
+	// Index existing playlists by path
+	children := make(map[uint][]models.Playlist)
+	var roots []models.Playlist
+	for _, playlist := range playlists {
+		if playlist.ParentID == nil {
+			roots = append(roots, playlist)
+		} else {
+			children[*playlist.ParentID] = append(children[*playlist.ParentID], playlist)
+		}
+	}
+
+	existing := make(map[string]models.Playlist, len(playlists))
+	var index func(parentPath string, siblings []models.Playlist)
+	index = func(parentPath string, siblings []models.Playlist) {
+		names := make([]string, len(siblings))
+		for i, playlist := range siblings {
+			names[i] = playlist.Name
+		}
+		for i, path := range playlistPaths(parentPath, names) {
+			existing[path] = siblings[i]
+			index(path, children[siblings[i].ID])
+		}
+	}
+	index("", roots)
+
+	// Walk the imported tree, reusing playlists whose path and type are unchanged
+	matched := make(map[uint]bool, len(playlists))
+	var walk func(parentPath string, parentID *uint, siblings []importers.Node) error
+	walk = func(parentPath string, parentID *uint, siblings []importers.Node) error {
+		names := make([]string, len(siblings))
+		for i, node := range siblings {
+			names[i] = node.Name
+		}
+
+		for i, path := range playlistPaths(parentPath, names) {
+			node := siblings[i]
+
+			playlist, ok := existing[path]
+			if ok && playlist.Type == node.Type {
+				matched[playlist.ID] = true
+				if node.Type == importers.NodeTypePlaylist && !slices.Equal(trackKeys[playlist.ID], node.TrackKeys) {
+					if err := tx.Where(ctx, "playlist_id = ?", playlist.ID).Delete(ctx, &models.PlaylistTrack{}); err != nil {
+						return err
+					}
+					if err := s.createPlaylistEntries(ctx, tx, playlist.ID, node.TrackKeys); err != nil {
+						return err
+					}
+					summary.PlaylistsChanged = append(summary.PlaylistsChanged, playlist.ID)
+				}
+			} else {
+				playlist = models.Playlist{
+					LibraryID: libraryID,
+					Name:      node.Name,
+					Type:      node.Type,
+					ParentID:  parentID,
+				}
+				if err := tx.Create(ctx, &playlist); err != nil {
+					return err
+				}
+				if node.Type == importers.NodeTypePlaylist {
+					if err := s.createPlaylistEntries(ctx, tx, playlist.ID, node.TrackKeys); err != nil {
+						return err
+					}
+				}
+				summary.PlaylistsAdded = append(summary.PlaylistsAdded, playlist.ID)
+			}
+
+			id := playlist.ID
+			if err := walk(path, &id, node.Children); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := walk("", nil, nodes); err != nil {
+		return err
+	}
+
+	// Remove playlists that are no longer part of the export. Smart playlists only exist here, so they are
+	// kept, and moved up to their closest remaining folder if theirs is removed.
+	removed := make(map[uint]bool, len(playlists))
+	byID := make(map[uint]models.Playlist, len(playlists))
+	for _, playlist := range playlists {
+		byID[playlist.ID] = playlist
+		removed[playlist.ID] = !matched[playlist.ID] && playlist.Type != models.PlaylistTypeSmart
+	}
+	for _, playlist := range playlists {
+		if playlist.Type != models.PlaylistTypeSmart || playlist.ParentID == nil || !removed[*playlist.ParentID] {
+			continue
+		}
+		parentID := playlist.ParentID
+		for parentID != nil && removed[*parentID] {
+			parentID = byID[*parentID].ParentID
+		}
+		if _, err := tx.Where(ctx, "id = ?", playlist.ID).UpdateColumns(ctx, &models.Playlist{}, map[string]interface{}{"parent_id": parentID}); err != nil {
+			return err
+		}
+	}
+
+	for _, playlist := range playlists {
+		if !removed[playlist.ID] {
+			continue
+		}
+		if err := tx.Where(ctx, "playlist_id = ?", playlist.ID).Delete(ctx, &models.PlaylistTrack{}); err != nil {
+			return err
+		}
+		if err := tx.Delete(ctx, &playlist); err != nil {
+			return err
+		}
+		summary.PlaylistsRemoved = append(summary.PlaylistsRemoved, playlist.ID)
+	}
+
+	return nil
+}
+
+// playlistPaths builds unique paths for sibling playlists, numbering repeated names
+func playlistPaths(parentPath string, names []string) []string {
+	seen := make(map[string]int, len(names))
+	paths := make([]string, len(names))
+	for i, name := range names {
+		seen[name]++
+		paths[i] = parentPath + "/" + name + "#" + strconv.Itoa(seen[name])
+	}
+	return paths
+}
+
+// trackMetadataEqual reports whether two tracks carry the same imported metadata
+func trackMetadataEqual(a, b models.Track) bool {
+	return a.TrackID == b.TrackID &&
+		a.Name == b.Name &&
+		a.Artist == b.Artist &&
+		a.Composer == b.Composer &&
+		a.Album == b.Album &&
+		a.Grouping == b.Grouping &&
+		a.Genre == b.Genre &&
+		a.Kind == b.Kind &&
+		a.Size == b.Size &&
+		a.TotalTime == b.TotalTime &&
+		a.DiscNumber == b.DiscNumber &&
+		a.TrackNumber == b.TrackNumber &&
+		a.Year == b.Year &&
+		a.AverageBpm == b.AverageBpm &&
+		a.BpmSource == b.BpmSource &&
+		a.DateAdded.Equal(b.DateAdded) &&
+		a.BitRate == b.BitRate &&
+		a.SampleRate == b.SampleRate &&
+		a.Comments == b.Comments &&
+		a.PlayCount == b.PlayCount &&
+		a.Rating == b.Rating &&
+		a.Location == b.Location &&
+		a.Remixer == b.Remixer &&
+		a.Tonality == b.Tonality &&
+		a.KeySource == b.KeySource &&
+		a.Label == b.Label &&
+		a.Mix == b.Mix
+}
+
+// copyTrackMetadata copies the imported metadata of src onto dst, keeping dst's identity and storage type
+func copyTrackMetadata(dst *models.Track, src models.Track) {
+	dst.TrackID = src.TrackID
+	dst.Name = src.Name
+	dst.Artist = src.Artist
+	dst.Composer = src.Composer
+	dst.Album = src.Album
+	dst.Grouping = src.Grouping
+	dst.Genre = src.Genre
+	dst.Kind = src.Kind
+	dst.Size = src.Size
+	dst.TotalTime = src.TotalTime
+	dst.DiscNumber = src.DiscNumber
+	dst.TrackNumber = src.TrackNumber
+	dst.Year = src.Year
+	dst.AverageBpm = src.AverageBpm
+	dst.BpmSource = src.BpmSource
+	dst.DateAdded = src.DateAdded
+	dst.BitRate = src.BitRate
+	dst.SampleRate = src.SampleRate
+	dst.Comments = src.Comments
+	dst.PlayCount = src.PlayCount
+	dst.Rating = src.Rating
+	dst.Location = src.Location
+	dst.Remixer = src.Remixer
+	dst.Tonality = src.Tonality
+	dst.KeySource = src.KeySource
+	dst.Label = src.Label
+	dst.Mix = src.Mix
+}
+
+// tempoMarkersEqual reports whether stored tempo markers match imported ones
+func tempoMarkersEqual(stored, imported []models.Tempo) bool {
+	if len(stored) != len(imported) {
+		return false
+	}
+	for i := range stored {
+		if stored[i].Inizio != imported[i].Inizio ||
+			stored[i].Bpm != imported[i].Bpm ||
+			stored[i].Metro != imported[i].Metro ||
+			stored[i].Battito != imported[i].Battito ||
+			stored[i].Source != imported[i].Source {
+			return false
+		}
+	}
+	return true
+}
+
+// keepAnalyzedMetadata carries over the analyzed tempo, key and beatgrid of a stored track to its imported
+// version when the import does not provide them, so that re-importing does not discard the analysis
+func keepAnalyzedMetadata(incoming *models.Track, current models.Track, tempos []models.Tempo) {
+	if incoming.AverageBpm == 0 && current.BpmSource == models.MetadataSourceAnalyzed {
+		incoming.AverageBpm, incoming.BpmSource = current.AverageBpm, current.BpmSource
+	}
+	if incoming.Tonality == "" && current.KeySource == models.MetadataSourceAnalyzed {
+		incoming.Tonality, incoming.KeySource = current.Tonality, current.KeySource
+	}
+	if len(incoming.Tempo) > 0 || len(tempos) == 0 {
+		return
+	}
+	for _, tempo := range tempos {
+		if tempo.Source != models.MetadataSourceAnalyzed {
+			return
+		}
+	}
+	incoming.Tempo = tempos
+}
+
+// cuePointsEqual reports whether stored cue points match imported ones, in order
+func cuePointsEqual(stored, imported []models.CuePoint) bool {
+	if len(stored) != len(imported) {
+		return false
+	}
+	for i := range stored {
+		if stored[i].Type != imported[i].Type ||
+			stored[i].Start != imported[i].Start ||
+			!equalPointers(stored[i].End, imported[i].End) ||
+			stored[i].HotCue != imported[i].HotCue ||
+			stored[i].Color != imported[i].Color ||
+			stored[i].Name != imported[i].Name {
+			return false
+		}
+	}
+	return true
+}
+
+// equalPointers reports whether two optional values are both unset or both set to the same value
+func equalPointers[T comparable](a, b *T) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
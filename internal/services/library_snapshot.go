@@ -0,0 +1,586 @@
+package services
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/dinis/musync/internal/database"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/models"
+	"gorm.io/gorm"
+)
+
+// Reasons a library snapshot was recorded
+const (
+	SnapshotReasonImport       = "import"
+	SnapshotReasonReimport     = "reimport"
+	SnapshotReasonRollback     = "rollback"
+	SnapshotReasonPlaylistEdit = "playlist_edit" // Entries added to, removed from or moved in a playlist
+	SnapshotReasonMerge        = "merge"         // Duplicate tracks merged
+	SnapshotReasonRescan       = "rescan"        // Track metadata updated from the tags of its file
+)
+
+// SnapshotTrack identifies a track in a snapshot diff
+type SnapshotTrack struct {
+	TrackID string
+	Name    string
+	Artist  string
+}
+
+// FieldChange describes a changed track field
+type FieldChange struct {
+	Field string
+	From  string
+	To    string
+}
+
+// TrackChange describes a track whose metadata differs between two versions
+type TrackChange struct {
+	SnapshotTrack
+	Fields []FieldChange
+}
+
+// PlaylistChange describes a playlist whose tracks differ between two versions
+type PlaylistChange struct {
+	Path          string
+	TracksAdded   []string // Track keys
+	TracksRemoved []string // Track keys
+	Reordered     bool     // Same tracks in a different order
+}
+
+// SnapshotDiff describes the differences between two versions of a library
+type SnapshotDiff struct {
+	LibraryID        uint
+	FromVersion      int
+	ToVersion        int
+	TracksAdded      []SnapshotTrack
+	TracksRemoved    []SnapshotTrack
+	TracksChanged    []TrackChange
+	PlaylistsAdded   []string // Playlist paths
+	PlaylistsRemoved []string // Playlist paths
+	PlaylistsChanged []PlaylistChange
+}
+
+// ListSnapshots returns the version history of a library, newest first
+func (s *MusicLibraryService) ListSnapshots(ctx context.Context, userID, libraryID uint) ([]models.LibrarySnapshot, error) {
+	// First check if the library belongs to the user
+	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
+		return nil, err
+	}
+
+	var snapshots []models.LibrarySnapshot
+	if err := s.db.Where(ctx, "library_id = ?", libraryID).Omit("data").Order("version DESC").Find(ctx, &snapshots); err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// DiffSnapshots compares two versions of a library
+func (s *MusicLibraryService) DiffSnapshots(ctx context.Context, userID, libraryID uint, fromVersion, toVersion int) (*SnapshotDiff, error) {
+	// First check if the library belongs to the user
+	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
+		return nil, err
+	}
+
+	from, err := s.loadSnapshotTree(ctx, s.db, libraryID, fromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, err := s.loadSnapshotTree(ctx, s.db, libraryID, toVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &SnapshotDiff{LibraryID: libraryID, FromVersion: fromVersion, ToVersion: toVersion}
+	diffSnapshotTracks(from.Tracks, to.Tracks, diff)
+	diffSnapshotPlaylists(from.Playlists, to.Playlists, diff)
+	return diff, nil
+}
+
+// RollbackLibrary restores a library to the state recorded in an earlier version.
+// The rollback is applied as a diff and recorded as a new version, so it can itself be undone.
+//
+// Versions record the tracks and static playlists of a library, and every change of them is recorded as a
+// version, see versionedEdit. Smart playlists, path remap rules and tempo and key analysis results are not
+// part of versions: a rollback keeps them as they are.
+func (s *MusicLibraryService) RollbackLibrary(ctx context.Context, userID, libraryID uint, version int) (*ImportSummary, error) {
+	// First check if the library belongs to the user
+	library, err := s.GetLibrary(ctx, userID, libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var summary *ImportSummary
+	err = s.db.Transaction(ctx, func(tx *database.DB) error {
+		locked, err := s.lockLibrary(ctx, tx, library.ID)
+		if err != nil {
+			return err
+		}
+		tree, err := s.loadSnapshotTree(ctx, tx, libraryID, version)
+		if err != nil {
+			return err
+		}
+
+		summary, err = s.applyLibrary(ctx, tx, locked, tree)
+		if err != nil {
+			return err
+		}
+
+		_, err = s.recordSnapshot(ctx, tx, libraryID, SnapshotReasonRollback, tree)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return summary, nil
+}
+
+// recordSnapshot stores a library tree as the next version of a library
+func (s *MusicLibraryService) recordSnapshot(ctx context.Context, tx *database.DB, libraryID uint, reason string, tree *importers.Library) (*models.LibrarySnapshot, error) {
+	data, err := encodeSnapshot(tree)
+	if err != nil {
+		return nil, err
+	}
+	return s.saveSnapshot(ctx, tx, libraryID, reason, data, len(tree.Tracks), countPlaylists(tree.Playlists))
+}
+
+// saveSnapshot stores encoded snapshot data as the next version of a library. The library row is locked
+// first, so that concurrent changes cannot number their versions alike.
+func (s *MusicLibraryService) saveSnapshot(ctx context.Context, tx *database.DB, libraryID uint, reason string, data []byte, trackCount, playlistCount int) (*models.LibrarySnapshot, error) {
+	if _, err := s.lockLibrary(ctx, tx, libraryID); err != nil {
+		return nil, err
+	}
+
+	version := 1
+	var latest models.LibrarySnapshot
+	err := tx.Where(ctx, "library_id = ?", libraryID).Omit("data").Order("version DESC").First(ctx, &latest)
+	switch {
+	case err == nil:
+		version = latest.Version + 1
+	case !errors.Is(err, apperrors.ErrNotFound):
+		return nil, err
+	}
+
+	snapshot := &models.LibrarySnapshot{
+		LibraryID:     libraryID,
+		Version:       version,
+		Reason:        reason,
+		TrackCount:    trackCount,
+		PlaylistCount: playlistCount,
+		Data:          data,
+	}
+	if err := tx.Create(ctx, snapshot); err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+// versionedEdit applies an edit of the tracks or playlists of a library in a transaction and records the
+// library's state after it as a new version, so that the edit shows up in the history and a rollback does
+// not lose it. Libraries without a history get their state before the edit as a baseline version.
+// No version is recorded if the edit left the library unchanged.
+func (s *MusicLibraryService) versionedEdit(ctx context.Context, libraryID uint, reason string, edit func(tx *database.DB) error) error {
+	return s.db.Transaction(ctx, func(tx *database.DB) error {
+		library, err := s.lockLibrary(ctx, tx, libraryID)
+		if err != nil {
+			return err
+		}
+		if err := s.ensureBaselineSnapshot(ctx, tx, library); err != nil {
+			return err
+		}
+
+		if err := edit(tx); err != nil {
+			return err
+		}
+
+		tree, err := s.loadLibraryTree(ctx, tx, library, false)
+		if err != nil {
+			return err
+		}
+		data, err := encodeSnapshot(tree)
+		if err != nil {
+			return err
+		}
+		var latest models.LibrarySnapshot
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Order("version DESC").First(ctx, &latest); err != nil {
+			return err
+		}
+		if bytes.Equal(latest.Data, data) {
+			return nil
+		}
+		_, err = s.saveSnapshot(ctx, tx, libraryID, reason, data, len(tree.Tracks), countPlaylists(tree.Playlists))
+		return err
+	})
+}
+
+// lockLibrary locks the row of a library until the end of the transaction, so that changes recorded as
+// versions of the library are applied one at a time, and returns the library as it is once locked
+func (s *MusicLibraryService) lockLibrary(ctx context.Context, tx *database.DB, libraryID uint) (*models.MusicLibrary, error) {
+	var library models.MusicLibrary
+	if err := tx.ForUpdate().Where(ctx, "id = ?", libraryID).First(ctx, &library); err != nil {
+		return nil, err
+	}
+	return &library, nil
+}
+
+// ensureBaselineSnapshot records the current state of a library that has no version history yet
+func (s *MusicLibraryService) ensureBaselineSnapshot(ctx context.Context, tx *database.DB, library *models.MusicLibrary) error {
+	var snapshots []models.LibrarySnapshot
+	if err := tx.Where(ctx, "library_id = ?", library.ID).Omit("data").Find(ctx, &snapshots); err != nil {
+		return err
+	}
+	if len(snapshots) > 0 {
+		return nil
+	}
+
+	tree, err := s.loadLibraryTree(ctx, tx, library, false)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.recordSnapshot(ctx, tx, library.ID, SnapshotReasonImport, tree)
+	return err
+}
+
+// loadSnapshotTree loads and decodes the library tree recorded in a version
+func (s *MusicLibraryService) loadSnapshotTree(ctx context.Context, db *database.DB, libraryID uint, version int) (*importers.Library, error) {
+	var snapshot models.LibrarySnapshot
+	if err := db.Where(ctx, "library_id = ? AND version = ?", libraryID, version).First(ctx, &snapshot); err != nil {
+		if errors.Is(err, apperrors.ErrNotFound) {
+			return nil, ErrSnapshotNotFound
+		}
+		return nil, err
+	}
+	return decodeSnapshot(snapshot.Data)
+}
+
+// encodeSnapshot serializes a library tree as gzip compressed JSON
+func encodeSnapshot(tree *importers.Library) ([]byte, error) {
+	encoder := newSnapshotEncoder()
+	for _, track := range tree.Tracks {
+		if err := encoder.Track(track); err != nil {
+			return nil, err
+		}
+	}
+	return encoder.Finish(tree)
+}
+
+// snapshotEncoder writes a library tree as gzip compressed JSON one track at a time,
+// so that only the compressed snapshot is kept in memory while a library is streamed in.
+// Database identities are dropped so that a decoded tree can be re-applied like a fresh import.
+type snapshotEncoder struct {
+	buf    bytes.Buffer
+	writer *gzip.Writer
+	tracks int
+}
+
+// snapshotTrailer holds the fields of a snapshot that are written after its tracks
+type snapshotTrailer struct {
+	Source      string
+	Version     string
+	ProductName string
+	Company     string
+	Playlists   []importers.Node
+}
+
+// newSnapshotEncoder creates an empty snapshotEncoder
+func newSnapshotEncoder() *snapshotEncoder {
+	e := &snapshotEncoder{}
+	e.writer = gzip.NewWriter(&e.buf)
+	return e
+}
+
+// Track appends a track to the snapshot
+func (e *snapshotEncoder) Track(track models.Track) error {
+	track.Model = gorm.Model{}
+	track.LibraryID = 0
+	tempos := make([]models.Tempo, len(track.Tempo))
+	for i, tempo := range track.Tempo {
+		tempo.Model = gorm.Model{}
+		tempo.TrackID = 0
+		tempos[i] = tempo
+	}
+	track.Tempo = tempos
+	cues := make([]models.CuePoint, len(track.CuePoints))
+	for i, cue := range track.CuePoints {
+		cue.Model = gorm.Model{}
+		cue.TrackID = 0
+		cues[i] = cue
+	}
+	track.CuePoints = cues
+
+	data, err := json.Marshal(track)
+	if err != nil {
+		return err
+	}
+
+	prefix := ","
+	if e.tracks == 0 {
+		prefix = `{"Tracks":[`
+	}
+	if _, err := e.writer.Write([]byte(prefix)); err != nil {
+		return err
+	}
+	if _, err := e.writer.Write(data); err != nil {
+		return err
+	}
+	e.tracks++
+	return nil
+}
+
+// Finish writes the library's metadata and playlist tree and returns the compressed snapshot
+func (e *snapshotEncoder) Finish(tree *importers.Library) ([]byte, error) {
+	trailer, err := json.Marshal(snapshotTrailer{
+		Source:      tree.Source,
+		Version:     tree.Version,
+		ProductName: tree.ProductName,
+		Company:     tree.Company,
+		Playlists:   tree.Playlists,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Splice the trailer's fields in after the track list
+	prefix := "],"
+	if e.tracks == 0 {
+		prefix = `{"Tracks":[],`
+	}
+	if _, err := e.writer.Write([]byte(prefix)); err != nil {
+		return nil, err
+	}
+	if _, err := e.writer.Write(trailer[1:]); err != nil {
+		return nil, err
+	}
+	if err := e.writer.Close(); err != nil {
+		return nil, err
+	}
+	return e.buf.Bytes(), nil
+}
+
+// decodeSnapshot deserializes a library tree stored by encodeSnapshot
+func decodeSnapshot(data []byte) (*importers.Library, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var tree importers.Library
+	if err := json.NewDecoder(reader).Decode(&tree); err != nil {
+		return nil, err
+	}
+	return &tree, nil
+}
+
+// countPlaylists counts the playlists and folders in a tree
+func countPlaylists(nodes []importers.Node) int {
+	count := len(nodes)
+	for _, node := range nodes {
+		count += countPlaylists(node.Children)
+	}
+	return count
+}
+
+// diffSnapshotTracks compares two track lists, matching tracks by TrackID and then by location
+func diffSnapshotTracks(from, to []models.Track, diff *SnapshotDiff) {
+	byTrackID := make(map[string]int, len(from))
+	byLocation := make(map[string]int, len(from))
+	for i, track := range from {
+		byTrackID[track.TrackID] = i
+		if track.Location != "" {
+			byLocation[track.Location] = i
+		}
+	}
+
+	matched := make([]bool, len(from))
+	for _, track := range to {
+		i, ok := byTrackID[track.TrackID]
+		if !ok || matched[i] {
+			i, ok = byLocation[track.Location]
+		}
+		if !ok || matched[i] {
+			diff.TracksAdded = append(diff.TracksAdded, toSnapshotTrack(track))
+			continue
+		}
+
+		matched[i] = true
+		if fields := trackFieldChanges(from[i], track); len(fields) > 0 {
+			diff.TracksChanged = append(diff.TracksChanged, TrackChange{
+				SnapshotTrack: toSnapshotTrack(track),
+				Fields:        fields,
+			})
+		}
+	}
+
+	for i, track := range from {
+		if !matched[i] {
+			diff.TracksRemoved = append(diff.TracksRemoved, toSnapshotTrack(track))
+		}
+	}
+}
+
+// snapshotPlaylist is a playlist or folder flattened out of a tree
+type snapshotPlaylist struct {
+	path      string
+	nodeType  int
+	trackKeys []string
+}
+
+// diffSnapshotPlaylists compares two playlist trees, matching playlists by their path
+func diffSnapshotPlaylists(from, to []importers.Node, diff *SnapshotDiff) {
+	fromPlaylists := flattenPlaylists(from)
+	toPlaylists := flattenPlaylists(to)
+
+	existing := make(map[string]snapshotPlaylist, len(fromPlaylists))
+	for _, playlist := range fromPlaylists {
+		existing[playlist.path] = playlist
+	}
+
+	matched := make(map[string]bool, len(fromPlaylists))
+	for _, playlist := range toPlaylists {
+		previous, ok := existing[playlist.path]
+		if !ok || previous.nodeType != playlist.nodeType {
+			diff.PlaylistsAdded = append(diff.PlaylistsAdded, displayPlaylistPath(playlist.path))
+			continue
+		}
+
+		matched[playlist.path] = true
+		if slices.Equal(previous.trackKeys, playlist.trackKeys) {
+			continue
+		}
+
+		change := PlaylistChange{Path: displayPlaylistPath(playlist.path)}
+		change.TracksAdded = subtractKeys(playlist.trackKeys, previous.trackKeys)
+		change.TracksRemoved = subtractKeys(previous.trackKeys, playlist.trackKeys)
+		change.Reordered = len(change.TracksAdded) == 0 && len(change.TracksRemoved) == 0
+		diff.PlaylistsChanged = append(diff.PlaylistsChanged, change)
+	}
+
+	for _, playlist := range fromPlaylists {
+		if !matched[playlist.path] {
+			diff.PlaylistsRemoved = append(diff.PlaylistsRemoved, displayPlaylistPath(playlist.path))
+		}
+	}
+}
+
+// flattenPlaylists lists every node of a playlist tree with its unique path, in tree order
+func flattenPlaylists(nodes []importers.Node) []snapshotPlaylist {
+	var playlists []snapshotPlaylist
+	var walk func(parentPath string, siblings []importers.Node)
+	walk = func(parentPath string, siblings []importers.Node) {
+		names := make([]string, len(siblings))
+		for i, node := range siblings {
+			names[i] = node.Name
+		}
+		for i, path := range playlistPaths(parentPath, names) {
+			node := siblings[i]
+			playlists = append(playlists, snapshotPlaylist{path: path, nodeType: node.Type, trackKeys: node.TrackKeys})
+			walk(path, node.Children)
+		}
+	}
+	walk("", nodes)
+	return playlists
+}
+
+// displayPlaylistPath turns a path built by playlistPaths into a readable one, e.g. "/Sets/Warmup".
+// Repeated sibling names keep their occurrence number, e.g. "/Sets/Warmup (2)".
+func displayPlaylistPath(path string) string {
+	segments := strings.Split(strings.TrimPrefix(path, "/"), "/")
+	for i, segment := range segments {
+		name, occurrence, _ := strings.Cut(segment, "#")
+		if n, err := strconv.Atoi(occurrence); err == nil && n > 1 {
+			name += " (" + occurrence + ")"
+		}
+		segments[i] = name
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// subtractKeys returns the keys of a that are not in b, preserving order and multiplicity
+func subtractKeys(a, b []string) []string {
+	remaining := make(map[string]int, len(b))
+	for _, key := range b {
+		remaining[key]++
+	}
+
+	var result []string
+	for _, key := range a {
+		if remaining[key] > 0 {
+			remaining[key]--
+			continue
+		}
+		result = append(result, key)
+	}
+	return result
+}
+
+// toSnapshotTrack builds the identifying summary of a track
+func toSnapshotTrack(track models.Track) SnapshotTrack {
+	return SnapshotTrack{TrackID: track.TrackID, Name: track.Name, Artist: track.Artist}
+}
+
+// trackFields lists the imported metadata fields of a track with their formatted values
+func trackFields(track models.Track) [][2]string {
+	return [][2]string{
+		{"track_id", track.TrackID},
+		{"title", track.Name},
+		{"artist", track.Artist},
+		{"composer", track.Composer},
+		{"album", track.Album},
+		{"grouping", track.Grouping},
+		{"genre", track.Genre},
+		{"kind", track.Kind},
+		{"size", strconv.FormatInt(track.Size, 10)},
+		{"duration", strconv.Itoa(track.TotalTime)},
+		{"disc_number", strconv.Itoa(track.DiscNumber)},
+		{"track_number", strconv.Itoa(track.TrackNumber)},
+		{"year", strconv.Itoa(track.Year)},
+		{"bpm", strconv.FormatFloat(track.AverageBpm, 'f', -1, 64)},
+		{"date_added", track.DateAdded.UTC().Format("2006-01-02")},
+		{"bit_rate", strconv.Itoa(track.BitRate)},
+		{"sample_rate", strconv.Itoa(track.SampleRate)},
+		{"comments", track.Comments},
+		{"play_count", strconv.Itoa(track.PlayCount)},
+		{"rating", strconv.Itoa(track.Rating)},
+		{"location", track.Location},
+		{"remixer", track.Remixer},
+		{"key", track.Tonality},
+		{"label", track.Label},
+		{"mix", track.Mix},
+	}
+}
+
+// trackFieldChanges lists the metadata fields that differ between two versions of a track
+func trackFieldChanges(from, to models.Track) []FieldChange {
+	var changes []FieldChange
+	fromFields, toFields := trackFields(from), trackFields(to)
+	for i := range fromFields {
+		if fromFields[i][1] != toFields[i][1] {
+			changes = append(changes, FieldChange{Field: fromFields[i][0], From: fromFields[i][1], To: toFields[i][1]})
+		}
+	}
+	if !tempoMarkersEqual(from.Tempo, to.Tempo) {
+		changes = append(changes, FieldChange{
+			Field: "tempo_markers",
+			From:  strconv.Itoa(len(from.Tempo)) + " markers",
+			To:    strconv.Itoa(len(to.Tempo)) + " markers",
+		})
+	}
+	if !cuePointsEqual(from.CuePoints, to.CuePoints) {
+		changes = append(changes, FieldChange{
+			Field: "cue_points",
+			From:  strconv.Itoa(len(from.CuePoints)) + " cues",
+			To:    strconv.Itoa(len(to.CuePoints)) + " cues",
+		})
+	}
+	return changes
+}
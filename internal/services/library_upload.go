@@ -0,0 +1,227 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"sync/atomic"
+)
+
+// Leading bytes of the compressed formats accepted for library uploads
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// StagedUpload is an uploaded library file that has been written to the import directory
+type StagedUpload struct {
+	Path string
+	Size int64
+}
+
+// Stage writes an uploaded library file to the import directory, enforcing the upload size limit.
+// The file may be a plain export or a gzip or zip compressed one.
+func (s *ImportJobService) Stage(reader io.Reader) (*StagedUpload, error) {
+	file, err := os.CreateTemp(s.config.Dir, "import-*.upload")
+	if err != nil {
+		return nil, err
+	}
+
+	// Read one byte past the limit to tell a file of exactly the maximum size from a larger one
+	size, err := io.Copy(file, io.LimitReader(reader, s.config.MaxUploadSize+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && size > s.config.MaxUploadSize {
+		err = ErrLibraryTooLarge
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return nil, err
+	}
+
+	return &StagedUpload{Path: file.Name(), Size: size}, nil
+}
+
+// MaxUploadSize returns the size limit of uploaded library files in bytes
+func (s *ImportJobService) MaxUploadSize() int64 {
+	return s.config.MaxUploadSize
+}
+
+// Discard removes a staged upload that will not be imported
+func (s *ImportJobService) Discard(upload *StagedUpload) {
+	os.Remove(upload.Path)
+}
+
+// Reimport applies a staged upload to an existing library as a diff and removes the upload
+func (s *ImportJobService) Reimport(ctx context.Context, userID, libraryID uint, source string, upload *StagedUpload) (*ImportSummary, error) {
+	defer s.Discard(upload)
+
+	opened, err := s.openStaged(upload.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer opened.Close()
+
+	return s.libraryService.ReimportLibrary(ctx, userID, libraryID, source, opened.reader)
+}
+
+// openedUpload is a staged upload opened for reading, decompressed if it was compressed
+type openedUpload struct {
+	reader  io.Reader       // Library file, limited to the maximum library size
+	counter *countingReader // Counts the bytes progress is reported in
+	size    int64           // Total number of bytes counted by counter
+	closers []io.Closer
+}
+
+// Close closes the staged file and any decompressor
+func (u *openedUpload) Close() error {
+	var err error
+	for i := len(u.closers) - 1; i >= 0; i-- {
+		if closeErr := u.closers[i].Close(); err == nil {
+			err = closeErr
+		}
+	}
+	return err
+}
+
+// openStaged opens a staged upload, transparently decompressing gzip files and zip archives.
+// Progress of a gzip file is counted in compressed bytes, that of a zip archive in bytes of the library file.
+func (s *ImportJobService) openStaged(stagedPath string) (*openedUpload, error) {
+	file, err := os.Open(stagedPath)
+	if err != nil {
+		return nil, err
+	}
+	opened := &openedUpload{closers: []io.Closer{file}}
+
+	info, err := file.Stat()
+	if err != nil {
+		opened.Close()
+		return nil, err
+	}
+
+	magic := make([]byte, len(zipMagic))
+	n, _ := io.ReadFull(file, magic)
+	magic = magic[:n]
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		opened.Close()
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		opened.counter = &countingReader{reader: file}
+		opened.size = info.Size()
+		decompressor, err := gzip.NewReader(opened.counter)
+		if err != nil {
+			opened.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLibraryFile, err)
+		}
+		opened.closers = append(opened.closers, decompressor)
+		opened.reader = &sizeLimitReader{reader: decompressor, remaining: s.config.MaxLibrarySize}
+
+	case bytes.Equal(magic, zipMagic):
+		archive, err := zip.NewReader(file, info.Size())
+		if err != nil {
+			opened.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLibraryFile, err)
+		}
+		entry, err := archiveLibraryFile(archive)
+		if err != nil {
+			opened.Close()
+			return nil, err
+		}
+		if entry.UncompressedSize64 > uint64(s.config.MaxLibrarySize) {
+			opened.Close()
+			return nil, ErrLibraryTooLarge
+		}
+		contents, err := entry.Open()
+		if err != nil {
+			opened.Close()
+			return nil, fmt.Errorf("%w: %v", ErrInvalidLibraryFile, err)
+		}
+		opened.closers = append(opened.closers, contents)
+		opened.counter = &countingReader{reader: contents}
+		opened.size = int64(entry.UncompressedSize64)
+		// The declared size is not trusted, the limit is enforced on the data itself
+		opened.reader = &sizeLimitReader{reader: opened.counter, remaining: s.config.MaxLibrarySize}
+
+	default:
+		opened.counter = &countingReader{reader: file}
+		opened.size = info.Size()
+		opened.reader = opened.counter
+	}
+
+	return opened, nil
+}
+
+// archiveLibraryFile returns the single library file in a zip archive, ignoring directories
+// and the metadata files added by macOS and other archivers
+func archiveLibraryFile(archive *zip.Reader) (*zip.File, error) {
+	var found *zip.File
+	for _, entry := range archive.File {
+		name := path.Base(entry.Name)
+		if entry.FileInfo().IsDir() || strings.HasPrefix(entry.Name, "__MACOSX/") || strings.HasPrefix(name, ".") {
+			continue
+		}
+		if found != nil {
+			return nil, fmt.Errorf("%w: zip archive contains more than one file", ErrInvalidLibraryFile)
+		}
+		found = entry
+	}
+	if found == nil {
+		return nil, fmt.Errorf("%w: zip archive is empty", ErrInvalidLibraryFile)
+	}
+	return found, nil
+}
+
+// sizeLimitReader fails with ErrLibraryTooLarge once more than the remaining number of bytes are read
+type sizeLimitReader struct {
+	reader    io.Reader
+	remaining int64
+}
+
+// Read reads from the underlying reader
+func (r *sizeLimitReader) Read(p []byte) (int, error) {
+	if r.remaining <= 0 {
+		// Only fail if there actually is more data
+		var probe [1]byte
+		if n, err := r.reader.Read(probe[:]); n > 0 {
+			return 0, ErrLibraryTooLarge
+		} else if err != nil {
+			return 0, err
+		}
+		return 0, nil
+	}
+
+	if int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.reader.Read(p)
+	r.remaining -= int64(n)
+	return n, err
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	reader io.Reader
+	count  atomic.Int64
+}
+
+// Read reads from the underlying reader
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.count.Add(int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes read so far
+func (r *countingReader) Count() int64 {
+	return r.count.Load()
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+)
+
+// Batch sizes for multi-row inserts. PostgreSQL accepts at most 65535 parameters per
+// statement, so each batch is kept well below that for the number of columns of its model.
+const (
+	trackBatchSize         = 500
+	tempoBatchSize         = 2000
+	cuePointBatchSize      = 2000
+	playlistEntryBatchSize = 5000
+)
+
+// trackWriter buffers imported tracks and stores them together with their tempo markers and cue points in batches
+type trackWriter struct {
+	ctx       context.Context
+	tx        *database.DB
+	libraryID uint
+	tracks    []models.Track
+	tempos    [][]models.Tempo
+	cues      [][]models.CuePoint
+	count     int
+	progress  func(count int) // Called after every stored batch, may be nil
+}
+
+// newTrackWriter creates a trackWriter that stores tracks in the given library
+func newTrackWriter(ctx context.Context, tx *database.DB, libraryID uint) *trackWriter {
+	return &trackWriter{
+		ctx:       ctx,
+		tx:        tx,
+		libraryID: libraryID,
+		tracks:    make([]models.Track, 0, trackBatchSize),
+		tempos:    make([][]models.Tempo, 0, trackBatchSize),
+		cues:      make([][]models.CuePoint, 0, trackBatchSize),
+	}
+}
+
+// Add queues a track, storing the queued tracks once a batch is full
+func (w *trackWriter) Add(track models.Track) error {
+	w.tempos = append(w.tempos, track.Tempo)
+	w.cues = append(w.cues, track.CuePoints)
+	track.Tempo = nil
+	track.CuePoints = nil
+	track.LibraryID = w.libraryID
+	w.tracks = append(w.tracks, track)
+
+	if len(w.tracks) >= trackBatchSize {
+		return w.Flush()
+	}
+	return nil
+}
+
+// Flush stores all queued tracks with their tempo markers and cue points
+func (w *trackWriter) Flush() error {
+	if len(w.tracks) == 0 {
+		return nil
+	}
+
+	// Insert the tracks first so that their IDs are known to the tempo markers and cue points
+	if err := w.tx.CreateInBatches(w.ctx, &w.tracks, trackBatchSize); err != nil {
+		return err
+	}
+
+	var markers []models.Tempo
+	for i, track := range w.tracks {
+		for _, marker := range w.tempos[i] {
+			marker.TrackID = track.ID
+			markers = append(markers, marker)
+		}
+	}
+	if len(markers) > 0 {
+		if err := w.tx.CreateInBatches(w.ctx, &markers, tempoBatchSize); err != nil {
+			return err
+		}
+	}
+
+	var cues []models.CuePoint
+	for i, track := range w.tracks {
+		for _, cue := range w.cues[i] {
+			cue.TrackID = track.ID
+			cues = append(cues, cue)
+		}
+	}
+	if len(cues) > 0 {
+		if err := w.tx.CreateInBatches(w.ctx, &cues, cuePointBatchSize); err != nil {
+			return err
+		}
+	}
+
+	w.count += len(w.tracks)
+	w.tracks = w.tracks[:0]
+	w.tempos = w.tempos[:0]
+	w.cues = w.cues[:0]
+	if w.progress != nil {
+		w.progress(w.count)
+	}
+	return nil
+}
+
+// Count returns the number of tracks stored so far
+func (w *trackWriter) Count() int {
+	return w.count
+}
@@ -1,174 +1,163 @@
 package services
 
 import (
+	"bufio"
 	"context"
-	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
-	"strconv"
-	"time"
+	"os"
+	"slices"
+	"strings"
 
 	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/exporters"
+	"github.com/dinis/musync/internal/importers"
+	"github.com/dinis/musync/internal/logging"
 	"github.com/dinis/musync/internal/models"
 )
 
-// XML structures for parsing Rekordbox XML
-type RekordboxXML struct {
-	XMLName    xml.Name            `xml:"DJ_PLAYLISTS"`
-	Version    string              `xml:"Version,attr"`
-	Product    RekordboxProduct    `xml:"PRODUCT"`
-	Collection RekordboxCollection `xml:"COLLECTION"`
-	Playlists  RekordboxPlaylists  `xml:"PLAYLISTS"`
-}
-
-type RekordboxProduct struct {
-	Name    string `xml:"Name,attr"`
-	Version string `xml:"Version,attr"`
-	Company string `xml:"Company,attr"`
+// MusicLibraryService handles operations related to music libraries
+type MusicLibraryService struct {
+	db          *database.DB
+	fileStorage *FileStorageService
+	importers   *importers.Registry
+	exporters   *exporters.Registry
 }
 
-type RekordboxCollection struct {
-	Entries int              `xml:"Entries,attr"`
-	Tracks  []RekordboxTrack `xml:"TRACK"`
+// NewMusicLibraryService creates a new MusicLibraryService
+func NewMusicLibraryService(db *database.DB, fileStorage *FileStorageService) *MusicLibraryService {
+	return &MusicLibraryService{
+		db:          db,
+		fileStorage: fileStorage,
+		importers:   importers.DefaultRegistry(),
+		exporters:   exporters.DefaultRegistry(),
+	}
 }
 
-type RekordboxTrack struct {
-	TrackID     string           `xml:"TrackID,attr"`
-	Name        string           `xml:"Name,attr"`
-	Artist      string           `xml:"Artist,attr"`
-	Composer    string           `xml:"Composer,attr"`
-	Album       string           `xml:"Album,attr"`
-	Grouping    string           `xml:"Grouping,attr"`
-	Genre       string           `xml:"Genre,attr"`
-	Kind        string           `xml:"Kind,attr"`
-	Size        string           `xml:"Size,attr"`
-	TotalTime   string           `xml:"TotalTime,attr"`
-	DiscNumber  string           `xml:"DiscNumber,attr"`
-	TrackNumber string           `xml:"TrackNumber,attr"`
-	Year        string           `xml:"Year,attr"`
-	AverageBpm  string           `xml:"AverageBpm,attr"`
-	DateAdded   string           `xml:"DateAdded,attr"`
-	BitRate     string           `xml:"BitRate,attr"`
-	SampleRate  string           `xml:"SampleRate,attr"`
-	Comments    string           `xml:"Comments,attr"`
-	PlayCount   string           `xml:"PlayCount,attr"`
-	Rating      string           `xml:"Rating,attr"`
-	Location    string           `xml:"Location,attr"`
-	Remixer     string           `xml:"Remixer,attr"`
-	Tonality    string           `xml:"Tonality,attr"`
-	Label       string           `xml:"Label,attr"`
-	Mix         string           `xml:"Mix,attr"`
-	Tempo       []RekordboxTempo `xml:"TEMPO"`
-}
+// UploadLibrary parses and stores a music library export.
+// If source is empty, the importer is detected from the start of the file.
+// Tracks are stored in batches while the file is being parsed, so large collections are never held in memory.
+// It returns the new library ID together with any non-fatal import warnings.
+func (s *MusicLibraryService) UploadLibrary(ctx context.Context, userID uint, name, source string, reader io.Reader) (uint, []string, error) {
+	importer, buffered, err := s.resolveImporter(source, reader)
+	if err != nil {
+		return 0, nil, err
+	}
 
-type RekordboxTempo struct {
-	Inizio  string `xml:"Inizio,attr"`
-	Bpm     string `xml:"Bpm,attr"`
-	Metro   string `xml:"Metro,attr"`
-	Battito string `xml:"Battito,attr"`
+	return s.storeLibrary(ctx, userID, name, importer, buffered, nil)
 }
 
-type RekordboxPlaylists struct {
-	Nodes []RekordboxNode `xml:"NODE"`
-}
+// resolveImporter returns the named importer, or detects one from the start of the file if source is empty.
+// The returned reader must be used in place of reader, as detection consumes the file's first bytes.
+func (s *MusicLibraryService) resolveImporter(source string, reader io.Reader) (importers.Importer, io.Reader, error) {
+	buffered := bufio.NewReaderSize(reader, importers.DetectSize)
 
-type RekordboxNode struct {
-	Type    string                   `xml:"Type,attr"`
-	Name    string                   `xml:"Name,attr"`
-	Count   string                   `xml:"Count,attr"`
-	KeyType string                   `xml:"KeyType,attr"`
-	Entries string                   `xml:"Entries,attr"`
-	Nodes   []RekordboxNode          `xml:"NODE"`
-	Tracks  []RekordboxPlaylistTrack `xml:"TRACK"`
-}
+	if source != "" {
+		importer, ok := s.importers.Get(source)
+		if !ok {
+			return nil, nil, ErrUnsupportedLibrarySource
+		}
+		return importer, buffered, nil
+	}
 
-type RekordboxPlaylistTrack struct {
-	Key string `xml:"Key,attr"`
+	header, _ := buffered.Peek(importers.DetectSize)
+	importer, err := s.importers.Detect(header)
+	if err != nil {
+		return nil, nil, ErrUnsupportedLibrarySource
+	}
+	return importer, buffered, nil
 }
 
-// MusicLibraryService handles operations related to music libraries
-type MusicLibraryService struct {
-	db          *database.DB
-	fileStorage *FileStorageService
-}
+// parseLibrary parses a complete library export with the named importer, or a detected one if source is empty
+func (s *MusicLibraryService) parseLibrary(source string, reader io.Reader) (*importers.Library, error) {
+	importer, buffered, err := s.resolveImporter(source, reader)
+	if err != nil {
+		return nil, err
+	}
 
-// NewMusicLibraryService creates a new MusicLibraryService
-func NewMusicLibraryService(db *database.DB) *MusicLibraryService {
-	return &MusicLibraryService{
-		db:          db,
-		fileStorage: NewFileStorageService(db),
+	library, err := importer.Parse(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLibraryFile, err)
 	}
+
+	return library, nil
 }
 
-// UploadLibrary parses and stores a music library from an XML file
-func (s *MusicLibraryService) UploadLibrary(ctx context.Context, userID uint, name string, xmlReader io.Reader) (uint, error) {
-	// Parse the XML file
-	var rekordboxXML RekordboxXML
-	if err := xml.NewDecoder(xmlReader).Decode(&rekordboxXML); err != nil {
-		return 0, errors.New("failed to parse XML file")
-	}
+// storeLibrary parses a library export and persists it with its tracks, tempo markers and playlists.
+// If progress is not nil, it is called with the number of tracks stored so far after every batch.
+func (s *MusicLibraryService) storeLibrary(ctx context.Context, userID uint, name string, importer importers.Importer, reader io.Reader, progress func(tracks int)) (uint, []string, error) {
+	var libraryID uint
+	var warnings []string
 
 	// Begin a transaction
-	var libraryID uint
 	err := s.db.Transaction(ctx, func(tx *database.DB) error {
-		// Create the music library
+		// Create the music library up front so tracks can reference it as they are parsed
 		library := models.MusicLibrary{
-			UserID:      userID,
-			Name:        name,
-			Source:      "rekordbox",
-			Version:     rekordboxXML.Version,
-			ProductName: rekordboxXML.Product.Name,
-			Company:     rekordboxXML.Product.Company,
+			UserID: userID,
+			Name:   name,
+			Source: importer.Name(),
 		}
 
 		if err := tx.Create(ctx, &library); err != nil {
 			return err
 		}
 
-		libraryID = library.ID
-
-		// Process tracks
-		trackIDMap := make(map[string]uint) // Map original TrackID to our Track ID
-		for _, rbTrack := range rekordboxXML.Collection.Tracks {
-			track, err := s.convertRekordboxTrack(ctx, rbTrack, library.ID)
-			if err != nil {
+		// Process tracks in batches, recording them for the first version as they pass by
+		writer := newTrackWriter(ctx, tx, library.ID)
+		writer.progress = progress
+		snapshot := newSnapshotEncoder()
+		imported, err := importers.Stream(importer, reader, func(track models.Track) error {
+			if err := snapshot.Track(track); err != nil {
 				return err
 			}
-
-			if err := tx.Create(ctx, &track); err != nil {
-				return err
+			return writer.Add(track)
+		})
+		if err != nil {
+			if errors.Is(err, importers.ErrInvalidFile) {
+				return fmt.Errorf("%w: %v", ErrInvalidLibraryFile, err)
 			}
+			return err
+		}
+		if err := writer.Flush(); err != nil {
+			return err
+		}
 
-			trackIDMap[rbTrack.TrackID] = track.ID
-
-			// Process tempo markers
-			for _, rbTempo := range rbTrack.Tempo {
-				tempo, err := s.convertRekordboxTempo(ctx, rbTempo, track.ID)
-				if err != nil {
-					return err
-				}
-
-				if err := tx.Create(ctx, &tempo); err != nil {
-					return err
-				}
-			}
+		// Store the metadata found in the file
+		library.Source = imported.Source
+		library.Version = imported.Version
+		library.ProductName = imported.ProductName
+		library.Company = imported.Company
+		if err := tx.Save(ctx, &library); err != nil {
+			return err
 		}
 
 		// Process playlists
-		for _, rbNode := range rekordboxXML.Playlists.Nodes {
-			if err := s.processRekordboxNode(ctx, tx, rbNode, library.ID, nil, trackIDMap); err != nil {
+		for _, node := range imported.Playlists {
+			if err := s.createPlaylistNode(ctx, tx, node, library.ID, nil); err != nil {
 				return err
 			}
 		}
 
+		// Record the import as the first version of the library
+		data, err := snapshot.Finish(imported)
+		if err != nil {
+			return err
+		}
+		if _, err := s.saveSnapshot(ctx, tx, library.ID, SnapshotReasonImport, data, writer.Count(), countPlaylists(imported.Playlists)); err != nil {
+			return err
+		}
+
+		libraryID = library.ID
+		warnings = imported.Warnings
 		return nil
 	})
 
 	if err != nil {
-		return 0, err
+		return 0, nil, err
 	}
 
-	return libraryID, nil
+	return libraryID, warnings, nil
 }
 
 // GetLibraries returns all music libraries for a user
@@ -189,141 +178,107 @@ func (s *MusicLibraryService) GetLibrary(ctx context.Context, userID, libraryID
 	return &library, nil
 }
 
-// GetTracks returns all tracks in a library
-func (s *MusicLibraryService) GetTracks(ctx context.Context, userID, libraryID uint) ([]models.Track, error) {
-	// First check if the library belongs to the user
-	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
+// GetTrack returns a track of the user together with its tempo markers and cue points
+func (s *MusicLibraryService) GetTrack(ctx context.Context, userID, trackID uint) (*models.Track, error) {
+	var track models.Track
+	if err := s.db.Where(ctx, "id = ? AND library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", trackID, userID).First(ctx, &track); err != nil {
 		return nil, err
 	}
 
-	var tracks []models.Track
-	if err := s.db.Where(ctx, "library_id = ?", libraryID).Find(ctx, &tracks); err != nil {
+	if err := s.db.Where(ctx, "track_id = ?", track.ID).Order("inizio").Find(ctx, &track.Tempo); err != nil {
 		return nil, err
 	}
-
-	// Normalize track locations for frontend compatibility
-	for i := range tracks {
-		tracks[i].Location = s.fileStorage.NormalizeTrackLocation(tracks[i].Location)
+	if err := s.db.Where(ctx, "track_id = ?", track.ID).Order("id").Find(ctx, &track.CuePoints); err != nil {
+		return nil, err
 	}
 
-	return tracks, nil
-}
+	// Normalize track location for frontend compatibility
+	track.Location = s.fileStorage.NormalizeTrackLocation(track.Location)
 
-// GetPlaylists returns all playlists in a library
-func (s *MusicLibraryService) GetPlaylists(ctx context.Context, userID, libraryID uint) ([]models.Playlist, error) {
-	// First check if the library belongs to the user
-	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
-		return nil, err
-	}
+	return &track, nil
+}
 
-	var playlists []models.Playlist
-	if err := s.db.Where(ctx, "library_id = ?", libraryID).Find(ctx, &playlists); err != nil {
-		return nil, err
-	}
-	return playlists, nil
+// TrackPlaylist is a playlist a track appears in, with the positions of the track in it
+type TrackPlaylist struct {
+	PlaylistID uint
+	Name       string
+	Path       string // Names of the enclosing folders and the playlist, separated by slashes
+	Positions  []int
 }
 
-// GetPlaylistTracks returns all tracks in a playlist
-func (s *MusicLibraryService) GetPlaylistTracks(ctx context.Context, userID, playlistID uint) ([]models.Track, error) {
-	// First get the playlist
-	var playlist models.Playlist
-	if err := s.db.First(ctx, &playlist, playlistID); err != nil {
+// GetTrackPlaylists returns the playlists a track returned by GetTrack appears in, ordered by path
+func (s *MusicLibraryService) GetTrackPlaylists(ctx context.Context, track *models.Track) ([]TrackPlaylist, error) {
+	var entries []models.PlaylistTrack
+	if err := s.db.Where(ctx, "track_key = ? AND playlist_id IN (SELECT id FROM playlists WHERE library_id = ? AND deleted_at IS NULL)", track.TrackID, track.LibraryID).Order("playlist_id, position, id").Find(ctx, &entries); err != nil {
 		return nil, err
 	}
-
-	// Check if the library belongs to the user
-	if _, err := s.GetLibrary(ctx, userID, playlist.LibraryID); err != nil {
-		return nil, err
+	if len(entries) == 0 {
+		return []TrackPlaylist{}, nil
 	}
 
-	// Get playlist tracks
-	var playlistTracks []models.PlaylistTrack
-	if err := s.db.Where(ctx, "playlist_id = ?", playlistID).Find(ctx, &playlistTracks); err != nil {
+	// Load the library's playlists to build the paths of the enclosing folders
+	var playlists []models.Playlist
+	if err := s.db.Where(ctx, "library_id = ?", track.LibraryID).Find(ctx, &playlists); err != nil {
 		return nil, err
 	}
+	byID := make(map[uint]models.Playlist, len(playlists))
+	for _, playlist := range playlists {
+		byID[playlist.ID] = playlist
+	}
 
-	// Get the actual tracks
-	var tracks []models.Track
-	for _, pt := range playlistTracks {
-		var track models.Track
-		if err := s.db.Where(ctx, "library_id = ? AND track_id = ?", playlist.LibraryID, pt.TrackKey).First(ctx, &track); err != nil {
-			continue // Skip tracks that can't be found
+	var memberships []TrackPlaylist
+	for _, entry := range entries {
+		if n := len(memberships); n > 0 && memberships[n-1].PlaylistID == entry.PlaylistID {
+			memberships[n-1].Positions = append(memberships[n-1].Positions, entry.Position)
+			continue
 		}
 
-		// Normalize track location for frontend compatibility
-		track.Location = s.fileStorage.NormalizeTrackLocation(track.Location)
-
-		tracks = append(tracks, track)
+		playlist := byID[entry.PlaylistID]
+		memberships = append(memberships, TrackPlaylist{
+			PlaylistID: playlist.ID,
+			Name:       playlist.Name,
+			Path:       playlistPath(playlist, byID),
+			Positions:  []int{entry.Position},
+		})
 	}
 
-	return tracks, nil
+	slices.SortStableFunc(memberships, func(a, b TrackPlaylist) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return memberships, nil
 }
 
-// GetFolderTracks returns all tracks in all playlists within a folder (recursively)
-func (s *MusicLibraryService) GetFolderTracks(ctx context.Context, userID, folderID uint) ([]models.Track, error) {
-	// First get the folder
-	var folder models.Playlist
-	if err := s.db.First(ctx, &folder, folderID); err != nil {
-		return nil, err
-	}
-
-	// Check if it's actually a folder
-	if folder.Type != 0 {
-		return nil, errors.New("specified ID is not a folder")
+// playlistPath joins the names of a playlist and its enclosing folders
+func playlistPath(playlist models.Playlist, byID map[uint]models.Playlist) string {
+	names := []string{playlist.Name}
+	// Guard against cycles in corrupt data by never walking more levels than there are playlists
+	for parentID := playlist.ParentID; parentID != nil && len(names) <= len(byID); {
+		parent, ok := byID[*parentID]
+		if !ok {
+			break
+		}
+		names = append(names, parent.Name)
+		parentID = parent.ParentID
 	}
+	slices.Reverse(names)
+	return "/" + strings.Join(names, "/")
+}
 
-	// Check if the library belongs to the user
-	if _, err := s.GetLibrary(ctx, userID, folder.LibraryID); err != nil {
+// GetPlaylists returns all playlists in a library
+func (s *MusicLibraryService) GetPlaylists(ctx context.Context, userID, libraryID uint) ([]models.Playlist, error) {
+	// First check if the library belongs to the user
+	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
 		return nil, err
 	}
 
-	// Get all playlists and subfolders within this folder
-	var childPlaylists []models.Playlist
-	if err := s.db.Where(ctx, "parent_id = ?", folderID).Find(ctx, &childPlaylists); err != nil {
+	var playlists []models.Playlist
+	if err := s.db.Where(ctx, "library_id = ?", libraryID).Find(ctx, &playlists); err != nil {
 		return nil, err
 	}
-
-	// Initialize a map to track unique tracks (to avoid duplicates)
-	trackMap := make(map[uint]models.Track)
-
-	// Process each child
-	for _, child := range childPlaylists {
-		var childTracks []models.Track
-
-		if child.Type == 0 {
-			// If it's a subfolder, recursively get its tracks
-			subfolderTracks, err := s.GetFolderTracks(ctx, userID, child.ID)
-			if err != nil {
-				// Log the error but continue with other playlists
-				continue
-			}
-			childTracks = subfolderTracks
-		} else {
-			// If it's a playlist, get its tracks
-			playlistTracks, err := s.GetPlaylistTracks(ctx, userID, child.ID)
-			if err != nil {
-				// Log the error but continue with other playlists
-				continue
-			}
-			childTracks = playlistTracks
-		}
-
-		// Add tracks to the map (to ensure uniqueness)
-		for _, track := range childTracks {
-			trackMap[track.ID] = track
-		}
-	}
-
-	// Convert the map to a slice
-	tracks := make([]models.Track, 0, len(trackMap))
-	for _, track := range trackMap {
-		tracks = append(tracks, track)
-	}
-
-	return tracks, nil
+	return playlists, nil
 }
 
-// DeleteLibrary deletes a library and all its associated tracks, playlists, and playlist tracks
+// DeleteLibrary deletes a library and all its associated tracks, playlists, playlist tracks and jobs
 func (s *MusicLibraryService) DeleteLibrary(ctx context.Context, userID, libraryID uint) error {
 	// First check if the library belongs to the user
 	library, err := s.GetLibrary(ctx, userID, libraryID)
@@ -332,7 +287,8 @@ func (s *MusicLibraryService) DeleteLibrary(ctx context.Context, userID, library
 	}
 
 	// Begin a transaction with unscoped operations (hard delete)
-	return s.db.Transaction(ctx, func(tx *database.DB) error {
+	var stagedUploads []string
+	err = s.db.Transaction(ctx, func(tx *database.DB) error {
 		// Get all playlists in this library
 		var playlists []models.Playlist
 		if err := tx.Where(ctx, "library_id = ?", libraryID).Find(ctx, &playlists); err != nil {
@@ -357,11 +313,24 @@ func (s *MusicLibraryService) DeleteLibrary(ctx context.Context, userID, library
 			return err
 		}
 
-		// Delete all tempo markers for each track
+		// Delete all tempo markers and cue points for each track
 		for _, track := range tracks {
 			if err := tx.Where(ctx, "track_id = ?", track.ID).Delete(ctx, &models.Tempo{}); err != nil {
 				return err
 			}
+			if err := tx.Where(ctx, "track_id = ?", track.ID).Delete(ctx, &models.CuePoint{}); err != nil {
+				return err
+			}
+		}
+
+		// Delete the waveform overviews of the tracks. They are removed for good, as their peaks are stored in them.
+		if err := tx.Unscoped().Where(ctx, "track_id IN (SELECT id FROM tracks WHERE library_id = ?)", libraryID).Delete(ctx, &models.TrackWaveform{}); err != nil {
+			return err
+		}
+
+		// Delete the analysis jobs of the tracks
+		if err := tx.Where(ctx, "track_id IN (SELECT id FROM tracks WHERE library_id = ?)", libraryID).Delete(ctx, &models.AnalysisJob{}); err != nil {
+			return err
 		}
 
 		// Delete all tracks in this library
@@ -369,6 +338,40 @@ func (s *MusicLibraryService) DeleteLibrary(ctx context.Context, userID, library
 			return err
 		}
 
+		// Delete the library's version history
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Delete(ctx, &models.LibrarySnapshot{}); err != nil {
+			return err
+		}
+
+		// Delete the library's path remap rules
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Delete(ctx, &models.PathRemap{}); err != nil {
+			return err
+		}
+
+		// Delete the library's health reports
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Delete(ctx, &models.HealthCheck{}); err != nil {
+			return err
+		}
+
+		// Delete the library's relocate jobs
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Delete(ctx, &models.RelocateJob{}); err != nil {
+			return err
+		}
+
+		// Delete the import jobs that created the library, along with uploads they still stage
+		var importJobs []models.ImportJob
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Find(ctx, &importJobs); err != nil {
+			return err
+		}
+		for _, job := range importJobs {
+			if job.FilePath != "" {
+				stagedUploads = append(stagedUploads, job.FilePath)
+			}
+		}
+		if err := tx.Where(ctx, "library_id = ?", libraryID).Delete(ctx, &models.ImportJob{}); err != nil {
+			return err
+		}
+
 		// Finally, delete the library itself
 		if err := tx.Delete(ctx, library); err != nil {
 			return err
@@ -376,80 +379,62 @@ func (s *MusicLibraryService) DeleteLibrary(ctx context.Context, userID, library
 
 		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	for _, path := range stagedUploads {
+		if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logging.GetLogger().Warn("Failed to remove upload of deleted library %d: %v", libraryID, err)
+		}
+	}
+	return nil
 }
 
 // Helper functions
 
-// convertRekordboxTrack converts a RekordboxTrack to a Track model
-func (s *MusicLibraryService) convertRekordboxTrack(ctx context.Context, rbTrack RekordboxTrack, libraryID uint) (models.Track, error) {
-	size, _ := strconv.ParseInt(rbTrack.Size, 10, 64)
-	totalTime, _ := strconv.Atoi(rbTrack.TotalTime)
-	discNumber, _ := strconv.Atoi(rbTrack.DiscNumber)
-	trackNumber, _ := strconv.Atoi(rbTrack.TrackNumber)
-	year, _ := strconv.Atoi(rbTrack.Year)
-	averageBpm, _ := strconv.ParseFloat(rbTrack.AverageBpm, 64)
-	bitRate, _ := strconv.Atoi(rbTrack.BitRate)
-	sampleRate, _ := strconv.Atoi(rbTrack.SampleRate)
-	playCount, _ := strconv.Atoi(rbTrack.PlayCount)
-	rating, _ := strconv.Atoi(rbTrack.Rating)
-
-	dateAdded, _ := time.Parse("2006-01-02", rbTrack.DateAdded)
-
-	return models.Track{
-		LibraryID:   libraryID,
-		TrackID:     rbTrack.TrackID,
-		Name:        rbTrack.Name,
-		Artist:      rbTrack.Artist,
-		Composer:    rbTrack.Composer,
-		Album:       rbTrack.Album,
-		Grouping:    rbTrack.Grouping,
-		Genre:       rbTrack.Genre,
-		Kind:        rbTrack.Kind,
-		Size:        size,
-		TotalTime:   totalTime,
-		DiscNumber:  discNumber,
-		TrackNumber: trackNumber,
-		Year:        year,
-		AverageBpm:  averageBpm,
-		DateAdded:   dateAdded,
-		BitRate:     bitRate,
-		SampleRate:  sampleRate,
-		Comments:    rbTrack.Comments,
-		PlayCount:   playCount,
-		Rating:      rating,
-		Location:    rbTrack.Location,
-		Remixer:     rbTrack.Remixer,
-		Tonality:    rbTrack.Tonality,
-		Label:       rbTrack.Label,
-		Mix:         rbTrack.Mix,
-	}, nil
-}
+// createTrack stores an imported track together with its tempo markers and cue points
+func (s *MusicLibraryService) createTrack(ctx context.Context, tx *database.DB, libraryID uint, track models.Track) (*models.Track, error) {
+	tempo := slices.Clone(track.Tempo)
+	cues := slices.Clone(track.CuePoints)
+	track.Tempo = nil
+	track.CuePoints = nil
+	track.LibraryID = libraryID
 
-// convertRekordboxTempo converts a RekordboxTempo to a Tempo model
-func (s *MusicLibraryService) convertRekordboxTempo(ctx context.Context, rbTempo RekordboxTempo, trackID uint) (models.Tempo, error) {
-	inizio, _ := strconv.ParseFloat(rbTempo.Inizio, 64)
-	bpm, _ := strconv.ParseFloat(rbTempo.Bpm, 64)
-	battito, _ := strconv.Atoi(rbTempo.Battito)
-
-	return models.Tempo{
-		TrackID: trackID,
-		Inizio:  inizio,
-		Bpm:     bpm,
-		Metro:   rbTempo.Metro,
-		Battito: battito,
-	}, nil
-}
+	if err := tx.Create(ctx, &track); err != nil {
+		return nil, err
+	}
+
+	// Process tempo markers
+	if len(tempo) > 0 {
+		for i := range tempo {
+			tempo[i].TrackID = track.ID
+		}
+		if err := tx.CreateInBatches(ctx, &tempo, tempoBatchSize); err != nil {
+			return nil, err
+		}
+	}
+
+	// Process cue points
+	if len(cues) > 0 {
+		for i := range cues {
+			cues[i].TrackID = track.ID
+		}
+		if err := tx.CreateInBatches(ctx, &cues, cuePointBatchSize); err != nil {
+			return nil, err
+		}
+	}
 
-// processRekordboxNode recursively processes a RekordboxNode (playlist or folder)
-func (s *MusicLibraryService) processRekordboxNode(ctx context.Context, tx *database.DB, rbNode RekordboxNode, libraryID uint, parentID *uint, trackIDMap map[string]uint) error {
-	// Determine if this is a folder or playlist
-	nodeType, _ := strconv.Atoi(rbNode.Type)
+	return &track, nil
+}
 
+// createPlaylistNode recursively stores an imported playlist or folder
+func (s *MusicLibraryService) createPlaylistNode(ctx context.Context, tx *database.DB, node importers.Node, libraryID uint, parentID *uint) error {
 	// Create the playlist/folder
 	playlist := models.Playlist{
 		LibraryID: libraryID,
-		Name:      rbNode.Name,
-		Type:      nodeType,
+		Name:      node.Name,
+		Type:      node.Type,
 		ParentID:  parentID,
 	}
 
@@ -458,25 +443,36 @@ func (s *MusicLibraryService) processRekordboxNode(ctx context.Context, tx *data
 	}
 
 	// Process tracks if this is a playlist
-	if nodeType == 1 && len(rbNode.Tracks) > 0 {
-		for _, rbTrack := range rbNode.Tracks {
-			playlistTrack := models.PlaylistTrack{
-				PlaylistID: playlist.ID,
-				TrackKey:   rbTrack.Key,
-			}
-
-			if err := tx.Create(ctx, &playlistTrack); err != nil {
-				return err
-			}
+	if node.Type == importers.NodeTypePlaylist {
+		if err := s.createPlaylistEntries(ctx, tx, playlist.ID, node.TrackKeys); err != nil {
+			return err
 		}
 	}
 
 	// Process child nodes recursively
-	for _, childNode := range rbNode.Nodes {
-		if err := s.processRekordboxNode(ctx, tx, childNode, libraryID, &playlist.ID, trackIDMap); err != nil {
+	for _, childNode := range node.Children {
+		if err := s.createPlaylistNode(ctx, tx, childNode, libraryID, &playlist.ID); err != nil {
 			return err
 		}
 	}
 
 	return nil
 }
+
+// createPlaylistEntries stores the entries of a playlist with batched inserts
+func (s *MusicLibraryService) createPlaylistEntries(ctx context.Context, tx *database.DB, playlistID uint, trackKeys []string) error {
+	if len(trackKeys) == 0 {
+		return nil
+	}
+
+	playlistTracks := make([]models.PlaylistTrack, len(trackKeys))
+	for i, key := range trackKeys {
+		playlistTracks[i] = models.PlaylistTrack{
+			PlaylistID: playlistID,
+			Position:   i,
+			TrackKey:   key,
+		}
+	}
+
+	return tx.CreateInBatches(ctx, &playlistTracks, playlistEntryBatchSize)
+}
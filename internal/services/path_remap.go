@@ -0,0 +1,252 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/storage"
+)
+
+// Relocate statuses of tracks
+const (
+	RelocateFound       = "found"       // The file exists
+	RelocateMissing     = "missing"     // The storage backend has no file at the location
+	RelocateUnreachable = "unreachable" // No storage backend serves the location, or it failed
+)
+
+// relocateWorkers is the number of files checked concurrently when relocating the tracks of a library
+const relocateWorkers = 8
+
+// RelocatedTrack is the outcome of remapping the location of a track
+type RelocatedTrack struct {
+	Track    models.Track
+	Location string            // Location the file is read from, after the remap rules are applied
+	Rule     *models.PathRemap // Rule that rewrote the location, nil if none matched
+	Status   string
+	Size     int64  // Size of the file if it was found
+	Error    string // Why the file is unreachable
+}
+
+// RemapLocation applies the first rule matching a track location and returns the rewritten location, along
+// with the rule. The location is returned unchanged with a nil rule if no rule matches.
+//
+// File URLs are matched on their decoded path, and paths with either separator, with or without a slash
+// before the drive letter, are matched alike. Drive letters match regardless of case. A rewritten file URL
+// stays a file URL, unless the rule rewrites it to a storage URL such as "s3://bucket/Music".
+func RemapLocation(location string, rules []models.PathRemap) (string, *models.PathRemap) {
+	path, fileURL := remapPath(location)
+	for i := range rules {
+		rest, ok := matchRemap(path, rules[i].From)
+		if !ok {
+			continue
+		}
+		return joinRemap(rules[i].To, rest, fileURL), &rules[i]
+	}
+	return location, nil
+}
+
+// remapPath returns the path of a location with forward slashes, decoded if the location is a file URL,
+// and whether it is one
+func remapPath(location string) (string, bool) {
+	fileURL := len(location) >= len("file://") && strings.EqualFold(location[:len("file://")], "file://")
+	if fileURL {
+		location = location[len("file://"):]
+		if len(location) >= len("localhost") && strings.EqualFold(location[:len("localhost")], "localhost") {
+			location = location[len("localhost"):]
+		}
+		if decoded, err := url.PathUnescape(location); err == nil {
+			location = decoded
+		}
+	}
+	return trimDriveSlash(strings.ReplaceAll(location, `\`, "/")), fileURL
+}
+
+// matchRemap reports whether a path starts with the prefix of a rule, ending at a path separator, and
+// returns the rest of the path
+func matchRemap(path, from string) (string, bool) {
+	from = trimDriveSlash(strings.TrimRight(strings.ReplaceAll(strings.TrimSpace(from), `\`, "/"), "/"))
+	if len(path) < len(from) {
+		return "", false
+	}
+	head, rest := path[:len(from)], path[len(from):]
+	if hasDriveLetter(from) {
+		if !strings.EqualFold(head, from) {
+			return "", false
+		}
+	} else if head != from {
+		return "", false
+	}
+	if rest != "" && rest[0] != '/' {
+		return "", false
+	}
+	return rest, true
+}
+
+// joinRemap appends the rest of a remapped path to the replacement of a rule. Paths are turned back into
+// file URLs if the original location was one, and keep backslashes if the replacement uses them.
+func joinRemap(to, rest string, fileURL bool) string {
+	to = strings.TrimSpace(to)
+	if strings.Contains(to, "://") {
+		return strings.TrimRight(to, "/") + escapeRemapPath(rest)
+	}
+
+	backslashes := strings.Contains(to, `\`)
+	path := strings.TrimRight(strings.ReplaceAll(to, `\`, "/"), "/") + rest
+	if path == "" {
+		path = "/"
+	}
+	if fileURL {
+		if hasDriveLetter(path) {
+			path = "/" + path
+		}
+		return "file://localhost" + escapeRemapPath(path)
+	}
+	if backslashes {
+		path = strings.ReplaceAll(path, "/", `\`)
+	}
+	return path
+}
+
+// escapeRemapPath percent-encodes a path for use in a URL
+func escapeRemapPath(path string) string {
+	return (&url.URL{Path: path}).EscapedPath()
+}
+
+// hasDriveLetter reports whether a path starts with a Windows drive letter
+func hasDriveLetter(path string) bool {
+	return len(path) >= 2 && path[1] == ':' &&
+		('A' <= path[0] && path[0] <= 'Z' || 'a' <= path[0] && path[0] <= 'z')
+}
+
+// trimDriveSlash removes the slash file URLs put before a drive letter
+func trimDriveSlash(path string) string {
+	if len(path) > 1 && path[0] == '/' && hasDriveLetter(path[1:]) {
+		return path[1:]
+	}
+	return path
+}
+
+// GetPathRemaps returns the path remap rules of a library in the order they are tried
+func (s *FileStorageService) GetPathRemaps(ctx context.Context, libraryID uint) ([]models.PathRemap, error) {
+	var rules []models.PathRemap
+	if err := s.db.Where(ctx, "library_id = ?", libraryID).Order("position, id").Find(ctx, &rules); err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// storageLocation returns the location the file of a track is read from: its hash in managed storage for
+// uploaded files, its location rewritten by the path remap rules of its library otherwise
+func (s *FileStorageService) storageLocation(track *models.Track, rules []models.PathRemap) (string, *models.PathRemap) {
+	if track.StorageType == models.StorageTypeManaged {
+		return storage.SchemeManaged + "://" + storage.ContentKey(track.FileHash), nil
+	}
+	return RemapLocation(track.Location, rules)
+}
+
+// GetPathRemaps returns the path remap rules of a library of the user
+func (s *MusicLibraryService) GetPathRemaps(ctx context.Context, userID, libraryID uint) ([]models.PathRemap, error) {
+	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
+		return nil, err
+	}
+	return s.fileStorage.GetPathRemaps(ctx, libraryID)
+}
+
+// SetPathRemaps replaces the path remap rules of a library of the user. Rules are tried in the given order.
+func (s *MusicLibraryService) SetPathRemaps(ctx context.Context, userID, libraryID uint, rules []models.PathRemap) ([]models.PathRemap, error) {
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.From) == "" || strings.TrimSpace(rule.To) == "" {
+			return nil, ErrInvalidPathRemap
+		}
+	}
+
+	library, err := s.GetLibrary(ctx, userID, libraryID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make([]models.PathRemap, len(rules))
+	for i, rule := range rules {
+		stored[i] = models.PathRemap{
+			LibraryID: library.ID,
+			Position:  i,
+			From:      strings.TrimSpace(rule.From),
+			To:        strings.TrimSpace(rule.To),
+		}
+	}
+
+	err = s.db.Transaction(ctx, func(tx *database.DB) error {
+		if err := tx.Unscoped().Where(ctx, "library_id = ?", library.ID).Delete(ctx, &models.PathRemap{}); err != nil {
+			return err
+		}
+		if len(stored) == 0 {
+			return nil
+		}
+		return tx.Create(ctx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return stored, nil
+}
+
+// relocateTracks applies path remap rules to the locations of tracks of a user and checks which of the
+// resulting locations hold a file. Files are checked concurrently, as cloud backends take a request per file.
+func (s *FileStorageService) relocateTracks(ctx context.Context, userID uint, tracks []models.Track, rules []models.PathRemap) ([]RelocatedTrack, error) {
+	resolver, err := s.newFileResolver(ctx, userID, tracks)
+	if err != nil {
+		return nil, err
+	}
+
+	relocated := make([]RelocatedTrack, len(tracks))
+	for i := range tracks {
+		location, rule := s.storageLocation(&tracks[i], rules)
+		relocated[i] = RelocatedTrack{Track: tracks[i], Location: location, Rule: rule}
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < min(relocateWorkers, len(tracks)); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				checkRelocatedTrack(ctx, resolver, &relocated[i])
+			}
+		}()
+	}
+	for i := range relocated {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return relocated, nil
+}
+
+// checkRelocatedTrack sets the status of a relocated track from the storage backend of its location
+func checkRelocatedTrack(ctx context.Context, resolver *fileResolver, track *RelocatedTrack) {
+	var info *storage.ObjectInfo
+	backend, key, err := resolver.resolve(&track.Track, track.Location)
+	if err == nil {
+		info, err = backend.Stat(ctx, key)
+	}
+	switch {
+	case err == nil:
+		track.Status = RelocateFound
+		track.Size = info.Size
+	case errors.Is(err, storage.ErrNotFound):
+		track.Status = RelocateMissing
+	default:
+		track.Status = RelocateUnreachable
+		track.Error = err.Error()
+	}
+}
@@ -0,0 +1,114 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/dinis/musync/internal/models"
+	"gorm.io/gorm"
+)
+
+// remapRule returns a path remap rule with an ID
+func remapRule(id uint, from, to string) models.PathRemap {
+	return models.PathRemap{Model: gorm.Model{ID: id}, From: from, To: to}
+}
+
+func TestRemapLocation(t *testing.T) {
+	usb := []models.PathRemap{remapRule(1, "/Volumes/USB/Music", "/mnt/usb/Music")}
+	tests := []struct {
+		name     string
+		location string
+		rules    []models.PathRemap
+		want     string
+		rule     uint // ID of the matching rule, 0 if none matches
+	}{
+		{"posix prefix", "/Volumes/USB/Music/a.mp3", usb, "/mnt/usb/Music/a.mp3", 1},
+		{"whole location", "/Volumes/USB/Music", usb, "/mnt/usb/Music", 1},
+		{"prefix ending inside a name", "/Volumes/USB/Music2/a.mp3", usb, "/Volumes/USB/Music2/a.mp3", 0},
+		{"posix paths are case-sensitive", "/volumes/usb/music/a.mp3", usb, "/volumes/usb/music/a.mp3", 0},
+		{
+			name:     "trailing slash in the prefix",
+			location: "/Volumes/USB/Music/a.mp3",
+			rules:    []models.PathRemap{remapRule(1, "/Volumes/USB/Music/", "/mnt/usb/")},
+			want:     "/mnt/usb/a.mp3",
+			rule:     1,
+		},
+		{
+			name:     "windows path to posix path",
+			location: `C:\Music\House\a.mp3`,
+			rules:    []models.PathRemap{remapRule(1, `C:\Music`, "/mnt/music")},
+			want:     "/mnt/music/House/a.mp3",
+			rule:     1,
+		},
+		{
+			name:     "windows prefix with forward slashes",
+			location: `C:\Music\a.mp3`,
+			rules:    []models.PathRemap{remapRule(1, "C:/Music", "/mnt/music")},
+			want:     "/mnt/music/a.mp3",
+			rule:     1,
+		},
+		{
+			name:     "drive letter in any case",
+			location: `c:\Music\a.mp3`,
+			rules:    []models.PathRemap{remapRule(1, "C:", "/mnt/c")},
+			want:     "/mnt/c/Music/a.mp3",
+			rule:     1,
+		},
+		{
+			name:     "posix path to windows path",
+			location: "/Volumes/USB/Music/a b.mp3",
+			rules:    []models.PathRemap{remapRule(1, "/Volumes/USB", `D:\Backup`)},
+			want:     `D:\Backup\Music\a b.mp3`,
+			rule:     1,
+		},
+		{
+			name:     "file url stays a file url",
+			location: "file://localhost/Volumes/USB/Music/a%20b.mp3",
+			rules:    []models.PathRemap{remapRule(1, "/Volumes/USB/Music", "/mnt/usb")},
+			want:     "file://localhost/mnt/usb/a%20b.mp3",
+			rule:     1,
+		},
+		{
+			name:     "file url with a drive letter",
+			location: "file://localhost/C:/Music/a.mp3",
+			rules:    []models.PathRemap{remapRule(1, `C:\Music`, `D:\Music`)},
+			want:     "file://localhost/D:/Music/a.mp3",
+			rule:     1,
+		},
+		{
+			name:     "file url without a host",
+			location: "file:///C:/Music/a.mp3",
+			rules:    []models.PathRemap{remapRule(1, `C:\Music`, "/mnt/music")},
+			want:     "file://localhost/mnt/music/a.mp3",
+			rule:     1,
+		},
+		{
+			name:     "storage url",
+			location: `C:\Music\a b.mp3`,
+			rules:    []models.PathRemap{remapRule(1, `C:\Music`, "s3://bucket/Music/")},
+			want:     "s3://bucket/Music/a%20b.mp3",
+			rule:     1,
+		},
+		{
+			name:     "first matching rule",
+			location: "/Music/Techno/a.mp3",
+			rules:    []models.PathRemap{remapRule(1, "/Music/House", "/house"), remapRule(2, "/Music", "/music"), remapRule(3, "/", "/other")},
+			want:     "/music/Techno/a.mp3",
+			rule:     2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rule := RemapLocation(tt.location, tt.rules)
+			if got != tt.want {
+				t.Errorf("RemapLocation(%q) = %q, want %q", tt.location, got, tt.want)
+			}
+			var ruleID uint
+			if rule != nil {
+				ruleID = rule.ID
+			}
+			if ruleID != tt.rule {
+				t.Errorf("RemapLocation(%q) rule = %d, want %d", tt.location, ruleID, tt.rule)
+			}
+		})
+	}
+}
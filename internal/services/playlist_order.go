@@ -0,0 +1,290 @@
+package services
+
+import (
+	"context"
+	"maps"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+)
+
+// PlaylistEntry is a position in a playlist together with the track at that position
+type PlaylistEntry struct {
+	Entry models.PlaylistTrack
+	Track models.Track
+}
+
+// playlistSortColumns maps the sort fields of playlist listings to their columns
+var playlistSortColumns = func() map[string]string {
+	columns := maps.Clone(trackSortColumns)
+	columns["position"] = "playlist_tracks.position"
+	return columns
+}()
+
+// GetPlaylistEntries returns a page of the entries of a playlist, in playlist order unless the query sorts them,
+// together with the number of entries matching the query.
+// A track that appears more than once is returned once per entry; entries whose track no longer exists are skipped.
+// The entries of a smart playlist are the tracks currently matching its rules.
+func (s *MusicLibraryService) GetPlaylistEntries(ctx context.Context, userID, playlistID uint, query TrackQuery) ([]PlaylistEntry, int64, error) {
+	playlist, err := s.getUserPlaylist(ctx, s.db, userID, playlistID)
+	if err != nil {
+		return nil, 0, err
+	}
+	if playlist.Type == models.PlaylistTypeSmart {
+		return s.getSmartPlaylistTracks(ctx, playlist, query)
+	}
+
+	if err := query.validate(); err != nil {
+		return nil, 0, err
+	}
+	order, err := trackOrder(query.Sort, playlistSortColumns, "playlist_tracks.position", "playlist_tracks.id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// Filters apply to the tracks, so the entries are joined with them
+	scope := func() *database.DB {
+		joined := s.db.Joins("JOIN tracks ON tracks.track_id = playlist_tracks.track_key AND tracks.library_id = ? AND tracks.deleted_at IS NULL", playlist.LibraryID)
+		return applyTrackFilters(ctx, joined.Where(ctx, "playlist_tracks.playlist_id = ?", playlist.ID), query)
+	}
+
+	total, err := scope().Count(ctx, &models.PlaylistTrack{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var entries []models.PlaylistTrack
+	limit, offset := trackPage(query)
+	if err := scope().Order(order).Limit(limit).Offset(offset).Find(ctx, &entries); err != nil {
+		return nil, 0, err
+	}
+
+	resolved, err := s.resolvePlaylistEntries(ctx, playlist.LibraryID, entries)
+	if err != nil {
+		return nil, 0, err
+	}
+	return resolved, total, nil
+}
+
+// ReorderPlaylist puts the entries of a playlist in the given order.
+// entryIDs must list every entry of the playlist exactly once.
+func (s *MusicLibraryService) ReorderPlaylist(ctx context.Context, userID, playlistID uint, entryIDs []uint) ([]PlaylistEntry, error) {
+	return s.updatePlaylistEntries(ctx, userID, playlistID, func(tx *database.DB, playlist *models.Playlist, entries []models.PlaylistTrack) ([]models.PlaylistTrack, error) {
+		if len(entryIDs) != len(entries) {
+			return nil, ErrInvalidPlaylistOrder
+		}
+
+		byID := make(map[uint]models.PlaylistTrack, len(entries))
+		for _, entry := range entries {
+			byID[entry.ID] = entry
+		}
+
+		ordered := make([]models.PlaylistTrack, 0, len(entries))
+		for _, id := range entryIDs {
+			entry, ok := byID[id]
+			if !ok {
+				// Unknown or repeated entry ID
+				return nil, ErrInvalidPlaylistOrder
+			}
+			delete(byID, id)
+			ordered = append(ordered, entry)
+		}
+		return ordered, nil
+	})
+}
+
+// MovePlaylistEntry moves an entry of a playlist to a new 0-based position, shifting the entries in between.
+// A position past the end moves the entry to the end.
+func (s *MusicLibraryService) MovePlaylistEntry(ctx context.Context, userID, playlistID, entryID uint, position int) ([]PlaylistEntry, error) {
+	if position < 0 {
+		return nil, ErrInvalidPlaylistOrder
+	}
+
+	return s.updatePlaylistEntries(ctx, userID, playlistID, func(tx *database.DB, playlist *models.Playlist, entries []models.PlaylistTrack) ([]models.PlaylistTrack, error) {
+		index := entryIndex(entries, entryID)
+		if index < 0 {
+			return nil, ErrPlaylistEntryNotFound
+		}
+
+		entry := entries[index]
+		entries = append(entries[:index], entries[index+1:]...)
+		return insertEntry(entries, entry, position), nil
+	})
+}
+
+// AddPlaylistEntry adds a track of the playlist's library at the given 0-based position, or at the end if position is nil.
+// The track may already be in the playlist.
+func (s *MusicLibraryService) AddPlaylistEntry(ctx context.Context, userID, playlistID, trackID uint, position *int) ([]PlaylistEntry, error) {
+	if position != nil && *position < 0 {
+		return nil, ErrInvalidPlaylistOrder
+	}
+
+	return s.updatePlaylistEntries(ctx, userID, playlistID, func(tx *database.DB, playlist *models.Playlist, entries []models.PlaylistTrack) ([]models.PlaylistTrack, error) {
+		// The track must belong to the same library as the playlist
+		var track models.Track
+		if err := tx.Where(ctx, "id = ? AND library_id = ?", trackID, playlist.LibraryID).First(ctx, &track); err != nil {
+			return nil, err
+		}
+
+		entry := models.PlaylistTrack{
+			PlaylistID: playlist.ID,
+			Position:   len(entries),
+			TrackKey:   track.TrackID,
+		}
+		if err := tx.Create(ctx, &entry); err != nil {
+			return nil, err
+		}
+
+		if position == nil {
+			return append(entries, entry), nil
+		}
+		return insertEntry(entries, entry, *position), nil
+	})
+}
+
+// RemovePlaylistEntry removes an entry from a playlist, closing the gap it leaves
+func (s *MusicLibraryService) RemovePlaylistEntry(ctx context.Context, userID, playlistID, entryID uint) ([]PlaylistEntry, error) {
+	return s.updatePlaylistEntries(ctx, userID, playlistID, func(tx *database.DB, playlist *models.Playlist, entries []models.PlaylistTrack) ([]models.PlaylistTrack, error) {
+		index := entryIndex(entries, entryID)
+		if index < 0 {
+			return nil, ErrPlaylistEntryNotFound
+		}
+
+		if err := tx.Delete(ctx, &entries[index]); err != nil {
+			return nil, err
+		}
+		return append(entries[:index], entries[index+1:]...), nil
+	})
+}
+
+// updatePlaylistEntries locks a playlist of the user, lets update compute the new order of its entries and
+// stores the order as consecutive positions, recording the library's new state as a version.
+// It returns the entries of the playlist after the update.
+func (s *MusicLibraryService) updatePlaylistEntries(ctx context.Context, userID, playlistID uint, update func(tx *database.DB, playlist *models.Playlist, entries []models.PlaylistTrack) ([]models.PlaylistTrack, error)) ([]PlaylistEntry, error) {
+	playlist, err := s.getUserPlaylist(ctx, s.db, userID, playlistID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []models.PlaylistTrack
+	err = s.versionedEdit(ctx, playlist.LibraryID, SnapshotReasonPlaylistEdit, func(tx *database.DB) error {
+		// Lock the playlist so that concurrent updates of its order are applied one after the other
+		var err error
+		playlist, err = s.getUserPlaylist(ctx, tx.ForUpdate(), userID, playlistID)
+		if err != nil {
+			return err
+		}
+		if playlist.Type == models.PlaylistTypeSmart {
+			return ErrSmartPlaylistReadOnly
+		}
+
+		current, err := s.loadPlaylistEntries(ctx, tx, playlist.ID)
+		if err != nil {
+			return err
+		}
+
+		entries, err = update(tx, playlist, current)
+		if err != nil {
+			return err
+		}
+		return renumberEntries(ctx, tx, entries)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.resolvePlaylistEntries(ctx, playlist.LibraryID, entries)
+}
+
+// getUserPlaylist returns a playlist in a library of the user, failing if it is a folder
+func (s *MusicLibraryService) getUserPlaylist(ctx context.Context, db *database.DB, userID, playlistID uint) (*models.Playlist, error) {
+	var playlist models.Playlist
+	if err := db.Where(ctx, "id = ? AND library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", playlistID, userID).First(ctx, &playlist); err != nil {
+		return nil, err
+	}
+
+	if playlist.Type == models.PlaylistTypeFolder {
+		return nil, ErrNotAPlaylist
+	}
+	return &playlist, nil
+}
+
+// loadPlaylistEntries returns the entries of a playlist in playlist order
+func (s *MusicLibraryService) loadPlaylistEntries(ctx context.Context, db *database.DB, playlistID uint) ([]models.PlaylistTrack, error) {
+	var entries []models.PlaylistTrack
+	if err := db.Where(ctx, "playlist_id = ?", playlistID).Order("position, id").Find(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// resolvePlaylistEntries looks up the tracks of playlist entries in a single query
+func (s *MusicLibraryService) resolvePlaylistEntries(ctx context.Context, libraryID uint, entries []models.PlaylistTrack) ([]PlaylistEntry, error) {
+	if len(entries) == 0 {
+		return []PlaylistEntry{}, nil
+	}
+
+	keys := make([]string, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		if !seen[entry.TrackKey] {
+			seen[entry.TrackKey] = true
+			keys = append(keys, entry.TrackKey)
+		}
+	}
+
+	var tracks []models.Track
+	if err := s.db.Where(ctx, "library_id = ? AND track_id IN ?", libraryID, keys).Find(ctx, &tracks); err != nil {
+		return nil, err
+	}
+
+	byKey := make(map[string]models.Track, len(tracks))
+	for _, track := range tracks {
+		// Normalize track location for frontend compatibility
+		track.Location = s.fileStorage.NormalizeTrackLocation(track.Location)
+		byKey[track.TrackID] = track
+	}
+
+	resolved := make([]PlaylistEntry, 0, len(entries))
+	for _, entry := range entries {
+		track, ok := byKey[entry.TrackKey]
+		if !ok {
+			continue // Skip tracks that can't be found
+		}
+		resolved = append(resolved, PlaylistEntry{Entry: entry, Track: track})
+	}
+	return resolved, nil
+}
+
+// renumberEntries stores the order of entries as consecutive positions, writing only the entries that moved
+func renumberEntries(ctx context.Context, tx *database.DB, entries []models.PlaylistTrack) error {
+	for i := range entries {
+		if entries[i].Position == i {
+			continue
+		}
+		if _, err := tx.Where(ctx, "id = ?", entries[i].ID).UpdateColumns(ctx, &models.PlaylistTrack{}, map[string]interface{}{"position": i}); err != nil {
+			return err
+		}
+		entries[i].Position = i
+	}
+	return nil
+}
+
+// entryIndex returns the index of the entry with the given ID, or -1 if there is none
+func entryIndex(entries []models.PlaylistTrack, entryID uint) int {
+	for i, entry := range entries {
+		if entry.ID == entryID {
+			return i
+		}
+	}
+	return -1
+}
+
+// insertEntry inserts an entry at the given position, or at the end if the position is past it
+func insertEntry(entries []models.PlaylistTrack, entry models.PlaylistTrack, position int) []models.PlaylistTrack {
+	position = min(position, len(entries))
+	entries = append(entries, models.PlaylistTrack{})
+	copy(entries[position+1:], entries[position:])
+	entries[position] = entry
+	return entries
+}
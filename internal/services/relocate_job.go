@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+	"github.com/dinis/musync/internal/database"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/models"
+)
+
+// Relocate job states
+const (
+	RelocateJobQueued    = "queued"
+	RelocateJobRunning   = "running"
+	RelocateJobCompleted = "completed"
+	RelocateJobFailed    = "failed"
+)
+
+// RelocateJobService relocates the tracks of libraries in the background. Each job applies the library's
+// path remap rules to the location of every track, stats the resulting file through the storage layer and
+// persists a report of which files were found.
+type RelocateJobService struct {
+	db          *database.DB
+	fileStorage *FileStorageService
+	config      config.RelocateConfig
+	jobs        *jobRunner[models.RelocateJob]
+}
+
+// NewRelocateJobService creates a new RelocateJobService
+func NewRelocateJobService(db *database.DB, cfg config.RelocateConfig, fileStorage *FileStorageService) *RelocateJobService {
+	s := &RelocateJobService{
+		db:          db,
+		fileStorage: fileStorage,
+		config:      cfg,
+	}
+	s.jobs = newJobRunner(db, "relocate job", "relocate_jobs", s.run)
+	return s
+}
+
+// Start requeues interrupted jobs and starts the relocate workers, which run until ctx is cancelled
+func (s *RelocateJobService) Start(ctx context.Context) error {
+	if err := s.jobs.requeue(ctx); err != nil {
+		return err
+	}
+	s.jobs.start(ctx, s.config.Workers)
+	return nil
+}
+
+// Wait blocks until the relocate workers have stopped after the context passed to Start was cancelled
+func (s *RelocateJobService) Wait() {
+	s.jobs.wait()
+}
+
+// Enqueue queues relocating the tracks of a library of the user. If a job for the library is already
+// queued, that job is returned instead; a running job may have read the rules before they last changed.
+func (s *RelocateJobService) Enqueue(ctx context.Context, userID, libraryID uint) (*models.RelocateJob, error) {
+	var library models.MusicLibrary
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", libraryID, userID).First(ctx, &library); err != nil {
+		return nil, err
+	}
+
+	var job models.RelocateJob
+	err := s.db.Where(ctx, "library_id = ? AND state = ?", library.ID, RelocateJobQueued).Order("id").First(ctx, &job)
+	switch {
+	case err == nil:
+		return &job, nil
+	case !errors.Is(err, apperrors.ErrNotFound):
+		return nil, err
+	}
+
+	job = models.RelocateJob{UserID: userID, LibraryID: library.ID, State: RelocateJobQueued}
+	if err := s.db.Create(ctx, &job); err != nil {
+		return nil, err
+	}
+	s.jobs.notify()
+	return &job, nil
+}
+
+// GetJob returns a relocate job of the user
+func (s *RelocateJobService) GetJob(ctx context.Context, userID, jobID uint) (*models.RelocateJob, error) {
+	var job models.RelocateJob
+	if err := s.db.Where(ctx, "id = ? AND user_id = ?", jobID, userID).First(ctx, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// GetLatestJob returns the most recently queued relocate job of a library of the user
+func (s *RelocateJobService) GetLatestJob(ctx context.Context, userID, libraryID uint) (*models.RelocateJob, error) {
+	var job models.RelocateJob
+	if err := s.db.Where(ctx, "library_id = ? AND user_id = ?", libraryID, userID).Order("id DESC").First(ctx, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// run relocates the tracks of the library of a job and records the report
+func (s *RelocateJobService) run(ctx context.Context, job *models.RelocateJob) {
+	logger := logging.GetLogger()
+
+	err := s.relocate(ctx, job)
+	if err != nil && ctx.Err() != nil {
+		// Interrupted by the shutdown, the job is requeued once its lease expires
+		return
+	}
+
+	now := time.Now()
+	job.FinishedAt = &now
+	if err != nil {
+		logger.Warn("Relocating library %d failed: %v", job.LibraryID, err)
+		job.State = RelocateJobFailed
+		job.Error = err.Error()
+	} else {
+		job.State = RelocateJobCompleted
+	}
+
+	if err := s.db.Save(ctx, job); err != nil {
+		logger.Error("Failed to record report of relocate job %d: %v", job.ID, err)
+	}
+}
+
+// relocate applies the path remap rules of a library to its tracks and checks which of the resulting
+// locations hold a file
+func (s *RelocateJobService) relocate(ctx context.Context, job *models.RelocateJob) error {
+	rules, err := s.fileStorage.GetPathRemaps(ctx, job.LibraryID)
+	if err != nil {
+		return err
+	}
+	var tracks []models.Track
+	if err := s.db.Where(ctx, "library_id = ?", job.LibraryID).Order("id").Find(ctx, &tracks); err != nil {
+		return err
+	}
+	relocated, err := s.fileStorage.relocateTracks(ctx, job.UserID, tracks, rules)
+	if err != nil {
+		return err
+	}
+
+	job.Tracks = make([]models.RelocateResult, len(relocated))
+	for i, track := range relocated {
+		job.Tracks[i] = models.RelocateResult{
+			TrackID:          track.Track.ID,
+			Title:            track.Track.Name,
+			Artist:           track.Track.Artist,
+			Location:         track.Track.Location,
+			ResolvedLocation: track.Location,
+			Status:           track.Status,
+			Error:            track.Error,
+		}
+		if track.Rule != nil {
+			job.Tracks[i].RuleID = &track.Rule.ID
+		}
+
+		switch track.Status {
+		case RelocateFound:
+			job.Found++
+		case RelocateMissing:
+			job.Missing++
+		default:
+			job.Unreachable++
+		}
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/dinis/musync/internal/audiotag"
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// TagDifference is a track field whose value in the tags embedded in the track's file differs from the library
+type TagDifference struct {
+	Field        string
+	LibraryValue interface{}
+	FileValue    interface{}
+}
+
+// TrackRescan is the result of comparing a track with the tags embedded in its file
+type TrackRescan struct {
+	Track       models.Track
+	Format      string // Container format the tags were read from
+	Differences []TagDifference
+	Applied     []string // Fields updated from the file, if the differences were applied
+}
+
+// rescanField compares and copies one track field with the matching embedded tag
+type rescanField struct {
+	name    string
+	library func(track *models.Track) interface{}
+	file    func(tags *audiotag.Tags) interface{} // nil if the file does not set the tag
+	equal   func(library, file interface{}) bool
+	apply   func(track *models.Track, tags *audiotag.Tags)
+}
+
+// rescanFields are the track fields compared with embedded tags, named like the fields of track responses
+var rescanFields = []rescanField{
+	textRescanField("title", func(t *models.Track) *string { return &t.Name }, func(t *audiotag.Tags) string { return t.Title }),
+	textRescanField("artist", func(t *models.Track) *string { return &t.Artist }, func(t *audiotag.Tags) string { return t.Artist }),
+	textRescanField("album", func(t *models.Track) *string { return &t.Album }, func(t *audiotag.Tags) string { return t.Album }),
+	textRescanField("genre", func(t *models.Track) *string { return &t.Genre }, func(t *audiotag.Tags) string { return t.Genre }),
+	textRescanField("composer", func(t *models.Track) *string { return &t.Composer }, func(t *audiotag.Tags) string { return t.Composer }),
+	textRescanField("grouping", func(t *models.Track) *string { return &t.Grouping }, func(t *audiotag.Tags) string { return t.Grouping }),
+	textRescanField("comments", func(t *models.Track) *string { return &t.Comments }, func(t *audiotag.Tags) string { return t.Comment }),
+	textRescanField("label", func(t *models.Track) *string { return &t.Label }, func(t *audiotag.Tags) string { return t.Label }),
+	textRescanField("remixer", func(t *models.Track) *string { return &t.Remixer }, func(t *audiotag.Tags) string { return t.Remixer }),
+	{
+		// Keys are equal if they name the same key, whatever the notation
+		name:    "key",
+		library: func(t *models.Track) interface{} { return t.Tonality },
+		file: func(t *audiotag.Tags) interface{} {
+			if t.Key == "" {
+				return nil
+			}
+			return t.Key
+		},
+		equal: func(library, file interface{}) bool {
+			libraryKey, libraryOK := musickey.Parse(library.(string))
+			fileKey, fileOK := musickey.Parse(file.(string))
+			if libraryOK && fileOK {
+				return libraryKey.Camelot() == fileKey.Camelot()
+			}
+			return strings.EqualFold(strings.TrimSpace(library.(string)), file.(string))
+		},
+		apply: func(t *models.Track, tags *audiotag.Tags) { t.Tonality, t.KeySource = tags.Key, "" },
+	},
+	{
+		// Most taggers write whole BPMs, so a rounded library BPM matches them
+		name:    "bpm",
+		library: func(t *models.Track) interface{} { return t.AverageBpm },
+		file: func(t *audiotag.Tags) interface{} {
+			if t.Bpm == 0 {
+				return nil
+			}
+			return t.Bpm
+		},
+		equal: func(library, file interface{}) bool {
+			libraryBpm, fileBpm := library.(float64), file.(float64)
+			if fileBpm == math.Trunc(fileBpm) {
+				libraryBpm = math.Round(libraryBpm)
+			}
+			return math.Abs(libraryBpm-fileBpm) < 0.01
+		},
+		apply: func(t *models.Track, tags *audiotag.Tags) { t.AverageBpm, t.BpmSource = tags.Bpm, "" },
+	},
+	intRescanField("year", func(t *models.Track) *int { return &t.Year }, func(t *audiotag.Tags) int { return t.Year }),
+	intRescanField("track_number", func(t *models.Track) *int { return &t.TrackNumber }, func(t *audiotag.Tags) int { return t.TrackNumber }),
+	intRescanField("disc_number", func(t *models.Track) *int { return &t.DiscNumber }, func(t *audiotag.Tags) int { return t.DiscNumber }),
+}
+
+// textRescanField compares a text field with a text tag, ignoring surrounding whitespace
+func textRescanField(name string, field func(*models.Track) *string, tag func(*audiotag.Tags) string) rescanField {
+	return rescanField{
+		name:    name,
+		library: func(t *models.Track) interface{} { return *field(t) },
+		file: func(t *audiotag.Tags) interface{} {
+			if value := tag(t); value != "" {
+				return value
+			}
+			return nil
+		},
+		equal: func(library, file interface{}) bool {
+			return strings.TrimSpace(library.(string)) == file.(string)
+		},
+		apply: func(t *models.Track, tags *audiotag.Tags) { *field(t) = tag(tags) },
+	}
+}
+
+// intRescanField compares a number field with a number tag
+func intRescanField(name string, field func(*models.Track) *int, tag func(*audiotag.Tags) int) rescanField {
+	return rescanField{
+		name:    name,
+		library: func(t *models.Track) interface{} { return *field(t) },
+		file: func(t *audiotag.Tags) interface{} {
+			if value := tag(t); value != 0 {
+				return value
+			}
+			return nil
+		},
+		equal: func(library, file interface{}) bool { return library == file },
+		apply: func(t *models.Track, tags *audiotag.Tags) { *field(t) = tag(tags) },
+	}
+}
+
+// RescanTrack reads the tags embedded in a track's file and compares them with the track. Tags the file
+// does not set are not reported. With apply set, the differing fields are updated from the file: only
+// those listed in fields, or all of them if fields is empty, and the update is recorded as a version of
+// the library.
+func (s *MusicLibraryService) RescanTrack(ctx context.Context, userID, trackID uint, apply bool, fields []string) (*TrackRescan, error) {
+	selected := make(map[string]bool, len(fields))
+	for _, name := range fields {
+		known := false
+		for _, field := range rescanFields {
+			known = known || field.name == name
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTagField, name)
+		}
+		selected[name] = true
+	}
+
+	track, err := s.GetTrack(ctx, userID, trackID)
+	if err != nil {
+		return nil, err
+	}
+
+	file, _, err := s.fileStorage.GetFileStream(ctx, userID, track.ID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrTrackFileUnavailable, err)
+	}
+	defer file.Close()
+
+	tags, err := audiotag.Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadableTags, err)
+	}
+
+	rescan := &TrackRescan{Track: *track, Format: tags.Format}
+	var changed []rescanField
+	for _, field := range rescanFields {
+		fileValue := field.file(tags)
+		if fileValue == nil {
+			continue
+		}
+		libraryValue := field.library(track)
+		if field.equal(libraryValue, fileValue) {
+			continue
+		}
+		rescan.Differences = append(rescan.Differences, TagDifference{Field: field.name, LibraryValue: libraryValue, FileValue: fileValue})
+		if len(selected) == 0 || selected[field.name] {
+			changed = append(changed, field)
+		}
+	}
+
+	if !apply || len(changed) == 0 {
+		return rescan, nil
+	}
+
+	// Save the stored row rather than the track returned by GetTrack, whose location is normalized for display
+	var stored models.Track
+	err = s.versionedEdit(ctx, track.LibraryID, SnapshotReasonRescan, func(tx *database.DB) error {
+		if err := tx.First(ctx, &stored, track.ID); err != nil {
+			return err
+		}
+		for _, field := range changed {
+			field.apply(&stored, tags)
+		}
+		return tx.Save(ctx, &stored)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, field := range changed {
+		field.apply(&rescan.Track, tags)
+		rescan.Applied = append(rescan.Applied, field.name)
+	}
+	rescan.Track.CamelotKey = stored.CamelotKey
+	rescan.Track.UpdatedAt = stored.UpdatedAt
+
+	return rescan, nil
+}
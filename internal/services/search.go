@@ -0,0 +1,233 @@
+package services
+
+import (
+	"context"
+	"html"
+	"strings"
+	"unicode"
+
+	"github.com/dinis/musync/internal/models"
+)
+
+// Result limits of a search
+const (
+	DefaultSearchLimit  = 50
+	MaxSearchLimit      = 200
+	searchPlaylistLimit = 20
+)
+
+// Markers ts_headline wraps matched words in. Control characters never appear in track tags,
+// so fragments can be HTML-escaped before the markers are turned into mark elements.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+// Options of ts_headline for short fields, which are returned whole, and for comments,
+// which are cut down to the fragments around the matches
+var (
+	fieldHeadlineOptions    = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true"
+	commentHeadlineOptions  = "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=2, MaxWords=20, MinWords=5"
+	highlightMarkerReplacer = strings.NewReplacer(highlightStart, "<mark>", highlightStop, "</mark>")
+)
+
+// trackSearchSQL ranks the tracks of a user's libraries matching a prefix full-text query or,
+// for typos, similar enough to the search text by trigrams. The highlighted fragments are only
+// built for the returned page.
+const trackSearchSQL = `
+WITH q AS (SELECT to_tsquery('simple', @query) AS query)
+SELECT hits.id, hits.rank,
+	ts_headline('simple', coalesce(t.name, ''), q.query, @fieldOptions) AS name,
+	ts_headline('simple', coalesce(t.artist, ''), q.query, @fieldOptions) AS artist,
+	ts_headline('simple', coalesce(t.remixer, ''), q.query, @fieldOptions) AS remixer,
+	ts_headline('simple', coalesce(t.album, ''), q.query, @fieldOptions) AS album,
+	ts_headline('simple', coalesce(t.label, ''), q.query, @fieldOptions) AS label,
+	ts_headline('simple', coalesce(t."grouping", ''), q.query, @fieldOptions) AS "grouping",
+	ts_headline('simple', coalesce(t.comments, ''), q.query, @commentOptions) AS comments
+FROM (
+	SELECT t.id, ts_rank(t.search_vector, q.query) * 2 + word_similarity(@text, t.search_text) AS rank
+	FROM tracks t, q
+	WHERE t.deleted_at IS NULL
+		AND t.library_id IN (SELECT id FROM music_libraries WHERE user_id = @userID AND deleted_at IS NULL)
+		AND (t.search_vector @@ q.query OR @text <% t.search_text)
+	ORDER BY rank DESC, t.id
+	LIMIT @limit
+) hits
+JOIN tracks t ON t.id = hits.id
+CROSS JOIN q
+ORDER BY hits.rank DESC, hits.id`
+
+// playlistSearchSQL ranks the playlists and folders of a user's libraries whose names contain or
+// are similar to the search text
+const playlistSearchSQL = `
+SELECT p.id, word_similarity(@text, p.name) AS rank
+FROM playlists p
+WHERE p.deleted_at IS NULL
+	AND p.library_id IN (SELECT id FROM music_libraries WHERE user_id = @userID AND deleted_at IS NULL)
+	AND (p.name ILIKE @pattern OR @text <% p.name)
+ORDER BY rank DESC, p.id
+LIMIT @limit`
+
+// SearchResults holds the ranked tracks and playlists matching a search
+type SearchResults struct {
+	Tracks    []TrackHit
+	Playlists []PlaylistHit
+}
+
+// TrackHit is a track matching a search. Highlights maps the fields containing a matched word
+// (title, artist, remixer, album, label, grouping, comments) to an HTML-escaped fragment with
+// the matches wrapped in mark elements.
+type TrackHit struct {
+	Track      models.Track
+	Rank       float64
+	Highlights map[string]string
+}
+
+// PlaylistHit is a playlist or folder whose name matches a search
+type PlaylistHit struct {
+	Playlist models.Playlist
+	Rank     float64
+}
+
+// trackSearchRow is a ranked track hit with the ts_headline output of each searched field
+type trackSearchRow struct {
+	ID       uint
+	Rank     float64
+	Name     string
+	Artist   string
+	Remixer  string
+	Album    string
+	Label    string
+	Grouping string
+	Comments string
+}
+
+// playlistSearchRow is a ranked playlist hit
+type playlistSearchRow struct {
+	ID   uint
+	Rank float64
+}
+
+// Search finds the tracks and playlists across all of a user's libraries matching a free text query.
+// Every word of the query matches as a prefix of a word in the title, artist, remixer, album, label,
+// grouping or comments. Tracks that only match approximately are included so that typos still find
+// results, ranked below exact matches. At most limit tracks are returned.
+func (s *MusicLibraryService) Search(ctx context.Context, userID uint, text string, limit int) (*SearchResults, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	if text == "" {
+		return nil, ErrEmptySearchQuery
+	}
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	limit = min(limit, MaxSearchLimit)
+
+	var rows []trackSearchRow
+	if err := s.db.Raw(ctx, &rows, trackSearchSQL, map[string]interface{}{
+		"query":          prefixTSQuery(text),
+		"text":           text,
+		"userID":         userID,
+		"limit":          limit,
+		"fieldOptions":   fieldHeadlineOptions,
+		"commentOptions": commentHeadlineOptions,
+	}); err != nil {
+		return nil, err
+	}
+
+	var playlistRows []playlistSearchRow
+	if err := s.db.Raw(ctx, &playlistRows, playlistSearchSQL, map[string]interface{}{
+		"text":    text,
+		"pattern": containsPattern(text),
+		"userID":  userID,
+		"limit":   searchPlaylistLimit,
+	}); err != nil {
+		return nil, err
+	}
+
+	results := &SearchResults{
+		Tracks:    make([]TrackHit, 0, len(rows)),
+		Playlists: make([]PlaylistHit, 0, len(playlistRows)),
+	}
+
+	if len(rows) > 0 {
+		ids := make([]uint, len(rows))
+		for i, row := range rows {
+			ids[i] = row.ID
+		}
+		var tracks []models.Track
+		if err := s.db.Where(ctx, "id IN ?", ids).Find(ctx, &tracks); err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.Track, len(tracks))
+		for _, track := range tracks {
+			track.Location = s.fileStorage.NormalizeTrackLocation(track.Location)
+			byID[track.ID] = track
+		}
+
+		for _, row := range rows {
+			track, ok := byID[row.ID]
+			if !ok {
+				continue
+			}
+			results.Tracks = append(results.Tracks, TrackHit{
+				Track:      track,
+				Rank:       row.Rank,
+				Highlights: trackHighlights(row),
+			})
+		}
+	}
+
+	if len(playlistRows) > 0 {
+		ids := make([]uint, len(playlistRows))
+		for i, row := range playlistRows {
+			ids[i] = row.ID
+		}
+		var playlists []models.Playlist
+		if err := s.db.Where(ctx, "id IN ?", ids).Find(ctx, &playlists); err != nil {
+			return nil, err
+		}
+		byID := make(map[uint]models.Playlist, len(playlists))
+		for _, playlist := range playlists {
+			byID[playlist.ID] = playlist
+		}
+
+		for _, row := range playlistRows {
+			if playlist, ok := byID[row.ID]; ok {
+				results.Playlists = append(results.Playlists, PlaylistHit{Playlist: playlist, Rank: row.Rank})
+			}
+		}
+	}
+
+	return results, nil
+}
+
+// prefixTSQuery builds a tsquery matching text where every word is a prefix of an indexed word.
+// Only letters and digits are kept, so the result is always valid tsquery syntax.
+func prefixTSQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+// trackHighlights returns the highlighted fragments of the fields of a hit that contain a matched word
+func trackHighlights(row trackSearchRow) map[string]string {
+	highlights := make(map[string]string)
+	for field, headline := range map[string]string{
+		"title":    row.Name,
+		"artist":   row.Artist,
+		"remixer":  row.Remixer,
+		"album":    row.Album,
+		"label":    row.Label,
+		"grouping": row.Grouping,
+		"comments": row.Comments,
+	} {
+		if strings.Contains(headline, highlightStart) {
+			highlights[field] = highlightMarkerReplacer.Replace(html.EscapeString(headline))
+		}
+	}
+	return highlights
+}
@@ -0,0 +1,451 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// maxSmartRuleDepth limits the nesting of smart playlist rule groups
+const maxSmartRuleDepth = 8
+
+// SmartRule is a node of a smart playlist's rule tree. A node is either a group, which combines its
+// child rules with the "and" or "or" operator or negates its single child rule with "not", or a
+// condition comparing a track field with a value, for example
+//
+//	{"operator": "and", "rules": [
+//		{"field": "genre", "operator": "contains", "value": "techno"},
+//		{"field": "bpm", "operator": "between", "value": [126, 132]},
+//		{"field": "rating", "operator": ">=", "value": 4}
+//	]}
+//
+// Text fields support =, !=, contains, not_contains, starts_with, ends_with and in, all case-insensitive.
+// Number fields support =, !=, <, <=, >, >=, between and in; ratings are given in stars, from 0 to 5. The date_added field takes YYYY-MM-DD
+// dates with <, <=, >, >= and between, or a number of days with in_last. The key field supports =, != and in
+// with keys in standard, Camelot or Open Key notation, and matches tracks by their normalized key.
+type SmartRule struct {
+	Operator string          `json:"operator"`
+	Rules    []SmartRule     `json:"rules,omitempty"`
+	Field    string          `json:"field,omitempty"`
+	Value    json.RawMessage `json:"value,omitempty"`
+}
+
+// Kinds of track fields smart playlist rules can compare
+const (
+	smartFieldText = iota
+	smartFieldNumber
+	smartFieldDate
+	smartFieldKey
+	smartFieldRating
+)
+
+// smartField is a track field smart playlist rules can compare
+type smartField struct {
+	column string
+	kind   int
+}
+
+// smartRuleFields maps the fields of smart playlist rules to their columns
+var smartRuleFields = map[string]smartField{
+	"title":        {"tracks.name", smartFieldText},
+	"artist":       {"tracks.artist", smartFieldText},
+	"album":        {"tracks.album", smartFieldText},
+	"genre":        {"tracks.genre", smartFieldText},
+	"label":        {"tracks.label", smartFieldText},
+	"remixer":      {"tracks.remixer", smartFieldText},
+	"composer":     {"tracks.composer", smartFieldText},
+	"grouping":     {`tracks."grouping"`, smartFieldText},
+	"comments":     {"tracks.comments", smartFieldText},
+	"key":          {"tracks.camelot_key", smartFieldKey},
+	"kind":         {"tracks.kind", smartFieldText},
+	"mix":          {"tracks.mix", smartFieldText},
+	"bpm":          {"tracks.average_bpm", smartFieldNumber},
+	"year":         {"tracks.year", smartFieldNumber},
+	"rating":       {"tracks.rating", smartFieldRating},
+	"play_count":   {"tracks.play_count", smartFieldNumber},
+	"duration":     {"tracks.total_time", smartFieldNumber},
+	"bit_rate":     {"tracks.bit_rate", smartFieldNumber},
+	"sample_rate":  {"tracks.sample_rate", smartFieldNumber},
+	"size":         {"tracks.size", smartFieldNumber},
+	"track_number": {"tracks.track_number", smartFieldNumber},
+	"disc_number":  {"tracks.disc_number", smartFieldNumber},
+	"date_added":   {"tracks.date_added", smartFieldDate},
+}
+
+// numberComparisons maps the comparison operators of number and date rules to SQL
+var numberComparisons = map[string]string{"=": "=", "!=": "<>", "<": "<", "<=": "<=", ">": ">", ">=": ">="}
+
+// CreateSmartPlaylist adds a smart playlist to a library, optionally within a folder
+func (s *MusicLibraryService) CreateSmartPlaylist(ctx context.Context, userID, libraryID uint, name string, parentID *uint, rules SmartRule) (*models.Playlist, error) {
+	// First check if the library belongs to the user
+	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
+		return nil, err
+	}
+
+	encoded, err := encodeSmartRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID != nil {
+		var parent models.Playlist
+		if err := s.db.Where(ctx, "id = ? AND library_id = ?", *parentID, libraryID).First(ctx, &parent); err != nil {
+			return nil, err
+		}
+		if parent.Type != models.PlaylistTypeFolder {
+			return nil, ErrNotAFolder
+		}
+	}
+
+	playlist := models.Playlist{
+		LibraryID: libraryID,
+		Name:      name,
+		Type:      models.PlaylistTypeSmart,
+		Rules:     encoded,
+		ParentID:  parentID,
+	}
+	if err := s.db.Create(ctx, &playlist); err != nil {
+		return nil, err
+	}
+	return &playlist, nil
+}
+
+// UpdateSmartPlaylist replaces the rules of a smart playlist, and renames it if name is not empty
+func (s *MusicLibraryService) UpdateSmartPlaylist(ctx context.Context, userID, playlistID uint, name string, rules SmartRule) (*models.Playlist, error) {
+	playlist, err := s.getUserPlaylist(ctx, s.db, userID, playlistID)
+	if err != nil {
+		return nil, err
+	}
+	if playlist.Type != models.PlaylistTypeSmart {
+		return nil, ErrNotASmartPlaylist
+	}
+
+	encoded, err := encodeSmartRules(rules)
+	if err != nil {
+		return nil, err
+	}
+
+	playlist.Rules = encoded
+	if name != "" {
+		playlist.Name = name
+	}
+	if err := s.db.Save(ctx, playlist); err != nil {
+		return nil, err
+	}
+	return playlist, nil
+}
+
+// getSmartPlaylistTracks returns a page of the tracks matching the rules of a smart playlist as playlist entries,
+// together with the number of tracks matching the query
+func (s *MusicLibraryService) getSmartPlaylistTracks(ctx context.Context, playlist *models.Playlist, query TrackQuery) ([]PlaylistEntry, int64, error) {
+	condition, args, err := smartPlaylistCondition(*playlist)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	tracks, total, err := s.findTracks(ctx, query, func() *database.DB {
+		return s.db.Where(ctx, "tracks.library_id = ?", playlist.LibraryID).Where(ctx, condition, args...)
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	_, offset := trackPage(query)
+	entries := make([]PlaylistEntry, len(tracks))
+	for i, track := range tracks {
+		entries[i] = PlaylistEntry{
+			Entry: models.PlaylistTrack{PlaylistID: playlist.ID, Position: offset + i, TrackKey: track.TrackID},
+			Track: track,
+		}
+	}
+	return entries, total, nil
+}
+
+// smartPlaylistTrackKeys returns the track keys of the tracks matching the rules of a smart playlist, in track order
+func (s *MusicLibraryService) smartPlaylistTrackKeys(ctx context.Context, db *database.DB, playlist models.Playlist) ([]string, error) {
+	condition, args, err := smartPlaylistCondition(playlist)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []models.Track
+	if err := db.Where(ctx, "tracks.library_id = ?", playlist.LibraryID).Where(ctx, condition, args...).Order("tracks.id").Find(ctx, &tracks); err != nil {
+		return nil, err
+	}
+
+	keys := make([]string, len(tracks))
+	for i, track := range tracks {
+		keys[i] = track.TrackID
+	}
+	return keys, nil
+}
+
+// encodeSmartRules checks that a rule tree compiles and returns it as JSON
+func encodeSmartRules(rules SmartRule) (string, error) {
+	if _, _, err := rules.compile(0); err != nil {
+		return "", err
+	}
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
+}
+
+// smartPlaylistCondition compiles the stored rules of a smart playlist to a condition on the tracks table
+func smartPlaylistCondition(playlist models.Playlist) (string, []interface{}, error) {
+	var rules SmartRule
+	if err := json.Unmarshal([]byte(playlist.Rules), &rules); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrInvalidSmartRules, err)
+	}
+	return rules.compile(0)
+}
+
+// compile builds the SQL condition of a rule and its arguments
+func (r SmartRule) compile(depth int) (string, []interface{}, error) {
+	if depth > maxSmartRuleDepth {
+		return "", nil, fmt.Errorf("%w: rules are nested more than %d levels deep", ErrInvalidSmartRules, maxSmartRuleDepth)
+	}
+
+	operator := strings.ToLower(r.Operator)
+	switch operator {
+	case "and", "or":
+		if r.Field != "" {
+			return "", nil, fmt.Errorf("%w: %s groups take rules, not a field", ErrInvalidSmartRules, operator)
+		}
+		if len(r.Rules) == 0 {
+			// An empty "and" matches every track, an empty "or" none
+			if operator == "and" {
+				return "TRUE", nil, nil
+			}
+			return "FALSE", nil, nil
+		}
+
+		conditions := make([]string, len(r.Rules))
+		var args []interface{}
+		for i, rule := range r.Rules {
+			condition, ruleArgs, err := rule.compile(depth + 1)
+			if err != nil {
+				return "", nil, err
+			}
+			conditions[i] = condition
+			args = append(args, ruleArgs...)
+		}
+		return "(" + strings.Join(conditions, " "+strings.ToUpper(operator)+" ") + ")", args, nil
+	case "not":
+		if len(r.Rules) != 1 {
+			return "", nil, fmt.Errorf("%w: not takes exactly one rule", ErrInvalidSmartRules)
+		}
+		condition, args, err := r.Rules[0].compile(depth + 1)
+		if err != nil {
+			return "", nil, err
+		}
+		return "NOT (" + condition + ")", args, nil
+	}
+
+	field, ok := smartRuleFields[r.Field]
+	if !ok {
+		return "", nil, fmt.Errorf("%w: unknown field %q", ErrInvalidSmartRules, r.Field)
+	}
+
+	var condition string
+	var args []interface{}
+	var err error
+	switch field.kind {
+	case smartFieldText:
+		condition, args, err = compileTextRule(field.column, operator, r.Value)
+	case smartFieldNumber:
+		condition, args, err = compileNumberRule(field.column, operator, r.Value, nil)
+	case smartFieldRating:
+		condition, args, err = compileNumberRule(field.column, operator, r.Value, storedRating)
+	case smartFieldKey:
+		condition, args, err = compileKeyRule(field.column, operator, r.Value)
+	default:
+		condition, args, err = compileDateRule(field.column, operator, r.Value)
+	}
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %s %s: %v", ErrInvalidSmartRules, r.Field, r.Operator, err)
+	}
+	return condition, args, nil
+}
+
+// compileTextRule builds the case-insensitive condition of a rule on a text column
+func compileTextRule(column, operator string, raw json.RawMessage) (string, []interface{}, error) {
+	if operator == "in" {
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+			return "", nil, fmt.Errorf("expected a list of strings")
+		}
+		for i, value := range values {
+			values[i] = strings.ToLower(value)
+		}
+		return "LOWER(" + column + ") IN ?", []interface{}{values}, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", nil, fmt.Errorf("expected a string")
+	}
+
+	switch operator {
+	case "=":
+		return "LOWER(" + column + ") = ?", []interface{}{strings.ToLower(value)}, nil
+	case "!=":
+		return "LOWER(" + column + ") <> ?", []interface{}{strings.ToLower(value)}, nil
+	case "contains":
+		return column + " ILIKE ?", []interface{}{containsPattern(value)}, nil
+	case "not_contains":
+		return column + " NOT ILIKE ?", []interface{}{containsPattern(value)}, nil
+	case "starts_with":
+		return column + " ILIKE ?", []interface{}{likeEscaper.Replace(value) + "%"}, nil
+	case "ends_with":
+		return column + " ILIKE ?", []interface{}{"%" + likeEscaper.Replace(value)}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported operator for a text field")
+	}
+}
+
+// compileKeyRule builds the condition of a rule on a column of keys in Camelot notation
+func compileKeyRule(column, operator string, raw json.RawMessage) (string, []interface{}, error) {
+	if operator == "in" {
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+			return "", nil, fmt.Errorf("expected a list of keys")
+		}
+		keys := make([]string, len(values))
+		for i, value := range values {
+			key, ok := musickey.Parse(value)
+			if !ok {
+				return "", nil, fmt.Errorf("unknown key %q", value)
+			}
+			keys[i] = key.Camelot()
+		}
+		return column + " IN ?", []interface{}{keys}, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", nil, fmt.Errorf("expected a key")
+	}
+	key, ok := musickey.Parse(value)
+	if !ok {
+		return "", nil, fmt.Errorf("unknown key %q", value)
+	}
+
+	switch operator {
+	case "=":
+		return column + " = ?", []interface{}{key.Camelot()}, nil
+	case "!=":
+		return column + " <> ?", []interface{}{key.Camelot()}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported operator for the key field")
+	}
+}
+
+// compileNumberRule builds the condition of a rule on a number column. If convert is not nil, it checks
+// the values of the rule and converts them to the values stored in the column.
+func compileNumberRule(column, operator string, raw json.RawMessage, convert func(float64) (float64, error)) (string, []interface{}, error) {
+	convertAll := func(values []float64) error {
+		if convert == nil {
+			return nil
+		}
+		for i := range values {
+			var err error
+			if values[i], err = convert(values[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	switch operator {
+	case "between":
+		var values []float64
+		if err := json.Unmarshal(raw, &values); err != nil || len(values) != 2 {
+			return "", nil, fmt.Errorf("expected a list of two numbers")
+		}
+		if err := convertAll(values); err != nil {
+			return "", nil, err
+		}
+		return column + " BETWEEN ? AND ?", []interface{}{min(values[0], values[1]), max(values[0], values[1])}, nil
+	case "in":
+		var values []float64
+		if err := json.Unmarshal(raw, &values); err != nil || len(values) == 0 {
+			return "", nil, fmt.Errorf("expected a list of numbers")
+		}
+		if err := convertAll(values); err != nil {
+			return "", nil, err
+		}
+		return column + " IN ?", []interface{}{values}, nil
+	}
+
+	comparison, ok := numberComparisons[operator]
+	if !ok {
+		return "", nil, fmt.Errorf("unsupported operator for a number field")
+	}
+	values := make([]float64, 1)
+	if err := json.Unmarshal(raw, &values[0]); err != nil {
+		return "", nil, fmt.Errorf("expected a number")
+	}
+	if err := convertAll(values); err != nil {
+		return "", nil, err
+	}
+	return column + " " + comparison + " ?", []interface{}{values[0]}, nil
+}
+
+// compileDateRule builds the condition of a rule on a date column. Dates are compared by day.
+func compileDateRule(column, operator string, raw json.RawMessage) (string, []interface{}, error) {
+	switch operator {
+	case "in_last":
+		var days int
+		if err := json.Unmarshal(raw, &days); err != nil || days < 0 {
+			return "", nil, fmt.Errorf("expected a number of days")
+		}
+		return column + " >= CURRENT_DATE - ?::integer", []interface{}{days}, nil
+	case "between":
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil || len(values) != 2 {
+			return "", nil, fmt.Errorf("expected a list of two dates")
+		}
+		from, err := time.Parse("2006-01-02", values[0])
+		if err != nil {
+			return "", nil, fmt.Errorf("expected YYYY-MM-DD dates")
+		}
+		to, err := time.Parse("2006-01-02", values[1])
+		if err != nil {
+			return "", nil, fmt.Errorf("expected YYYY-MM-DD dates")
+		}
+		if to.Before(from) {
+			from, to = to, from
+		}
+		return "(" + column + " >= ? AND " + column + " < ?)", []interface{}{from, to.AddDate(0, 0, 1)}, nil
+	}
+
+	var value string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", nil, fmt.Errorf("expected a YYYY-MM-DD date")
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return "", nil, fmt.Errorf("expected a YYYY-MM-DD date")
+	}
+
+	switch operator {
+	case "<":
+		return column + " < ?", []interface{}{day}, nil
+	case "<=":
+		return column + " < ?", []interface{}{day.AddDate(0, 0, 1)}, nil
+	case ">":
+		return column + " >= ?", []interface{}{day.AddDate(0, 0, 1)}, nil
+	case ">=":
+		return column + " >= ?", []interface{}{day}, nil
+	default:
+		return "", nil, fmt.Errorf("unsupported operator for a date field")
+	}
+}
@@ -0,0 +1,192 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestSmartRuleCompile(t *testing.T) {
+	tests := []struct {
+		name      string
+		rule      string
+		condition string
+		args      []interface{}
+	}{
+		{
+			name:      "text equals",
+			rule:      `{"field": "genre", "operator": "=", "value": "Techno"}`,
+			condition: "LOWER(tracks.genre) = ?",
+			args:      []interface{}{"techno"},
+		},
+		{
+			name:      "text contains escapes wildcards",
+			rule:      `{"field": "title", "operator": "contains", "value": "100%"}`,
+			condition: "tracks.name ILIKE ?",
+			args:      []interface{}{`%100\%%`},
+		},
+		{
+			name:      "text in",
+			rule:      `{"field": "artist", "operator": "in", "value": ["A", "b"]}`,
+			condition: "LOWER(tracks.artist) IN ?",
+			args:      []interface{}{[]string{"a", "b"}},
+		},
+		{
+			name:      "number between orders its bounds",
+			rule:      `{"field": "bpm", "operator": "between", "value": [132, 126]}`,
+			condition: "tracks.average_bpm BETWEEN ? AND ?",
+			args:      []interface{}{126.0, 132.0},
+		},
+		{
+			name:      "number comparison",
+			rule:      `{"field": "year", "operator": "!=", "value": 1999}`,
+			condition: "tracks.year <> ?",
+			args:      []interface{}{1999.0},
+		},
+		{
+			name:      "rating in stars",
+			rule:      `{"field": "rating", "operator": ">=", "value": 4}`,
+			condition: "tracks.rating >= ?",
+			args:      []interface{}{204.0},
+		},
+		{
+			name:      "rating between stars",
+			rule:      `{"field": "rating", "operator": "between", "value": [1, 5]}`,
+			condition: "tracks.rating BETWEEN ? AND ?",
+			args:      []interface{}{51.0, 255.0},
+		},
+		{
+			name:      "rating in stars list",
+			rule:      `{"field": "rating", "operator": "in", "value": [0, 3]}`,
+			condition: "tracks.rating IN ?",
+			args:      []interface{}{[]float64{0, 153}},
+		},
+		{
+			name:      "key in any notation",
+			rule:      `{"field": "key", "operator": "in", "value": ["Am", "6m", "9B"]}`,
+			condition: "tracks.camelot_key IN ?",
+			args:      []interface{}{[]string{"8A", "1A", "9B"}},
+		},
+		{
+			name:      "key not equal",
+			rule:      `{"field": "key", "operator": "!=", "value": "F# minor"}`,
+			condition: "tracks.camelot_key <> ?",
+			args:      []interface{}{"11A"},
+		},
+		{
+			name:      "in last days",
+			rule:      `{"field": "date_added", "operator": "in_last", "value": 30}`,
+			condition: "tracks.date_added >= CURRENT_DATE - ?::integer",
+			args:      []interface{}{30},
+		},
+		{
+			name: "nested groups",
+			rule: `{"operator": "and", "rules": [
+				{"field": "genre", "operator": "=", "value": "house"},
+				{"operator": "not", "rules": [{"field": "rating", "operator": "<", "value": 2}]}
+			]}`,
+			condition: "(LOWER(tracks.genre) = ? AND NOT (tracks.rating < ?))",
+			args:      []interface{}{"house", 102.0},
+		},
+		{
+			name:      "empty and matches every track",
+			rule:      `{"operator": "and"}`,
+			condition: "TRUE",
+		},
+		{
+			name:      "empty or matches no track",
+			rule:      `{"operator": "or"}`,
+			condition: "FALSE",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule SmartRule
+			if err := json.Unmarshal([]byte(tt.rule), &rule); err != nil {
+				t.Fatal(err)
+			}
+			condition, args, err := rule.compile(0)
+			if err != nil {
+				t.Fatalf("compile: %v", err)
+			}
+			if condition != tt.condition {
+				t.Errorf("condition = %q, want %q", condition, tt.condition)
+			}
+			if !reflect.DeepEqual(args, tt.args) {
+				t.Errorf("args = %#v, want %#v", args, tt.args)
+			}
+		})
+	}
+}
+
+func TestSmartRuleCompileRejectsInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule string
+	}{
+		{"unknown field", `{"field": "mood", "operator": "=", "value": "happy"}`},
+		{"unknown text operator", `{"field": "genre", "operator": ">", "value": "house"}`},
+		{"unknown number operator", `{"field": "bpm", "operator": "contains", "value": 128}`},
+		{"unknown date operator", `{"field": "date_added", "operator": "=", "value": "2024-01-01"}`},
+		{"unknown key operator", `{"field": "key", "operator": "contains", "value": "8A"}`},
+		{"unknown group operator", `{"operator": "xor", "rules": []}`},
+		{"text value of a number field", `{"field": "bpm", "operator": "=", "value": "fast"}`},
+		{"between with one bound", `{"field": "bpm", "operator": "between", "value": [128]}`},
+		{"rating above five stars", `{"field": "rating", "operator": ">=", "value": 204}`},
+		{"negative rating", `{"field": "rating", "operator": "between", "value": [-1, 3]}`},
+		{"rating list above five stars", `{"field": "rating", "operator": "in", "value": [4, 6]}`},
+		{"unknown key", `{"field": "key", "operator": "=", "value": "H minor"}`},
+		{"invalid date", `{"field": "date_added", "operator": "<", "value": "01/02/2024"}`},
+		{"not with two rules", `{"operator": "not", "rules": [{"operator": "and"}, {"operator": "or"}]}`},
+		{"group with a field", `{"operator": "and", "field": "genre", "rules": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var rule SmartRule
+			if err := json.Unmarshal([]byte(tt.rule), &rule); err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := rule.compile(0); !errors.Is(err, ErrInvalidSmartRules) {
+				t.Errorf("compile error = %v, want %v", err, ErrInvalidSmartRules)
+			}
+		})
+	}
+}
+
+func TestSmartRuleCompileLimitsDepth(t *testing.T) {
+	rule := SmartRule{Operator: "and"}
+	for i := 0; i <= maxSmartRuleDepth; i++ {
+		rule = SmartRule{Operator: "not", Rules: []SmartRule{rule}}
+	}
+	if _, _, err := rule.compile(0); !errors.Is(err, ErrInvalidSmartRules) {
+		t.Errorf("compile error = %v, want %v", err, ErrInvalidSmartRules)
+	}
+}
+
+func TestStoredRating(t *testing.T) {
+	tests := []struct {
+		stars float64
+		want  float64
+		ok    bool
+	}{
+		{0, 0, true},
+		{1, 51, true},
+		{2.5, 127.5, true},
+		{4, 204, true},
+		{5, 255, true},
+		{-1, 0, false},
+		{6, 0, false},
+		{255, 0, false},
+	}
+	for _, tt := range tests {
+		got, err := storedRating(tt.stars)
+		if (err == nil) != tt.ok {
+			t.Errorf("storedRating(%v) error = %v, want ok %v", tt.stars, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("storedRating(%v) = %v, want %v", tt.stars, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,560 @@
+package services
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+	"github.com/dinis/musync/internal/database"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/storage"
+)
+
+// File upload states
+const (
+	FileUploadUploading  = "uploading"
+	FileUploadCompleting = "completing" // Fully received and being stored
+	FileUploadCompleted  = "completed"
+	FileUploadFailed     = "failed"
+)
+
+// uploadSweepInterval is how often unfinished uploads are checked for expiry
+const uploadSweepInterval = 10 * time.Minute
+
+// NewFileUpload describes a file the client is about to upload
+type NewFileUpload struct {
+	Name     string // File name
+	Path     string // Path of the file on the client
+	Size     int64  // Size in bytes
+	Hash     string // Hex encoded SHA-256, optional
+	TrackIDs []uint // Tracks to link the file to besides the matching ones
+}
+
+// TrackFileService receives resumable uploads of track files. Chunks are staged next to each other, and
+// completed files are stored in managed storage under the hash of their contents and linked to the
+// tracks they belong to, which are then streamed from managed storage. Uploads that stop receiving
+// chunks expire, and their staged files are removed.
+type TrackFileService struct {
+	db          *database.DB
+	fileStorage *FileStorageService
+	config      config.StorageConfig
+	wg          sync.WaitGroup
+}
+
+// NewTrackFileService creates a new TrackFileService
+func NewTrackFileService(db *database.DB, cfg config.StorageConfig, fileStorage *FileStorageService) *TrackFileService {
+	return &TrackFileService{
+		db:          db,
+		fileStorage: fileStorage,
+		config:      cfg,
+	}
+}
+
+// Start starts expiring the unfinished uploads that have not received a chunk for the configured time,
+// until ctx is cancelled
+func (s *TrackFileService) Start(ctx context.Context) {
+	if s.config.UploadTTL <= 0 {
+		return
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		ticker := time.NewTicker(uploadSweepInterval)
+		defer ticker.Stop()
+		for {
+			if err := s.expireUploads(ctx); err != nil && ctx.Err() == nil {
+				logging.GetLogger().Error("Failed to expire uploads: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Wait blocks until upload expiry has stopped after the context passed to Start was cancelled
+func (s *TrackFileService) Wait() {
+	s.wg.Wait()
+}
+
+// CreateUpload starts the upload of a file of the user
+func (s *TrackFileService) CreateUpload(ctx context.Context, userID uint, file NewFileUpload) (*models.FileUpload, error) {
+	if s.config.MaxFileSize > 0 && file.Size > s.config.MaxFileSize {
+		return nil, ErrTrackFileTooLarge
+	}
+
+	// Tracks to link explicitly must belong to the user
+	trackIDs := uniqueIDs(file.TrackIDs)
+	if len(trackIDs) > 0 {
+		count, err := s.db.Where(ctx, "id IN ? AND library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", trackIDs, userID).Count(ctx, &models.Track{})
+		if err != nil {
+			return nil, err
+		}
+		if count != int64(len(trackIDs)) {
+			return nil, apperrors.ErrNotFound
+		}
+	}
+
+	if err := os.MkdirAll(s.config.UploadDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create upload directory: %w", err)
+	}
+	staging, err := os.CreateTemp(s.config.UploadDir, "upload-*.part")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create staging file: %w", err)
+	}
+	staging.Close()
+
+	upload := &models.FileUpload{
+		UserID:      userID,
+		State:       FileUploadUploading,
+		Name:        path.Base(strings.ReplaceAll(file.Name, `\`, "/")),
+		Path:        file.Path,
+		Size:        file.Size,
+		Hash:        strings.ToLower(file.Hash),
+		StagingPath: staging.Name(),
+		TrackIDs:    trackIDs,
+	}
+	if err := s.db.Create(ctx, upload); err != nil {
+		os.Remove(staging.Name())
+		return nil, err
+	}
+	return upload, nil
+}
+
+// GetUpload returns an upload of the user
+func (s *TrackFileService) GetUpload(ctx context.Context, userID, uploadID uint) (*models.FileUpload, error) {
+	return s.getUpload(ctx, s.db, userID, uploadID)
+}
+
+// getUpload returns an upload of the user, read with db
+func (s *TrackFileService) getUpload(ctx context.Context, db *database.DB, userID, uploadID uint) (*models.FileUpload, error) {
+	var upload models.FileUpload
+	if err := db.Where(ctx, "id = ? AND user_id = ?", uploadID, userID).First(ctx, &upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// AppendChunk appends a chunk read from r to an upload of the user. The offset must equal the number of
+// bytes received so far, otherwise ErrUploadOffsetMismatch is returned along with the upload, so that the
+// client can resume from its offset. The bytes received are kept even if reading the chunk fails. Once the
+// declared size is reached the file is stored and linked to its tracks; a chunk at the end of an upload
+// whose completion failed retries it.
+func (s *TrackFileService) AppendChunk(ctx context.Context, userID, uploadID uint, offset int64, r io.Reader) (*models.FileUpload, error) {
+	upload, err := s.getUpload(ctx, s.db, userID, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkChunk(upload, offset); err != nil {
+		return upload, err
+	}
+
+	// The chunk is received into a file of its own without holding the upload, since clients may send it slowly
+	chunk, written, chunkErr := receiveChunk(upload, offset, r)
+	if chunk == "" {
+		return upload, chunkErr
+	}
+	defer os.Remove(chunk)
+	if errors.Is(chunkErr, ErrUploadExceedsSize) {
+		return upload, chunkErr
+	}
+
+	err = s.db.Transaction(ctx, func(tx *database.DB) error {
+		// Lock the upload so that its chunks are added one after the other, whichever server receives them
+		locked, err := s.getUpload(ctx, tx.ForUpdate(), userID, uploadID)
+		if err != nil {
+			return err
+		}
+		upload = locked
+		if err := checkChunk(upload, offset); err != nil {
+			return err
+		}
+
+		columns := make(map[string]interface{})
+		if written > 0 {
+			// The chunk file becomes part of the staged file, the chunks received before it stay as they are
+			if err := os.Rename(chunk, stagedChunkPath(upload.StagingPath, offset)); err != nil {
+				return fmt.Errorf("failed to stage chunk: %w", err)
+			}
+			upload.Offset += written
+			columns["offset"] = upload.Offset
+		}
+		if chunkErr == nil && upload.Offset == upload.Size {
+			// Only the request moving the upload out of the uploading state completes it
+			upload.State = FileUploadCompleting
+			columns["state"] = upload.State
+		}
+		if len(columns) == 0 {
+			return nil
+		}
+
+		// Receiving a chunk postpones the expiry of the upload
+		columns["updated_at"] = time.Now()
+		_, err = tx.Where(ctx, "id = ?", upload.ID).UpdateColumns(ctx, &models.FileUpload{}, columns)
+		return err
+	})
+	if err != nil {
+		if errors.Is(err, ErrUploadOffsetMismatch) || errors.Is(err, ErrUploadFinished) {
+			return upload, err
+		}
+		return nil, err
+	}
+	if chunkErr != nil {
+		return upload, fmt.Errorf("failed to receive chunk: %w", chunkErr)
+	}
+
+	if upload.State == FileUploadCompleting {
+		return upload, s.complete(ctx, upload)
+	}
+	return upload, nil
+}
+
+// checkChunk checks that a chunk at offset continues an upload
+func checkChunk(upload *models.FileUpload, offset int64) error {
+	if upload.State != FileUploadUploading {
+		return ErrUploadFinished
+	}
+	if offset != upload.Offset {
+		return ErrUploadOffsetMismatch
+	}
+	return nil
+}
+
+// receiveChunk writes a chunk read from r to a new file next to the staged file of an upload, and returns
+// the file and the number of bytes written. The bytes read before a failure are kept. The file is empty if
+// the chunk extends past the size of the upload.
+func receiveChunk(upload *models.FileUpload, offset int64, r io.Reader) (string, int64, error) {
+	file, err := os.CreateTemp(filepath.Dir(upload.StagingPath), filepath.Base(upload.StagingPath)+".chunk-*")
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to create chunk file: %w", err)
+	}
+
+	// Read one byte more than the upload lacks, to detect chunks extending past its size
+	written, err := io.Copy(file, io.LimitReader(r, upload.Size-offset+1))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if offset+written > upload.Size {
+		return file.Name(), 0, ErrUploadExceedsSize
+	}
+	return file.Name(), written, err
+}
+
+// CancelUpload deletes an unfinished upload of the user and its staged file
+func (s *TrackFileService) CancelUpload(ctx context.Context, userID, uploadID uint) error {
+	var upload *models.FileUpload
+	err := s.db.Transaction(ctx, func(tx *database.DB) error {
+		// Wait for a chunk being added
+		var err error
+		upload, err = s.getUpload(ctx, tx.ForUpdate(), userID, uploadID)
+		if err != nil {
+			return err
+		}
+		if upload.State == FileUploadCompleting || upload.State == FileUploadCompleted {
+			return ErrUploadFinished
+		}
+		return tx.Delete(ctx, upload)
+	})
+	if err != nil {
+		return err
+	}
+	s.removeStaging(upload.ID, upload.StagingPath)
+	return nil
+}
+
+// expiredUpload is an upload marked as expired, with the staged file to remove
+type expiredUpload struct {
+	ID          uint
+	StagingPath string
+}
+
+// expireUploads fails the unfinished uploads that have not received a chunk for the configured time, or
+// whose completion was interrupted that long ago, and removes their staged files. Uploads a chunk is being
+// added to are skipped.
+func (s *TrackFileService) expireUploads(ctx context.Context) error {
+	var expired []expiredUpload
+	cutoff := time.Now().Add(-time.Duration(s.config.UploadTTL) * time.Hour)
+	if err := s.db.Raw(ctx, &expired, `
+		UPDATE file_uploads SET state = ?, error = ?, staging_path = '', updated_at = ?
+		FROM (
+			SELECT id, staging_path FROM file_uploads
+			WHERE state IN ? AND updated_at < ? AND deleted_at IS NULL
+			FOR UPDATE SKIP LOCKED
+		) AS expired
+		WHERE file_uploads.id = expired.id
+		RETURNING expired.id, expired.staging_path`,
+		FileUploadFailed, ErrUploadExpired.Error(), time.Now(), []string{FileUploadUploading, FileUploadCompleting}, cutoff); err != nil {
+		return err
+	}
+
+	for _, upload := range expired {
+		s.removeStaging(upload.ID, upload.StagingPath)
+	}
+	if len(expired) > 0 {
+		logging.GetLogger().Info("Expired %d unfinished uploads", len(expired))
+	}
+	return nil
+}
+
+// complete stores an upload that has been moved to the completing state in managed storage, links it to its
+// tracks and removes its staged file. The upload is not locked meanwhile, its state keeps other requests from
+// completing it as well. A file that does not match its declared hash fails the upload; any other failure moves
+// the upload back to the uploading state, so that a chunk at its end retries the completion.
+func (s *TrackFileService) complete(ctx context.Context, upload *models.FileUpload) error {
+	stagingPath := upload.StagingPath
+	err := s.store(ctx, upload)
+	switch {
+	case err == nil:
+		s.removeStaging(upload.ID, stagingPath)
+		return nil
+	case errors.Is(err, ErrUploadHashMismatch):
+		finished, updateErr := s.finishCompletion(ctx, upload, map[string]interface{}{
+			"state":        FileUploadFailed,
+			"error":        err.Error(),
+			"staging_path": "",
+		})
+		if updateErr != nil {
+			return updateErr
+		}
+		if !finished {
+			return ErrUploadFinished
+		}
+		upload.State = FileUploadFailed
+		upload.Error = err.Error()
+		upload.StagingPath = ""
+		s.removeStaging(upload.ID, stagingPath)
+		return err
+	case errors.Is(err, ErrUploadFinished):
+		return err
+	}
+
+	if _, updateErr := s.finishCompletion(ctx, upload, map[string]interface{}{"state": FileUploadUploading}); updateErr != nil {
+		logging.GetLogger().Error("Failed to reopen upload %d after its completion failed: %v", upload.ID, updateErr)
+	}
+	upload.State = FileUploadUploading
+	return err
+}
+
+// finishCompletion updates an upload that is still completing, and reports whether it was
+func (s *TrackFileService) finishCompletion(ctx context.Context, upload *models.FileUpload, columns map[string]interface{}) (bool, error) {
+	columns["updated_at"] = time.Now()
+	updated, err := s.db.Where(ctx, "id = ? AND state = ?", upload.ID, FileUploadCompleting).UpdateColumns(ctx, &models.FileUpload{}, columns)
+	return updated > 0, err
+}
+
+// store hashes the staged file of a completing upload, stores it in managed storage under its hash and links it
+// to its tracks
+func (s *TrackFileService) store(ctx context.Context, upload *models.FileUpload) error {
+	staged, err := openStaged(upload.StagingPath, upload.Size)
+	if err != nil {
+		return fmt.Errorf("failed to open staged file: %w", err)
+	}
+	hash := sha256.New()
+	_, err = io.Copy(hash, staged)
+	staged.Close()
+	if err != nil {
+		return fmt.Errorf("failed to hash uploaded file: %w", err)
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if upload.Hash != "" && upload.Hash != sum {
+		return ErrUploadHashMismatch
+	}
+
+	backend, ok := s.fileStorage.files.Backend(storage.SchemeManaged)
+	if !ok {
+		return storage.ErrUnknownScheme
+	}
+	key := storage.ContentKey(sum)
+	if _, err := backend.Stat(ctx, key); errors.Is(err, storage.ErrNotFound) {
+		staged, err := openStaged(upload.StagingPath, upload.Size)
+		if err != nil {
+			return fmt.Errorf("failed to open staged file: %w", err)
+		}
+		err = backend.Put(ctx, key, staged, upload.Size)
+		staged.Close()
+		if err != nil {
+			return fmt.Errorf("failed to store uploaded file: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("failed to check managed storage: %w", err)
+	}
+
+	return s.db.Transaction(ctx, func(tx *database.DB) error {
+		// The upload may have expired or been completed meanwhile
+		locked, err := s.getUpload(ctx, tx.ForUpdate(), upload.UserID, upload.ID)
+		if err != nil {
+			return err
+		}
+		if locked.State != FileUploadCompleting {
+			return ErrUploadFinished
+		}
+
+		var trackFile models.TrackFile
+		err = tx.Where(ctx, "user_id = ? AND hash = ?", upload.UserID, sum).First(ctx, &trackFile)
+		switch {
+		case errors.Is(err, apperrors.ErrNotFound):
+			trackFile = models.TrackFile{UserID: upload.UserID, Hash: sum}
+		case err != nil:
+			return err
+		}
+		trackFile.Size = upload.Size
+		trackFile.Name = upload.Name
+		if err := tx.Save(ctx, &trackFile); err != nil {
+			return err
+		}
+
+		trackIDs, err := s.matchTracks(ctx, tx, upload, sum)
+		if err != nil {
+			return err
+		}
+		if len(trackIDs) > 0 {
+			if _, err := tx.Where(ctx, "id IN ?", trackIDs).UpdateColumns(ctx, &models.Track{}, map[string]interface{}{
+				"storage_type": models.StorageTypeManaged,
+				"file_hash":    sum,
+			}); err != nil {
+				return err
+			}
+		}
+
+		completed := *locked
+		completed.State = FileUploadCompleted
+		completed.Hash = sum
+		completed.TrackFileID = &trackFile.ID
+		completed.LinkedTracks = trackIDs
+		completed.StagingPath = ""
+		completed.Error = ""
+		if err := tx.Save(ctx, &completed); err != nil {
+			return err
+		}
+		*upload = completed
+		return nil
+	})
+}
+
+// matchTracks returns the tracks of the user a completed file belongs to: the tracks requested for the
+// upload, the tracks that already have its hash, the tracks whose location is the file's path on the
+// client, and the tracks with its size and name. Tracks sharing only its size are not linked.
+func (s *TrackFileService) matchTracks(ctx context.Context, tx *database.DB, upload *models.FileUpload, hash string) ([]uint, error) {
+	var candidates []models.Track
+	if err := tx.Where(ctx, "library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", upload.UserID).
+		Where(ctx, "id IN ? OR file_hash = ? OR location ILIKE ? OR location ILIKE ?",
+			upload.TrackIDs, hash, containsPattern(upload.Name), containsPattern(url.PathEscape(upload.Name))).
+		Order("id").Find(ctx, &candidates); err != nil {
+		return nil, err
+	}
+
+	requested := make(map[uint]bool, len(upload.TrackIDs))
+	for _, id := range upload.TrackIDs {
+		requested[id] = true
+	}
+	clientPath := duplicateLocationKey(upload.Path)
+
+	var linked []uint
+	for _, track := range candidates {
+		location := duplicateLocationKey(s.fileStorage.NormalizeTrackLocation(track.Location))
+		switch {
+		case requested[track.ID], track.FileHash == hash:
+			linked = append(linked, track.ID)
+		case clientPath != "" && location == clientPath:
+			linked = append(linked, track.ID)
+		case track.Size == upload.Size && path.Base(location) == strings.ToLower(upload.Name):
+			linked = append(linked, track.ID)
+		}
+	}
+	return linked, nil
+}
+
+// stagedChunkPath returns the file holding the chunk of an upload starting at offset. The bytes of an upload
+// are its staged file followed by the files of the chunks received after it.
+func stagedChunkPath(stagingPath string, offset int64) string {
+	return fmt.Sprintf("%s.%d", stagingPath, offset)
+}
+
+// stagedFile reads the bytes received for an upload
+type stagedFile struct {
+	io.Reader
+	files []*os.File
+}
+
+// openStaged opens the bytes received for an upload of the given size
+func openStaged(stagingPath string, size int64) (*stagedFile, error) {
+	staged := &stagedFile{}
+	var received int64
+	for name := stagingPath; ; {
+		file, err := os.Open(name)
+		if err != nil {
+			staged.Close()
+			return nil, err
+		}
+		staged.files = append(staged.files, file)
+		info, err := file.Stat()
+		if err != nil {
+			staged.Close()
+			return nil, err
+		}
+		received += info.Size()
+		if received >= size {
+			break
+		}
+		if name = stagedChunkPath(stagingPath, received); name == file.Name() {
+			staged.Close()
+			return nil, fmt.Errorf("empty chunk at offset %d", received)
+		}
+	}
+
+	readers := make([]io.Reader, len(staged.files))
+	for i, file := range staged.files {
+		readers[i] = file
+	}
+	staged.Reader = io.LimitReader(io.MultiReader(readers...), size)
+	return staged, nil
+}
+
+// Close closes the files of the staged bytes
+func (f *stagedFile) Close() {
+	for _, file := range f.files {
+		file.Close()
+	}
+}
+
+// removeStaging deletes the staged file of an upload and the files of its chunks
+func (s *TrackFileService) removeStaging(uploadID uint, stagingPath string) {
+	if stagingPath == "" {
+		return
+	}
+	chunks, _ := filepath.Glob(stagingPath + ".*")
+	for _, name := range append(chunks, stagingPath) {
+		if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+			logging.GetLogger().Warn("Failed to remove staged file of upload %d: %v", uploadID, err)
+		}
+	}
+}
+
+// uniqueIDs returns ids without duplicates, in their original order
+func uniqueIDs(ids []uint) []uint {
+	seen := make(map[uint]bool, len(ids))
+	var unique []uint
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	return unique
+}
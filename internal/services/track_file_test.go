@@ -0,0 +1,62 @@
+package services
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenStaged(t *testing.T) {
+	tests := []struct {
+		name   string
+		staged string
+		chunks map[int64]string
+		size   int64
+		want   string
+	}{
+		{"chunks after an empty staged file", "", map[int64]string{0: "abc", 3: "defg"}, 7, "abcdefg"},
+		{"chunks after the staged bytes", "abc", map[int64]string{3: "de", 5: "f"}, 6, "abcdef"},
+		{"staged bytes only", "abcdef", nil, 6, "abcdef"},
+		{"chunks outside the sequence are ignored", "", map[int64]string{0: "ab", 1: "xyz", 2: "cd"}, 4, "abcd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stagingPath := filepath.Join(t.TempDir(), "upload.part")
+			if err := os.WriteFile(stagingPath, []byte(tt.staged), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			for offset, data := range tt.chunks {
+				if err := os.WriteFile(stagedChunkPath(stagingPath, offset), []byte(data), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			staged, err := openStaged(stagingPath, tt.size)
+			if err != nil {
+				t.Fatalf("openStaged: %v", err)
+			}
+			defer staged.Close()
+			got, err := io.ReadAll(staged)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("staged bytes = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOpenStagedMissingChunk(t *testing.T) {
+	stagingPath := filepath.Join(t.TempDir(), "upload.part")
+	if err := os.WriteFile(stagingPath, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(stagedChunkPath(stagingPath, 0), []byte("abc"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := openStaged(stagingPath, 6); err == nil {
+		t.Error("openStaged succeeded without the chunk at offset 3")
+	}
+}
@@ -0,0 +1,299 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/database"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/musickey"
+)
+
+// Page sizes of track listings
+const (
+	DefaultTrackPageSize = 100
+	MaxTrackPageSize     = 1000
+)
+
+// Ratings are stored on the 0-255 scale of Rekordbox, where each star is worth 51, and queried in stars
+const (
+	MaxRatingStars  = 5
+	ratingStarValue = 51
+)
+
+// TrackQuery filters, sorts and paginates a track listing. Unset filters match every track.
+type TrackQuery struct {
+	Genres    []string // Any of the genres, case-insensitive
+	Keys      []string // Any of the keys, in standard, Camelot or Open Key notation
+	Artist    string   // Substring of the artist, case-insensitive
+	Label     string   // Substring of the label, case-insensitive
+	BpmMin    *float64
+	BpmMax    *float64
+	YearMin   *int
+	YearMax   *int
+	RatingMin *int       // In stars, 0 to MaxRatingStars
+	RatingMax *int       // In stars, 0 to MaxRatingStars
+	AddedFrom *time.Time // Inclusive
+	AddedTo   *time.Time // Exclusive
+	Sort      []TrackSort
+	Limit     int // DefaultTrackPageSize if 0, at most MaxTrackPageSize
+	Offset    int
+}
+
+// TrackSort is a sort key of a track listing
+type TrackSort struct {
+	Field      string
+	Descending bool
+}
+
+// trackSortColumns maps the sort fields of track listings to their columns
+var trackSortColumns = map[string]string{
+	"id":         "tracks.id",
+	"title":      "tracks.name",
+	"artist":     "tracks.artist",
+	"album":      "tracks.album",
+	"genre":      "tracks.genre",
+	"label":      "tracks.label",
+	"bpm":        "tracks.average_bpm",
+	"key":        "LPAD(tracks.camelot_key, 3, '0')", // Around the Camelot wheel, e.g. 1A, 1B, 2A, ... 12B
+	"year":       "tracks.year",
+	"rating":     "tracks.rating",
+	"play_count": "tracks.play_count",
+	"duration":   "tracks.total_time",
+	"date_added": "tracks.date_added",
+}
+
+// GetTracks returns a page of the tracks in a library together with the number of tracks matching the query
+func (s *MusicLibraryService) GetTracks(ctx context.Context, userID, libraryID uint, query TrackQuery) ([]models.Track, int64, error) {
+	// First check if the library belongs to the user
+	if _, err := s.GetLibrary(ctx, userID, libraryID); err != nil {
+		return nil, 0, err
+	}
+
+	return s.findTracks(ctx, query, func() *database.DB {
+		return s.db.Where(ctx, "tracks.library_id = ?", libraryID)
+	})
+}
+
+// GetFolderTracks returns a page of the distinct tracks in all playlists and smart playlists within a folder and its subfolders,
+// together with the number of tracks matching the query
+func (s *MusicLibraryService) GetFolderTracks(ctx context.Context, userID, folderID uint, query TrackQuery) ([]models.Track, int64, error) {
+	var folder models.Playlist
+	if err := s.db.Where(ctx, "id = ? AND library_id IN (SELECT id FROM music_libraries WHERE user_id = ? AND deleted_at IS NULL)", folderID, userID).First(ctx, &folder); err != nil {
+		return nil, 0, err
+	}
+
+	// Check if it's actually a folder
+	if folder.Type != models.PlaylistTypeFolder {
+		return nil, 0, ErrNotAFolder
+	}
+
+	// Collect the playlists within the folder from the library's playlist tree
+	var playlists []models.Playlist
+	if err := s.db.Where(ctx, "library_id = ?", folder.LibraryID).Find(ctx, &playlists); err != nil {
+		return nil, 0, err
+	}
+	children := make(map[uint][]models.Playlist)
+	for _, playlist := range playlists {
+		if playlist.ParentID != nil {
+			children[*playlist.ParentID] = append(children[*playlist.ParentID], playlist)
+		}
+	}
+
+	var playlistIDs []uint
+	var smartPlaylists []models.Playlist
+	visited := map[uint]bool{folder.ID: true}
+	pending := []uint{folder.ID}
+	for len(pending) > 0 {
+		parentID := pending[0]
+		pending = pending[1:]
+		for _, child := range children[parentID] {
+			if visited[child.ID] {
+				continue
+			}
+			visited[child.ID] = true
+			switch child.Type {
+			case models.PlaylistTypeFolder:
+				pending = append(pending, child.ID)
+			case models.PlaylistTypeSmart:
+				smartPlaylists = append(smartPlaylists, child)
+			default:
+				playlistIDs = append(playlistIDs, child.ID)
+			}
+		}
+	}
+
+	// A track belongs to the folder if it has an entry in one of its playlists or matches one of its smart playlists
+	var conditions []string
+	var args []interface{}
+	if len(playlistIDs) > 0 {
+		conditions = append(conditions, "tracks.track_id IN (SELECT track_key FROM playlist_tracks WHERE playlist_id IN ? AND deleted_at IS NULL)")
+		args = append(args, playlistIDs)
+	}
+	for _, playlist := range smartPlaylists {
+		condition, smartArgs, err := smartPlaylistCondition(playlist)
+		if err != nil {
+			return nil, 0, err
+		}
+		conditions = append(conditions, condition)
+		args = append(args, smartArgs...)
+	}
+	if len(conditions) == 0 {
+		return []models.Track{}, 0, nil
+	}
+
+	return s.findTracks(ctx, query, func() *database.DB {
+		return s.db.Where(ctx, "tracks.library_id = ?", folder.LibraryID).Where(ctx, "("+strings.Join(conditions, " OR ")+")", args...)
+	})
+}
+
+// findTracks counts and loads a page of the tracks selected by scope that match the query
+func (s *MusicLibraryService) findTracks(ctx context.Context, query TrackQuery, scope func() *database.DB) ([]models.Track, int64, error) {
+	if err := query.validate(); err != nil {
+		return nil, 0, err
+	}
+	order, err := trackOrder(query.Sort, trackSortColumns, "", "tracks.id")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total, err := applyTrackFilters(ctx, scope(), query).Count(ctx, &models.Track{})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var tracks []models.Track
+	limit, offset := trackPage(query)
+	if err := applyTrackFilters(ctx, scope(), query).Order(order).Limit(limit).Offset(offset).Find(ctx, &tracks); err != nil {
+		return nil, 0, err
+	}
+
+	// Normalize track locations for frontend compatibility
+	for i := range tracks {
+		tracks[i].Location = s.fileStorage.NormalizeTrackLocation(tracks[i].Location)
+	}
+
+	return tracks, total, nil
+}
+
+// validate checks the filters of a query that the filters do not clamp themselves
+func (q TrackQuery) validate() error {
+	for _, stars := range []*int{q.RatingMin, q.RatingMax} {
+		if stars == nil {
+			continue
+		}
+		if _, err := storedRating(float64(*stars)); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidTrackQuery, err)
+		}
+	}
+	return nil
+}
+
+// storedRating converts a rating in stars to the scale ratings are stored on
+func storedRating(stars float64) (float64, error) {
+	if stars < 0 || stars > MaxRatingStars {
+		return 0, fmt.Errorf("rating must be between 0 and %d stars", MaxRatingStars)
+	}
+	return stars * ratingStarValue, nil
+}
+
+// applyTrackFilters adds the filters of a query on the tracks table to db
+func applyTrackFilters(ctx context.Context, db *database.DB, query TrackQuery) *database.DB {
+	if len(query.Genres) > 0 {
+		genres := make([]string, len(query.Genres))
+		for i, genre := range query.Genres {
+			genres[i] = strings.ToLower(genre)
+		}
+		db = db.Where(ctx, "LOWER(tracks.genre) IN ?", genres)
+	}
+	if len(query.Keys) > 0 {
+		// Keys in any notation match through their normalized form
+		normalized := make([]string, 0, len(query.Keys))
+		for _, value := range query.Keys {
+			if key, ok := musickey.Parse(value); ok {
+				normalized = append(normalized, key.Camelot())
+			}
+		}
+		if len(normalized) > 0 {
+			db = db.Where(ctx, "(tracks.camelot_key IN ? OR tracks.tonality IN ?)", normalized, query.Keys)
+		} else {
+			db = db.Where(ctx, "tracks.tonality IN ?", query.Keys)
+		}
+	}
+	if query.Artist != "" {
+		db = db.Where(ctx, "tracks.artist ILIKE ?", containsPattern(query.Artist))
+	}
+	if query.Label != "" {
+		db = db.Where(ctx, "tracks.label ILIKE ?", containsPattern(query.Label))
+	}
+	if query.BpmMin != nil {
+		db = db.Where(ctx, "tracks.average_bpm >= ?", *query.BpmMin)
+	}
+	if query.BpmMax != nil {
+		db = db.Where(ctx, "tracks.average_bpm <= ?", *query.BpmMax)
+	}
+	if query.YearMin != nil {
+		db = db.Where(ctx, "tracks.year >= ?", *query.YearMin)
+	}
+	if query.YearMax != nil {
+		db = db.Where(ctx, "tracks.year <= ?", *query.YearMax)
+	}
+	if query.RatingMin != nil {
+		db = db.Where(ctx, "tracks.rating >= ?", *query.RatingMin*ratingStarValue)
+	}
+	if query.RatingMax != nil {
+		db = db.Where(ctx, "tracks.rating <= ?", *query.RatingMax*ratingStarValue)
+	}
+	if query.AddedFrom != nil {
+		db = db.Where(ctx, "tracks.date_added >= ?", *query.AddedFrom)
+	}
+	if query.AddedTo != nil {
+		db = db.Where(ctx, "tracks.date_added < ?", *query.AddedTo)
+	}
+	return db
+}
+
+// trackOrder builds the ORDER BY clause of a listing from the sort keys of a query and the sortable
+// columns of the listing. Without sort keys the fallback order is used. The tiebreak column is always
+// appended so that pages are stable.
+func trackOrder(sorts []TrackSort, columns map[string]string, fallback, tiebreak string) (string, error) {
+	if len(sorts) == 0 {
+		if fallback == "" {
+			return tiebreak, nil
+		}
+		return fallback + ", " + tiebreak, nil
+	}
+
+	order := make([]string, 0, len(sorts)+1)
+	for _, sort := range sorts {
+		column, ok := columns[sort.Field]
+		if !ok {
+			return "", fmt.Errorf("%w: unknown sort field %q", ErrInvalidTrackQuery, sort.Field)
+		}
+		if sort.Descending {
+			column += " DESC"
+		}
+		order = append(order, column)
+	}
+	order = append(order, tiebreak)
+	return strings.Join(order, ", "), nil
+}
+
+// trackPage returns the limit and offset of a query, applying the default and maximum page size
+func trackPage(query TrackQuery) (int, int) {
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultTrackPageSize
+	}
+	return min(limit, MaxTrackPageSize), max(query.Offset, 0)
+}
+
+// likeEscaper escapes the wildcards of LIKE patterns
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern returns an ILIKE pattern matching values that contain s
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+	"github.com/dinis/musync/internal/database"
+	apperrors "github.com/dinis/musync/internal/errors"
+	"github.com/dinis/musync/internal/logging"
+	"github.com/dinis/musync/internal/models"
+	"github.com/dinis/musync/internal/waveform"
+)
+
+// Waveform generation states
+const (
+	WaveformQueued    = "queued"
+	WaveformRunning   = "running"
+	WaveformCompleted = "completed"
+	WaveformFailed    = "failed"
+)
+
+// DefaultWaveformResolution is the number of points of a waveform overview when none is requested
+const DefaultWaveformResolution = 1024
+
+// WaveformService generates waveform overviews of tracks in the background and caches them per track.
+// Pending generations are persisted, so that those interrupted by a restart are run again.
+type WaveformService struct {
+	db          *database.DB
+	fileStorage *FileStorageService
+	config      config.WaveformConfig
+	jobs        *jobRunner[models.TrackWaveform]
+}
+
+// NewWaveformService creates a new WaveformService
+func NewWaveformService(db *database.DB, cfg config.WaveformConfig, fileStorage *FileStorageService) *WaveformService {
+	s := &WaveformService{
+		db:          db,
+		fileStorage: fileStorage,
+		config:      cfg,
+	}
+	s.jobs = newJobRunner(db, "waveform generation", "track_waveforms", s.run)
+	return s
+}
+
+// Start requeues interrupted generations and starts the waveform workers, which run until ctx is cancelled
+func (s *WaveformService) Start(ctx context.Context) error {
+	if err := s.jobs.requeue(ctx); err != nil {
+		return err
+	}
+	s.jobs.start(ctx, s.config.Workers)
+	return nil
+}
+
+// Wait blocks until the waveform workers have stopped after the context passed to Start was cancelled
+func (s *WaveformService) Wait() {
+	s.jobs.wait()
+}
+
+// GetWaveform returns the waveform overview of a track of the user. If there is none yet, or the track's file
+// has changed since it was generated, its generation is queued and the returned waveform is in the queued state.
+func (s *WaveformService) GetWaveform(ctx context.Context, userID, trackID uint) (*models.TrackWaveform, error) {
+	track, err := s.fileStorage.GetTrackInfo(ctx, userID, trackID)
+	if err != nil {
+		return nil, apperrors.ErrNotFound
+	}
+
+	var cached models.TrackWaveform
+	err = s.db.Where(ctx, "track_id = ?", track.ID).First(ctx, &cached)
+	switch {
+	case errors.Is(err, apperrors.ErrNotFound):
+		cached = models.TrackWaveform{TrackID: track.ID, State: WaveformQueued, Location: track.Location, Size: track.Size}
+		if err := s.db.Create(ctx, &cached); err != nil {
+			// Another request may have queued it first
+			if findErr := s.db.Where(ctx, "track_id = ?", track.ID).First(ctx, &cached); findErr != nil {
+				return nil, err
+			}
+			return &cached, nil
+		}
+		s.jobs.notify()
+		return &cached, nil
+	case err != nil:
+		return nil, err
+	}
+
+	// A finished waveform of a file that has since been relocated or replaced is stale
+	stale := cached.Location != track.Location || cached.Size != track.Size
+	if stale && (cached.State == WaveformCompleted || cached.State == WaveformFailed) {
+		values := map[string]interface{}{
+			"state":       WaveformQueued,
+			"location":    track.Location,
+			"size":        track.Size,
+			"error":       "",
+			"started_at":  nil,
+			"finished_at": nil,
+		}
+		if _, err := s.db.Where(ctx, "id = ?", cached.ID).UpdateColumns(ctx, &models.TrackWaveform{}, values); err != nil {
+			return nil, err
+		}
+		cached.State, cached.Location, cached.Size, cached.Error = WaveformQueued, track.Location, track.Size, ""
+		cached.StartedAt, cached.FinishedAt = nil, nil
+		s.jobs.notify()
+	}
+	return &cached, nil
+}
+
+// run generates a waveform and records the outcome
+func (s *WaveformService) run(ctx context.Context, job *models.TrackWaveform) {
+	overview, err := s.generate(ctx, job)
+
+	now := time.Now()
+	values := map[string]interface{}{"finished_at": now}
+	if err != nil {
+		logging.GetLogger().Warn("Waveform generation for track %d failed: %v", job.TrackID, err)
+		values["state"] = WaveformFailed
+		values["error"] = err.Error()
+	} else {
+		values["state"] = WaveformCompleted
+		values["format"] = overview.Format
+		values["sample_rate"] = overview.SampleRate
+		values["duration"] = overview.Duration
+		values["peaks"] = overview.Peaks
+		values["rms"] = overview.RMS
+	}
+
+	if _, err := s.db.Where(ctx, "id = ?", job.ID).UpdateColumns(ctx, &models.TrackWaveform{}, values); err != nil {
+		logging.GetLogger().Error("Failed to record waveform of track %d: %v", job.TrackID, err)
+	}
+}
+
+// generate decodes the file of a waveform's track and computes its overview
+func (s *WaveformService) generate(ctx context.Context, job *models.TrackWaveform) (*waveform.Overview, error) {
+	var track models.Track
+	if err := s.db.First(ctx, &track, job.TrackID); err != nil {
+		return nil, err
+	}
+	var library models.MusicLibrary
+	if err := s.db.First(ctx, &library, track.LibraryID); err != nil {
+		return nil, err
+	}
+
+	file, _, err := s.fileStorage.GetFileStream(ctx, library.UserID, track.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	return waveform.Generate(file)
+}
@@ -0,0 +1,218 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// SchemeFile is the URL scheme of files on the local file system
+const SchemeFile = "file"
+
+// tempPrefix starts the names of the temporary files Put writes to
+const tempPrefix = ".musync-upload-"
+
+// LocalBackend stores files in a directory of the local file system. Keys are absolute paths, or paths
+// relative to the root, and must lie inside the root once symbolic links are resolved.
+type LocalBackend struct {
+	root string
+}
+
+// NewLocalBackend creates a backend sandboxed to a root directory
+func NewLocalBackend(root string) *LocalBackend {
+	if absolute, err := filepath.Abs(root); err == nil {
+		root = absolute
+	}
+	return &LocalBackend{root: filepath.Clean(root)}
+}
+
+// Root returns the directory the backend is sandboxed to
+func (b *LocalBackend) Root() string {
+	return b.root
+}
+
+// path returns the file system path of a key, without checking that it lies inside the root
+func (b *LocalBackend) path(key string) string {
+	// File URLs of Windows paths have a slash before the drive letter
+	if runtime.GOOS == "windows" && len(key) > 2 && key[0] == '/' && key[2] == ':' {
+		key = key[1:]
+	}
+	path := filepath.FromSlash(key)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(b.root, path)
+	}
+	return filepath.Clean(path)
+}
+
+// inside reports whether a clean path lies inside the root
+func (b *LocalBackend) inside(path string) bool {
+	rel, err := filepath.Rel(b.root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// resolve returns the file system path of an existing file, rejecting keys that escape the root
+func (b *LocalBackend) resolve(key string) (string, error) {
+	path := b.path(key)
+	if !b.inside(path) {
+		return "", ErrInvalidKey
+	}
+	real, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	if !b.inside(real) {
+		return "", ErrInvalidKey
+	}
+	return real, nil
+}
+
+// Open returns a reader for a range of a file
+func (b *LocalBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	path, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return file, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(file, length), file}, nil
+}
+
+// Stat returns the size and modification time of a file
+func (b *LocalBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	path, err := b.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()}, nil
+}
+
+// Put writes a file through a temporary file in the same directory, so that readers never see it partially written
+func (b *LocalBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	path := b.path(key)
+	if !b.inside(path) || path == b.root {
+		return ErrInvalidKey
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	// The directory may be reached through a symbolic link leading out of the root
+	if real, err := filepath.EvalSymlinks(dir); err != nil || !b.inside(real) {
+		return ErrInvalidKey
+	}
+
+	tmp, err := os.CreateTemp(dir, tempPrefix+"*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	written, err := io.Copy(tmp, r)
+	if err == nil && size >= 0 && written != size {
+		err = io.ErrUnexpectedEOF
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes a file. Deleting a file that does not exist is not an error.
+func (b *LocalBackend) Delete(ctx context.Context, key string) error {
+	path := b.path(key)
+	if !b.inside(path) {
+		return ErrInvalidKey
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// List returns the files under the root whose keys start with prefix. Keys are returned in the form of
+// the prefix: absolute paths for an absolute prefix, relative to the root otherwise.
+func (b *LocalBackend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Walk the deepest directory the prefix names completely
+	dir := b.path(prefix)
+	if !strings.HasSuffix(prefix, "/") && prefix != "" {
+		dir = filepath.Dir(dir)
+	}
+	if !b.inside(dir) {
+		dir = b.root
+	}
+
+	absolute := strings.HasPrefix(prefix, "/") || filepath.IsAbs(filepath.FromSlash(prefix))
+	var objects []ObjectInfo
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) || errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		// Files being written by Put are skipped
+		if entry.IsDir() || !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), tempPrefix) {
+			return nil
+		}
+
+		key := filepath.ToSlash(path)
+		if !absolute {
+			rel, err := filepath.Rel(b.root, path)
+			if err != nil {
+				return nil
+			}
+			key = filepath.ToSlash(rel)
+		}
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
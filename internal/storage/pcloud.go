@@ -0,0 +1,263 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+)
+
+// SchemePCloud is the URL scheme of files in pCloud, addressed by path, e.g. "pcloud:///Music/file.mp3",
+// or by file ID, e.g. "pcloud://1234567"
+const SchemePCloud = "pcloud"
+
+// pCloud API error codes of paths that do not exist
+var pcloudNotFound = map[int]bool{
+	2002: true, // A component of parent directory does not exist
+	2005: true, // Directory does not exist
+	2009: true, // File not found
+}
+
+// PCloudBackend stores files in a pCloud account through its HTTP API
+type PCloudBackend struct {
+	config config.PCloudConfig
+	client *http.Client
+}
+
+// NewPCloudBackend creates a backend for the account of the configured access token
+func NewPCloudBackend(cfg config.PCloudConfig, client *http.Client) *PCloudBackend {
+	cfg.APIURL = strings.TrimSuffix(cfg.APIURL, "/")
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &PCloudBackend{config: cfg, client: client}
+}
+
+// pcloudResult is the status every API response carries
+type pcloudResult struct {
+	Result int    `json:"result"`
+	Error  string `json:"error"`
+}
+
+// pcloudMetadata describes a file or folder
+type pcloudMetadata struct {
+	Name     string           `json:"name"`
+	IsFolder bool             `json:"isfolder"`
+	Size     int64            `json:"size"`
+	Modified string           `json:"modified"`
+	Hash     uint64           `json:"hash"` // Changes whenever the contents change
+	Contents []pcloudMetadata `json:"contents"`
+}
+
+// modTime parses the modification time of a file
+func (m pcloudMetadata) modTime() time.Time {
+	modified, _ := time.Parse(time.RFC1123Z, m.Modified)
+	return modified
+}
+
+// etag returns the entity tag of a file from its hash
+func (m pcloudMetadata) etag() string {
+	if m.Hash == 0 {
+		return ""
+	}
+	return fmt.Sprintf(`"%x"`, m.Hash)
+}
+
+// target returns the parameters addressing the file of a key: a file ID if the key is numeric, a path otherwise
+func (b *PCloudBackend) target(key string) url.Values {
+	if key != "" && strings.Trim(key, "0123456789") == "" {
+		return url.Values{"fileid": {key}}
+	}
+	return url.Values{"path": {"/" + strings.TrimPrefix(key, "/")}}
+}
+
+// Open returns a reader for a range of a file, downloaded from a link the API issues for it
+func (b *PCloudBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	var link struct {
+		pcloudResult
+		Hosts []string `json:"hosts"`
+		Path  string   `json:"path"`
+	}
+	if err := b.call(ctx, http.MethodGet, "getfilelink", b.target(key), nil, 0, &link); err != nil {
+		return nil, err
+	}
+	if len(link.Hosts) == 0 {
+		return nil, errors.New("pcloud getfilelink returned no hosts")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+link.Hosts[0]+link.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case length > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("pcloud download failed with status %s", resp.Status)
+	}
+	return rangeBody(resp, offset, length)
+}
+
+// Stat returns the size and modification time of a file
+func (b *PCloudBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	var stat struct {
+		pcloudResult
+		Metadata pcloudMetadata `json:"metadata"`
+	}
+	if err := b.call(ctx, http.MethodGet, "stat", b.target(key), nil, 0, &stat); err != nil {
+		return nil, err
+	}
+	if stat.Metadata.IsFolder {
+		return nil, ErrNotFound
+	}
+	return &ObjectInfo{Key: key, Size: stat.Metadata.Size, ModTime: stat.Metadata.modTime(), ETag: stat.Metadata.etag()}, nil
+}
+
+// Put uploads a file to a path, creating its folders as needed
+func (b *PCloudBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	params := b.target(key)
+	filePath := params.Get("path")
+	if filePath == "" || filePath == "/" {
+		return ErrInvalidKey
+	}
+	folder, name := path.Split(filePath)
+
+	// Create the folders one level at a time, as the API only creates the last component of a path
+	created := ""
+	for _, component := range strings.Split(strings.Trim(folder, "/"), "/") {
+		if component == "" {
+			continue
+		}
+		created += "/" + component
+		var result pcloudResult
+		if err := b.call(ctx, http.MethodGet, "createfolderifnotexists", url.Values{"path": {created}}, nil, 0, &result); err != nil {
+			return err
+		}
+	}
+
+	var result pcloudResult
+	upload := url.Values{"path": {folder}, "filename": {name}, "nopartial": {"1"}}
+	return b.call(ctx, http.MethodPut, "uploadfile", upload, r, size, &result)
+}
+
+// Delete removes a file. Deleting a file that does not exist is not an error.
+func (b *PCloudBackend) Delete(ctx context.Context, key string) error {
+	var result pcloudResult
+	if err := b.call(ctx, http.MethodGet, "deletefile", b.target(key), nil, 0, &result); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return nil
+}
+
+// List returns the files below the folder a path prefix names whose paths start with the prefix
+func (b *PCloudBackend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	absolute := strings.HasPrefix(prefix, "/")
+	prefix = "/" + strings.TrimPrefix(prefix, "/")
+	folder := prefix
+	if !strings.HasSuffix(folder, "/") {
+		folder = path.Dir(folder)
+	}
+
+	var listing struct {
+		pcloudResult
+		Metadata pcloudMetadata `json:"metadata"`
+	}
+	err := b.call(ctx, http.MethodGet, "listfolder", url.Values{"path": {folder}, "recursive": {"1"}}, nil, 0, &listing)
+	if errors.Is(err, ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	var walk func(dir string, entries []pcloudMetadata)
+	walk = func(dir string, entries []pcloudMetadata) {
+		for _, entry := range entries {
+			entryPath := path.Join(dir, entry.Name)
+			if entry.IsFolder {
+				walk(entryPath, entry.Contents)
+				continue
+			}
+			if !strings.HasPrefix(entryPath, prefix) {
+				continue
+			}
+			key := entryPath
+			if !absolute {
+				key = strings.TrimPrefix(key, "/")
+			}
+			objects = append(objects, ObjectInfo{Key: key, Size: entry.Size, ModTime: entry.modTime(), ETag: entry.etag()})
+		}
+	}
+	walk(folder, listing.Metadata.Contents)
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// call invokes an API method and decodes its response into dest, which must embed pcloudResult.
+// Failures reported by the API are turned into errors, ErrNotFound for paths that do not exist.
+func (b *PCloudBackend) call(ctx context.Context, method, name string, params url.Values, body io.Reader, size int64, dest interface{}) error {
+	query := url.Values{}
+	for key, values := range params {
+		query[key] = values
+	}
+	query.Set("access_token", b.config.AccessToken)
+
+	if body == nil {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, b.config.APIURL+"/"+name+"?"+query.Encode(), body)
+	if err != nil {
+		return err
+	}
+	if size >= 0 {
+		req.ContentLength = size
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("pcloud %s failed with status %s", name, resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	var result pcloudResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return err
+	}
+	if result.Result != 0 {
+		if pcloudNotFound[result.Result] {
+			return ErrNotFound
+		}
+		return fmt.Errorf("pcloud %s failed: %d: %s", name, result.Result, result.Error)
+	}
+	return json.Unmarshal(data, dest)
+}
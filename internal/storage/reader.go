@@ -0,0 +1,104 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// maxSkip is the largest forward seek that is served by reading ahead instead of reopening the file
+const maxSkip = 64 << 10
+
+// Reader reads a stored file with random access. The file is opened from the current offset on the first
+// read, and reopened at the new offset on the first read after a seek.
+type Reader struct {
+	ctx     context.Context
+	backend StorageBackend
+	key     string
+	info    ObjectInfo
+	offset  int64
+	body    io.ReadCloser // Open at offset, nil if the file has not been opened there
+}
+
+// NewReader returns a Reader for a file of a backend
+func NewReader(ctx context.Context, backend StorageBackend, key string) (*Reader, error) {
+	info, err := backend.Stat(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{ctx: ctx, backend: backend, key: key, info: *info}, nil
+}
+
+// Size returns the size of the file in bytes
+func (r *Reader) Size() int64 {
+	return r.info.Size
+}
+
+// ModTime returns the modification time of the file, zero if the backend does not know it
+func (r *Reader) ModTime() time.Time {
+	return r.info.ModTime
+}
+
+// ETag returns the entity tag the backend has for the file, empty if it has none
+func (r *Reader) ETag() string {
+	return r.info.ETag
+}
+
+// Read reads from the file at the current offset
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.info.Size {
+		return 0, io.EOF
+	}
+	if r.body == nil {
+		body, err := r.backend.Open(r.ctx, r.key, r.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		r.body = body
+	}
+
+	n, err := r.body.Read(p)
+	r.offset += int64(n)
+	if errors.Is(err, io.EOF) && r.offset < r.info.Size {
+		return n, io.ErrUnexpectedEOF
+	}
+	return n, err
+}
+
+// Seek sets the offset of the next read
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.info.Size
+	}
+	if offset < 0 {
+		return r.offset, errors.New("storage: negative position")
+	}
+
+	if r.body != nil && offset != r.offset {
+		// Short forward seeks skip ahead in the open file
+		if skip := offset - r.offset; skip > 0 && skip <= maxSkip {
+			if _, err := io.CopyN(io.Discard, r.body, skip); err == nil {
+				r.offset = offset
+				return offset, nil
+			}
+		}
+		r.body.Close()
+		r.body = nil
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// Close closes the file
+func (r *Reader) Close() error {
+	if r.body == nil {
+		return nil
+	}
+	err := r.body.Close()
+	r.body = nil
+	return err
+}
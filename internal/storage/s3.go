@@ -0,0 +1,376 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+)
+
+// SchemeS3 is the URL scheme of files in an S3-compatible object store, e.g. "s3://bucket/path/to/file.mp3"
+const SchemeS3 = "s3"
+
+// unsignedPayload is the payload hash of requests whose body is not signed
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// S3Backend stores files in an S3-compatible object store. Keys start with the bucket, followed by a slash
+// and the object key. Requests are signed with AWS Signature Version 4.
+type S3Backend struct {
+	config   config.S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Backend creates a backend for the object store at the configured endpoint
+func NewS3Backend(cfg config.S3Config, client *http.Client) (*S3Backend, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return nil, fmt.Errorf("invalid S3 endpoint %q", cfg.Endpoint)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &S3Backend{config: cfg, endpoint: endpoint, client: client}, nil
+}
+
+// split splits a key into its bucket and object key
+func (b *S3Backend) split(key string) (string, string, error) {
+	bucket, object, _ := strings.Cut(strings.TrimPrefix(key, "/"), "/")
+	if bucket == "" {
+		return "", "", ErrInvalidKey
+	}
+	return bucket, object, nil
+}
+
+// Open returns a reader for a range of an object
+func (b *S3Backend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	bucket, object, err := b.split(key)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	header := http.Header{}
+	switch {
+	case length > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	case offset > 0:
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := b.do(ctx, http.MethodGet, bucket, object, nil, header, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	return rangeBody(resp, offset, length)
+}
+
+// Stat returns the size and modification time of an object
+func (b *S3Backend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	bucket, object, err := b.split(key)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := b.do(ctx, http.MethodHead, bucket, object, nil, nil, nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	info := &ObjectInfo{Key: key, Size: resp.ContentLength, ETag: resp.Header.Get("ETag")}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		info.ModTime = modified
+	}
+	return info, nil
+}
+
+// Put uploads an object. Uploads of unknown size are spooled to a temporary file first, as the object
+// store needs the length up front.
+func (b *S3Backend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	bucket, object, err := b.split(key)
+	if err != nil {
+		return err
+	}
+	if object == "" {
+		return ErrInvalidKey
+	}
+
+	if size < 0 {
+		spool, err := os.CreateTemp("", "musync-s3-*")
+		if err != nil {
+			return err
+		}
+		defer os.Remove(spool.Name())
+		defer spool.Close()
+		if size, err = io.Copy(spool, r); err != nil {
+			return err
+		}
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		r = spool
+	}
+
+	resp, err := b.do(ctx, http.MethodPut, bucket, object, nil, nil, r, size)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Delete removes an object. Deleting an object that does not exist is not an error.
+func (b *S3Backend) Delete(ctx context.Context, key string) error {
+	bucket, object, err := b.split(key)
+	if err != nil {
+		return err
+	}
+	resp, err := b.do(ctx, http.MethodDelete, bucket, object, nil, nil, nil, 0)
+	if errors.Is(err, ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// listBucketResult is the response to a ListObjectsV2 request
+type listBucketResult struct {
+	Contents []struct {
+		Key          string
+		Size         int64
+		LastModified time.Time
+		ETag         string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// List returns the objects of a bucket whose keys start with the object key part of prefix
+func (b *S3Backend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	bucket, objectPrefix, err := b.split(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var objects []ObjectInfo
+	query := url.Values{"list-type": {"2"}, "prefix": {objectPrefix}}
+	for {
+		resp, err := b.do(ctx, http.MethodGet, bucket, "", query, nil, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		var result listBucketResult
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			objects = append(objects, ObjectInfo{Key: bucket + "/" + object.Key, Size: object.Size, ModTime: object.LastModified, ETag: object.ETag})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	return objects, nil
+}
+
+// s3Error is the body of an error response
+type s3Error struct {
+	Code    string
+	Message string
+}
+
+// do sends a signed request for an object, or for a bucket if object is empty. Responses with a status
+// other than 2xx are turned into errors, ErrNotFound for 404.
+func (b *S3Backend) do(ctx context.Context, method, bucket, object string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Response, error) {
+	req, err := b.request(ctx, method, bucket, object, query, header, body, size)
+	if err != nil {
+		return nil, err
+	}
+	b.sign(req, unsignedPayload, time.Now())
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	var apiErr s3Error
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&apiErr); err != nil || apiErr.Code == "" {
+		return nil, fmt.Errorf("s3 %s %s/%s failed with status %s", method, bucket, object, resp.Status)
+	}
+	return nil, fmt.Errorf("s3 %s %s/%s failed: %s: %s", method, bucket, object, apiErr.Code, apiErr.Message)
+}
+
+// request creates an unsigned request for an object, or for a bucket if object is empty, addressing the
+// bucket in the path or in the host name as configured
+func (b *S3Backend) request(ctx context.Context, method, bucket, object string, query url.Values, header http.Header, body io.Reader, size int64) (*http.Request, error) {
+	u := *b.endpoint
+	if b.config.PathStyle {
+		u.Path += "/" + bucket + "/" + object
+	} else {
+		u.Host = bucket + "." + u.Host
+		u.Path += "/" + object
+	}
+	u.RawPath = uriEncode(u.Path, false)
+	u.RawQuery = canonicalQuery(query)
+
+	if body == nil || size == 0 {
+		body = http.NoBody
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = size
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	return req, nil
+}
+
+// sign adds the AWS Signature Version 4 authorization of a request. The host, range and x-amz-* headers
+// are signed.
+func (b *S3Backend) sign(req *http.Request, payloadHash string, now time.Time) {
+	timestamp := now.UTC().Format("20060102T150405Z")
+	date := timestamp[:8]
+	req.Header.Set("X-Amz-Date", timestamp)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		name = strings.ToLower(name)
+		if name == "range" || strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + b.config.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + timestamp + "\n" + scope + "\n" + hexSHA256(canonicalRequest)
+
+	key := hmacSHA256([]byte("AWS4"+b.config.SecretKey), date)
+	key = hmacSHA256(key, b.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+b.config.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQuery encodes query parameters sorted by name, as signatures require
+func canonicalQuery(query url.Values) string {
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, name := range names {
+		values := append([]string(nil), query[name]...)
+		sort.Strings(values)
+		for _, value := range values {
+			parts = append(parts, uriEncode(name, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// uriEncode percent-encodes all bytes but the unreserved characters of RFC 3986, and slashes unless
+// encodeSlash is set
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '.', c == '_', c == '~', c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			encoded.WriteString("%" + strings.ToUpper(strconv.FormatUint(uint64(c)|0x100, 16)[1:]))
+		}
+	}
+	return encoded.String()
+}
+
+// hexSHA256 returns the hex encoded SHA-256 hash of s
+func hexSHA256(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data with key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// rangeBody returns the body of a response to a range request. If the server ignored the range and sent
+// the whole file, the bytes before offset are skipped and the body is cut to length.
+func rangeBody(resp *http.Response, offset, length int64) (io.ReadCloser, error) {
+	if resp.StatusCode == http.StatusPartialContent || (offset == 0 && length < 0) {
+		return resp.Body, nil
+	}
+	if offset > 0 {
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+	}
+	if length < 0 {
+		return resp.Body, nil
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{io.LimitReader(resp.Body, length), resp.Body}, nil
+}
@@ -0,0 +1,164 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// UserPrefix returns the directory the files of a user lie in, in backends shared by all users
+func UserPrefix(userID uint) string {
+	return "users/" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// scoper is implemented by backends shared by all users, to return a view confined to a directory
+type scoper interface {
+	scope(prefix string) StorageBackend
+}
+
+// Scope returns a registry resolving the locations of a user's tracks. Its backends are confined to the
+// user's directory, see UserPrefix, so that a location can only name the user's own files:
+//
+//   - file:///Music/a.mp3 is read from <local root>/users/<id>/Music/a.mp3
+//   - s3://bucket/Music/a.mp3 is read from the object users/<id>/Music/a.mp3 of the bucket
+//   - pcloud:///Music/a.mp3 is read from /users/<id>/Music/a.mp3; file IDs are refused
+//
+// Managed storage is left out: its files are shared by content hash, and are only served to the users
+// who uploaded them.
+func (r *Registry) Scope(userID uint) *Registry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	scoped := NewRegistry()
+	prefix := UserPrefix(userID)
+	for scheme, backend := range r.backends {
+		if s, ok := backend.(scoper); ok && scheme != SchemeManaged {
+			scoped.backends[scheme] = s.scope(prefix)
+		}
+	}
+	return scoped
+}
+
+// cleanKey returns a key as a relative slash separated path, with "." and ".." elements resolved so that
+// it cannot leave the directory it is joined to
+func cleanKey(key string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(key, "/")), "/")
+}
+
+// scopedBackend confines the keys of a backend to a directory
+type scopedBackend struct {
+	backend StorageBackend
+	key     func(key string) (string, error) // Key in the backend of a key of the view
+	unkey   func(key string) string          // Key in the view of a key listed by the backend, nil if the same
+}
+
+// Open returns a reader for a range of a file of the directory
+func (b *scopedBackend) Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	scoped, err := b.key(key)
+	if err != nil {
+		return nil, err
+	}
+	return b.backend.Open(ctx, scoped, offset, length)
+}
+
+// Stat returns the size and modification time of a file of the directory
+func (b *scopedBackend) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	scoped, err := b.key(key)
+	if err != nil {
+		return nil, err
+	}
+	info, err := b.backend.Stat(ctx, scoped)
+	if err != nil {
+		return nil, err
+	}
+	info.Key = key
+	return info, nil
+}
+
+// Put stores a file in the directory
+func (b *scopedBackend) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	scoped, err := b.key(key)
+	if err != nil {
+		return err
+	}
+	return b.backend.Put(ctx, scoped, r, size)
+}
+
+// Delete removes a file of the directory
+func (b *scopedBackend) Delete(ctx context.Context, key string) error {
+	scoped, err := b.key(key)
+	if err != nil {
+		return err
+	}
+	return b.backend.Delete(ctx, scoped)
+}
+
+// List returns the files of the directory whose keys start with prefix, with keys relative to the directory
+func (b *scopedBackend) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	scoped, err := b.key(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasSuffix(prefix, "/") && !strings.HasSuffix(scoped, "/") {
+		scoped += "/"
+	}
+	objects, err := b.backend.List(ctx, scoped)
+	if err != nil {
+		return nil, err
+	}
+	if b.unkey != nil {
+		for i := range objects {
+			objects[i].Key = b.unkey(objects[i].Key)
+		}
+	}
+	return objects, nil
+}
+
+// scope returns a view of the backend rooted at a subdirectory of its root. Keys are paths relative to the
+// subdirectory, whether or not they start with a slash.
+func (b *LocalBackend) scope(prefix string) StorageBackend {
+	return &scopedBackend{
+		backend: NewLocalBackend(filepath.Join(b.root, filepath.FromSlash(prefix))),
+		key: func(key string) (string, error) {
+			return cleanKey(key), nil
+		},
+	}
+}
+
+// scope returns a view of the backend confined to the objects under a prefix, in every bucket
+func (b *S3Backend) scope(prefix string) StorageBackend {
+	return &scopedBackend{
+		backend: b,
+		key: func(key string) (string, error) {
+			bucket, object, err := b.split(key)
+			if err != nil {
+				return "", err
+			}
+			return bucket + "/" + prefix + "/" + cleanKey(object), nil
+		},
+		unkey: func(key string) string {
+			bucket, object, _ := strings.Cut(key, "/")
+			return bucket + "/" + strings.TrimPrefix(object, prefix+"/")
+		},
+	}
+}
+
+// scope returns a view of the backend confined to a folder. Files cannot be addressed by ID, as the
+// folder they are in is not known without asking the API.
+func (b *PCloudBackend) scope(prefix string) StorageBackend {
+	return &scopedBackend{
+		backend: b,
+		key: func(key string) (string, error) {
+			if _, byID := b.target(key)["fileid"]; byID {
+				return "", ErrInvalidKey
+			}
+			return "/" + prefix + "/" + cleanKey(key), nil
+		},
+		unkey: func(key string) string {
+			return "/" + strings.TrimPrefix(key, "/"+prefix+"/")
+		},
+	}
+}
@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/dinis/musync/internal/config"
+)
+
+// writeFile creates a file and its directories below dir
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// readKey reads a whole file of a backend
+func readKey(backend StorageBackend, key string) (string, error) {
+	body, err := backend.Open(context.Background(), key, 0, -1)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	data, err := io.ReadAll(body)
+	return string(data), err
+}
+
+func TestLocalBackendRejectsPathsOutsideRoot(t *testing.T) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "root")
+	writeFile(t, root, "Music/a.mp3", "inside")
+	writeFile(t, dir, "secret.txt", "outside")
+	if err := os.Symlink(filepath.Join(dir, "secret.txt"), filepath.Join(root, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+	backend := NewLocalBackend(root)
+
+	tests := []struct {
+		key  string
+		want string
+		err  error
+	}{
+		{"Music/a.mp3", "inside", nil},
+		{filepath.ToSlash(root) + "/Music/a.mp3", "inside", nil},
+		{"Music/../Music/a.mp3", "inside", nil},
+		{"../secret.txt", "", ErrInvalidKey},
+		{"Music/../../secret.txt", "", ErrInvalidKey},
+		{filepath.ToSlash(dir) + "/secret.txt", "", ErrInvalidKey},
+		{"/etc/passwd", "", ErrInvalidKey},
+		{"link.txt", "", ErrInvalidKey},
+		{"Music/missing.mp3", "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			got, err := readKey(backend, tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if err := backend.Put(context.Background(), "../escaped.txt", strings.NewReader("x"), 1); !errors.Is(err, ErrInvalidKey) {
+		t.Errorf("Put outside the root = %v, want ErrInvalidKey", err)
+	}
+}
+
+func TestCleanKey(t *testing.T) {
+	tests := map[string]string{
+		"":                      "",
+		"/":                     "",
+		"a.mp3":                 "a.mp3",
+		"/Music/a.mp3":          "Music/a.mp3",
+		"Music//./a.mp3":        "Music/a.mp3",
+		"../a.mp3":              "a.mp3",
+		"/Music/../../../a.mp3": "a.mp3",
+		"Music/../users/2/a":    "users/2/a",
+	}
+	for key, want := range tests {
+		if got := cleanKey(key); got != want {
+			t.Errorf("cleanKey(%q) = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestScopeConfinesLocalFilesToTheUser(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "users/1/Music/a.mp3", "user 1")
+	writeFile(t, root, "users/2/Music/a.mp3", "user 2")
+	writeFile(t, root, "shared.mp3", "shared")
+
+	registry := NewRegistry()
+	registry.Register(SchemeFile, NewLocalBackend(root))
+	registry.Register(SchemeManaged, NewLocalBackend(t.TempDir()))
+	scoped := registry.Scope(1)
+
+	tests := []struct {
+		location string
+		want     string
+		err      error
+	}{
+		{"file:///Music/a.mp3", "user 1", nil},
+		{"file://localhost/Music/a.mp3", "user 1", nil},
+		{"Music/a.mp3", "user 1", nil},
+		{"file:///../2/Music/a.mp3", "", ErrNotFound},
+		{"file://localhost/Music/../../../users/2/Music/a.mp3", "", ErrNotFound},
+		{"file://localhost/%2E%2E/%2E%2E/shared.mp3", "", ErrNotFound},
+		{"file://localhost" + filepath.ToSlash(root) + "/users/2/Music/a.mp3", "", ErrNotFound},
+		{"managed://sha256/ab/abcd", "", ErrUnknownScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			backend, key, err := scoped.Resolve(tt.location)
+			var got string
+			if err == nil {
+				got, err = readKey(backend, key)
+			}
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("read %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScopedKeys(t *testing.T) {
+	s3, err := NewS3Backend(config.S3Config{Endpoint: "http://localhost:9000"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pcloud := NewPCloudBackend(config.PCloudConfig{APIURL: "https://api.pcloud.com"}, nil)
+
+	tests := []struct {
+		name    string
+		backend StorageBackend
+		key     string
+		want    string
+		err     error
+	}{
+		{"s3 object", s3.scope(UserPrefix(7)), "bucket/Music/a.mp3", "bucket/users/7/Music/a.mp3", nil},
+		{"s3 escape", s3.scope(UserPrefix(7)), "bucket/../../users/8/a.mp3", "bucket/users/7/users/8/a.mp3", nil},
+		{"s3 without bucket", s3.scope(UserPrefix(7)), "/", "", ErrInvalidKey},
+		{"pcloud path", pcloud.scope(UserPrefix(7)), "/Music/a.mp3", "/users/7/Music/a.mp3", nil},
+		{"pcloud escape", pcloud.scope(UserPrefix(7)), "/Music/../../a.mp3", "/users/7/a.mp3", nil},
+		{"pcloud file ID", pcloud.scope(UserPrefix(7)), "1234567", "", ErrInvalidKey},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.backend.(*scopedBackend).key(tt.key)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if got != tt.want {
+				t.Errorf("key = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dinis/musync/internal/config"
+)
+
+// Storage errors
+var (
+	ErrNotFound      = errors.New("file not found in storage")
+	ErrInvalidKey    = errors.New("invalid storage key")
+	ErrUnknownScheme = errors.New("no storage backend for location")
+	ErrUnsafeRoot    = errors.New("storage directory must be set and must not be the file system root")
+)
+
+// ObjectInfo describes a stored file
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+	ETag    string // Entity tag of the contents, quoted as in HTTP, empty if the backend has none
+}
+
+// StorageBackend stores files under keys. Keys are the part of a location after its scheme, see Resolve.
+type StorageBackend interface {
+	// Open returns a reader for length bytes of a file starting at offset, or for the rest of the file if
+	// length is negative
+	Open(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Stat returns the size and modification time of a file
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Put stores size bytes read from r as a file, replacing any file with the same key
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Delete removes a file. Deleting a file that does not exist is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns the files whose keys start with prefix, ordered by key
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+}
+
+// SchemeManaged is the URL scheme of track files uploaded to server-managed storage
+const SchemeManaged = "managed"
+
+// ContentKey returns the key a file is stored under in managed storage, from the hex encoded SHA-256 of
+// its contents. Files are spread over directories named after the first two digits of the hash.
+func ContentKey(hash string) string {
+	if len(hash) < 2 {
+		return "sha256/" + hash
+	}
+	return "sha256/" + hash[:2] + "/" + hash
+}
+
+// Registry maps the URL schemes of track locations to the backends that store their files
+type Registry struct {
+	mu       sync.RWMutex
+	backends map[string]StorageBackend
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{backends: make(map[string]StorageBackend)}
+}
+
+// Register adds a backend for a URL scheme, replacing any backend registered for it
+func (r *Registry) Register(scheme string, backend StorageBackend) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.backends[strings.ToLower(scheme)] = backend
+}
+
+// Backend returns the backend registered for a URL scheme
+func (r *Registry) Backend(scheme string) (StorageBackend, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	backend, ok := r.backends[strings.ToLower(scheme)]
+	return backend, ok
+}
+
+// Schemes returns the registered URL schemes in alphabetical order
+func (r *Registry) Schemes() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	schemes := make([]string, 0, len(r.backends))
+	for scheme := range r.backends {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// Resolve returns the backend storing the file at a location, and the file's key in it.
+//
+// The key is the URL-decoded part of the location after "scheme://". For file URLs the host, which is
+// empty or "localhost", is dropped, so that the key is the absolute path. Locations without a scheme are
+// taken to be file paths.
+func (r *Registry) Resolve(location string) (StorageBackend, string, error) {
+	scheme, rest, found := strings.Cut(location, "://")
+	if !found {
+		scheme, rest = SchemeFile, location
+	} else if strings.EqualFold(scheme, SchemeFile) {
+		rest = strings.TrimPrefix(rest, "localhost")
+	}
+	if decoded, err := url.PathUnescape(rest); err == nil {
+		rest = decoded
+	}
+
+	backend, ok := r.Backend(scheme)
+	if !ok {
+		return nil, "", ErrUnknownScheme
+	}
+	return backend, rest, nil
+}
+
+// NewRegistryFromConfig creates a registry with a local backend sandboxed to the configured root, a local
+// backend for managed storage, and the S3-compatible and pCloud backends if they are configured.
+// It fails with ErrUnsafeRoot if either directory is empty or the root of the file system.
+func NewRegistryFromConfig(cfg config.StorageConfig) (*Registry, error) {
+	local, err := newSandboxedBackend(cfg.LocalRoot)
+	if err != nil {
+		return nil, fmt.Errorf("STORAGE_LOCAL_ROOT: %w", err)
+	}
+	managed, err := newSandboxedBackend(cfg.ManagedDir)
+	if err != nil {
+		return nil, fmt.Errorf("STORAGE_MANAGED_DIR: %w", err)
+	}
+
+	registry := NewRegistry()
+	registry.Register(SchemeFile, local)
+	registry.Register(SchemeManaged, managed)
+
+	if cfg.S3.Endpoint != "" {
+		backend, err := NewS3Backend(cfg.S3, nil)
+		if err != nil {
+			return nil, err
+		}
+		registry.Register(SchemeS3, backend)
+	}
+	if cfg.PCloud.AccessToken != "" {
+		registry.Register(SchemePCloud, NewPCloudBackend(cfg.PCloud, nil))
+	}
+	return registry, nil
+}
+
+// newSandboxedBackend creates a local backend for a directory, refusing an empty directory or the root
+// of the file system, which would expose every file of the server
+func newSandboxedBackend(root string) (*LocalBackend, error) {
+	if strings.TrimSpace(root) == "" {
+		return nil, ErrUnsafeRoot
+	}
+	backend := NewLocalBackend(root)
+	if filepath.Dir(backend.Root()) == backend.Root() {
+		return nil, ErrUnsafeRoot
+	}
+	return backend, nil
+}
@@ -0,0 +1,75 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/dinis/musync/internal/config"
+)
+
+func TestRegistryResolve(t *testing.T) {
+	local := NewLocalBackend(t.TempDir())
+	managed := NewLocalBackend(t.TempDir())
+	registry := NewRegistry()
+	registry.Register(SchemeFile, local)
+	registry.Register("Managed", managed)
+
+	tests := []struct {
+		location string
+		backend  StorageBackend
+		key      string
+		err      error
+	}{
+		{"/Music/a.mp3", local, "/Music/a.mp3", nil},
+		{"file:///Music/a.mp3", local, "/Music/a.mp3", nil},
+		{"file://localhost/Music/My%20Track.mp3", local, "/Music/My Track.mp3", nil},
+		{"FILE://localhost/Music/a.mp3", local, "/Music/a.mp3", nil},
+		{"file://localhost/C:/Music/a.mp3", local, "/C:/Music/a.mp3", nil},
+		{"managed://sha256/ab/abcd", managed, "sha256/ab/abcd", nil},
+		{"MANAGED://sha256/ab/abcd", managed, "sha256/ab/abcd", nil},
+		{"file://localhost/Music/100%.mp3", local, "/Music/100%.mp3", nil},
+		{"s3://bucket/a.mp3", nil, "", ErrUnknownScheme},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			backend, key, err := registry.Resolve(tt.location)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if backend != tt.backend || key != tt.key {
+				t.Errorf("Resolve = %v, %q, want %v, %q", backend, key, tt.backend, tt.key)
+			}
+		})
+	}
+}
+
+func TestNewRegistryFromConfigRefusesUnsafeRoots(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name       string
+		localRoot  string
+		managedDir string
+		err        error
+	}{
+		{"sandboxed", filepath.Join(dir, "music"), filepath.Join(dir, "files"), nil},
+		{"empty local root", "", filepath.Join(dir, "files"), ErrUnsafeRoot},
+		{"blank local root", "  ", filepath.Join(dir, "files"), ErrUnsafeRoot},
+		{"file system root", "/", filepath.Join(dir, "files"), ErrUnsafeRoot},
+		{"file system root, not clean", "/music/..", filepath.Join(dir, "files"), ErrUnsafeRoot},
+		{"managed file system root", filepath.Join(dir, "music"), "/", ErrUnsafeRoot},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry, err := NewRegistryFromConfig(config.StorageConfig{LocalRoot: tt.localRoot, ManagedDir: tt.managedDir})
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil {
+				if got := registry.Schemes(); len(got) != 2 || got[0] != SchemeFile || got[1] != SchemeManaged {
+					t.Errorf("Schemes = %v", got)
+				}
+			}
+		})
+	}
+}
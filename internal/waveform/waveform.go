@@ -0,0 +1,134 @@
+package waveform
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/dinis/musync/internal/audio"
+)
+
+// Resolution limits of overviews, in points per track
+const (
+	MinResolution = 16
+	MaxResolution = 4096 // Resolution overviews are generated at, lower ones are downsampled from it
+)
+
+// blockFrames is the number of sample frames summarized in a block while decoding. Blocks are merged
+// into points once the length of the track is known.
+const blockFrames = 256
+
+// Overview is the amplitude envelope of a track. Each point covers an equal share of the track and holds the
+// peak and RMS amplitude of its samples across all channels, scaled from 0 to 255.
+type Overview struct {
+	Format     string // Container format of the file
+	SampleRate int
+	Duration   float64 // In seconds
+	Peaks      []uint8
+	RMS        []uint8
+}
+
+// block summarizes consecutive samples
+type block struct {
+	peak       float64
+	sumSquares float64
+	samples    int
+}
+
+// Generate decodes a WAV, AIFF, FLAC or MP3 file and computes its overview at MaxResolution points, or at
+// one point per block of samples for very short files.
+func Generate(r io.ReadSeeker) (*Overview, error) {
+	format, err := audio.Detect(r)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder, err := audio.NewDecoder(r)
+	if err != nil {
+		return nil, err
+	}
+	info := decoder.Info()
+	blocks, err := decodeBlocks(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if info.Frames == 0 {
+		info.Frames = int64(len(blocks)) * blockFrames
+	}
+
+	overview := &Overview{Format: format, SampleRate: info.SampleRate}
+	if info.SampleRate > 0 {
+		overview.Duration = float64(info.Frames) / float64(info.SampleRate)
+	}
+	overview.Peaks, overview.RMS = points(blocks, MaxResolution)
+	return overview, nil
+}
+
+// decodeBlocks reads all samples of a decoder and summarizes them in blocks of blockFrames frames
+func decodeBlocks(decoder audio.Decoder) ([]block, error) {
+	channels := decoder.Info().Channels
+	samples := make([]float32, blockFrames*channels)
+
+	var blocks []block
+	for {
+		n, err := decoder.Read(samples)
+		if n > 0 {
+			var b block
+			for _, sample := range samples[:n] {
+				value := float64(sample)
+				b.peak = math.Max(b.peak, math.Abs(value))
+				b.sumSquares += value * value
+			}
+			b.samples = n
+			blocks = append(blocks, b)
+		}
+		if errors.Is(err, io.EOF) {
+			return blocks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// points merges blocks into at most resolution points of equal length and quantizes them
+func points(blocks []block, resolution int) ([]uint8, []uint8) {
+	count := min(resolution, len(blocks))
+	peaks := make([]uint8, count)
+	rms := make([]uint8, count)
+	for i := 0; i < count; i++ {
+		var merged block
+		for _, b := range blocks[i*len(blocks)/count : (i+1)*len(blocks)/count] {
+			merged.peak = math.Max(merged.peak, b.peak)
+			merged.sumSquares += b.sumSquares
+			merged.samples += b.samples
+		}
+		peaks[i] = quantize(merged.peak)
+		if merged.samples > 0 {
+			rms[i] = quantize(math.Sqrt(merged.sumSquares / float64(merged.samples)))
+		}
+	}
+	return peaks, rms
+}
+
+// Resample returns the overview downsampled to at most resolution points. Peaks keep their maximum and RMS
+// amplitudes are combined by power.
+func (o *Overview) Resample(resolution int) *Overview {
+	if resolution >= len(o.Peaks) {
+		return o
+	}
+
+	blocks := make([]block, len(o.Peaks))
+	for i := range o.Peaks {
+		value := float64(o.RMS[i]) / 255
+		blocks[i] = block{peak: float64(o.Peaks[i]) / 255, sumSquares: value * value, samples: 1}
+	}
+	resampled := *o
+	resampled.Peaks, resampled.RMS = points(blocks, resolution)
+	return &resampled
+}
+
+// quantize scales an amplitude from 0 to 1 to a byte, clipping louder samples
+func quantize(amplitude float64) uint8 {
+	return uint8(math.Round(math.Min(amplitude, 1) * 255))
+}
@@ -0,0 +1,131 @@
+package waveform
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/dinis/musync/internal/audio"
+)
+
+// wavHeader is the header of a 16-bit PCM WAV file
+type wavHeader struct {
+	RIFF          [4]byte
+	FileSize      uint32
+	WAVE, Fmt     [4]byte
+	FmtSize       uint32
+	Format        uint16
+	Channels      uint16
+	SampleRate    uint32
+	ByteRate      uint32
+	BlockAlign    uint16
+	BitsPerSample uint16
+	Data          [4]byte
+	DataSize      uint32
+}
+
+// wavFile encodes interleaved samples as a 16-bit PCM WAV file
+func wavFile(rate, channels int, samples []int16) []byte {
+	var buf bytes.Buffer
+	binary.Write(&buf, binary.LittleEndian, wavHeader{
+		RIFF:          [4]byte{'R', 'I', 'F', 'F'},
+		FileSize:      uint32(36 + 2*len(samples)),
+		WAVE:          [4]byte{'W', 'A', 'V', 'E'},
+		Fmt:           [4]byte{'f', 'm', 't', ' '},
+		FmtSize:       16,
+		Format:        1,
+		Channels:      uint16(channels),
+		SampleRate:    uint32(rate),
+		ByteRate:      uint32(2 * channels * rate),
+		BlockAlign:    uint16(2 * channels),
+		BitsPerSample: 16,
+		Data:          [4]byte{'d', 'a', 't', 'a'},
+		DataSize:      uint32(2 * len(samples)),
+	})
+	binary.Write(&buf, binary.LittleEndian, samples)
+	return buf.Bytes()
+}
+
+// steppedSignal returns eight blocks of stereo samples. In block k every other frame has the amplitude k/8,
+// alternately positive and negative, and the frames in between are silent.
+func steppedSignal() []int16 {
+	var samples []int16
+	for k := 0; k < 8; k++ {
+		for frame := 0; frame < blockFrames; frame++ {
+			var value int16
+			if frame%2 == 0 {
+				value = int16(k * 4096)
+				if frame%4 == 2 {
+					value = -value
+				}
+			}
+			samples = append(samples, value, value)
+		}
+	}
+	return samples
+}
+
+func TestGenerate(t *testing.T) {
+	overview, err := Generate(bytes.NewReader(wavFile(8000, 2, steppedSignal())))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if overview.Format != audio.FormatWAV || overview.SampleRate != 8000 {
+		t.Errorf("format = %s at %d Hz, want %s at 8000 Hz", overview.Format, overview.SampleRate, audio.FormatWAV)
+	}
+	if math.Abs(overview.Duration-8*blockFrames/8000.0) > 1e-9 {
+		t.Errorf("Duration = %v, want %v", overview.Duration, 8*blockFrames/8000.0)
+	}
+
+	// A short file has a point per block. Peaks are k/8 and RMS amplitudes k/8/sqrt(2), scaled to 255.
+	if want := []uint8{0, 32, 64, 96, 128, 159, 191, 223}; !reflect.DeepEqual(overview.Peaks, want) {
+		t.Errorf("Peaks = %v, want %v", overview.Peaks, want)
+	}
+	if want := []uint8{0, 23, 45, 68, 90, 113, 135, 158}; !reflect.DeepEqual(overview.RMS, want) {
+		t.Errorf("RMS = %v, want %v", overview.RMS, want)
+	}
+}
+
+func TestGenerateClipsLoudSamples(t *testing.T) {
+	samples := make([]int16, blockFrames)
+	for i := range samples {
+		samples[i] = math.MinInt16
+	}
+	overview, err := Generate(bytes.NewReader(wavFile(8000, 1, samples)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(overview.Peaks, []uint8{255}) || !reflect.DeepEqual(overview.RMS, []uint8{255}) {
+		t.Errorf("Peaks = %v and RMS = %v, want 255", overview.Peaks, overview.RMS)
+	}
+}
+
+func TestResample(t *testing.T) {
+	overview := &Overview{
+		Peaks: []uint8{0, 32, 64, 96, 128, 159, 191, 223},
+		RMS:   []uint8{0, 23, 45, 68, 90, 113, 135, 158},
+	}
+
+	// Peaks keep the maximum of each pair of points, RMS amplitudes their root mean square
+	resampled := overview.Resample(4)
+	if want := []uint8{32, 96, 159, 223}; !reflect.DeepEqual(resampled.Peaks, want) {
+		t.Errorf("Peaks = %v, want %v", resampled.Peaks, want)
+	}
+	if want := []uint8{16, 58, 102, 147}; !reflect.DeepEqual(resampled.RMS, want) {
+		t.Errorf("RMS = %v, want %v", resampled.RMS, want)
+	}
+	if len(overview.Peaks) != 8 {
+		t.Errorf("Resample changed the original overview")
+	}
+
+	// Points of unequal length when the resolution does not divide the points
+	if want := []uint8{32, 128, 223}; !reflect.DeepEqual(overview.Resample(3).Peaks, want) {
+		t.Errorf("Peaks at 3 points = %v, want %v", overview.Resample(3).Peaks, want)
+	}
+
+	if overview.Resample(8) != overview || overview.Resample(MaxResolution) != overview {
+		t.Errorf("Resample to at least the number of points returned a copy")
+	}
+}
@@ -0,0 +1,39 @@
+import React from 'react';
+import { Waveform } from '../services/library';
+
+interface WaveformOverviewProps {
+  waveform: Waveform;
+  progress: number; // Played fraction of the track, from 0 to 1
+  onSeek: (fraction: number) => void;
+}
+
+// Draws the peak and RMS envelope of a track mirrored around its centre line, with the played part highlighted.
+// Clicking seeks to the clicked position.
+const WaveformOverview: React.FC<WaveformOverviewProps> = ({ waveform, progress, onSeek }) => {
+  const peaks = waveform.peaks ?? [];
+  const rms = waveform.rms ?? [];
+  const played = Math.round(progress * peaks.length);
+
+  const handleClick = (e: React.MouseEvent<SVGSVGElement>) => {
+    const bounds = e.currentTarget.getBoundingClientRect();
+    onSeek(Math.min(Math.max((e.clientX - bounds.left) / bounds.width, 0), 1));
+  };
+
+  return (
+    <svg
+      className="waveform-overview"
+      viewBox={`0 0 ${peaks.length} 256`}
+      preserveAspectRatio="none"
+      onClick={handleClick}
+    >
+      {peaks.map((peak, i) => (
+        <g key={i} className={i < played ? 'played' : undefined}>
+          <rect className="peak" x={i} y={128 - peak / 2} width={1} height={Math.max(peak, 1)} />
+          <rect className="rms" x={i} y={128 - (rms[i] ?? 0) / 2} width={1} height={rms[i] ?? 0} />
+        </g>
+      ))}
+    </svg>
+  );
+};
+
+export default WaveformOverview;
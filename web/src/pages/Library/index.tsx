@@ -79,11 +79,8 @@ const LibraryPage: React.FC = () => {
       setUploadError(null);
       setUploadSuccess(false);
 
-      // Convert file to base64
-      const base64Data = await libraryService.fileToBase64(uploadFile);
-
-      // Upload library
-      await libraryService.uploadLibrary(uploadName, base64Data);
+      // Upload library and wait for the import to finish
+      await libraryService.uploadLibrary(uploadName, uploadFile);
 
       // Reset form
       setUploadName('');
@@ -93,7 +90,7 @@ const LibraryPage: React.FC = () => {
       // Refresh libraries
       fetchLibraries();
     } catch (err: any) {
-      setUploadError(err.response?.data?.error || 'Failed to upload library');
+      setUploadError(err.response?.data?.error || err.message || 'Failed to upload library');
     } finally {
       setUploading(false);
     }
@@ -168,7 +165,7 @@ const LibraryPage: React.FC = () => {
               <input
                 type="file"
                 id="file"
-                accept=".xml"
+                accept=".xml,.nml,.m3u,.m3u8,.gz,.zip"
                 onChange={handleFileChange}
                 required
               />
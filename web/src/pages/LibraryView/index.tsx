@@ -1,16 +1,22 @@
 import React, { useEffect, useState, useRef } from 'react';
 import { useParams, useNavigate } from 'react-router-dom';
-import libraryService, { Library, Track, Playlist } from '../../services/library';
+import libraryService, { Library, Track, Playlist, PlaylistTrack, Waveform, MAX_TRACK_PAGE_SIZE } from '../../services/library';
 import AudioPlayer, { RHAP_UI } from 'react-h5-audio-player';
 import 'react-h5-audio-player/lib/styles.css';
 import './LibraryView.css';
 import { FaFolder, FaList, FaChevronRight, FaChevronDown } from 'react-icons/fa';
+import WaveformOverview from '../../components/WaveformOverview';
+
+// Number of points of the waveform shown in the player bar
+const WAVEFORM_RESOLUTION = 512;
 
 const LibraryView: React.FC = () => {
   const { id } = useParams<{ id: string }>();
   const navigate = useNavigate();
   const [library, setLibrary] = useState<Library | null>(null);
   const [tracks, setTracks] = useState<Track[]>([]);
+  const [totalTracks, setTotalTracks] = useState<number>(0);
+  const [loadingMoreTracks, setLoadingMoreTracks] = useState<boolean>(false);
   const [playlists, setPlaylists] = useState<Playlist[]>([]);
   const [loading, setLoading] = useState<boolean>(true);
   const [error, setError] = useState<string | null>(null);
@@ -18,7 +24,7 @@ const LibraryView: React.FC = () => {
   const [currentTrack, setCurrentTrack] = useState<Track | null>(null);
   const [isPlaying, setIsPlaying] = useState<boolean>(false);
   const [selectedPlaylist, setSelectedPlaylist] = useState<number | null>(null);
-  const [playlistTracks, setPlaylistTracks] = useState<Track[]>([]);
+  const [playlistTracks, setPlaylistTracks] = useState<PlaylistTrack[]>([]);
   const [loadingPlaylist, setLoadingPlaylist] = useState<boolean>(false);
   const [expandedFolders, setExpandedFolders] = useState<Set<number>>(new Set());
   const [selectedFolder, setSelectedFolder] = useState<number | null>(null);
@@ -30,6 +36,8 @@ const LibraryView: React.FC = () => {
   const [isLoadingTrack, setIsLoadingTrack] = useState<boolean>(false);
   const [trackError, setTrackError] = useState<string | null>(null);
   const [streamUrl, setStreamUrl] = useState<string>('');
+  const [waveform, setWaveform] = useState<Waveform | null>(null);
+  const playerRef = useRef<AudioPlayer>(null);
 
   useEffect(() => {
     if (!id) {
@@ -52,9 +60,10 @@ const LibraryView: React.FC = () => {
         const libraryData = await libraryService.getLibrary(libraryId);
         setLibrary(libraryData);
 
-        // Fetch tracks
-        const tracksData = await libraryService.getTracks(libraryId);
-        setTracks(tracksData);
+        // Fetch the first page of tracks
+        const tracksPage = await libraryService.getTracks(libraryId);
+        setTracks(tracksPage.items);
+        setTotalTracks(tracksPage.total);
 
         // Fetch playlists
         const playlistsData = await libraryService.getPlaylists(libraryId);
@@ -72,6 +81,46 @@ const LibraryView: React.FC = () => {
     fetchLibraryData();
   }, [id, navigate]);
 
+  // Load the waveform overview of the current track, which is generated in the background on first request
+  useEffect(() => {
+    setWaveform(null);
+    if (!currentTrack) return;
+
+    const controller = new AbortController();
+    libraryService
+      .getTrackWaveform(currentTrack.id, WAVEFORM_RESOLUTION, controller.signal)
+      .then(setWaveform)
+      .catch((err) => {
+        if (!controller.signal.aborted) {
+          console.warn('Waveform unavailable:', err);
+        }
+      });
+    return () => controller.abort();
+  }, [currentTrack]);
+
+  // Seek to a position of the current track, given as a fraction of its duration
+  const handleSeek = (fraction: number) => {
+    const audio = playerRef.current?.audio.current;
+    if (audio && duration > 0) {
+      audio.currentTime = fraction * duration;
+    }
+  };
+
+  // Load the next page of library tracks
+  const handleLoadMoreTracks = async () => {
+    if (!library) return;
+    try {
+      setLoadingMoreTracks(true);
+      const page = await libraryService.getTracks(library.id, { offset: tracks.length });
+      setTracks((previous) => [...previous, ...page.items]);
+      setTotalTracks(page.total);
+    } catch (err: any) {
+      setError(err.response?.data?.error || 'Failed to load more tracks');
+    } finally {
+      setLoadingMoreTracks(false);
+    }
+  };
+
   const handlePlayTrack = async (track: Track) => {
     const isCurrentTrack = currentTrack && currentTrack.id === track.id;
 
@@ -130,8 +179,8 @@ const LibraryView: React.FC = () => {
 
           // Load all tracks from the folder
           setLoadingFolder(true);
-          const tracks = await libraryService.getFolderTracks(playlistId);
-          setFolderTracks(tracks);
+          const page = await libraryService.getFolderTracks(playlistId, { limit: MAX_TRACK_PAGE_SIZE });
+          setFolderTracks(page.items);
           setSelectedFolder(playlistId);
         } catch (err: any) {
           setError(err.response?.data?.error || 'Failed to load folder tracks');
@@ -157,8 +206,8 @@ const LibraryView: React.FC = () => {
 
       // Load playlist tracks
       setLoadingPlaylist(true);
-      const tracks = await libraryService.getPlaylistTracks(playlistId);
-      setPlaylistTracks(tracks);
+      const page = await libraryService.getPlaylistTracks(playlistId, { limit: MAX_TRACK_PAGE_SIZE });
+      setPlaylistTracks(page.items);
       setSelectedPlaylist(playlistId);
     } catch (err: any) {
       setError(err.response?.data?.error || 'Failed to load playlist tracks');
@@ -270,7 +319,7 @@ const LibraryView: React.FC = () => {
             className={activeTab === 'tracks' ? 'active' : ''} 
             onClick={() => setActiveTab('tracks')}
           >
-            Tracks ({tracks.length})
+            Tracks ({totalTracks})
           </button>
           <button 
             className={activeTab === 'playlists' ? 'active' : ''} 
@@ -336,6 +385,11 @@ const LibraryView: React.FC = () => {
                 </tbody>
               </table>
             )}
+            {tracks.length < totalTracks && (
+              <button className="load-more-button" onClick={handleLoadMoreTracks} disabled={loadingMoreTracks}>
+                {loadingMoreTracks ? 'Loading...' : `Load more (${tracks.length} of ${totalTracks})`}
+              </button>
+            )}
           </div>
         )}
 
@@ -376,7 +430,7 @@ const LibraryView: React.FC = () => {
                     <tbody>
                       {playlistTracks.map((track) => (
                         <tr 
-                          key={track.id} 
+                          key={track.entry_id || `track-${track.id}`} 
                           className={currentTrack?.id === track.id ? 'playing' : ''}
                           onClick={() => handlePlayTrack(track)}
                         >
@@ -489,7 +543,16 @@ const LibraryView: React.FC = () => {
               <strong>{currentTrack.title}</strong> - {currentTrack.artist}
             </div>
 
+            {waveform && (
+              <WaveformOverview
+                waveform={waveform}
+                progress={duration > 0 ? currentTime / duration : 0}
+                onSeek={handleSeek}
+              />
+            )}
+
             <AudioPlayer
+              ref={playerRef}
               src={streamUrl}
               autoPlay={isPlaying}
               showSkipControls={false}
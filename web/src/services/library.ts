@@ -28,16 +28,95 @@ export interface Track {
   location_path?: string;
 }
 
+// A track at a position in a playlist; the same track may appear more than once.
+// Tracks of smart playlists are matched by rules and have no entry ID (0).
+export interface PlaylistTrack extends Track {
+  entry_id: number;
+  position: number;
+}
+
+// A page of a track listing together with the number of tracks matching the filters
+export interface TrackPage<T extends Track = Track> {
+  items: T[];
+  total: number;
+}
+
+// Filters, sorting and pagination of track listings
+export interface TrackListParams {
+  genre?: string;
+  key?: string;
+  artist?: string;
+  label?: string;
+  bpm_min?: number;
+  bpm_max?: number;
+  year_min?: number;
+  year_max?: number;
+  rating_min?: number;
+  rating_max?: number;
+  added_from?: string; // YYYY-MM-DD
+  added_to?: string; // YYYY-MM-DD
+  sort?: string; // Comma separated fields, prefixed with - for descending order
+  fields?: 'basic' | 'full';
+  limit?: number;
+  offset?: number;
+}
+
+// Largest page the track listings return
+export const MAX_TRACK_PAGE_SIZE = 1000;
+
+// Read a track listing response and its total count header
+const toTrackPage = <T extends Track>(response: { data: T[]; headers: any }): TrackPage<T> => ({
+  items: response.data,
+  total: Number(response.headers['x-total-count'] ?? response.data.length),
+});
+
 export interface Playlist {
   id: number;
   library_id: number;
   name: string;
-  type: number;
+  type: number; // 0 folder, 1 playlist, 2 smart playlist
+  rules?: unknown;
   parent_id?: number;
   created_at: string;
   updated_at: string;
 }
 
+export interface ImportJob {
+  id: number;
+  name: string;
+  source: string;
+  state: 'queued' | 'running' | 'completed' | 'failed';
+  tracks_processed: number;
+  tracks_total: number;
+  bytes_processed: number;
+  bytes_total: number;
+  warnings: string[];
+  error?: string;
+  library_id?: number;
+  created_at: string;
+  started_at?: string;
+  finished_at?: string;
+}
+
+// Waveform overview of a track; peaks and rms hold the amplitude of each point from 0 to 255
+export interface Waveform {
+  track_id: number;
+  state: 'queued' | 'running' | 'completed' | 'failed';
+  error?: string;
+  format?: string;
+  sample_rate?: number;
+  duration?: number;
+  resolution?: number;
+  peaks?: number[];
+  rms?: number[];
+}
+
+// How often a running import is polled
+const IMPORT_POLL_INTERVAL_MS = 1000;
+
+// How often a waveform that is being generated is polled
+const WAVEFORM_POLL_INTERVAL_MS = 2000;
+
 // Create the library service
 class LibraryService {
   // Get all libraries for the authenticated user
@@ -60,23 +139,48 @@ class LibraryService {
     }
   }
 
-  // Get all tracks in a library
-  async getTracks(libraryId: number): Promise<Track[]> {
+  // Get a page of the tracks in a library
+  async getTracks(libraryId: number, params?: TrackListParams): Promise<TrackPage> {
     try {
-      const response = await axios.get<Track[]>(`${API_URL}/libraries/${libraryId}/tracks`);
-      return response.data;
+      const response = await axios.get<Track[]>(`${API_URL}/libraries/${libraryId}/tracks`, { params });
+      return toTrackPage(response);
     } catch (error) {
       throw error;
     }
   }
 
-  // Upload a library XML file
-  async uploadLibrary(name: string, fileData: string): Promise<{ library_id: number }> {
+  // Upload a library file (optionally gzip or zip compressed) and wait for its background import to finish
+  async uploadLibrary(
+    name: string,
+    file: File,
+    onProgress?: (job: ImportJob) => void
+  ): Promise<{ library_id: number }> {
     try {
-      const response = await axios.post(`${API_URL}/libraries`, {
-        name,
-        file_data: fileData // Base64 encoded XML file
-      });
+      const form = new FormData();
+      form.append('name', name);
+      form.append('file', file);
+      const response = await axios.post<{ job_id: number }>(`${API_URL}/libraries`, form);
+
+      for (;;) {
+        const job = await this.getImportJob(response.data.job_id);
+        onProgress?.(job);
+        if (job.state === 'completed' && job.library_id !== undefined) {
+          return { library_id: job.library_id };
+        }
+        if (job.state === 'failed') {
+          throw new Error(job.error || 'Library import failed');
+        }
+        await new Promise((resolve) => setTimeout(resolve, IMPORT_POLL_INTERVAL_MS));
+      }
+    } catch (error) {
+      throw error;
+    }
+  }
+
+  // Get the state and progress of a library import
+  async getImportJob(id: number): Promise<ImportJob> {
+    try {
+      const response = await axios.get<ImportJob>(`${API_URL}/imports/${id}`);
       return response.data;
     } catch (error) {
       throw error;
@@ -107,21 +211,21 @@ class LibraryService {
     }
   }
 
-  // Get all tracks in a playlist
-  async getPlaylistTracks(playlistId: number): Promise<Track[]> {
+  // Get a page of the tracks in a playlist, in playlist order unless sorted
+  async getPlaylistTracks(playlistId: number, params?: TrackListParams): Promise<TrackPage<PlaylistTrack>> {
     try {
-      const response = await axios.get<Track[]>(`${API_URL}/playlists/${playlistId}/tracks`);
-      return response.data;
+      const response = await axios.get<PlaylistTrack[]>(`${API_URL}/playlists/${playlistId}/tracks`, { params });
+      return toTrackPage(response);
     } catch (error) {
       throw error;
     }
   }
 
-  // Get all tracks in all playlists within a folder (recursively)
-  async getFolderTracks(folderId: number): Promise<Track[]> {
+  // Get a page of the tracks in all playlists within a folder (recursively)
+  async getFolderTracks(folderId: number, params?: TrackListParams): Promise<TrackPage> {
     try {
-      const response = await axios.get<Track[]>(`${API_URL}/folders/${folderId}/tracks`);
-      return response.data;
+      const response = await axios.get<Track[]>(`${API_URL}/folders/${folderId}/tracks`, { params });
+      return toTrackPage(response);
     } catch (error) {
       throw error;
     }
@@ -147,6 +251,25 @@ class LibraryService {
     }
   }
 
+  // Get the waveform overview of a track, waiting while it is generated in the background
+  async getTrackWaveform(trackId: number, resolution: number, signal?: AbortSignal): Promise<Waveform> {
+    try {
+      for (;;) {
+        const response = await axios.get<Waveform>(`${API_URL}/tracks/${trackId}/waveform`, {
+          params: { resolution },
+          signal,
+          validateStatus: (status) => status === 200 || status === 202,
+        });
+        if (response.status === 200) {
+          return response.data;
+        }
+        await new Promise((resolve) => setTimeout(resolve, WAVEFORM_POLL_INTERVAL_MS));
+      }
+    } catch (error) {
+      throw error;
+    }
+  }
+
   // Convert a File object to base64
   async fileToBase64(file: File): Promise<string> {
     return new Promise((resolve, reject) => {